@@ -5,8 +5,14 @@
 package happy
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/mkungla/happy/pkg/varflag"
 	"github.com/mkungla/happy/pkg/vars"
 	"github.com/mkungla/happy/pkg/version"
 	"golang.org/x/mod/semver"
@@ -18,15 +24,43 @@ type Addon struct {
 	errs []error
 
 	registerAction ActionWithOptions
+	healthAction   Action
+	installAction  Action
+	upgradeAction  ActionUpgrade
 	events         []Event
+	schemas        []EventSchema
+	listens        []string
 	acceptsOpts    []OptionArg
+	capabilities   []capability
+	assets         fs.FS
 
-	cmds []*Command
-	svcs []*Service
+	// disabled with app.addons.disabled option
+	disabled bool
+
+	deps []addonDependency
+	// api and app version constraints
+	requiresAPI version.Constraint
+	requiresApp version.Constraint
+
+	cmds  []*Command
+	svcs  []*Service
+	flags []varflag.Flag
+	// lazy commands created only when dispatched
+	lazyCmds []lazyCommand
 
 	API API
 }
 
+type lazyCommand struct {
+	name    string
+	factory func() *Command
+}
+
+type addonDependency struct {
+	name       string
+	constraint version.Constraint
+}
+
 type AddonInfo struct {
 	Name        string
 	Description string
@@ -43,7 +77,18 @@ func NewAddon(name string, opts ...OptionArg) *Addon {
 	addon.opts, err = NewOptions("config", getDefaultAddonConfig())
 	if err != nil {
 		addon.errs = append(addon.errs, err)
+		return addon
+	}
+	for _, opt := range opts {
+		if err := opt.apply(addon.opts); err != nil {
+			addon.errs = append(addon.errs, fmt.Errorf("%w: %s %s", ErrAddon, name, err.Error()))
+		}
+	}
+	if err := addon.opts.setDefaults(); err != nil {
+		addon.errs = append(addon.errs, err)
 	}
+	addon.info.Description = addon.opts.Get("description").String()
+	addon.info.Version = version.Version(addon.opts.Get("version").String())
 	return addon
 }
 
@@ -75,6 +120,31 @@ func (addon *Addon) OnRegister(action ActionWithOptions) {
 	addon.registerAction = action
 }
 
+// OnInstall sets action called before OnRegister action on first run
+// with the addon, e.g. to create data files of the addon. Version of
+// the addon is persisted in application state when app.fs.enabled is
+// set and install and upgrade actions are not called otherwise.
+func (addon *Addon) OnInstall(action Action) {
+	addon.installAction = action
+}
+
+// OnUpgrade sets action called before OnRegister action when addon
+// version differs from version used on previous run, e.g. to migrate
+// settings, caches or data files of the addon. New version is
+// persisted only when action succeeds, so failed upgrade is retried
+// on next run.
+func (addon *Addon) OnUpgrade(action ActionUpgrade) {
+	addon.upgradeAction = action
+}
+
+// OnHealthCheck sets action reporting health of the addon e.g. state
+// of connections it depends on. Returned error wrapping ErrAddonDegraded
+// reports addon as degraded, any other error as failed. Action is called
+// when health is queried and should return quickly.
+func (addon *Addon) OnHealthCheck(action Action) {
+	addon.healthAction = action
+}
+
 func (addon *Addon) Emits(scope, key, description string, example *vars.Map) {
 	addon.EmitsEvent(registerEvent(scope, key, description, example))
 }
@@ -83,6 +153,44 @@ func (addon *Addon) EmitsEvent(event Event) {
 	addon.events = append(addon.events, event)
 }
 
+// EmitsSchema registers event which addon emits, in development
+// mode payloads of dispatched events are validated against the schema.
+func (addon *Addon) EmitsSchema(schema EventSchema) {
+	addon.schemas = append(addon.schemas, schema)
+}
+
+// ListensTo declares event the addon listens to, scope and key can be
+// glob patterns as with Service.OnEvent. Events listened by services
+// provided by the addon do not have to be declared.
+func (addon *Addon) ListensTo(scope, key string) {
+	addon.listens = append(addon.listens, scope+"."+key)
+}
+
+// listenPatterns returns declared listener patterns and
+// patterns of services provided by the addon.
+func (addon *Addon) listenPatterns() []string {
+	seen := make(map[string]bool)
+	var patterns []string
+	add := func(lid string) {
+		if lid == "any" {
+			lid = "*.*"
+		}
+		if !seen[lid] {
+			seen[lid] = true
+			patterns = append(patterns, lid)
+		}
+	}
+	for _, lid := range addon.listens {
+		add(lid)
+	}
+	for _, svc := range addon.svcs {
+		for _, l := range svc.listeners {
+			add(l.lid)
+		}
+	}
+	return patterns
+}
+
 func (addon *Addon) Setting(key string, value any, description string, validator OptionValueValidator) {
 	addon.acceptsOpts = append(addon.acceptsOpts, OptionArg{
 		key:       key,
@@ -93,6 +201,145 @@ func (addon *Addon) Setting(key string, value any, description string, validator
 	})
 }
 
+// DependsOn declares that addon requires addon with given name
+// and version satisfying constraint e.g. ">=v1.2.0, <v2", empty
+// constraint accepts any version. Addons are registered after
+// addons they depend on.
+func (addon *Addon) DependsOn(name, constraint string) {
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s dependency %s %s", ErrAddon, addon.info.Name, name, err.Error()))
+		return
+	}
+	addon.deps = append(addon.deps, addonDependency{name: name, constraint: c})
+}
+
+// RequiresAPI declares addon API versions the addon is built for
+// e.g. "^v1.0.0", application fails to register addon when
+// APIVersion does not satisfy the constraint.
+func (addon *Addon) RequiresAPI(constraint string) {
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s api %s", ErrAddon, addon.info.Name, err.Error()))
+		return
+	}
+	addon.requiresAPI = c
+}
+
+// RequiresApp declares application versions the addon supports
+// e.g. ">=v1.2.0", constraint is checked against app.version
+// option and is not checked for development builds.
+func (addon *Addon) RequiresApp(constraint string) {
+	c, err := version.ParseConstraint(constraint)
+	if err != nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s app %s", ErrAddon, addon.info.Name, err.Error()))
+		return
+	}
+	addon.requiresApp = c
+}
+
+// checkCompatibility reports error when addon does not
+// support addon API or application version appver.
+func (addon *Addon) checkCompatibility(appver version.Version) error {
+	if !addon.requiresAPI.Check(APIVersion) {
+		return fmt.Errorf("%w: %s %s requires addon API %s, SDK provides %s",
+			ErrAddonIncompatible, addon.info.Name, addon.info.Version, addon.requiresAPI, APIVersion)
+	}
+	if !version.IsDev(appver.String()) && !addon.requiresApp.Check(appver) {
+		return fmt.Errorf("%w: %s %s requires application version %s, got %s",
+			ErrAddonIncompatible, addon.info.Name, addon.info.Version, addon.requiresApp, appver)
+	}
+	return nil
+}
+
+// Config declares read-only configuration option of the addon registered
+// as <addon>.<key> at configure time. Values set with
+// happy.Option("<addon>.<key>", v) must be of same kind as default
+// value, or string parseable to it, and pass validator when not nil.
+func (addon *Addon) Config(key string, value any, description string, validator OptionValueValidator) {
+	addon.acceptsOpts = append(addon.acceptsOpts, OptionArg{
+		key:       key,
+		value:     value,
+		desc:      description,
+		kind:      ReadOnlyOption | ConfigOption,
+		validator: optionKindValidator(value, validator),
+	})
+}
+
+// Options returns keys of options declared with Config
+// and Setting in namespace of the addon sorted by key.
+func (addon *Addon) Options() []string {
+	keys := make([]string, 0, len(addon.acceptsOpts))
+	for _, opt := range addon.acceptsOpts {
+		keys = append(keys, addon.info.Name+"."+opt.key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unknownOptionError returns error describing option key
+// which is not declared by the addon.
+func (addon *Addon) unknownOptionError(key string) error {
+	accepts := "none"
+	if keys := addon.Options(); len(keys) > 0 {
+		accepts = strings.Join(keys, ", ")
+	}
+	return fmt.Errorf("%w: unknown option %s, addon %s accepts: %s",
+		ErrOptionValidation, key, addon.info.Name, accepts)
+}
+
+// optionKindValidator returns validator rejecting values which are
+// not of same kind as default value before calling validator.
+func optionKindValidator(def any, validator OptionValueValidator) OptionValueValidator {
+	defval, err := vars.NewValue(def)
+	if err != nil {
+		return validator
+	}
+	_, isDuration := def.(time.Duration)
+	return func(key string, val vars.Value) error {
+		if val.Kind() != defval.Kind() {
+			var err error
+			if isDuration && val.Kind() == vars.KindString {
+				_, err = time.ParseDuration(val.String())
+			} else {
+				_, err = vars.ParseValueAs(val.String(), defval.Kind())
+			}
+			if err != nil {
+				return fmt.Errorf("%w: %s must be %s got %s(%s)",
+					ErrOptionValidation, key, defval.Kind(), val.Kind(), val.String())
+			}
+		}
+		if validator != nil {
+			return validator(key, val)
+		}
+		return nil
+	}
+}
+
+// ProvidesCapability publishes implementation of capability e.g. "storage"
+// or "auth" which other addons and the application resolve with
+// happy.Capability. Capability is published before OnRegister action of
+// the addon is called, addons using it should declare dependency with
+// DependsOn so that they are registered after the provider.
+func (addon *Addon) ProvidesCapability(name string, impl any) {
+	if impl == nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s provided <nil> capability %s", ErrAddon, addon.info.Name, name))
+		return
+	}
+	addon.capabilities = append(addon.capabilities, capability{
+		name:     name,
+		provider: addon.info.Name,
+		impl:     impl,
+	})
+}
+
+// ProvidesAssets sets file system e.g. embed.FS of templates and
+// static files shipped with the addon, it is mounted to application
+// assets under addons/<addon> directory, see Session.Assets.
+func (addon *Addon) ProvidesAssets(fsys fs.FS) {
+	addon.assets = fsys
+}
+
 func (addon *Addon) ProvidesCommand(cmd *Command) {
 	if cmd == nil {
 		addon.errs = append(addon.errs, fmt.Errorf("%w: %s provided <nil> command", ErrAddon, addon.info.Name))
@@ -101,10 +348,211 @@ func (addon *Addon) ProvidesCommand(cmd *Command) {
 	addon.cmds = append(addon.cmds, cmd)
 }
 
+// ProvidesLazyCommand adds command name created by factory only when it is
+// dispatched or full command tree is needed, see Application.AddLazyCommand.
+func (addon *Addon) ProvidesLazyCommand(name string, factory func() *Command) {
+	if factory == nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s provided <nil> factory for command %s", ErrAddon, addon.info.Name, name))
+		return
+	}
+	addon.lazyCmds = append(addon.lazyCmds, lazyCommand{name: name, factory: factory})
+}
+
+// lazyCommandFactory wraps factory so that created command is owned
+// by the addon like commands added with ProvidesCommand.
+func (addon *Addon) lazyCommandFactory(factory func() *Command) func() *Command {
+	return func() *Command {
+		cmd := factory()
+		if cmd == nil {
+			return nil
+		}
+		cmd.setAddon(addon.info.Name)
+		if addon.disabled {
+			cmd.hidden = true
+		}
+		addon.cmds = append(addon.cmds, cmd)
+		return cmd
+	}
+}
+
+// ProvidesFlag adds global flag to the root command of the application,
+// addon keeps reference to the flag to read its value once the
+// application is configured. Flag which name or alias is already used
+// by the application or another addon fails application configuration,
+// prefix flags with addon name e.g. --<addon>-<flag> to avoid conflicts.
+func (addon *Addon) ProvidesFlag(f varflag.Flag) {
+	if f == nil {
+		addon.errs = append(addon.errs, fmt.Errorf("%w: %s provided <nil> flag", ErrAddon, addon.info.Name))
+		return
+	}
+	addon.flags = append(addon.flags, f)
+}
+
 func (addon *Addon) ProvidesService(svc *Service) {
 	if svc == nil {
 		addon.errs = append(addon.errs, fmt.Errorf("%w: %s provided <nil> service", ErrAddon, addon.info.Name))
 		return
 	}
+	svc.addon = addon.info.Name
 	addon.svcs = append(addon.svcs, svc)
 }
+
+// providesCommand reports whether cmd is command
+// provided by the addon or one of its subcommands.
+func (addon *Addon) providesCommand(cmd *Command) bool {
+	var contains func(c *Command) bool
+	contains = func(c *Command) bool {
+		if c == cmd {
+			return true
+		}
+		for _, sub := range c.subCommands {
+			if contains(sub) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, c := range addon.cmds {
+		if contains(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAddons returns addons ordered so that each addon follows
+// addons it depends on, declaration order is kept otherwise. It returns
+// error when dependency is missing, its version does not satisfy the
+// constraint or dependencies are cyclic.
+func resolveAddons(addons []*Addon) ([]*Addon, error) {
+	byName := make(map[string]*Addon, len(addons))
+	var errs []error
+	for _, addon := range addons {
+		if _, ok := byName[addon.info.Name]; ok {
+			errs = append(errs, fmt.Errorf("%w: addon %s loaded more than once", ErrAddon, addon.info.Name))
+			continue
+		}
+		byName[addon.info.Name] = addon
+	}
+	for _, addon := range addons {
+		for _, dep := range addon.deps {
+			d, ok := byName[dep.name]
+			if !ok {
+				errs = append(errs, fmt.Errorf("%w: %s requires addon %s which is not loaded", ErrAddon, addon.info.Name, dep.name))
+				continue
+			}
+			if !dep.constraint.Check(d.info.Version) {
+				errs = append(errs, fmt.Errorf("%w: %s requires addon %s %s, got %s",
+					ErrAddon, addon.info.Name, dep.name, dep.constraint, d.info.Version))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	var (
+		state  = make(map[string]int, len(addons))
+		sorted = make([]*Addon, 0, len(addons))
+		path   []string
+		visit  func(addon *Addon) error
+	)
+	visit = func(addon *Addon) error {
+		switch state[addon.info.Name] {
+		case visited:
+			return nil
+		case visiting:
+			cycle := append(path, addon.info.Name)
+			for i, name := range cycle {
+				if name == addon.info.Name {
+					cycle = cycle[i:]
+					break
+				}
+			}
+			return fmt.Errorf("%w: dependency cycle %s", ErrAddon, strings.Join(cycle, " -> "))
+		}
+		state[addon.info.Name] = visiting
+		path = append(path, addon.info.Name)
+		for _, dep := range addon.deps {
+			if err := visit(byName[dep.name]); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[addon.info.Name] = visited
+		sorted = append(sorted, addon)
+		return nil
+	}
+	for _, addon := range addons {
+		if err := visit(addon); err != nil {
+			return nil, err
+		}
+	}
+	return sorted, nil
+}
+
+// AddonStatus is health status of the addon.
+type AddonStatus string
+
+const (
+	AddonHealthy  AddonStatus = "healthy"
+	AddonDegraded AddonStatus = "degraded"
+	AddonFailed   AddonStatus = "failed"
+	AddonDisabled AddonStatus = "disabled"
+)
+
+// AddonHealth describes health of registered addon, see Session.AddonHealth.
+type AddonHealth struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Status  AddonStatus `json:"status"`
+	Err     string      `json:"err,omitempty"`
+}
+
+// addonHealthInfo is registered addon which health can be checked.
+type addonHealthInfo struct {
+	name     string
+	version  string
+	svcs     []string
+	check    Action
+	disabled bool
+}
+
+// health reports addon failed when any of its services failed
+// or health check returns error.
+func (info *addonHealthInfo) health(sess *Session) AddonHealth {
+	h := AddonHealth{
+		Name:    info.name,
+		Version: info.version,
+		Status:  AddonHealthy,
+	}
+	if info.disabled {
+		h.Status = AddonDisabled
+		return h
+	}
+	for _, svc := range info.svcs {
+		for _, sinfo := range sess.serviceInfos() {
+			if sinfo.Name() != svc || !sinfo.Failed() {
+				continue
+			}
+			h.Status = AddonFailed
+			h.Err = fmt.Sprintf("service %s failed", svc)
+			return h
+		}
+	}
+	if info.check == nil {
+		return h
+	}
+	if err := info.check(sess); err != nil {
+		h.Status = AddonFailed
+		if errors.Is(err, ErrAddonDegraded) {
+			h.Status = AddonDegraded
+		}
+		h.Err = err.Error()
+	}
+	return h
+}
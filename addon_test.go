@@ -0,0 +1,409 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/hlog/hlogtest"
+	"github.com/mkungla/happy/pkg/varflag"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/pkg/version"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newTestConfigAddon() *Addon {
+	addon := NewAddon("cache")
+	addon.Config("ttl", time.Minute, "ttl of cached entries", nil)
+	addon.Config("size", 100, "max number of cached entries", func(key string, val vars.Value) error {
+		if v, _ := val.Int(); v <= 0 {
+			return fmt.Errorf("%w: %s must be positive", ErrOptionValidation, key)
+		}
+		return nil
+	})
+	return addon
+}
+
+func TestAddonConfig(t *testing.T) {
+	app := New(Option("cache.size", "50"), Option("cache.ttl", "5s"))
+	app.activeCmd = app.rootCmd
+	addon := newTestConfigAddon()
+	var opts *Options
+	addon.OnRegister(func(sess *Session, o *Options) error {
+		opts = o
+		return nil
+	})
+	app.WithAddons(addon)
+	testutils.NoError(t, app.registerAddons())
+	testutils.Equal(t, "50", opts.Get("size").String())
+	testutils.Equal(t, "5s", opts.Get("ttl").String())
+	testutils.Equal(t, "cache.size,cache.ttl", strings.Join(addon.Options(), ","))
+}
+
+func TestAddonConfigInvalid(t *testing.T) {
+	tests := []struct {
+		key   string
+		value any
+		want  string
+	}{
+		{"cache.size", "many", "cache.size must be int"},
+		{"cache.size", 0, "cache.size must be positive"},
+		{"cache.ttl", "soon", "cache.ttl must be int64"},
+		{"cache.tll", "5s", "unknown option cache.tll, addon cache accepts: cache.size, cache.ttl"},
+	}
+	for _, tt := range tests {
+		app := New(Option(tt.key, tt.value))
+		app.activeCmd = app.rootCmd
+		app.WithAddons(newTestConfigAddon())
+		err := app.registerAddons()
+		testutils.ErrorIs(t, err, ErrOptionValidation)
+		if err != nil {
+			testutils.True(t, strings.Contains(err.Error(), tt.want), err.Error())
+		}
+	}
+}
+
+func TestAddonDependencies(t *testing.T) {
+	db := NewAddon("db", Option("version", "v1.4.0"))
+	cache := NewAddon("cache", Option("version", "v0.2.0"))
+	cache.DependsOn("db", "^v1.2")
+	web := NewAddon("web")
+	web.DependsOn("cache", "")
+	web.DependsOn("db", ">=1.0.0, <2")
+
+	app := New()
+	app.activeCmd = app.rootCmd
+	app.WithAddons(web, cache, db)
+	testutils.NoError(t, app.registerAddons())
+	var names []string
+	for _, addon := range app.addons {
+		names = append(names, addon.info.Name)
+	}
+	testutils.Equal(t, "db,cache,web", strings.Join(names, ","))
+}
+
+func TestAddonDependenciesInvalid(t *testing.T) {
+	newAddon := func(name, v string, deps ...string) *Addon {
+		addon := NewAddon(name, Option("version", v))
+		for _, dep := range deps {
+			n, c, _ := strings.Cut(dep, " ")
+			addon.DependsOn(n, c)
+		}
+		return addon
+	}
+	tests := []struct {
+		name   string
+		addons []*Addon
+		want   string
+	}{
+		{"missing", []*Addon{newAddon("web", "v1.0.0", "db")}, "web requires addon db which is not loaded"},
+		{"version", []*Addon{
+			newAddon("web", "v1.0.0", "db ~v1.2.0"),
+			newAddon("db", "v1.3.0"),
+		}, "web requires addon db ~v1.2.0, got v1.3.0"},
+		{"cycle", []*Addon{
+			newAddon("web", "v1.0.0", "cache"),
+			newAddon("cache", "v1.0.0", "db"),
+			newAddon("db", "v1.0.0", "cache"),
+		}, "dependency cycle cache -> db -> cache"},
+		{"duplicate", []*Addon{newAddon("db", "v1.0.0"), newAddon("db", "v1.0.0")}, "addon db loaded more than once"},
+		{"constraint", []*Addon{newAddon("web", "v1.0.0", "db >=x")}, "invalid version constraint"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New()
+			app.activeCmd = app.rootCmd
+			app.WithAddons(tt.addons...)
+			err := app.registerAddons()
+			testutils.ErrorIs(t, err, ErrAddon)
+			if err != nil {
+				testutils.True(t, strings.Contains(err.Error(), tt.want), err.Error())
+			}
+		})
+	}
+}
+
+func TestAddonCompatibility(t *testing.T) {
+	tests := []struct {
+		name   string
+		app    string
+		api    string
+		appreq string
+		want   string
+	}{
+		{"compatible", "v1.3.0", "^v1.0.0", ">=v1.2.0", ""},
+		{"api", "v1.3.0", "^v2.0.0", "", "requires addon API ^v2.0.0, SDK provides " + APIVersion},
+		{"app", "v1.1.0", "", ">=v1.2.0", "requires application version >=v1.2.0, got v1.1.0"},
+		{"dev", "v1.0.0-0xDEV", "", ">=v1.2.0", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(Option("app.version", tt.app))
+			app.activeCmd = app.rootCmd
+			addon := NewAddon("cache", Option("version", "v0.1.0"))
+			if tt.api != "" {
+				addon.RequiresAPI(tt.api)
+			}
+			if tt.appreq != "" {
+				addon.RequiresApp(tt.appreq)
+			}
+			app.WithAddons(addon)
+			err := app.registerAddons()
+			if tt.want == "" {
+				testutils.NoError(t, err)
+				return
+			}
+			testutils.ErrorIs(t, err, ErrAddonIncompatible)
+			if err != nil {
+				testutils.True(t, strings.Contains(err.Error(), tt.want), err.Error())
+			}
+		})
+	}
+}
+
+func TestAddonDisabled(t *testing.T) {
+	app := New(Option("app.addons.disabled", "billing"))
+	app.activeCmd = app.rootCmd
+
+	registered := false
+	billing := NewAddon("billing")
+	billing.OnRegister(func(sess *Session, opts *Options) error {
+		registered = true
+		return nil
+	})
+	invoices := NewCommand("invoices")
+	invoices.Do(func(sess *Session, args Args) error { return nil })
+	billing.ProvidesCommand(invoices)
+	billing.ProvidesService(NewService("billing-sync"))
+	app.WithAddons(billing, NewAddon("cache"))
+	testutils.NoError(t, app.registerAddonCommands())
+
+	app.disableAddons()
+	testutils.True(t, invoices.Hidden(), "command of disabled addon should be hidden")
+	testutils.True(t, billing.providesCommand(invoices), "addon should provide invoices command")
+
+	testutils.NoError(t, app.registerAddons())
+	testutils.False(t, registered, "disabled addon should not be registered")
+	testutils.False(t, app.session.AddonEnabled("billing"), "billing should be disabled")
+	testutils.True(t, app.session.AddonEnabled("cache"), "cache should be enabled")
+	for _, h := range app.session.AddonHealth() {
+		if h.Name == "billing" {
+			testutils.Equal(t, AddonDisabled, h.Status)
+		}
+	}
+	_, err := app.session.ServiceInfo("billing-sync")
+	testutils.Error(t, err)
+}
+
+func TestAddonDisabledDependency(t *testing.T) {
+	app := New(Option("app.addons.disabled", "db"))
+	app.activeCmd = app.rootCmd
+	web := NewAddon("web")
+	web.DependsOn("db", "")
+	app.WithAddons(web, NewAddon("db"))
+	app.disableAddons()
+	err := app.registerAddons()
+	testutils.ErrorIs(t, err, ErrAddon)
+	if err != nil {
+		testutils.True(t, strings.Contains(err.Error(), "web requires addon db which is disabled"), err.Error())
+	}
+}
+
+type testStorage interface {
+	Load(key string) string
+}
+
+type memStorage map[string]string
+
+func (m memStorage) Load(key string) string { return m[key] }
+
+func TestAddonCapability(t *testing.T) {
+	app := New()
+	app.activeCmd = app.rootCmd
+
+	storage := NewAddon("storage")
+	storage.ProvidesCapability("storage", memStorage{"greeting": "hello"})
+	var loaded string
+	web := NewAddon("web")
+	web.DependsOn("storage", "")
+	web.OnRegister(func(sess *Session, opts *Options) error {
+		store, err := Capability[testStorage](sess, "storage")
+		if err != nil {
+			return err
+		}
+		loaded = store.Load("greeting")
+		return nil
+	})
+	app.WithAddons(web, storage)
+	testutils.NoError(t, app.registerAddons())
+	testutils.Equal(t, "hello", loaded)
+
+	caps := app.session.Capabilities()
+	testutils.Equal(t, 1, len(caps))
+	testutils.Equal(t, "storage", caps[0].Provider)
+	testutils.Equal(t, "happy.memStorage", caps[0].Type)
+
+	_, err := Capability[testStorage](app.session, "auth")
+	testutils.ErrorIs(t, err, ErrAddon)
+	_, err = Capability[fmt.Stringer](app.session, "storage")
+	testutils.ErrorIs(t, err, ErrAddon)
+	if err != nil {
+		testutils.True(t, strings.Contains(err.Error(), "is happy.memStorage, not fmt.Stringer"), err.Error())
+	}
+}
+
+func TestAddonCapabilityConflict(t *testing.T) {
+	app := New()
+	app.activeCmd = app.rootCmd
+	s3 := NewAddon("s3")
+	s3.ProvidesCapability("storage", memStorage{})
+	gcs := NewAddon("gcs")
+	gcs.ProvidesCapability("storage", memStorage{})
+	app.WithAddons(s3, gcs)
+	err := app.registerAddons()
+	testutils.ErrorIs(t, err, ErrAddon)
+	if err != nil {
+		testutils.True(t, strings.Contains(err.Error(), "gcs provides capability storage already provided by s3"), err.Error())
+	}
+}
+
+func TestAddonInstallUpgrade(t *testing.T) {
+	var calls []string
+	newCache := func(v string) *Addon {
+		addon := NewAddon("cache", Option("version", v))
+		addon.OnInstall(func(sess *Session) error {
+			calls = append(calls, "install "+v)
+			return nil
+		})
+		addon.OnUpgrade(func(sess *Session, from, to Version) error {
+			if to == "v2.0.0" {
+				return errors.New("disk full")
+			}
+			calls = append(calls, fmt.Sprintf("upgrade %s %s", from, to))
+			return nil
+		})
+		return addon
+	}
+	run := func(state *persistentState, addon *Addon) (*Application, error) {
+		app := New(Option("app.fs.enabled", true))
+		app.activeCmd = app.rootCmd
+		app.state = state
+		app.WithAddons(addon)
+		return app, app.registerAddons()
+	}
+
+	app, err := run(nil, newCache("v1.0.0"))
+	testutils.NoError(t, err)
+	testutils.True(t, app.addonsChanged, "installed addon should be persisted")
+	state := &persistentState{Addons: app.addonVersions}
+
+	app, err = run(state, newCache("v1.0.0"))
+	testutils.NoError(t, err)
+	testutils.False(t, app.addonsChanged, "unchanged addon should not be persisted")
+
+	app, err = run(state, newCache("v1.1.0"))
+	testutils.NoError(t, err)
+	testutils.Equal(t, version.Version("v1.1.0"), app.addonVersions["cache"])
+	state = &persistentState{Addons: app.addonVersions}
+
+	app, err = run(state, newCache("v2.0.0"))
+	testutils.ErrorIs(t, err, ErrAddon)
+	testutils.Equal(t, version.Version("v1.1.0"), app.addonVersions["cache"])
+
+	testutils.Equal(t, "install v1.0.0,upgrade v1.0.0 v1.1.0", strings.Join(calls, ","))
+}
+
+func TestAddonOptionSandbox(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.activeCmd = app.rootCmd
+	rec := hlogtest.NewRecorder(t)
+	app.AddLogHandler(rec.Handler())
+
+	addon := NewAddon("cache")
+	addon.Setting("ttl", 10, "ttl in seconds", nil)
+	cmd := NewCommand("warm")
+	var setErrs []error
+	cmd.Do(func(sess *Session, args Args) error {
+		setErrs = append(setErrs, sess.Set("cache.ttl", 20), sess.Set("app.throttle.ticks", 0))
+		return nil
+	})
+	addon.ProvidesCommand(cmd)
+	svc := NewService("cache-sync")
+	addon.ProvidesService(svc)
+	app.WithAddons(addon)
+	testutils.NoError(t, app.registerAddonCommands())
+	testutils.NoError(t, app.registerAddons())
+
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "warm"}))
+	testutils.NoError(t, cmd.callDoAction(app.session))
+	testutils.Equal(t, 2, len(setErrs))
+	testutils.NoError(t, setErrs[0])
+	testutils.ErrorIs(t, setErrs[1], ErrOptionSandbox)
+	testutils.Equal(t, 20, app.session.Get("cache.ttl").Int())
+	rec.AssertLogged(hlog.LevelWarn, "option write rejected", "addon", "cache", "key", "app.throttle.ticks")
+
+	// application session is not restricted
+	testutils.NoError(t, app.session.Set("app.throttle.ticks", 0))
+	testutils.Equal(t, "cache", svc.addon)
+}
+
+func TestAddonFlags(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.activeCmd = app.rootCmd
+
+	addon := NewAddon("cache")
+	noCache, err := varflag.Bool("cache-disable", false, "disable cache")
+	testutils.NoError(t, err)
+	addon.ProvidesFlag(noCache)
+	app.WithAddons(addon)
+	testutils.NoError(t, app.registerAddonCommands())
+
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "--cache-disable"}))
+	testutils.True(t, noCache.Present())
+	testutils.True(t, app.rootCmd.flag("cache-disable").Var().Bool())
+}
+
+func TestAddonFlagsConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		flag   string
+		alias  string
+		second bool
+	}{
+		{"application-flag", "debug", "", false},
+		{"application-alias", "cache-verbose", "v", false},
+		{"other-addon", "cache-disable", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := New(Option("log.console", false))
+			app.activeCmd = app.rootCmd
+
+			var aliases []string
+			if tt.alias != "" {
+				aliases = append(aliases, tt.alias)
+			}
+			f, err := varflag.Bool(tt.flag, false, "", aliases...)
+			testutils.NoError(t, err)
+			addon := NewAddon("cache")
+			addon.ProvidesFlag(f)
+			app.WithAddons(addon)
+			if tt.second {
+				f2, err := varflag.Bool(tt.flag, false, "")
+				testutils.NoError(t, err)
+				other := NewAddon("store")
+				other.ProvidesFlag(f2)
+				app.WithAddons(other)
+			}
+			err = app.registerAddonCommands()
+			testutils.ErrorIs(t, err, ErrAddon)
+		})
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+// AlertRule is condition evaluated by the monitor every
+// app.monitor.alerts.interval, see Monitor.AddAlertRule. Alert fires
+// when Check reports condition for at least For and monitor.alert event
+// with status "firing" is dispatched, event with status "resolved" is
+// dispatched once condition clears.
+type AlertRule struct {
+	Name string
+	For  time.Duration
+	// Check returns message describing the condition
+	// and whether condition is met.
+	Check func(sess *Session) (msg string, firing bool)
+}
+
+// Alert is alert which condition is met, see Monitor.Alerts.
+type Alert struct {
+	Name    string `json:"name"`
+	Message string `json:"message"`
+	// Since is when condition was first met and Firing whether it
+	// has been met longer than For of the rule.
+	Since  time.Time `json:"since"`
+	Firing bool      `json:"firing"`
+}
+
+// alertState is state of single alert rule.
+type alertState struct {
+	rule  AlertRule
+	alert Alert
+}
+
+// ServiceUnhealthyAlert returns rule firing when health check of service
+// with given name or address fails for d, empty service matches any
+// service, see Session.Health.
+func ServiceUnhealthyAlert(service string, d time.Duration) AlertRule {
+	name := "service.unhealthy"
+	if service != "" {
+		name += "." + service
+	}
+	return AlertRule{
+		Name: name,
+		For:  d,
+		Check: func(sess *Session) (string, bool) {
+			var failing []string
+			for _, sh := range sess.Health().Services {
+				if sh.Status == HealthOK {
+					continue
+				}
+				if service == "" || sh.Service == service || strings.HasSuffix(sh.Service, "/service/"+service) {
+					failing = append(failing, fmt.Sprintf("%s: %s", sh.Service, sh.Err))
+				}
+			}
+			return strings.Join(failing, ", "), len(failing) > 0
+		},
+	}
+}
+
+// GoroutinesAlert returns rule firing when number of goroutines
+// exceeds max for d.
+func GoroutinesAlert(max int, d time.Duration) AlertRule {
+	return AlertRule{
+		Name: "runtime.goroutines",
+		For:  d,
+		Check: func(sess *Session) (string, bool) {
+			n := runtime.NumGoroutine()
+			return fmt.Sprintf("%d goroutines exceeds %d", n, max), n > max
+		},
+	}
+}
+
+// EventQueueSaturatedAlert returns rule firing when session event queue
+// is filled at least to given ratio e.g. 0.9 of its capacity for d.
+func EventQueueSaturatedAlert(ratio float64, d time.Duration) AlertRule {
+	return AlertRule{
+		Name: "events.queue.saturated",
+		For:  d,
+		Check: func(sess *Session) (string, bool) {
+			stats := sess.EventStats()
+			if stats.QueueCapacity == 0 {
+				return "", false
+			}
+			return fmt.Sprintf("event queue %d/%d", stats.QueueDepth, stats.QueueCapacity),
+				float64(stats.QueueDepth) >= ratio*float64(stats.QueueCapacity)
+		},
+	}
+}
+
+// AddAlertRule adds alert rule evaluated by the monitor, rule with the
+// same name replaces previous rule.
+func (m *Monitor) AddAlertRule(rule AlertRule) {
+	if m == nil || rule.Check == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.alerts[rule.Name] = &alertState{rule: rule}
+}
+
+// Alerts returns alerts which condition is met sorted by name,
+// including pending alerts which are not firing yet.
+func (m *Monitor) Alerts() []Alert {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var alerts []Alert
+	for _, st := range m.alerts {
+		if !st.alert.Since.IsZero() {
+			alerts = append(alerts, st.alert)
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		return alerts[i].Name < alerts[j].Name
+	})
+	return alerts
+}
+
+// evaluateAlerts checks alert rules and returns monitor.alert events
+// of alerts which started firing or were resolved.
+func (m *Monitor) evaluateAlerts(sess *Session, now time.Time) []Event {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	states := make([]*alertState, 0, len(m.alerts))
+	for _, st := range m.alerts {
+		states = append(states, st)
+	}
+	m.mu.RUnlock()
+	sort.Slice(states, func(i, j int) bool {
+		return states[i].rule.Name < states[j].rule.Name
+	})
+
+	var events []Event
+	for _, st := range states {
+		// checks are called without holding the lock
+		// since they may record metrics.
+		msg, firing := st.rule.Check(sess)
+		m.mu.Lock()
+		switch {
+		case firing:
+			if st.alert.Since.IsZero() {
+				st.alert = Alert{Name: st.rule.Name, Since: now}
+			}
+			st.alert.Message = msg
+			if !st.alert.Firing && now.Sub(st.alert.Since) >= st.rule.For {
+				st.alert.Firing = true
+				events = append(events, alertEvent("firing", st.alert))
+			}
+		case st.alert.Firing:
+			st.alert.Message = msg
+			events = append(events, alertEvent("resolved", st.alert))
+			st.alert = Alert{}
+		default:
+			st.alert = Alert{}
+		}
+		m.mu.Unlock()
+	}
+	return events
+}
+
+// runAlerts evaluates alert rules every interval until ctx is done.
+// Alert events are not waited to be queued since alerts often fire
+// when event queue is saturated, event is dropped when queue is full
+// unless monitor scope has coalesce overflow policy. Current alerts
+// remain available from Monitor.Alerts.
+func (m *Monitor) runAlerts(ctx context.Context, sess *Session, interval time.Duration) {
+	if m == nil || interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, ev := range m.evaluateAlerts(sess, now) {
+				sess.dispatchNoWait(ev)
+			}
+		}
+	}
+}
+
+func alertEvent(status string, alert Alert) Event {
+	payload := new(vars.Map)
+	payload.Store("name", alert.Name)
+	payload.Store("status", status)
+	payload.Store("message", alert.Message)
+	payload.Store("since", alert.Since.UTC().Format(time.RFC3339))
+	return NewEvent("monitor", "alert", payload, nil)
+}
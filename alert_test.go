@@ -0,0 +1,133 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestMonitorAlerts(t *testing.T) {
+	var disabled *Monitor
+	disabled.AddAlertRule(GoroutinesAlert(1, 0))
+	testutils.Equal(t, 0, len(disabled.Alerts()))
+
+	sess := newTestSession(t)
+	m := newMonitor()
+	firing := false
+	m.AddAlertRule(AlertRule{
+		Name: "disk.full",
+		For:  30 * time.Second,
+		Check: func(sess *Session) (string, bool) {
+			return "disk 95% full", firing
+		},
+	})
+
+	now := time.Now()
+	testutils.Equal(t, 0, len(m.evaluateAlerts(sess, now)))
+	testutils.Equal(t, 0, len(m.Alerts()))
+
+	firing = true
+	testutils.Equal(t, 0, len(m.evaluateAlerts(sess, now)), "alert should be pending until For elapsed")
+	alerts := m.Alerts()
+	testutils.Equal(t, 1, len(alerts))
+	testutils.False(t, alerts[0].Firing)
+
+	events := m.evaluateAlerts(sess, now.Add(30*time.Second))
+	testutils.Equal(t, 1, len(events))
+	testutils.Equal(t, "monitor", events[0].Scope())
+	testutils.Equal(t, "alert", events[0].Key())
+	testutils.Equal(t, "disk.full", events[0].Payload().Get("name").String())
+	testutils.Equal(t, "firing", events[0].Payload().Get("status").String())
+	testutils.Equal(t, "disk 95% full", events[0].Payload().Get("message").String())
+	testutils.True(t, m.Alerts()[0].Firing)
+	testutils.Equal(t, 0, len(m.evaluateAlerts(sess, now.Add(time.Minute))), "firing alert should be dispatched once")
+
+	firing = false
+	events = m.evaluateAlerts(sess, now.Add(2*time.Minute))
+	testutils.Equal(t, 1, len(events))
+	testutils.Equal(t, "resolved", events[0].Payload().Get("status").String())
+	testutils.Equal(t, 0, len(m.Alerts()))
+}
+
+func TestMonitorRunAlertsQueueFull(t *testing.T) {
+	sess := newTestSession(t)
+	sess.evch = make(chan Event, 1)
+	sess.evch <- NewEvent("app", "queued", nil, nil)
+
+	m := newMonitor()
+	m.AddAlertRule(AlertRule{
+		Name: "queue.full",
+		Check: func(sess *Session) (string, bool) {
+			return "event queue is full", true
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		m.runAlerts(ctx, sess, time.Millisecond)
+		close(done)
+	}()
+	deadline := time.After(time.Second)
+	for sess.evstats.dropped.Load() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("alert event should be dropped when event queue is full")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("runAlerts should not block on full event queue")
+	}
+	alerts := m.Alerts()
+	testutils.Equal(t, 1, len(alerts))
+	testutils.True(t, alerts[0].Firing)
+}
+
+func TestAlertRules(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+
+	msg, firing := GoroutinesAlert(0, 0).Check(sess)
+	testutils.True(t, firing, msg)
+	_, firing = GoroutinesAlert(1<<20, 0).Check(sess)
+	testutils.False(t, firing)
+
+	_, firing = EventQueueSaturatedAlert(0.9, 0).Check(sess)
+	testutils.False(t, firing)
+	for i := 0; i < cap(sess.evch); i++ {
+		sess.Dispatch(NewEvent("test", "fill", nil, nil))
+	}
+	msg, firing = EventQueueSaturatedAlert(0.9, 0).Check(sess)
+	testutils.True(t, firing, msg)
+
+	var dbErr error
+	db := NewService("db")
+	db.OnHealthCheck(func(sess *Session) error { return dbErr })
+	addr, err := address.Parse("happy://host/app/service/db")
+	testutils.NoError(t, err)
+	svcc := db.container(sess, addr)
+	sess.setServiceInfo(&svcc.info)
+	svcc.info.started()
+
+	rule := ServiceUnhealthyAlert("db", 30*time.Second)
+	testutils.Equal(t, "service.unhealthy.db", rule.Name)
+	_, firing = rule.Check(sess)
+	testutils.False(t, firing)
+	dbErr = errors.New("replica lag")
+	msg, firing = rule.Check(sess)
+	testutils.True(t, firing)
+	testutils.Equal(t, "happy://host/app/service/db: replica lag", msg)
+	_, firing = ServiceUnhealthyAlert("cache", 0).Check(sess)
+	testutils.False(t, firing)
+}
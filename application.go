@@ -5,15 +5,22 @@
 package happy
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/mkungla/happy/pkg/address"
 	"github.com/mkungla/happy/pkg/hlog"
 	"github.com/mkungla/happy/pkg/varflag"
 	"github.com/mkungla/happy/pkg/vars"
@@ -35,6 +42,12 @@ type Application struct {
 	tockAction ActionTock
 
 	installAction Action
+	// firstRunAction is called once when session is ready on first run
+	firstRunAction Action
+	// instanceIDCreated is set when instance id is not persisted yet
+	instanceIDCreated bool
+	// banner is rendered before output of the command
+	banner func(sess *Session) string
 
 	// pendingOpts contains options
 	// which are not yet applied.
@@ -48,18 +61,79 @@ type Application struct {
 	logger *hlog.Logger
 	lvl    *slog.LevelVar
 
+	// exited is set when application exited, exitCode and exitErr
+	// are returned from Run
+	exited   bool
+	exitCode int
+	exitErr  error
+	// args are command line arguments, os.Args unless set by Run
+	args []string
+	// runCtx is context passed to Run
+	runCtx context.Context
+	// deadlineExceeded is closed when app.deadline is exceeded
+	deadlineExceeded chan struct{}
+
 	// exit handler
-	exitOs     bool
-	exitFunc   []func(code int) error
-	exitCh     chan struct{}
-	errs       []error
-	isDev      bool
-	profile    string
+	exitOs   bool
+	exitFunc []exitFunc
+	exitCh   chan struct{}
+	errs     []error
+	isDev    bool
+	profile  string
+	// option overrides of named profiles registered with Profile
+	profiles   map[string][]OptionArg
 	migrations map[string]migration
 
 	firstuse     bool
 	state        *persistentState
 	setupNextRun bool
+	// firstRunPending is set until first run action completes,
+	// firstRunDone is set when it completed on this run
+	firstRunPending bool
+	firstRunDone    bool
+	// versions of addons persisted in state, addonsChanged
+	// is set when addon was installed or upgraded on this run
+	addonVersions map[string]version.Version
+	addonsChanged bool
+	// saves user.* settings on change
+	userSettings *userSettings
+
+	helpCmdTmpl string
+	rawArgs     []string
+
+	// log file when log.file is set
+	logFile     *hlog.FileWriter
+	logFileStop func()
+	// remote log sink when log.remote is set
+	logRemote *hlog.NetWriter
+	// OTLP log exporter when log.otlp.endpoint is set
+	logOTLP *hlog.OTLPHandler
+	// stops SIGUSR1/SIGUSR2 log level handling
+	logSignalStop func()
+	// stops SIGHUP config reload handling
+	reloadSignalStop func()
+	// signalStop stops handling of shutdown signals and signals
+	// registered with OnSignal
+	signalStop      func()
+	signalHandlers  map[os.Signal][]SignalHandler
+	shutdownSignals []os.Signal
+	// redacts sensitive values before records reach any log sink
+	redactor *hlog.Redactor
+	// deduplicates and samples records when log.dedup or log.sample.debug is set
+	logSampler *hlog.Sampler
+	// background log writer when log.async is set
+	logAsync *hlog.AsyncHandler
+	// additional log sinks added with AddLogHandler
+	logHandlers []slog.Handler
+	// translates logged message keys when catalog is set with SetLogCatalog
+	logTranslator *hlog.Translator
+	// extCmd is set when unknown subcommand resolved to <app>-<cmd> on PATH
+	extCmd *externalCommand
+	// instance lock when app.instance.single is set
+	instance *instanceLock
+	// recent log records for crash report when app.crash.report is set
+	crashLogs *hlog.Ring
+	crashOnce sync.Once
 }
 
 // New returns new happy application instance.
@@ -70,8 +144,12 @@ func New(opts ...OptionArg) *Application {
 		initialized: time.Now(),
 		exitOs:      true,
 		lvl:         &slog.LevelVar{},
+		args:        os.Args,
 	}
 	err := a.configureApplication(opts)
+	a.session.engine = a.engine
+	a.session.color = colorEnabled(ColorAuto, os.Stdout)
+	a.session.theme = DefaultTheme()
 
 	a.configureLogger()
 
@@ -103,8 +181,15 @@ func (a *Application) Main() {
 
 	// initialize application
 	if err := a.initialize(); err != nil {
-		a.logger.Error("initialization failed", err)
-		a.exit(1)
+		a.fail("initialization failed", err)
+		return
+	}
+	if a.exited {
+		return
+	}
+
+	if a.extCmd != nil {
+		a.exit(a.extCmd.run(a.session))
 		return
 	}
 
@@ -129,6 +214,10 @@ func (a *Application) Main() {
 		}
 		return
 	}
+	a.logSignalStop = handleLogLevelSignals(a)
+	a.reloadSignalStop = handleReloadSignal(a)
+	a.setupCrashReport()
+
 	// Start application main process
 	go a.execute()
 
@@ -136,6 +225,25 @@ func (a *Application) Main() {
 	osmain(a.exitCh)
 }
 
+// Run runs application with command line arguments args, excluding
+// program name, like Main but without calling os.Exit so that full
+// application can be executed in tests or embedded in other programs.
+// Session is destroyed when ctx is done. Run blocks until application
+// exits and returns its exit code and error which caused it to fail.
+func (a *Application) Run(ctx context.Context, args []string) (exitCode int, err error) {
+	if a.running {
+		return 1, fmt.Errorf("%w: application is already running", ErrApplication)
+	}
+	if err := ctx.Err(); err != nil {
+		return 1, err
+	}
+	a.exitOs = false
+	a.runCtx = ctx
+	a.args = append([]string{os.Args[0]}, args...)
+	a.Main()
+	return a.exitCode, a.exitErr
+}
+
 func (a *Application) Before(action ActionWithArgs) {
 	if a.rootCmd != nil {
 		a.rootCmd.Before(action)
@@ -178,6 +286,23 @@ func (a *Application) OnInstall(action Action) {
 	a.installAction = action
 }
 
+// OnFirstRun sets action called once when session is ready on first
+// run of the application, before Do action of the command. First run is
+// detected from persisted state in application config directory, so
+// action is called only when app.fs.enabled is set. When action fails
+// application exits and action is called again on next run.
+func (a *Application) OnFirstRun(action Action) {
+	a.firstRunAction = action
+}
+
+// Banner sets function rendering banner printed to stderr when session
+// is ready, before Do action of the command e.g. branding, deprecation
+// or update notices. Empty banner is not printed. Banner is suppressed
+// with --no-banner flag or by disabling app.banner option.
+func (a *Application) Banner(banner func(sess *Session) string) {
+	a.banner = banner
+}
+
 type migration struct {
 	version    version.Version
 	upAction   ActionMigrate
@@ -229,18 +354,153 @@ func (a *Application) RegisterService(svc *Service) {
 	}
 }
 
+// WithTracer sets tracer used to trace service lifecycle,
+// event dispatching and handling and ticks.
+func (a *Application) WithTracer(tracer Tracer) {
+	a.engine.tracer = tracer
+}
+
+// AddAlertRule adds alert rule evaluated by the monitor,
+// see Monitor.AddAlertRule.
+func (a *Application) AddAlertRule(rule AlertRule) {
+	a.session.monitor.AddAlertRule(rule)
+}
+
+// AddMetricsExporter adds exporter pushing metrics every
+// app.metrics.export.interval e.g. to metrics backend not supported by
+// built-in StatsD and OTLP exporters.
+func (a *Application) AddMetricsExporter(name string, exporter MetricsExporter) {
+	a.engine.metricsExporters = append(a.engine.metricsExporters, namedMetricsExporter{
+		name:     name,
+		exporter: exporter,
+	})
+}
+
+// UseEventMiddleware adds middleware applied to every delivered event,
+// see Engine.UseEventMiddleware.
+func (a *Application) UseEventMiddleware(mw ...EventMiddleware) {
+	a.engine.UseEventMiddleware(mw...)
+}
+
+// AddEventBus adds isolated event bus with its own queue and workers.
+// Events with given scopes are routed to the bus and delivered only to
+// services listening on it, see Service.ListenOnBus. Events are enqueued
+// on the bus when dispatched and overflow policy of the event scope applies
+// when bus queue is full, see app.events.overflow.scopes.
+func (a *Application) AddEventBus(name string, workers, queueSize int, scopes ...string) {
+	if err := a.engine.addEventBus(name, workers, queueSize, scopes...); err != nil {
+		a.errs = append(a.errs, err)
+	}
+}
+
+// BridgeEvents forwards events matching pattern e.g. "ui.*" from one
+// event bus to another.
+func (a *Application) BridgeEvents(from, to, pattern string) {
+	if err := a.engine.bridgeEvents(from, to, pattern); err != nil {
+		a.errs = append(a.errs, err)
+	}
+}
+
+// WithAssets sets file system of application assets available with
+// Session.Assets, e.g. embed.FS with templates and static files.
+func (a *Application) WithAssets(fsys fs.FS) {
+	a.session.assets.mu.Lock()
+	a.session.assets.root = fsys
+	a.session.assets.mu.Unlock()
+}
+
+// WithAssetsOverride adds directories on disk overriding application
+// assets set with WithAssets. Files in override directories shadow
+// assets with same path and directory listings are merged, directories
+// added later have higher priority. Directories listed in
+// app.assets.override option have priority over these directories.
+// Missing directories are ignored.
+func (a *Application) WithAssetsOverride(dirs ...string) {
+	for _, dir := range dirs {
+		a.session.assets.addOverride(os.DirFS(dir))
+	}
+}
+
+// AddLogHandler adds log sink e.g. third-party handler, records are
+// passed to it in addition to configured sinks. Use hlog.FromStdHandler
+// to add log/slog handler.
+func (a *Application) AddLogHandler(h slog.Handler) {
+	a.logHandlers = append(a.logHandlers, h)
+	a.configureLogger()
+}
+
+// SetLogCatalog sets catalog used to translate logged messages which are
+// message keys in catalog to language set by log.lang option, e.g.
+// session.Log().Info("cache.miss", "key", k) is logged with localized
+// template of cache.miss where {key} is replaced with value of k.
+func (a *Application) SetLogCatalog(catalog hlog.Catalog) {
+	lang := a.session.Get("log.lang").String()
+	if lang == "" {
+		lang = a.session.Get("app.lang").String()
+	}
+	if lang == "" {
+		lang = hlog.LanguageFromEnv()
+	}
+	a.logTranslator = hlog.NewTranslator(catalog, lang, "en")
+	a.configureLogger()
+}
+
+// RedactLog masks parts of log messages and attribute values matching
+// any of detectors before they reach any log sink e.g. bearer tokens.
+func (a *Application) RedactLog(detectors ...*regexp.Regexp) {
+	for _, re := range detectors {
+		a.redactor.AddDetector(re)
+	}
+}
+
 func (a *Application) AddCommand(cmd *Command) {
 	if a.rootCmd != nil {
 		a.rootCmd.AddSubCommand(cmd)
 	}
 }
 
+// AddLazyCommand registers command name created by factory only when it is
+// dispatched or when help, docs or completion need full command tree.
+// It keeps startup fast for applications with many commands.
+func (a *Application) AddLazyCommand(name string, factory func() *Command) {
+	if a.rootCmd != nil {
+		a.rootCmd.AddLazySubCommand(name, factory)
+	}
+}
+
 func (a *Application) AddFlag(f varflag.Flag) {
 	if a.rootCmd != nil {
 		a.rootCmd.AddFlag(f)
 	}
 }
 
+// SetTheme sets theme used for help, error and console log output.
+func (a *Application) SetTheme(theme Theme) {
+	a.session.mu.Lock()
+	a.session.theme = theme
+	a.session.mu.Unlock()
+	if a.session.Get("log.format").String() == "console" {
+		a.configureLogger()
+	}
+}
+
+// SetHelpTemplate overrides application help templates e.g. to brand
+// help output or add footer links. global is used for application help
+// and command for help of commands which do not set own template,
+// empty value keeps default template. See Command.SetHelpTemplate.
+func (a *Application) SetHelpTemplate(global, command string) {
+	if global != "" && a.rootCmd != nil {
+		a.rootCmd.SetHelpTemplate(global)
+	}
+	if command != "" {
+		if err := validateHelpTemplate(command); err != nil {
+			a.errs = append(a.errs, err)
+			return
+		}
+		a.helpCmdTmpl = command
+	}
+}
+
 func (a *Application) Setting(key string, value any, description string, validator OptionValueValidator) {
 	if strings.HasPrefix(key, "app.") {
 		a.errs = append(a.errs, fmt.Errorf("%w: custom option %q can not start with app.", ErrOption, key))
@@ -276,26 +536,75 @@ func (a *Application) shutdown() {
 
 }
 
+// fail logs err and exits with exit code of err, err is returned from Run.
+func (a *Application) fail(msg string, err error) {
+	a.logger.Error(msg, err)
+	a.exitErr = err
+	a.exit(exitCode(err))
+}
+
+// exitCode returns exit code of application which failed with err.
+func exitCode(err error) int {
+	var sigerr *signalError
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, ErrCommandTimeout):
+		return ExitCodeTimeout
+	case errors.Is(err, ErrRunDeadline):
+		return ExitCodeDeadline
+	case errors.As(err, &sigerr):
+		return sigerr.exitCode()
+	default:
+		return 1
+	}
+}
+
 func (a *Application) exit(code int) {
 	a.logger.SystemDebug("shutting down", slog.Int("exit.code", code))
+	a.exited = true
+	a.exitCode = code
 
-	for _, fn := range a.exitFunc {
-		if err := fn(code); err != nil {
-			a.logger.Error("exit func", err)
-		}
-	}
+	a.callExitFuncs(code)
 
 	a.shutdown()
 
 	a.logger.SystemDebug("shutdown complete", slog.Duration("uptime", a.engine.uptime()))
 
-	if a.exitCh != nil {
-		a.exitCh <- struct{}{}
-	}
-
 	if err := a.save(); err != nil {
 		a.logger.Error("failed to save state", err)
 	}
+	if a.logSampler != nil {
+		a.logSampler.Flush()
+	}
+	if a.logAsync != nil {
+		_ = a.logAsync.Close()
+	}
+	if a.logFile != nil {
+		a.logFileStop()
+		if err := a.logFile.Close(); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to close log file: %s\n", err)
+		}
+	}
+	if a.logRemote != nil {
+		_ = a.logRemote.Close()
+	}
+	if a.reloadSignalStop != nil {
+		a.reloadSignalStop()
+	}
+	if a.signalStop != nil {
+		a.signalStop()
+	}
+	if a.logSignalStop != nil {
+		a.logSignalStop()
+	}
+	if a.logOTLP != nil {
+		_ = a.logOTLP.Close()
+	}
+	// Run returns once exit has completed cleanup
+	if a.exitCh != nil {
+		a.exitCh <- struct{}{}
+	}
 	if a.exitOs {
 		os.Exit(code)
 	}
@@ -326,7 +635,7 @@ func (a *Application) initializePaths() error {
 	if slug == "" {
 		return fmt.Errorf("%w: invalid slug %s", ErrApplication, slug)
 	}
-	dir := slug
+	dir := instanceSlug(a.session)
 	if a.profile != "default" {
 		dir = filepath.Join(dir, a.profile)
 	}
@@ -338,7 +647,7 @@ func (a *Application) initializePaths() error {
 	if err := a.session.opts.db.Store("app.path.tmp", tempDir); err != nil {
 		return err
 	}
-	a.exitFunc = append(a.exitFunc, func(code int) error {
+	a.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
 		tmp := os.TempDir()
 		if !strings.HasPrefix(tempDir, tmp) {
 			return fmt.Errorf("%w: invalid tmp dir %s", ErrApplication, tempDir)
@@ -383,6 +692,40 @@ func (a *Application) initializePaths() error {
 	return nil
 }
 
+// loadLazyCommands creates lazy commands dispatched by arguments, all lazy
+// commands are created when help is requested or dispatched command needs
+// full command tree.
+func (a *Application) loadLazyCommands() error {
+	argv, _ := splitRawArgs(a.args)
+	if len(argv) > 0 {
+		argv = argv[1:]
+	}
+	cmd := a.rootCmd
+	help := false
+	for _, arg := range argv {
+		if arg == "-h" || arg == "--help" {
+			help = true
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		sub, exists, err := cmd.loadSubCommand(arg)
+		if err != nil {
+			return err
+		}
+		if exists {
+			cmd = sub
+		}
+	}
+	if help || cmd.walksTree {
+		return a.rootCmd.loadSubCommands()
+	}
+	if cmd.doAction == nil {
+		return cmd.loadSubCommands()
+	}
+	return nil
+}
+
 func (a *Application) initialize() error {
 	defer func() {
 		dur := time.Since(a.initialized)
@@ -404,12 +747,63 @@ func (a *Application) initialize() error {
 	if a.rootCmd == nil {
 		return fmt.Errorf("%w: root command was not initialized on time", ErrHappy)
 	}
+	if a.session.Get("app.daemon").Bool() {
+		for _, cmd := range daemonCommands() {
+			if !a.rootCmd.hasSubCommand(cmd.name) {
+				a.rootCmd.AddSubCommand(cmd)
+			}
+		}
+	}
+	if a.session.Get("app.windows.service").Bool() {
+		for _, cmd := range windowsServiceCommands(a) {
+			if !a.rootCmd.hasSubCommand(cmd.name) {
+				a.rootCmd.AddSubCommand(cmd)
+			}
+		}
+	}
+	// completion is provided for applications with sub commands
+	if a.rootCmd.hasSubCommands() {
+		if !a.rootCmd.hasSubCommand("completion") {
+			a.rootCmd.AddSubCommand(completionCommand(a.rootCmd))
+		}
+		if !a.rootCmd.hasSubCommand(completeCommandName) {
+			a.rootCmd.AddSubCommand(completeCommand(a.rootCmd))
+		}
+		if !a.rootCmd.hasSubCommand("events") {
+			a.rootCmd.AddSubCommand(eventsCommand(a))
+		}
+		if !a.rootCmd.hasSubCommand("docs") {
+			a.rootCmd.AddSubCommand(docsCommand(a.rootCmd))
+		}
+		if !a.rootCmd.hasSubCommand("env") {
+			a.rootCmd.AddSubCommand(envCommand(a))
+		}
+		if !a.rootCmd.hasSubCommand("stats") {
+			a.rootCmd.AddSubCommand(statsCommand())
+		}
+		if !a.rootCmd.hasSubCommand("status") {
+			a.rootCmd.AddSubCommand(statusCommand())
+		}
+		if !a.rootCmd.hasSubCommand(shellCommandName) && a.session.Get("app.shell").Bool() {
+			a.rootCmd.AddSubCommand(shellCommand(a.rootCmd))
+		}
+	}
+	if err := a.loadLazyCommands(); err != nil {
+		return err
+	}
 	if err := a.rootCmd.verify(); err != nil {
 		return err
 	}
 
-	if e := a.rootCmd.flags.Parse(os.Args); e != nil {
-		return errors.Join(ErrApplication, e)
+	argv, raw := splitRawArgs(a.args)
+	a.rawArgs = raw
+	if e := a.rootCmd.flags.Parse(argv); e != nil {
+		// flags of external command are unknown to us
+		if a.extCmd = findExternalCommand(a.rootCmd, a.args); a.extCmd == nil {
+			return errors.Join(ErrApplication, e)
+		}
+	} else if len(a.rootCmd.flags.GetActiveSets()) == 1 && len(a.rootCmd.flags.Args()) > 0 {
+		a.extCmd = findExternalCommand(a.rootCmd, a.args)
 	}
 
 	// print application version and exit
@@ -417,6 +811,54 @@ func (a *Application) initialize() error {
 		a.lvl.Set(100)
 		a.printVersion()
 		a.exit(0)
+		return nil
+	}
+
+	a.profile = a.rootCmd.flag("profile").Var().String()
+	if err := a.applyProfile(); err != nil {
+		return err
+	}
+	if err := a.applyInstance(); err != nil {
+		return err
+	}
+	if err := a.initializePaths(); err != nil {
+		return err
+	}
+	if err := a.load(); err != nil {
+		return err
+	}
+	if err := a.loadUserSettings(); err != nil {
+		return err
+	}
+	if err := a.initializeIDs(); err != nil {
+		return err
+	}
+	if err := a.loadConfig(); err != nil {
+		return err
+	}
+	if err := a.loadEnv(); err != nil {
+		return err
+	}
+	if dirs := a.session.Get("app.assets.override").String(); dirs != "" {
+		for _, dir := range strings.Split(dirs, ",") {
+			if dir = strings.TrimSpace(dir); dir != "" {
+				a.WithAssetsOverride(dir)
+			}
+		}
+	}
+	if err := a.loadTranslations(); err != nil {
+		return err
+	}
+
+	// resolve colored output from flags, logger is reconfigured
+	// when colors got disabled.
+	colorMode := a.rootCmd.flag("color").String()
+	if a.rootCmd.flag("no-color").Present() {
+		colorMode = ColorNever
+	}
+	if color := colorEnabled(colorMode, os.Stdout); color != a.session.color {
+		a.session.color = color
+		a.configureLogger()
 	}
 
 	// set log verbosity from flags
@@ -427,20 +869,32 @@ func (a *Application) initialize() error {
 	} else if a.rootCmd.flag("verbose").Var().Bool() {
 		a.lvl.Set(slog.Level(hlog.LevelInfo))
 	}
+	if name := a.rootCmd.flag("log-level").String(); name != "" {
+		lvl, err := hlog.ParseLevel(name)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrCommandFlags, err.Error())
+		}
+		a.lvl.Set(slog.Level(lvl))
+	}
 
-	a.profile = a.rootCmd.flag("profile").Var().String()
 	a.logger.SystemDebug("using profile", slog.String("profile", a.profile))
-	if err := a.initializePaths(); err != nil {
-		return err
-	} else {
-		if err := a.load(); err != nil {
-			return err
-		}
+
+	a.disableAddons()
+
+	if a.extCmd != nil {
+		a.activeCmd = a.rootCmd
+		return nil
 	}
 
 	if err := a.setActiveCommand(); err != nil {
 		return err
 	}
+	for _, addon := range a.addons {
+		if addon.disabled && addon.providesCommand(a.activeCmd) {
+			return fmt.Errorf("%w: command %s is provided by disabled addon %s",
+				ErrCommand, a.activeCmd.name, addon.info.Name)
+		}
+	}
 
 	// show help
 	if a.rootCmd.flag("help").Present() {
@@ -448,17 +902,46 @@ func (a *Application) initialize() error {
 			a.logger.Error("failed to create help view", err)
 		}
 		a.shutdown()
-		os.Exit(0)
+		a.exited = true
+		if a.exitOs {
+			os.Exit(0)
+		}
 		return nil
 	}
 	// set x flag to session
 	a.session.x = a.rootCmd.flag("x").Present()
+	a.session.noninteractive = a.rootCmd.flag("no-interactive").Present()
+
+	// start application in background and exit
+	if a.rootCmd.flag("daemon").Present() {
+		if err := a.daemonize(); err != nil {
+			return err
+		}
+		a.exit(0)
+		return nil
+	}
+	if os.Getenv(daemonEnvKey) != "" {
+		if err := a.startDaemon(); err != nil {
+			return err
+		}
+	}
+	if a.session.Get("app.instance.single").Bool() {
+		forwarded, err := a.acquireInstance()
+		if err != nil {
+			return err
+		}
+		if forwarded {
+			a.exit(0)
+			return nil
+		}
+	}
 
 	a.logger.Debug(
 		"enable logging",
 		slog.String("level", hlog.Level(a.lvl.Level()).String()),
 		slog.String("cmd", a.activeCmd.name),
 	)
+	a.activeCmd.warnDeprecated(a.session)
 
 	// loaded persistent state
 	if a.state != nil {
@@ -482,6 +965,7 @@ func (a *Application) initialize() error {
 		a.setupNextRun = false
 		a.logger.Ok("setup complete")
 	}
+	a.detectFirstRun()
 
 	// apply pending options for app settings if set
 	if err := a.applySettings(); err != nil {
@@ -495,6 +979,22 @@ func (a *Application) initialize() error {
 		return err
 	}
 
+	if addr := a.session.Get("app.diagnostics.addr").String(); addr != "" {
+		if err := a.engine.serviceRegister(a.session, diagnosticsService(addr)); err != nil {
+			return err
+		}
+	}
+	if addr := a.session.Get("app.metrics.addr").String(); addr != "" {
+		if err := a.engine.serviceRegister(a.session, metricsService(addr)); err != nil {
+			return err
+		}
+	}
+	if addr := a.session.Get("app.health.addr").String(); addr != "" {
+		if err := a.engine.serviceRegister(a.session, healthService(addr)); err != nil {
+			return err
+		}
+	}
+
 	// migrate
 	if err := a.migrate(); err != nil {
 		return err
@@ -539,13 +1039,103 @@ func (a *Application) firstUse() error {
 	return nil
 }
 
+// detectFirstRun sets firstRunPending when there is no persisted state
+// or first run action did not complete on previous runs.
+func (a *Application) detectFirstRun() {
+	if a.firstRunAction == nil || !a.session.Get("app.fs.enabled").Bool() {
+		return
+	}
+	a.firstRunPending = a.state == nil || a.state.FirstRunPending
+	a.logger.SystemDebug("detect first run", slog.Bool("pending", a.firstRunPending))
+}
+
+// firstRun calls first run action when first run is pending.
+func (a *Application) firstRun() error {
+	if !a.firstRunPending {
+		return nil
+	}
+	if err := a.firstRunAction(a.session); err != nil {
+		return err
+	}
+	a.firstRunPending = false
+	a.firstRunDone = true
+	a.logger.Ok("first run setup complete")
+	return nil
+}
+
+// printBanner writes banner to w unless it is suppressed.
+func (a *Application) printBanner(w io.Writer) {
+	if a.banner == nil || !a.session.Get("app.banner").Bool() ||
+		a.rootCmd.flag("no-banner").Present() {
+		return
+	}
+	banner := a.banner(a.session)
+	if banner == "" {
+		return
+	}
+	if !strings.HasSuffix(banner, "\n") {
+		banner += "\n"
+	}
+	fmt.Fprint(w, banner)
+}
+
+// initializeIDs sets app.instance.id persisted in application state and
+// random app.run.id of current run. Instance id is generated on every
+// run when app.fs.enabled is not set.
+func (a *Application) initializeIDs() error {
+	instanceID := ""
+	if a.state != nil {
+		instanceID = a.state.InstanceID
+	}
+	if instanceID == "" {
+		id, err := newID()
+		if err != nil {
+			return err
+		}
+		instanceID = id
+		a.instanceIDCreated = a.session.Get("app.fs.enabled").Bool()
+	}
+	runID, err := newID()
+	if err != nil {
+		return err
+	}
+	// exceptions which by pass validation
+	if err := a.session.opts.db.Store("app.instance.id", instanceID); err != nil {
+		return err
+	}
+	if err := a.session.opts.db.Store("app.run.id", runID); err != nil {
+		return err
+	}
+	a.logger.SystemDebug("identifiers",
+		slog.String("instance.id", instanceID),
+		slog.String("run.id", runID),
+	)
+	a.configureLogger()
+	return nil
+}
+
+// newID returns random 128 bit identifier in hex.
+func newID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("%w: failed to generate id: %s", ErrApplication, err.Error())
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
 type persistentState struct {
 	Date          time.Time         `json:"date"`
 	Version       version.Version   `json:"version"`
 	LastMigration version.Version   `json:"lastMigration"`
 	Settings      []persistentValue `json:"settings"`
 	SetupNextRun  bool              `json:"setupNextRun"`
-	cfile         string
+	// FirstRunPending is set until OnFirstRun action completes.
+	FirstRunPending bool `json:"firstRunPending,omitempty"`
+	// Addons are versions of addons used on last run.
+	Addons map[string]version.Version `json:"addons,omitempty"`
+	// InstanceID is generated on first run, see app.instance.id.
+	InstanceID string `json:"instanceID,omitempty"`
+	cfile      string
 }
 
 type persistentValue struct {
@@ -582,30 +1172,40 @@ func (a *Application) load() error {
 	a.state.cfile = cfile
 
 	for _, setting := range a.state.Settings {
-		// override predef options
 		varval, err := vars.NewValueAs(setting.Value, vars.Kind(setting.Kind))
 		if err != nil {
 			return err
 		}
-		if a.session.Has(setting.Key) {
-			if err := a.session.opts.set(setting.Key, varval.Any(), true); err != nil {
-				return err
-			}
-			continue
+		if err := a.overrideOption(setting.Key, varval.Any(), "state"); err != nil {
+			return err
 		}
+	}
+	return nil
+}
+
+// overrideOption overrides value of the option or pending option
+// with value loaded from given source.
+func (a *Application) overrideOption(key string, value any, source string) error {
+	if a.session.Has(key) {
+		// override predef options
+		if err := a.session.opts.set(key, value, true); err != nil {
+			return err
+		}
+	} else {
 		// override predef pending opts
 		found := false
 		for i, opt := range a.pendingOpts {
-			if opt.key == setting.Key {
-				a.pendingOpts[i].value = varval.Any()
+			if opt.key == key {
+				a.pendingOpts[i].value = value
 				found = true
 				break
 			}
 		}
 		if !found {
-			a.pendingOpts = append(a.pendingOpts, Option(setting.Key, varval.Any()))
+			a.pendingOpts = append(a.pendingOpts, Option(key, value))
 		}
 	}
+	a.session.setOptionSource(key, source)
 	return nil
 }
 
@@ -616,7 +1216,7 @@ func (a *Application) save() error {
 	if a.activeCmd == nil {
 		return nil
 	}
-	if !a.activeCmd.allowOnFreshInstall {
+	if !a.activeCmd.allowOnFreshInstall && !a.addonsChanged && !a.firstRunDone && !a.instanceIDCreated {
 		a.logger.SystemDebug("skip saving")
 		return nil
 	}
@@ -640,9 +1240,15 @@ func (a *Application) save() error {
 		return err
 	}
 	ps := &persistentState{
-		Date:         time.Now().UTC(),
-		Version:      ver,
-		SetupNextRun: a.setupNextRun,
+		Date:            time.Now().UTC(),
+		Version:         ver,
+		SetupNextRun:    a.setupNextRun,
+		FirstRunPending: a.firstRunPending,
+		Addons:          a.addonVersions,
+		InstanceID:      a.session.Get("app.instance.id").String(),
+	}
+	if ps.Addons == nil && a.state != nil {
+		ps.Addons = a.state.Addons
 	}
 	settings := a.session.Settings()
 	settings.Range(func(v vars.Variable) bool {
@@ -666,6 +1272,7 @@ func (a *Application) setActiveCommand() error {
 	name := settree[len(settree)-1].Name()
 	if name == "/" {
 		a.activeCmd = a.rootCmd
+		a.activeCmd.setRawArgs(a.rawArgs)
 		// only set app tick tock if current command is root command
 		a.engine.onTick(a.tickAction)
 		a.engine.onTock(a.tockAction)
@@ -695,23 +1302,58 @@ func (a *Application) setActiveCommand() error {
 	}
 
 	a.activeCmd = activeCmd
+	a.activeCmd.setRawArgs(a.rawArgs)
 
 	return nil
 }
 
+// startBuiltinService starts built-in service name when it is
+// enabled with address option key.
+func (a *Application) startBuiltinService(key, name string) {
+	if a.session.Get(key).String() == "" {
+		return
+	}
+	hostaddr, err := address.Parse(a.session.Get("app.host.addr").String())
+	if err == nil {
+		var svcaddr *address.Address
+		if svcaddr, err = hostaddr.ResolveService(name); err == nil {
+			a.session.Dispatch(StartServicesEvent(svcaddr.String()))
+		}
+	}
+	if err != nil {
+		a.logger.Error("failed to start "+name+" service", err)
+	}
+}
+
 func (a *Application) execute() {
+	defer a.recoverPanic()
 	if err := a.session.start(); err != nil {
-		a.logger.Error("failed to start session", err)
-		a.exit(1)
+		a.fail("failed to start session", err)
 		return
 	}
+	a.signalStop = a.handleSignals()
+	if a.runCtx != nil {
+		go a.destroyOnDone(a.runCtx)
+	}
+	if deadline := time.Duration(a.session.Get("app.deadline").Int64()); deadline > 0 {
+		a.session.mu.Lock()
+		a.session.deadline = a.initialized.Add(deadline)
+		a.session.mu.Unlock()
+		a.deadlineExceeded = make(chan struct{})
+		go a.watchDeadline(deadline)
+	}
 
 	if err := a.engine.start(a.session); err != nil {
-		a.logger.Error("failed to start the engine", err)
-		a.exit(1)
+		a.fail("failed to start the engine", err)
 		return
 	}
 
+	go a.instance.serve(a.session)
+
+	a.startBuiltinService("app.diagnostics.addr", diagnosticsServiceName)
+	a.startBuiltinService("app.metrics.addr", metricsServiceName)
+	a.startBuiltinService("app.health.addr", healthServiceName)
+
 	if a.isDev {
 		a.logger.Notice("development mode",
 			slog.Bool("enabled", true),
@@ -721,8 +1363,7 @@ func (a *Application) execute() {
 
 	// execute before action chain
 	if err := a.executeBeforeActions(); err != nil {
-		a.logger.Error("prerequisites failed", err)
-		a.exit(1)
+		a.fail("prerequisites failed", err)
 		return
 	}
 
@@ -730,15 +1371,26 @@ func (a *Application) execute() {
 	a.logger.SystemDebug("waiting session...")
 	<-a.session.Ready()
 
-	if a.session.Err() != nil {
-		a.exit(1)
+	if err := a.session.Err(); err != nil {
+		a.exitErr = err
+		a.exit(exitCode(err))
 		return
 	}
 
+	if err := a.firstRun(); err != nil {
+		a.fail("first run setup failed", err)
+		return
+	}
+
+	a.printBanner(os.Stderr)
+
 	cmdtree := strings.Join(a.activeCmd.parents, ".") + "." + a.activeCmd.name
 	a.logger.SystemDebug("session ready: execute", slog.String("action", "Do"), slog.String("command", cmdtree))
 
-	err := a.activeCmd.callDoAction(a.session)
+	timeout, err := a.commandTimeout()
+	if err == nil {
+		err = a.callDoActionWithTimeout(timeout)
+	}
 	if err != nil {
 		a.executeAfterFailureActions(err)
 	} else {
@@ -747,12 +1399,35 @@ func (a *Application) execute() {
 	a.executeAfterAlwaysActions(err)
 }
 
+// destroyOnDone destroys session when ctx passed to Run is done.
+func (a *Application) destroyOnDone(ctx context.Context) {
+	select {
+	case <-ctx.Done():
+		a.session.Destroy(ctx.Err())
+	case <-a.session.Done():
+	}
+}
+
+// watchDeadline destroys session with ErrRunDeadline when application
+// runs longer than deadline, services are drained on shutdown.
+func (a *Application) watchDeadline(deadline time.Duration) {
+	timer := time.NewTimer(time.Until(a.initialized.Add(deadline)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		a.logger.Warn("run deadline exceeded", slog.Duration("deadline", deadline))
+		a.session.Destroy(fmt.Errorf("%w: %s", ErrRunDeadline, deadline))
+		close(a.deadlineExceeded)
+	case <-a.session.Done():
+	}
+}
+
 func (a *Application) printVersion() {
 	fmt.Println(a.session.Get("app.version").String())
 }
 
 func (a *Application) configureApplication(opts []OptionArg) (err error) {
-	a.session = &Session{}
+	a.session = &Session{sessionState: &sessionState{assets: &Assets{}, i18n: &translations{}, monitor: newMonitor()}}
 	defAppConf, conferr := getDefaultApplicationConfig()
 	a.session.opts, err = NewOptions("config", defAppConf)
 	if conferr != nil {
@@ -777,6 +1452,7 @@ func (a *Application) configureApplication(opts []OptionArg) (err error) {
 					errs = append(errs, err)
 					continue
 				}
+				a.session.setOptionSource(key, "option")
 				provided = true
 				break
 			}
@@ -793,6 +1469,7 @@ func (a *Application) configureApplication(opts []OptionArg) (err error) {
 	for _, opt := range opts {
 		if !a.session.Has(opt.key) {
 			a.pendingOpts = append(a.pendingOpts, opt)
+			a.session.setOptionSource(opt.key, "option")
 		}
 	}
 
@@ -802,7 +1479,9 @@ func (a *Application) configureApplication(opts []OptionArg) (err error) {
 }
 
 func (a *Application) configureLogger() {
-	a.lvl.Set(slog.Level(a.session.Get("log.level").Int()))
+	if lvl, err := hlog.ParseLevel(a.session.Get("log.level").String()); err == nil {
+		a.lvl.Set(slog.Level(lvl))
+	}
 	secretsCnf := a.session.Get("log.secrets").String()
 	var secrets []string
 	if len(secretsCnf) > 0 {
@@ -811,24 +1490,361 @@ func (a *Application) configureLogger() {
 			secrets = append(secrets, strings.TrimSpace(secret))
 		}
 	}
-	handler := hlog.Config{
-		Options: slog.HandlerOptions{
-			AddSource: a.session.Get("log.source").Bool(),
-			Level:     a.lvl,
-			// ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			// 	return a
-			// },
-		},
-		Colors:  a.session.Get("log.colors").Bool(),
-		Secrets: secrets,
-		JSON:    false,
-	}.NewHandler(os.Stdout)
+	// per scope level overrides apply to sinks using log.level
+	if a.session.logScopes == nil {
+		a.session.logScopes = hlog.NewScopeLevels(a.lvl)
+	}
+	if err := a.session.logScopes.Set(a.session.Get("log.scopes").String()); err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log.scopes: %s\n", err)
+	}
+	if a.redactor == nil {
+		a.redactor = a.newRedactor()
+		a.session.redactor = a.redactor
+	}
+
+	newHandler := func(w io.Writer, format, level string, colors bool) slog.Handler {
+		var leveler slog.Leveler = slog.Level(hlog.LevelAll)
+		if lvl, err := hlog.ParseLevel(level); err == nil && level != "" {
+			leveler = slog.Level(lvl)
+		}
+		var handler slog.Handler
+		if format == "console" {
+			theme := a.session.theme.Log
+			handler = hlog.NewConsoleHandler(w, hlog.ConsoleConfig{
+				Level:  leveler,
+				Source: a.lvl,
+				Colors: colors,
+				Theme:  &theme,
+			})
+		} else {
+			handler = hlog.Config{
+				Options: slog.HandlerOptions{
+					AddSource: a.session.Get("log.source").Bool(),
+					Level:     leveler,
+					// ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+					// 	return a
+					// },
+				},
+				Colors:  colors,
+				Secrets: secrets,
+				JSON:    format == "json",
+			}.NewHandler(w)
+			if ids := a.idAttrs(); len(ids) > 0 {
+				handler = handler.WithAttrs(ids)
+			}
+		}
+		if level == "" {
+			return a.session.logScopes.Handler(handler)
+		}
+		return handler
+	}
+
+	// each sink has its own level and format
+	var handlers []slog.Handler
+	if a.session.Get("log.console").Bool() {
+		handlers = append(handlers, newHandler(
+			os.Stdout,
+			a.session.Get("log.format").String(),
+			"",
+			a.session.Get("log.colors").Bool() && a.session.color,
+		))
+	}
+	if file, err := a.openLogFile(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to open log file: %s\n", err)
+	} else if file != nil {
+		handlers = append(handlers, newHandler(
+			file,
+			a.session.Get("log.file.format").String(),
+			a.session.Get("log.file.level").String(),
+			false,
+		))
+	}
+	if remote, err := a.openLogRemote(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure remote log: %s\n", err)
+	} else if remote != nil {
+		handlers = append(handlers, newHandler(
+			remote,
+			a.session.Get("log.remote.format").String(),
+			a.session.Get("log.remote.level").String(),
+			false,
+		))
+	}
+
+	if otlp, err := a.openLogOTLP(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to configure otlp log export: %s\n", err)
+	} else if otlp != nil {
+		var h slog.Handler = otlp
+		if ids := a.idAttrs(); len(ids) > 0 {
+			h = h.WithAttrs(ids)
+		}
+		if a.session.Get("log.otlp.level").String() == "" {
+			h = a.session.logScopes.Handler(otlp)
+		}
+		handlers = append(handlers, h)
+	}
+
+	if a.session.Get("app.crash.report").Bool() {
+		if a.crashLogs == nil {
+			a.crashLogs = hlog.NewRing(crashRecentLogs)
+		}
+		handlers = append(handlers, a.session.logScopes.Handler(a.crashLogs.Handler()))
+	}
+
+	handlers = append(handlers, a.logHandlers...)
+
+	var handler slog.Handler
+	switch len(handlers) {
+	case 0:
+		handler = newHandler(io.Discard, "", "", false)
+	case 1:
+		handler = handlers[0]
+	default:
+		handler = hlog.NewFanoutHandler(handlers...)
+	}
+
+	if a.logSampler != nil {
+		a.logSampler.Flush()
+		a.logSampler = nil
+	}
+	if a.logAsync != nil {
+		_ = a.logAsync.Close()
+		a.logAsync = nil
+		a.session.logFlush = nil
+	}
+	if a.session.Get("log.async").Bool() {
+		a.logAsync = hlog.NewAsyncHandler(handler, hlog.AsyncConfig{
+			QueueSize: a.session.Get("log.async.queue").Int(),
+		})
+		handler = a.logAsync
+		a.session.logFlush = a.logAsync.Flush
+	}
+	window := time.Duration(a.session.Get("log.dedup").Int64())
+	debugRate := a.session.Get("log.sample.debug").Int()
+	if window > 0 || debugRate > 1 {
+		a.logSampler = hlog.NewSampler(hlog.SamplerConfig{
+			Window:    window,
+			Burst:     a.session.Get("log.dedup.burst").Int(),
+			DebugRate: debugRate,
+		})
+		handler = a.logSampler.Handler(handler)
+	}
+
+	if a.session.logMetrics == nil {
+		if keys := a.session.Get("log.metrics").String(); keys != "" {
+			var labels []string
+			for _, key := range strings.Split(keys, ",") {
+				labels = append(labels, strings.TrimSpace(key))
+			}
+			a.session.logMetrics = hlog.NewMetrics(labels...)
+		}
+	}
+	if a.session.logMetrics != nil {
+		handler = a.session.logMetrics.Handler(handler)
+	}
 
-	a.logger = hlog.New(handler)
+	handler = hlog.NewContextHandler(handler)
+	// messages are translated after redaction so that
+	// templates are rendered with redacted values
+	if a.logTranslator != nil {
+		handler = a.logTranslator.Handler(handler)
+	}
+	a.logger = hlog.NewLeveled(a.redactor.Handler(handler), a.lvl)
+	errcnf := hlog.ErrorConfig{Causes: a.session.Get("log.error.causes").Bool()}
+	if stack := a.session.Get("log.error.stack").String(); stack != "" {
+		if lvl, err := hlog.ParseLevel(stack); err == nil {
+			errcnf.Stack = true
+			errcnf.StackLevel = lvl
+		}
+	}
+	if errcnf.Causes || errcnf.Stack {
+		a.logger = a.logger.WithErrorConfig(errcnf)
+	}
 	a.session.logger = a.logger
 	hlog.SetDefault(a.logger, a.session.Get("log.stdlog").Bool())
 }
 
+// idAttrs returns instance and run id attributes added to records of
+// machine readable log sinks, console output is left uncluttered.
+func (a *Application) idAttrs() []slog.Attr {
+	var attrs []slog.Attr
+	if id := a.session.Get("app.instance.id").String(); id != "" {
+		attrs = append(attrs, slog.String("instance.id", id))
+	}
+	if id := a.session.Get("app.run.id").String(); id != "" {
+		attrs = append(attrs, slog.String("run.id", id))
+	}
+	return attrs
+}
+
+// upgradeAddon calls install or upgrade action of the addon when
+// addon is used first time or its version changed since last run.
+func (a *Application) upgradeAddon(addon *Addon) error {
+	if !a.session.Get("app.fs.enabled").Bool() {
+		return nil
+	}
+	if a.addonVersions == nil {
+		a.addonVersions = make(map[string]version.Version)
+		if a.state != nil {
+			for name, ver := range a.state.Addons {
+				a.addonVersions[name] = ver
+			}
+		}
+	}
+	name, curr := addon.info.Name, addon.info.Version
+	prev, installed := a.addonVersions[name]
+	if installed && prev == curr {
+		return nil
+	}
+	if !installed {
+		if addon.installAction != nil {
+			if err := addon.installAction(a.session); err != nil {
+				return fmt.Errorf("%w: %s install failed: %w", ErrAddon, name, err)
+			}
+		}
+		a.logger.Debug("installed addon", slog.String("addon", name), slog.String("version", curr.String()))
+	} else {
+		if addon.upgradeAction != nil {
+			if err := addon.upgradeAction(a.session, Version(prev), Version(curr)); err != nil {
+				return fmt.Errorf("%w: %s upgrade from %s to %s failed: %w", ErrAddon, name, prev, curr, err)
+			}
+		}
+		a.logger.Info("upgraded addon",
+			slog.String("addon", name),
+			slog.String("from", prev.String()),
+			slog.String("to", curr.String()),
+		)
+	}
+	a.addonVersions[name] = curr
+	a.addonsChanged = true
+	return nil
+}
+
+// disableAddons marks addons listed in app.addons.disabled option,
+// which may be set in profile settings, disabled and hides their commands.
+func (a *Application) disableAddons() {
+	list := a.session.Get("app.addons.disabled").String()
+	for _, opt := range a.pendingOpts {
+		if opt.key == "app.addons.disabled" {
+			list = fmt.Sprint(opt.value)
+		}
+	}
+	if list == "" {
+		return
+	}
+	names := make(map[string]bool)
+	for _, name := range strings.Split(list, ",") {
+		names[strings.TrimSpace(name)] = true
+	}
+	for _, addon := range a.addons {
+		if !names[addon.info.Name] {
+			continue
+		}
+		addon.disabled = true
+		for _, cmd := range addon.cmds {
+			cmd.mu.Lock()
+			cmd.hidden = true
+			cmd.mu.Unlock()
+		}
+	}
+}
+
+// newRedactor returns redactor masking default sensitive keys
+// and keys configured with log.redact and log.secrets options.
+func (a *Application) newRedactor() *hlog.Redactor {
+	var keys []string
+	for _, opt := range []string{"log.redact", "log.secrets"} {
+		if cnf := a.session.Get(opt).String(); cnf != "" {
+			keys = append(keys, strings.Split(cnf, ",")...)
+		}
+	}
+	r, err := hlog.NewRedactor(keys...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid log.redact: %s\n", err)
+		r, _ = hlog.NewRedactor()
+	}
+	return r
+}
+
+// openLogFile opens log file configured with log.file option,
+// it returns nil when option is not set. File is opened once and
+// reopened when process receives SIGHUP.
+func (a *Application) openLogFile() (*hlog.FileWriter, error) {
+	if a.logFile != nil {
+		return a.logFile, nil
+	}
+	path := a.session.Get("log.file").String()
+	if path == "" {
+		return nil, nil
+	}
+	file, err := hlog.NewFileWriter(hlog.FileConfig{
+		Path:       path,
+		MaxSize:    int64(a.session.Get("log.max.size").Int()) << 20,
+		MaxAge:     time.Duration(a.session.Get("log.max.age").Int64()),
+		MaxBackups: a.session.Get("log.max.backups").Int(),
+		Compress:   a.session.Get("log.compress").Bool(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.logFile = file
+	a.logFileStop = file.ReopenOnSIGHUP()
+	return file, nil
+}
+
+// openLogRemote returns writer for log.remote address,
+// it returns nil when option is not set.
+func (a *Application) openLogRemote() (*hlog.NetWriter, error) {
+	if a.logRemote != nil {
+		return a.logRemote, nil
+	}
+	addr := a.session.Get("log.remote").String()
+	if addr == "" {
+		return nil, nil
+	}
+	remote, err := hlog.NewNetWriter(addr)
+	if err != nil {
+		return nil, err
+	}
+	a.logRemote = remote
+	return remote, nil
+}
+
+// openLogOTLP returns OTLP exporter for log.otlp.endpoint,
+// it returns nil when option is not set.
+func (a *Application) openLogOTLP() (*hlog.OTLPHandler, error) {
+	if a.logOTLP != nil {
+		return a.logOTLP, nil
+	}
+	endpoint := a.session.Get("log.otlp.endpoint").String()
+	if endpoint == "" {
+		return nil, nil
+	}
+	var level slog.Leveler = slog.Level(hlog.LevelAll)
+	if lvl, err := hlog.ParseLevel(a.session.Get("log.otlp.level").String()); err == nil {
+		level = slog.Level(lvl)
+	}
+	headers := make(map[string]string)
+	for _, header := range strings.Split(a.session.Get("log.otlp.headers").String(), ",") {
+		if k, v, ok := strings.Cut(header, "="); ok {
+			headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	otlp, err := hlog.NewOTLPHandler(hlog.OTLPConfig{
+		Endpoint: endpoint,
+		Headers:  headers,
+		Level:    level,
+		Resource: map[string]string{
+			"service.name":    a.session.Get("app.slug").String(),
+			"service.version": a.session.Get("app.version").String(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	a.logOTLP = otlp
+	return otlp, nil
+}
+
 func (a *Application) configureRootCommand() error {
 	rootCmd := NewCommand(
 		filepath.Base(os.Args[0]),
@@ -849,6 +1865,8 @@ func (a *Application) configureRootCommand() error {
 		{"no-color", false, "disable colored output", nil},
 		{"version", false, "print application version", nil},
 		{"x", false, "the -x flag prints all the external commands as they are executed.", nil},
+		{"no-interactive", false, "disable interactive prompts, prompts use defaults or fail", nil},
+		{"no-banner", false, "do not print application banner", nil},
 		{"system-debug", false, "enable system debug log level (very verbose)", nil},
 		{"debug", false, "enable debug log level. when debug flag is after the command then debug level will be enabled only for that command", nil},
 		{"verbose", false, "enable verbose log level", []string{"v"}},
@@ -862,6 +1880,17 @@ func (a *Application) configureRootCommand() error {
 		rootCmd.AddFlag(f)
 	}
 
+	colorFlag, err := varflag.Option(
+		"color",
+		[]string{ColorAuto},
+		[]string{ColorAuto, ColorAlways, ColorNever},
+		"colored output, auto enables colors for terminals unless NO_COLOR is set",
+	)
+	if err != nil {
+		return err
+	}
+	rootCmd.AddFlag(colorFlag)
+
 	profile := "default"
 	if a.isDev {
 		profile = "devel"
@@ -872,21 +1901,45 @@ func (a *Application) configureRootCommand() error {
 		return err
 	}
 	rootCmd.AddFlag(profileFlag)
+
+	timeoutFlag, err := varflag.New("timeout", "", "time limit for command e.g. 30s or 5m, overrides command default, 0 disables the limit")
+	if err != nil {
+		return err
+	}
+	rootCmd.AddFlag(timeoutFlag)
+
+	logLevelFlag, err := varflag.New("log-level", "", "set log level e.g. trace, debug or warn, overrides other verbosity flags")
+	if err != nil {
+		return err
+	}
+	rootCmd.AddFlag(logLevelFlag)
+
+	configFlag, err := varflag.New("config", "", "load options from config file instead of config files in standard locations")
+	if err != nil {
+		return err
+	}
+	rootCmd.AddFlag(configFlag)
+
+	instanceFlag, err := varflag.New("instance", "", "name of isolated application instance e.g. 2, instances have separate config, cache and data")
+	if err != nil {
+		return err
+	}
+	rootCmd.AddFlag(instanceFlag)
+
+	if a.session.Get("app.daemon").Bool() {
+		daemonFlag, err := varflag.Bool("daemon", false, "run application in background, see stop and status commands")
+		if err != nil {
+			return err
+		}
+		rootCmd.AddFlag(daemonFlag)
+	}
 	a.rootCmd = rootCmd
 	return nil
 }
 
 func (a *Application) executeBeforeActions() error {
 	a.logger.SystemDebug("execute before actions")
-	if a.rootCmd != a.activeCmd {
-		if err := a.rootCmd.callBeforeAction(a.session); err != nil {
-			return err
-		}
-	}
-	if err := a.activeCmd.callBeforeAction(a.session); err != nil {
-		return err
-	}
-	return nil
+	return a.activeCmd.callBeforeChain(a.session)
 }
 
 func (a *Application) executeAfterFailureActions(err error) {
@@ -929,10 +1982,61 @@ func (a *Application) executeAfterAlwaysActions(err error) {
 		}
 	}
 
-	if err != nil {
-		a.exit(1)
-	} else {
-		a.exit(0)
+	a.exitErr = err
+	a.exit(exitCode(err))
+}
+
+// commandTimeout returns time limit of active command,
+// --timeout flag overrides default timeout of command.
+func (a *Application) commandTimeout() (time.Duration, error) {
+	flag := a.rootCmd.flag("timeout")
+	if !flag.Present() {
+		return a.activeCmd.timeout, nil
+	}
+	timeout, err := time.ParseDuration(flag.String())
+	if err != nil || timeout < 0 {
+		return 0, fmt.Errorf("%w: invalid --timeout %q", ErrCommandFlags, flag.String())
+	}
+	return timeout, nil
+}
+
+// callDoActionWithTimeout calls Do action of active command. When time
+// limit or app.deadline is exceeded session is destroyed with
+// ErrCommandTimeout or ErrRunDeadline so that action can return on
+// sess.Done, after actions are called without waiting for Do action
+// to return.
+func (a *Application) callDoActionWithTimeout(timeout time.Duration) error {
+	if timeout == 0 && a.deadlineExceeded == nil {
+		return a.activeCmd.callDoAction(a.session)
+	}
+	var timerC <-chan time.Time
+	if timeout > 0 {
+		deadline := time.Now().Add(timeout)
+		a.session.mu.Lock()
+		if a.session.deadline.IsZero() || deadline.Before(a.session.deadline) {
+			a.session.deadline = deadline
+		}
+		a.session.mu.Unlock()
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timerC = timer.C
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer a.recoverPanic()
+		done <- a.activeCmd.callDoAction(a.session)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-a.deadlineExceeded:
+		return a.session.Err()
+	case <-timerC:
+		err := fmt.Errorf("%w: %s exceeded %s", ErrCommandTimeout, a.activeCmd.name, timeout)
+		a.session.Destroy(err)
+		return err
 	}
 }
 
@@ -943,25 +2047,99 @@ func (a *Application) registerAddonCommands() error {
 			if err := cmd.Err(); err != nil {
 				return err
 			}
+			cmd.setAddon(addon.info.Name)
 			a.AddCommand(cmd)
 			provided = true
 		}
+		for _, lazy := range addon.lazyCmds {
+			a.AddLazyCommand(lazy.name, addon.lazyCommandFactory(lazy.factory))
+			provided = true
+		}
 	}
 
 	if provided {
 		a.logger.SystemDebug("attached commands provided by addons")
 	}
 
+	return a.registerAddonFlags()
+}
+
+// registerAddonFlags adds global flags provided by addons to the root
+// command, flag names and aliases must not shadow flags of the
+// application or of other addons.
+func (a *Application) registerAddonFlags() error {
+	owners := make(map[string]string)
+	for _, f := range a.rootCmd.flags.Flags() {
+		for _, name := range flagNames(f) {
+			owners[name] = "application"
+		}
+	}
+	for _, addon := range a.addons {
+		for _, f := range addon.flags {
+			for _, name := range flagNames(f) {
+				if owner, exists := owners[name]; exists {
+					return fmt.Errorf(
+						"%w: flag --%s provided by addon %s conflicts with flag of %s",
+						ErrAddon, name, addon.info.Name, owner)
+				}
+				owners[name] = "addon " + addon.info.Name
+			}
+			a.rootCmd.AddFlag(f)
+			a.logger.SystemDebug(
+				"attached flag provided by addon",
+				slog.String("addon", addon.info.Name),
+				slog.String("flag", f.Name()),
+			)
+		}
+	}
 	return nil
 }
 
+// flagNames returns name and aliases of the flag.
+func flagNames(f varflag.Flag) []string {
+	return append([]string{f.Name()}, f.Aliases()...)
+}
+
 func (a *Application) registerAddons() error {
 	var provided bool
 
+	var (
+		appver            = version.Version(a.session.Get("app.version").String())
+		enabled, disabled []*Addon
+	)
 	for _, addon := range a.addons {
 		if len(addon.errs) > 0 {
 			return errors.Join(addon.errs...)
 		}
+		if addon.disabled {
+			disabled = append(disabled, addon)
+			continue
+		}
+		if err := addon.checkCompatibility(appver); err != nil {
+			return err
+		}
+		enabled = append(enabled, addon)
+	}
+	for _, addon := range enabled {
+		for _, dep := range addon.deps {
+			for _, d := range disabled {
+				if d.info.Name == dep.name {
+					return fmt.Errorf("%w: %s requires addon %s which is disabled", ErrAddon, addon.info.Name, dep.name)
+				}
+			}
+		}
+	}
+	addons, err := resolveAddons(enabled)
+	if err != nil {
+		return err
+	}
+	a.addons = append(addons, disabled...)
+	for _, addon := range disabled {
+		a.session.registerAddonHealth(addon)
+		a.logger.Debug("addon disabled", slog.String("addon", addon.info.Name))
+	}
+
+	for _, addon := range addons {
 		opts, err := NewOptions(addon.info.Name, addon.acceptsOpts)
 		if err != nil {
 			return err
@@ -1006,20 +2184,17 @@ func (a *Application) registerAddons() error {
 
 			key := strings.TrimPrefix(opt.key, addon.info.Name+".")
 			if !opts.Accepts(key) {
-				pendingOpts = append(pendingOpts, opt)
-				continue
+				return addon.unknownOptionError(opt.key)
 			}
-			globalkey := opt.key
-			opt.key = key
-			if err := opt.apply(opts); err != nil {
+			// save it to session first so that validation
+			// errors refer to the option with addon namespace
+			if err := a.session.Set(opt.key, opt.value); err != nil {
 				return err
 			}
-
-			// save it to session
-			if err := a.session.Set(globalkey, opt.value); err != nil {
+			opt.key = key
+			if err := opt.apply(opts); err != nil {
 				return err
 			}
-
 		}
 		if len(pendingOpts) != len(a.pendingOpts) {
 			a.pendingOpts = pendingOpts
@@ -1029,12 +2204,31 @@ func (a *Application) registerAddons() error {
 			return err
 		}
 
+		if !a.activeCmd.skipAddons {
+			if err := a.upgradeAddon(addon); err != nil {
+				return err
+			}
+		}
+
+		if addon.assets != nil {
+			if err := a.session.assets.Mount(addonAssetsDir+"/"+addon.info.Name, addon.assets); err != nil {
+				return err
+			}
+		}
+
+		for _, c := range addon.capabilities {
+			if err := a.session.registerCapability(c); err != nil {
+				return err
+			}
+		}
+
 		if addon.registerAction != nil && !a.activeCmd.skipAddons {
 			if err := addon.registerAction(a.session, opts); err != nil {
 				return err
 			}
 		}
 		provided = true
+		a.session.registerAddonHealth(addon)
 		a.logger.Debug(
 			"registered addon",
 			slog.Group("addon",
@@ -1055,6 +2249,12 @@ func (a *Application) registerAddons() error {
 			}
 		}
 
+		for _, schema := range addon.schemas {
+			if err := a.engine.registerEventSchema(schema); err != nil {
+				return err
+			}
+		}
+
 		if addon.API != nil {
 			if err := a.session.registerAPI(addon.info.Name, addon.API); err != nil {
 				return err
@@ -1074,6 +2274,17 @@ func (a *Application) registerInternalEvents() error {
 		registerEvent("services", "stop.services", "stops local or disconnects remote service defined in payload", nil),
 		registerEvent("services", "service.started", "triggered when service has been started", nil),
 		registerEvent("services", "service.stopped", "triggered when service has been stopped", nil),
+		registerEvent("engine", "stalled", "triggered when watchdog detects handler running longer than app.engine.watchdog", nil),
+		registerEvent("engine", "paused", "triggered when engine has been paused", nil),
+		registerEvent("engine", "resumed", "triggered when engine has been resumed", nil),
+		registerEvent("events", "dead.letter", "triggered when event listener failed to handle event", nil),
+		registerEvent("monitor", "alert", "triggered when alert rule starts firing or is resolved, see Monitor.AddAlertRule", nil),
+		registerEvent("config", "changed", "triggered for each option changed when config is reloaded on SIGHUP", nil),
+		registerEvent("runtime", "stats", "triggered with each runtime stats sample taken every app.stats.interval", nil),
+	}
+	if a.session.Get("app.instance.forward").Bool() {
+		sysevs = append(sysevs, registerEvent("app", "instance.args",
+			"triggered when instance launched while application was running forwarded its arguments, see InstanceArgs", nil))
 	}
 
 	for _, rev := range sysevs {
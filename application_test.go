@@ -5,8 +5,18 @@
 package happy
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/hlog/hlogtest"
 	"github.com/mkungla/happy/sdk/testutils"
 )
 
@@ -18,3 +28,320 @@ func TestAppDefaultInstance(t *testing.T) {
 	}
 	testutils.False(t, app.running)
 }
+
+func TestAppCommandTimeout(t *testing.T) {
+	app := New()
+	app.session = newTestSession(t)
+	app.session.done = make(chan struct{})
+
+	cmd := NewCommand("sync", Option("timeout", 20*time.Millisecond))
+	cmd.Do(func(sess *Session, args Args) error {
+		_, ok := sess.Deadline()
+		testutils.True(t, ok, "session should have deadline")
+		<-sess.Done()
+		return sess.Err()
+	})
+	app.AddCommand(cmd)
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{os.Args[0], "sync"}))
+	app.activeCmd = cmd
+
+	timeout, err := app.commandTimeout()
+	testutils.NoError(t, err)
+	testutils.Equal(t, 20*time.Millisecond, timeout)
+	testutils.ErrorIs(t, app.callDoActionWithTimeout(timeout), ErrCommandTimeout)
+	testutils.ErrorIs(t, app.session.Err(), ErrCommandTimeout)
+}
+
+func TestAppCommandTimeoutFlag(t *testing.T) {
+	app := New()
+	cmd := NewCommand("sync", Option("timeout", time.Minute))
+	cmd.Do(func(sess *Session, args Args) error { return nil })
+	app.AddCommand(cmd)
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{os.Args[0], "--timeout", "5s", "sync"}))
+	app.activeCmd = cmd
+
+	timeout, err := app.commandTimeout()
+	testutils.NoError(t, err)
+	testutils.Equal(t, 5*time.Second, timeout)
+	testutils.NoError(t, app.callDoActionWithTimeout(timeout))
+
+	app = New()
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{os.Args[0], "--timeout", "soon"}))
+	app.activeCmd = app.rootCmd
+	_, err = app.commandTimeout()
+	testutils.ErrorIs(t, err, ErrCommandFlags)
+}
+
+func TestAppLogScopes(t *testing.T) {
+	app := New(Option("log.scopes", "service=cache:debug"))
+	testutils.Equal(t, "service=cache:debug", app.session.logScopes.String())
+	testutils.True(t, app.session.Log().With("service", "cache").Enabled(hlog.LevelDebug), "cache should log debug")
+	testutils.False(t, app.session.Log().Enabled(hlog.LevelDebug), "root should not log debug")
+
+	testutils.NoError(t, app.session.Set("log.scopes", "service=db:debug"))
+	testutils.False(t, app.session.Log().With("service", "cache").Enabled(hlog.LevelDebug), "cache override removed")
+	testutils.Error(t, app.session.Set("log.scopes", "service=db"))
+}
+
+func TestAppRedactLog(t *testing.T) {
+	app := New(Option("log.redact", "*_dsn"))
+	var buf bytes.Buffer
+	app.logger = hlog.New(app.redactor.Handler(hlog.Config{}.NewHandler(&buf)))
+	app.RedactLog(regexp.MustCompile(`sk-[a-z0-9]+`))
+	app.logger.Info("key sk-abc123", "db_dsn", "postgres://u:p@h", "token", "t0k3n")
+	out := buf.String()
+	for _, leaked := range []string{"sk-abc123", "postgres://", "t0k3n"} {
+		testutils.False(t, strings.Contains(out, leaked), leaked+" leaked in "+out)
+	}
+}
+
+func TestAppSetLogCatalog(t *testing.T) {
+	app := New(Option("log.console", false), Option("log.lang", "et"))
+	rec := hlogtest.NewRecorder(t)
+	app.AddLogHandler(rec.Handler())
+	app.SetLogCatalog(hlog.MapCatalog{
+		"en": {"login": "user {user} logged in with {password}"},
+		"et": {"login": "kasutaja {user} logis sisse parooliga {password}"},
+	})
+	app.session.Log().Info("login", "user", "john", "password", "hunter2")
+	rec.AssertLogged(hlog.LevelInfo, "kasutaja john logis sisse parooliga "+hlog.RedactedValue)
+}
+
+func TestAppLogAsync(t *testing.T) {
+	app := New(Option("log.async", true), Option("log.console", false))
+	testutils.NotNil(t, app.logAsync, "async log handler should be configured")
+	flushed := false
+	app.session.logFlush = func() { flushed = true }
+	app.session.Destroy(nil)
+	testutils.True(t, flushed, "logs should be flushed when session is destroyed")
+	testutils.NoError(t, app.logAsync.Close())
+}
+
+func TestAppAddLogHandler(t *testing.T) {
+	app := New(Option("log.console", false))
+	rec := hlogtest.NewRecorder(t)
+	app.AddLogHandler(rec.Handler())
+	app.session.Log().Info("hello", "password", "hunter2")
+	rec.AssertLogged(hlog.LevelInfo, "hello", "password", hlog.RedactedValue)
+}
+
+func TestAppOnFirstRun(t *testing.T) {
+	dir := t.TempDir()
+	var calls int
+	fail := true
+	run := func() *Application {
+		app := New(Option("log.console", false), Option("app.fs.enabled", true))
+		testutils.NoError(t, app.session.opts.db.Store("app.path.config", dir))
+		app.activeCmd = app.rootCmd
+		app.OnFirstRun(func(sess *Session) error {
+			calls++
+			if fail {
+				return errors.New("setup failed")
+			}
+			return nil
+		})
+		testutils.NoError(t, app.load())
+		app.detectFirstRun()
+		return app
+	}
+
+	app := run()
+	testutils.True(t, app.firstRunPending, "first run should be pending without state")
+	testutils.Error(t, app.firstRun())
+	testutils.NoError(t, app.save())
+
+	fail = false
+	app = run()
+	testutils.True(t, app.firstRunPending, "failed first run should be retried")
+	testutils.NoError(t, app.firstRun())
+	testutils.NoError(t, app.save())
+
+	app = run()
+	testutils.False(t, app.firstRunPending, "first run completed")
+	testutils.NoError(t, app.firstRun())
+	testutils.Equal(t, 2, calls)
+}
+
+func TestAppBanner(t *testing.T) {
+	newApp := func(args ...string) *Application {
+		app := New(Option("log.console", false))
+		testutils.NoError(t, app.rootCmd.flags.Parse(append([]string{"app"}, args...)))
+		app.Banner(func(sess *Session) string {
+			return "happy " + sess.Get("app.version").String()
+		})
+		return app
+	}
+	var buf bytes.Buffer
+	app := newApp()
+	app.printBanner(&buf)
+	testutils.Equal(t, "happy "+app.session.Get("app.version").String()+"\n", buf.String())
+
+	buf.Reset()
+	newApp("--no-banner").printBanner(&buf)
+	testutils.Equal(t, "", buf.String())
+
+	buf.Reset()
+	app = newApp()
+	testutils.NoError(t, app.session.opts.set("app.banner", false, true))
+	app.printBanner(&buf)
+	testutils.Equal(t, "", buf.String())
+}
+
+func TestAppRun(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.Do(func(sess *Session, args Args) error {
+		if args.Arg(0).String() != "ok" {
+			return fmt.Errorf("unexpected arguments: %v", args.Args())
+		}
+		return nil
+	})
+	code, err := app.Run(context.Background(), []string{"ok"})
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+
+	code, err = app.Run(context.Background(), nil)
+	testutils.ErrorIs(t, err, ErrApplication, "second run")
+	testutils.Equal(t, 1, code)
+
+	fail := errors.New("failed")
+	app = New(Option("log.console", false))
+	app.Do(func(sess *Session, args Args) error {
+		return fail
+	})
+	code, err = app.Run(context.Background(), nil)
+	testutils.ErrorIs(t, err, fail)
+	testutils.Equal(t, 1, code)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	app = New(Option("log.console", false))
+	app.Do(func(sess *Session, args Args) error {
+		cancel()
+		<-sess.Done()
+		return sess.Err()
+	})
+	code, err = app.Run(ctx, nil)
+	testutils.ErrorIs(t, err, context.Canceled)
+	testutils.Equal(t, 1, code)
+}
+
+func TestAppRunFlushesLogs(t *testing.T) {
+	rec := hlogtest.NewRecorder(t)
+	app := New(
+		Option("log.console", false),
+		Option("log.async", true),
+		Option("log.level", "info"),
+	)
+	app.AddLogHandler(rec.Handler())
+	app.AddExitFunc(0, func(sess *Session, code int) error {
+		sess.Log().Info("exit func called")
+		return nil
+	})
+	app.Do(func(sess *Session, args Args) error {
+		return nil
+	})
+	code, err := app.Run(context.Background(), nil)
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+	rec.AssertLogged(hlog.LevelInfo, "exit func called")
+}
+
+func TestAppRunDeadline(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.deadline", 50*time.Millisecond))
+	app.Do(func(sess *Session, args Args) error {
+		deadline, ok := sess.Deadline()
+		testutils.True(t, ok, "session should have deadline")
+		testutils.False(t, deadline.IsZero(), "deadline should be set")
+		<-sess.Done()
+		return sess.Err()
+	})
+	code, err := app.Run(context.Background(), nil)
+	testutils.ErrorIs(t, err, ErrRunDeadline)
+	testutils.Equal(t, ExitCodeDeadline, code)
+
+	// Do action ignoring session is not waited for
+	release := make(chan struct{})
+	defer close(release)
+	app = New(Option("log.console", false), Option("app.deadline", 50*time.Millisecond))
+	app.Do(func(sess *Session, args Args) error {
+		<-release
+		return nil
+	})
+	code, err = app.Run(context.Background(), nil)
+	testutils.ErrorIs(t, err, ErrRunDeadline)
+	testutils.Equal(t, ExitCodeDeadline, code)
+}
+
+func TestExitCode(t *testing.T) {
+	testutils.Equal(t, 0, exitCode(nil))
+	testutils.Equal(t, 1, exitCode(errors.New("failed")))
+	testutils.Equal(t, ExitCodeTimeout, exitCode(fmt.Errorf("%w: do", ErrCommandTimeout)))
+	testutils.Equal(t, ExitCodeDeadline, exitCode(fmt.Errorf("%w: 1s", ErrRunDeadline)))
+}
+
+func TestAppIdentifiers(t *testing.T) {
+	dir := t.TempDir()
+	run := func() *Application {
+		app := New(Option("log.console", false), Option("app.fs.enabled", true))
+		testutils.NoError(t, app.session.opts.db.Store("app.path.config", dir))
+		app.activeCmd = app.rootCmd
+		testutils.NoError(t, app.load())
+		testutils.NoError(t, app.initializeIDs())
+		return app
+	}
+
+	first := run()
+	instanceID := first.session.Get("app.instance.id").String()
+	runID := first.session.Get("app.run.id").String()
+	testutils.Equal(t, 32, len(instanceID))
+	testutils.Equal(t, 32, len(runID))
+	testutils.True(t, first.instanceIDCreated, "instance id should be created")
+	testutils.NoError(t, first.save())
+
+	second := run()
+	testutils.False(t, second.instanceIDCreated, "instance id should be loaded")
+	testutils.Equal(t, instanceID, second.session.Get("app.instance.id").String())
+	testutils.NotEqual(t, runID, second.session.Get("app.run.id").String())
+
+	testutils.NoError(t, second.session.start())
+	defer second.session.Destroy(nil)
+	desc := second.session.Describe()
+	testutils.Equal(t, instanceID, desc.InstanceID)
+	testutils.Equal(t, second.session.Get("app.run.id").String(), desc.RunID)
+	testutils.Equal(t, 2, len(second.idAttrs()))
+}
+
+func TestAppLazyCommands(t *testing.T) {
+	newApp := func(created *[]string) *Application {
+		app := New(Option("log.console", false))
+		for _, name := range []string{"deploy", "status"} {
+			name := name
+			app.AddLazyCommand(name, func() *Command {
+				*created = append(*created, name)
+				cmd := NewCommand(name)
+				cmd.Do(func(sess *Session, args Args) error { return nil })
+				return cmd
+			})
+		}
+		return app
+	}
+
+	var created []string
+	code, err := newApp(&created).Run(context.Background(), []string{"status"})
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+	testutils.Equal(t, "status", strings.Join(created, ","))
+
+	created = nil
+	app := newApp(&created)
+	app.args = []string{"app", "--help"}
+	testutils.NoError(t, app.loadLazyCommands())
+	testutils.Equal(t, "deploy,status", strings.Join(created, ","))
+
+	created = nil
+	app = newApp(&created)
+	app.Do(func(sess *Session, args Args) error { return nil })
+	app.args = []string{"app", "docs", "markdown"}
+	app.rootCmd.AddSubCommand(docsCommand(app.rootCmd))
+	testutils.NoError(t, app.loadLazyCommands())
+	testutils.Equal(t, "deploy,status", strings.Join(created, ","))
+}
@@ -0,0 +1,284 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// addonAssetsDir is directory in application assets
+// under which assets of addons are mounted.
+const addonAssetsDir = "addons"
+
+// Assets is read-only file system of application assets, assets
+// provided by addons are mounted under addons/<addon> so that e.g.
+// templates of addon "mail" are read with
+//
+//	fs.ReadFile(sess.Assets(), "addons/mail/templates/welcome.html")
+//
+// Override directories are layered on top of application assets, files
+// in override directories shadow files with same name and directory
+// listings are merged, so that defaults shipped in the binary can be
+// customized locally.
+type Assets struct {
+	mu     sync.RWMutex
+	root   fs.FS
+	mounts map[string]fs.FS
+	// overrides in order they were added, last one has highest priority
+	overrides []fs.FS
+}
+
+func (a *Assets) addOverride(fsys fs.FS) {
+	a.mu.Lock()
+	a.overrides = append(a.overrides, fsys)
+	a.mu.Unlock()
+}
+
+// layers returns override and application assets file
+// systems in priority order.
+func (a *Assets) layers() []fs.FS {
+	layers := make([]fs.FS, 0, len(a.overrides)+1)
+	for i := len(a.overrides) - 1; i >= 0; i-- {
+		layers = append(layers, a.overrides[i])
+	}
+	if a.root != nil {
+		layers = append(layers, a.root)
+	}
+	return layers
+}
+
+// Mount mounts fsys to directory dir, directories of mounted
+// file systems shadow files of application assets.
+func (a *Assets) Mount(dir string, fsys fs.FS) error {
+	if !fs.ValidPath(dir) || dir == "." {
+		return fmt.Errorf("%w: invalid assets mount path %q", ErrApplication, dir)
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.mounts == nil {
+		a.mounts = make(map[string]fs.FS)
+	}
+	for mdir := range a.mounts {
+		if mdir == dir || strings.HasPrefix(dir, mdir+"/") || strings.HasPrefix(mdir, dir+"/") {
+			return fmt.Errorf("%w: assets mount %s overlaps with %s", ErrApplication, dir, mdir)
+		}
+	}
+	a.mounts[dir] = fsys
+	return nil
+}
+
+// Open opens named file from mounted file system or application assets.
+func (a *Assets) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	for dir, fsys := range a.mounts {
+		if name == dir {
+			f, err := fsys.Open(".")
+			if err != nil {
+				return nil, err
+			}
+			return &assetsMountRoot{File: f, name: path.Base(dir)}, nil
+		}
+		if rel, ok := strings.CutPrefix(name, dir+"/"); ok {
+			return fsys.Open(rel)
+		}
+	}
+	var dirs []fs.File
+	for _, layer := range a.layers() {
+		f, err := layer.Open(name)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			closeAll(dirs)
+			return nil, err
+		}
+		if isAssetsDir(f) {
+			dirs = append(dirs, f)
+			continue
+		}
+		// files of lower layers are shadowed by directories
+		if len(dirs) > 0 {
+			f.Close()
+			continue
+		}
+		return f, nil
+	}
+	entries := a.mountEntries(name)
+	if len(dirs) == 1 && entries == nil {
+		return dirs[0], nil
+	}
+	// parent directories of mounts exist even without application assets
+	if len(dirs) == 0 && entries == nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return a.mergeDirs(name, dirs, entries)
+}
+
+// mergeDirs merges listings of directory name in layers and mount
+// points under it, entries of higher priority layers shadow entries
+// of lower priority layers and mount points shadow both.
+func (a *Assets) mergeDirs(name string, dirs []fs.File, entries map[string]fs.DirEntry) (fs.File, error) {
+	defer closeAll(dirs)
+	if entries == nil {
+		entries = make(map[string]fs.DirEntry)
+	}
+	var info fs.FileInfo
+	for i, f := range dirs {
+		if i == 0 {
+			if stat, err := f.Stat(); err == nil {
+				info = stat
+			}
+		}
+		list, err := f.(fs.ReadDirFile).ReadDir(-1)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range list {
+			if _, exists := entries[e.Name()]; !exists {
+				entries[e.Name()] = e
+			}
+		}
+	}
+	return &assetsDir{name: name, entries: entries, info: info}, nil
+}
+
+func isAssetsDir(f fs.File) bool {
+	if _, ok := f.(fs.ReadDirFile); !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.IsDir()
+}
+
+func closeAll(files []fs.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
+
+// mountEntries returns directory entries of mount points which are
+// in directory name or nil when name is not parent of any mount point.
+func (a *Assets) mountEntries(name string) map[string]fs.DirEntry {
+	var entries map[string]fs.DirEntry
+	for dir := range a.mounts {
+		rel := dir
+		if name != "." {
+			var ok bool
+			if rel, ok = strings.CutPrefix(dir, name+"/"); !ok {
+				continue
+			}
+		}
+		child, _, _ := strings.Cut(rel, "/")
+		if entries == nil {
+			entries = make(map[string]fs.DirEntry)
+		}
+		entries[child] = assetsDirEntry(child)
+	}
+	return entries
+}
+
+// assetsDir is directory listing merged from application
+// assets and mount points.
+type assetsDir struct {
+	name    string
+	entries map[string]fs.DirEntry
+	// info is info of highest priority layer directory
+	info fs.FileInfo
+	list []fs.DirEntry
+	read bool
+}
+
+func (d *assetsDir) Stat() (fs.FileInfo, error) {
+	if d.info != nil {
+		return d.info, nil
+	}
+	return assetsDirEntry(path.Base(d.name)), nil
+}
+
+func (d *assetsDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+func (d *assetsDir) Close() error { return nil }
+
+func (d *assetsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		for _, e := range d.entries {
+			d.list = append(d.list, e)
+		}
+		sort.Slice(d.list, func(i, j int) bool {
+			return d.list[i].Name() < d.list[j].Name()
+		})
+		d.read = true
+	}
+	if n <= 0 {
+		list := d.list
+		d.list = nil
+		return list, nil
+	}
+	if len(d.list) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.list) {
+		n = len(d.list)
+	}
+	list := d.list[:n]
+	d.list = d.list[n:]
+	return list, nil
+}
+
+// assetsMountRoot is root directory of mounted file system
+// which is named as mount point instead of ".".
+type assetsMountRoot struct {
+	fs.File
+	name string
+}
+
+func (r *assetsMountRoot) Stat() (fs.FileInfo, error) {
+	info, err := r.File.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return assetsMountInfo{FileInfo: info, name: r.name}, nil
+}
+
+func (r *assetsMountRoot) ReadDir(n int) ([]fs.DirEntry, error) {
+	dir, ok := r.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: r.name, Err: errors.New("not implemented")}
+	}
+	return dir.ReadDir(n)
+}
+
+type assetsMountInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i assetsMountInfo) Name() string { return i.name }
+
+// assetsDirEntry is synthetic directory of mount point path.
+type assetsDirEntry string
+
+func (e assetsDirEntry) Name() string               { return string(e) }
+func (e assetsDirEntry) IsDir() bool                { return true }
+func (e assetsDirEntry) Type() fs.FileMode          { return fs.ModeDir }
+func (e assetsDirEntry) Info() (fs.FileInfo, error) { return e, nil }
+func (e assetsDirEntry) Size() int64                { return 0 }
+func (e assetsDirEntry) Mode() fs.FileMode          { return fs.ModeDir | 0555 }
+func (e assetsDirEntry) ModTime() time.Time         { return time.Time{} }
+func (e assetsDirEntry) Sys() any                   { return nil }
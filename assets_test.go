@@ -0,0 +1,90 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestAssets(t *testing.T) {
+	app := New()
+	app.activeCmd = app.rootCmd
+	app.WithAssets(fstest.MapFS{
+		"static/app.css": {Data: []byte("body{}")},
+	})
+	mail := NewAddon("mail")
+	mail.ProvidesAssets(fstest.MapFS{
+		"templates/welcome.html": {Data: []byte("<h1>welcome</h1>")},
+	})
+	app.WithAddons(mail)
+	testutils.NoError(t, app.registerAddons())
+
+	assets := app.session.Assets()
+	data, err := fs.ReadFile(assets, "addons/mail/templates/welcome.html")
+	testutils.NoError(t, err)
+	testutils.Equal(t, "<h1>welcome</h1>", string(data))
+	data, err = fs.ReadFile(assets, "static/app.css")
+	testutils.NoError(t, err)
+	testutils.Equal(t, "body{}", string(data))
+
+	entries, err := fs.ReadDir(assets, ".")
+	testutils.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	testutils.Equal(t, "addons,static", strings.Join(names, ","))
+
+	testutils.NoError(t, fstest.TestFS(assets, "static/app.css", "addons/mail/templates/welcome.html"))
+	testutils.ErrorIs(t, assets.Mount("addons/mail/extra", fstest.MapFS{}), ErrApplication)
+	_, err = assets.Open("addons/cache/x")
+	testutils.ErrorIs(t, err, fs.ErrNotExist)
+}
+
+func TestAssetsOverride(t *testing.T) {
+	low := t.TempDir()
+	high := t.TempDir()
+	testutils.NoError(t, os.MkdirAll(filepath.Join(low, "static"), 0700))
+	testutils.NoError(t, os.WriteFile(filepath.Join(low, "static", "app.css"), []byte("low"), 0600))
+	testutils.NoError(t, os.WriteFile(filepath.Join(low, "static", "extra.css"), []byte("extra"), 0600))
+	testutils.NoError(t, os.MkdirAll(filepath.Join(high, "static"), 0700))
+	testutils.NoError(t, os.WriteFile(filepath.Join(high, "static", "app.css"), []byte("high"), 0600))
+
+	app := New(Option("log.console", false))
+	app.WithAssets(fstest.MapFS{
+		"static/app.css":  {Data: []byte("embedded")},
+		"static/base.css": {Data: []byte("base")},
+		"README":          {Data: []byte("readme")},
+	})
+	app.WithAssetsOverride(low, high, filepath.Join(low, "missing"))
+
+	assets := app.session.Assets()
+	for name, want := range map[string]string{
+		"static/app.css":   "high",
+		"static/extra.css": "extra",
+		"static/base.css":  "base",
+		"README":           "readme",
+	} {
+		data, err := fs.ReadFile(assets, name)
+		testutils.NoError(t, err)
+		testutils.Equal(t, want, string(data))
+	}
+
+	entries, err := fs.ReadDir(assets, "static")
+	testutils.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	testutils.Equal(t, "app.css,base.css,extra.css", strings.Join(names, ","))
+	testutils.NoError(t, fstest.TestFS(assets, "README", "static/app.css", "static/base.css", "static/extra.css"))
+}
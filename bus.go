@@ -0,0 +1,269 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"fmt"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// MainEventBus is name of the default event bus. Services listen
+// on main event bus unless Service.ListenOnBus is used.
+const MainEventBus = "main"
+
+// eventBus is isolated event bus with its own queue and workers.
+// Events which scope is routed to the bus are delivered only to
+// services listening on that bus. Dispatched events are enqueued
+// on the bus directly, so busy bus does not delay the main event
+// loop and events of other buses.
+type eventBus struct {
+	name      string
+	workers   int
+	queueSize int
+	scopes    []string
+	pool      *eventPool
+
+	// jobs coalesced while bus queue was full
+	cmu       sync.Mutex
+	coalesced map[string]eventJob
+	ckeys     []string
+}
+
+// eventBridge forwards events matching pattern from one bus to another.
+type eventBridge struct {
+	from    string
+	to      string
+	pattern string
+}
+
+func (e *Engine) addEventBus(name string, workers, queueSize int, scopes ...string) error {
+	if name == "" || name == MainEventBus {
+		return fmt.Errorf("%w: invalid event bus name %q", ErrEngine, name)
+	}
+	if workers < 1 {
+		return fmt.Errorf("%w: event bus %s must have at least one worker", ErrEngine, name)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.buses[name]; ok {
+		return fmt.Errorf("%w: event bus %s already exists", ErrEngine, name)
+	}
+	for _, scope := range scopes {
+		if bus, ok := e.busScopes[scope]; ok {
+			return fmt.Errorf("%w: event scope %s already routed to bus %s", ErrEngine, scope, bus)
+		}
+	}
+	for _, scope := range scopes {
+		e.busScopes[scope] = name
+	}
+	e.buses[name] = &eventBus{
+		name:      name,
+		workers:   workers,
+		queueSize: queueSize,
+		scopes:    scopes,
+	}
+	return nil
+}
+
+func (e *Engine) bridgeEvents(from, to, pattern string) error {
+	if from == to {
+		return fmt.Errorf("%w: can not bridge event bus %s to itself", ErrEngine, from)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.bridges = append(e.bridges, eventBridge{
+		from:    from,
+		to:      to,
+		pattern: pattern,
+	})
+	return nil
+}
+
+// verifyEventBuses ensures that bridges and services refer to
+// existing event buses.
+func (e *Engine) verifyEventBuses() error {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	exists := func(name string) bool {
+		_, ok := e.buses[name]
+		return ok || name == MainEventBus
+	}
+	for _, bridge := range e.bridges {
+		if !exists(bridge.from) || !exists(bridge.to) {
+			return fmt.Errorf("%w: bridge %s -> %s refers to unknown event bus", ErrEngine, bridge.from, bridge.to)
+		}
+	}
+	for addr, svcc := range e.registry {
+		if !exists(svcc.svc.busName()) {
+			return fmt.Errorf("%w: service %s listens on unknown event bus %s", ErrEngine, addr, svcc.svc.busName())
+		}
+	}
+	return nil
+}
+
+func (e *Engine) startEventBuses(sess *Session) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	for _, bus := range e.buses {
+		bus.pool = newEventPool(e, bus.workers, bus.queueSize)
+		bus.pool.bus = bus
+		bus.pool.start(e.evContext, sess)
+		sess.Log().SystemDebug("event bus started", slog.String("bus", bus.name))
+	}
+}
+
+// ingressBus returns event bus which events of given scope are dispatched
+// to directly or nil when events of the scope go through main event loop.
+func (e *Engine) ingressBus(scope string) *eventBus {
+	if e.deterministic {
+		return nil
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	name, ok := e.busScopes[scope]
+	if !ok {
+		return nil
+	}
+	if bus := e.buses[name]; bus != nil && bus.pool != nil {
+		return bus
+	}
+	return nil
+}
+
+// enqueueOnBus enqueues job on the bus applying overflow policy of event
+// scope when bus queue is full, see app.events.overflow.scopes.
+func (e *Engine) enqueueOnBus(sess *Session, bus *eventBus, job eventJob) {
+	policy := sess.overflow.policy(job.ev.Scope())
+	if policy == OverflowBlock {
+		bus.pool.enqueueJob(e.evContext, job)
+		return
+	}
+	select {
+	case bus.pool.queue <- job:
+		return
+	default:
+	}
+	if policy == OverflowDrop {
+		sess.evstats.dropped.Add(1)
+		sess.Log().SystemDebug(
+			"event bus queue full - dropping event",
+			slog.String("bus", bus.name),
+			slog.String("scope", job.ev.Scope()),
+			slog.String("key", job.ev.Key()),
+		)
+		return
+	}
+	skey := job.ev.Scope() + "." + job.ev.Key()
+	bus.cmu.Lock()
+	if bus.coalesced == nil {
+		bus.coalesced = make(map[string]eventJob)
+	}
+	if _, ok := bus.coalesced[skey]; ok {
+		sess.evstats.coalesced.Add(1)
+	} else {
+		bus.ckeys = append(bus.ckeys, skey)
+	}
+	bus.coalesced[skey] = job
+	bus.cmu.Unlock()
+	// wake worker in case queue was drained meanwhile
+	select {
+	case bus.pool.queue <- eventJob{}:
+	default:
+	}
+}
+
+// takeCoalesced returns jobs coalesced while bus queue was full
+// in order they were first coalesced.
+func (b *eventBus) takeCoalesced() []eventJob {
+	b.cmu.Lock()
+	defer b.cmu.Unlock()
+	if len(b.ckeys) == 0 {
+		return nil
+	}
+	jobs := make([]eventJob, 0, len(b.ckeys))
+	for _, skey := range b.ckeys {
+		jobs = append(jobs, b.coalesced[skey])
+	}
+	b.ckeys = nil
+	b.coalesced = nil
+	return jobs
+}
+
+func (e *Engine) waitEventBuses() {
+	e.mu.RLock()
+	var pools []*eventPool
+	for _, bus := range e.buses {
+		if bus.pool != nil {
+			pools = append(pools, bus.pool)
+		}
+	}
+	// workers handling events take engine lock
+	e.mu.RUnlock()
+	for _, pool := range pools {
+		pool.wait()
+	}
+}
+
+// publishEvent delivers event on the bus its scope is routed to and
+// on buses it is bridged to. Event dispatched directly to ingress bus
+// is delivered by the worker of that bus handling it.
+func (e *Engine) publishEvent(sess *Session, ev Event, registry map[string]*serviceContainer, ingress *eventBus) {
+	e.mu.RLock()
+	if len(e.buses) == 0 {
+		e.mu.RUnlock()
+		e.publishOnBus(sess, nil, ev, registry)
+		return
+	}
+	name, ok := e.busScopes[ev.Scope()]
+	if !ok {
+		name = MainEventBus
+	}
+	targets := []string{name}
+	for _, bridge := range e.bridges {
+		if bridge.from == name && eventPatternMatch(bridge.pattern, ev.Scope(), ev.Key()) {
+			targets = append(targets, bridge.to)
+		}
+	}
+	buses := make([]*eventBus, len(targets))
+	for i, target := range targets {
+		buses[i] = e.buses[target]
+	}
+	e.mu.RUnlock()
+
+	for i, bus := range buses {
+		if ingress != nil && bus == ingress {
+			e.deliverEvent(sess, ev, busRegistry(registry, targets[i]))
+			continue
+		}
+		e.publishOnBus(sess, bus, ev, busRegistry(registry, targets[i]))
+	}
+}
+
+// publishOnBus delivers event on given bus, nil bus is main event bus.
+func (e *Engine) publishOnBus(sess *Session, bus *eventBus, ev Event, registry map[string]*serviceContainer) {
+	switch {
+	case e.deterministic:
+		e.deliverEvent(sess, ev, registry)
+	case bus != nil && bus.pool != nil:
+		e.enqueueOnBus(sess, bus, eventJob{ev: ev, registry: registry})
+	case e.evPool != nil:
+		e.evPool.enqueue(e.evContext, ev, registry)
+	default:
+		go e.deliverEvent(sess, ev, registry)
+	}
+}
+
+// busRegistry returns services listening on given bus.
+func busRegistry(registry map[string]*serviceContainer, bus string) map[string]*serviceContainer {
+	services := make(map[string]*serviceContainer)
+	for addr, svcc := range registry {
+		if svcc.svc.busName() == bus {
+			services[addr] = svcc
+		}
+	}
+	return services
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEngineEventBuses(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	testutils.Error(t, e.addEventBus(MainEventBus, 1, 10))
+	testutils.NoError(t, e.addEventBus("ui", 1, 10, "ui"))
+	testutils.Error(t, e.addEventBus("ui", 1, 10))
+	testutils.Error(t, e.addEventBus("other", 1, 10, "ui"))
+	testutils.NoError(t, e.bridgeEvents("ui", MainEventBus, "ui.closed"))
+
+	received := make(chan string, 10)
+	registry := make(map[string]*serviceContainer)
+	for _, name := range []string{"control", "render"} {
+		name := name
+		svc := NewService(name)
+		if name == "render" {
+			svc.ListenOnBus("ui")
+		}
+		svc.OnAnyEvent(func(sess *Session, ev Event) error {
+			received <- name + ":" + ev.Key()
+			return nil
+		})
+		addr, err := address.Parse("happy://host/app/service/" + name)
+		testutils.NoError(t, err)
+		registry[addr.String()] = svc.container(sess, addr)
+	}
+	e.registry = registry
+	testutils.NoError(t, e.verifyEventBuses())
+
+	e.publishEvent(sess, NewEvent("ui", "clicked", nil, nil), registry, nil)
+	e.publishEvent(sess, NewEvent("ui", "closed", nil, nil), registry, nil)
+	e.publishEvent(sess, NewEvent("app", "started", nil, nil), registry, nil)
+
+	var got []string
+	for i := 0; i < 4; i++ {
+		got = append(got, <-received)
+	}
+	sort.Strings(got)
+	testutils.EqualAny(t, []string{
+		"control:closed",
+		"control:started",
+		"render:clicked",
+		"render:closed",
+	}, got)
+
+	testutils.NoError(t, e.bridgeEvents("missing", MainEventBus, "*.*"))
+	testutils.Error(t, e.verifyEventBuses())
+}
+
+func TestEngineEventBusIsolation(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	sess.engine = e
+	testutils.NoError(t, e.addEventBus("ui", 1, 1, "ui"))
+	e.events["ui.render"] = registerEvent("ui", "render", "", new(vars.Map))
+	e.events["app.control"] = registerEvent("app", "control", "", new(vars.Map))
+
+	rendering := make(chan struct{}, 10)
+	release := make(chan struct{})
+	control := make(chan struct{}, 1)
+	render := NewService("render")
+	render.ListenOnBus("ui")
+	render.OnEvent("ui", "render", func(sess *Session, ev Event) error {
+		rendering <- struct{}{}
+		<-release
+		return nil
+	})
+	ctrl := NewService("control")
+	ctrl.OnEvent("app", "control", func(sess *Session, ev Event) error {
+		control <- struct{}{}
+		return nil
+	})
+	for _, svc := range []*Service{render, ctrl} {
+		addr, err := address.Parse("happy://host/app/service/" + svc.name)
+		testutils.NoError(t, err)
+		e.registry[addr.String()] = svc.container(sess, addr)
+	}
+
+	e.startEventDispatcher(sess)
+	defer func() {
+		close(release)
+		e.evCancel()
+		e.waitEventBuses()
+	}()
+
+	// saturate ui bus, producer blocks on full bus queue
+	go func() {
+		for i := 0; i < 5; i++ {
+			sess.Dispatch(NewEvent("ui", "render", nil, nil))
+		}
+	}()
+	select {
+	case <-rendering:
+	case <-time.After(time.Second):
+		t.Fatal("ui event was not delivered")
+	}
+
+	sess.Dispatch(NewEvent("app", "control", nil, nil))
+	select {
+	case <-control:
+	case <-time.After(time.Second):
+		t.Fatal("saturated ui bus delayed main bus delivery")
+	}
+}
@@ -9,11 +9,11 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 	"time"
 
-	"github.com/mkungla/happy/pkg/hlog"
 	"github.com/mkungla/happy/pkg/varflag"
 )
 
@@ -68,6 +68,8 @@ func (a *Application) clihelp() error {
   {{ .Description }}{{end}}
   `)
 
+	theme, color := a.session.Theme(), a.session.Color()
+	view.banner.setTheme(theme, color)
 	if err := view.printBanner(); err != nil {
 		return err
 	}
@@ -79,11 +81,29 @@ func (a *Application) clihelp() error {
 			Commands: a.rootCmd.subCommands,
 			Flags:    a.rootCmd.flags.Flags(),
 		}
+		help.setTheme(theme, color)
+		help.setTranslator(a.session.T)
+		help.setTemplate(helpGlobalTmpl)
+		if a.rootCmd.helpTmpl != "" {
+			help.setTemplate(a.rootCmd.helpTmpl)
+		}
 		if err := help.print(); err != nil {
 			return err
 		}
 	} else {
 		helpCmd := helpCommand{}
+		helpCmd.setTheme(theme, color)
+		helpCmd.setTranslator(a.session.T)
+		helpCmd.setTemplate(helpCommandTmpl)
+		if a.helpCmdTmpl != "" {
+			helpCmd.setTemplate(a.helpCmdTmpl)
+		}
+		for cmd := a.activeCmd; cmd != nil && cmd != a.rootCmd; cmd = cmd.parent {
+			if cmd.helpTmpl != "" {
+				helpCmd.setTemplate(cmd.helpTmpl)
+				break
+			}
+		}
 		if err := helpCmd.print(a.session, a.activeCmd); err != nil {
 			return err
 		}
@@ -95,15 +115,7 @@ func (h *view) printBanner() error {
 	if err := h.banner.parseTmpl("header-tmpl", h.Info, 0); err != nil {
 		return err
 	}
-	fmt.Fprintln(
-		os.Stdout,
-		hlog.Colorize(
-			h.banner.buffer.String(),
-			hlog.FgYellow,
-			0,
-			0,
-		),
-	)
+	fmt.Fprintln(os.Stdout, h.banner.theme.Banner.Render(h.banner.buffer.String(), h.banner.color))
 	return nil
 }
 
@@ -112,6 +124,21 @@ type cliTmplParser struct {
 	tmpl   string
 	buffer bytes.Buffer
 	t      *template.Template
+	theme  Theme
+	color  bool
+	// translate translates message keys, see Session.T
+	translate func(key string, args ...any) string
+}
+
+// setTheme sets theme used to style template output.
+func (t *cliTmplParser) setTheme(theme Theme, color bool) {
+	t.theme = theme
+	t.color = color
+}
+
+// setTranslator sets func translating texts of template.
+func (t *cliTmplParser) setTranslator(translate func(key string, args ...any) string) {
+	t.translate = translate
 }
 
 // SetTemplate sets template to be parsed.
@@ -124,7 +151,21 @@ func (t *cliTmplParser) setTemplate(tmpl string) {
 // and elapsed is time duration used by specific type of templates and can usually set to "0".
 func (t *cliTmplParser) parseTmpl(name string, h interface{}, elapsed time.Duration) error {
 	t.t = template.New(name)
-	t.t.Funcs(template.FuncMap{
+	t.t.Funcs(t.funcs(elapsed))
+	tmpl, err := t.t.Parse(t.tmpl)
+	if err != nil {
+		return err
+	}
+	err = tmpl.Execute(&t.buffer, h)
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (t *cliTmplParser) funcs(elapsed time.Duration) template.FuncMap {
+	return template.FuncMap{
+		"funcText":        t.text,
 		"funcTextBold":    t.textBold,
 		"funcCmdCategory": t.cmdCategory,
 		"funcCmdName":     t.cmdName,
@@ -132,14 +173,15 @@ func (t *cliTmplParser) parseTmpl(name string, h interface{}, elapsed time.Durat
 		"funcDate":        t.dateOnly,
 		"funcYear":        t.year,
 		"funcElapsed":     func() string { return elapsed.String() },
-	})
-	tmpl, err := t.t.Parse(t.tmpl)
-	if err != nil {
-		return err
 	}
-	err = tmpl.Execute(&t.buffer, h)
-	if err != nil {
-		return err
+}
+
+// validateHelpTemplate checks that custom help template parses
+// with help template funcs.
+func validateHelpTemplate(tmpl string) error {
+	t := &cliTmplParser{}
+	if _, err := template.New("help").Funcs(t.funcs(0)).Parse(tmpl); err != nil {
+		return fmt.Errorf("%w: invalid help template: %s", ErrCommand, err.Error())
 	}
 	return nil
 }
@@ -148,14 +190,14 @@ func (t *cliTmplParser) cmdCategory(s string) string {
 	if s == "" {
 		return s
 	}
-	return strings.ToUpper(s)
+	return t.theme.Category.Render(strings.ToUpper(s), t.color)
 }
 
 func (t *cliTmplParser) cmdName(s string) string {
 	if s == "" {
 		return s
 	}
-	return fmt.Sprintf("\033[1m %-20s\033[0m", s)
+	return t.theme.Command.Render(fmt.Sprintf(" %-20s", s), t.color)
 }
 
 func (t *cliTmplParser) flagName(s string, a string) string {
@@ -165,14 +207,22 @@ func (t *cliTmplParser) flagName(s string, a string) string {
 	if len(a) > 0 {
 		s += ", " + a
 	}
-	return fmt.Sprintf("%-25s", s)
+	return t.theme.Flag.Render(fmt.Sprintf("%-25s", s), t.color)
+}
+
+// text returns message key translated to language of the session.
+func (t *cliTmplParser) text(key string) string {
+	if t.translate == nil {
+		return (&translations{}).translate(key)
+	}
+	return t.translate(key)
 }
 
 func (t *cliTmplParser) textBold(s string) string {
 	if s == "" {
 		return s
 	}
-	return fmt.Sprintf("\033[1m%s\033[0m", s)
+	return t.theme.Title.Render(s, t.color)
 }
 
 func (t *cliTmplParser) dateOnly(ts time.Time) string {
@@ -185,31 +235,22 @@ func (t *cliTmplParser) year() int {
 }
 
 // HelpGlobal used to show help for application.
+// Custom global help template set with Application.SetHelpTemplate
+// is executed with helpGlobal as data.
 type helpGlobal struct {
 	cliTmplParser
-	Name                string
-	Commands            map[string]*Command
-	Flags               []varflag.Flag
-	PrimaryCommands     []*Command
-	CommandsCategorized map[string][]*Command
+	Name            string
+	Commands        map[string]*Command
+	Flags           []varflag.Flag
+	PrimaryCommands []command
+	Categories      []commandCategory
 }
 
 // Print application help.
 func (h *helpGlobal) print() error {
 	h.Name = filepath.Base(os.Args[0])
-	h.setTemplate(helpGlobalTmpl)
-
-	for _, cmd := range h.Commands {
-		cat := cmd.category
-		if cat == "" {
-			h.PrimaryCommands = append(h.PrimaryCommands, cmd)
-		} else {
-			if h.CommandsCategorized == nil {
-				h.CommandsCategorized = make(map[string][]*Command)
-			}
-			h.CommandsCategorized[cat] = append(h.CommandsCategorized[cat], cmd)
-		}
-	}
+
+	h.PrimaryCommands, h.Categories = categorizeCommands(h.Commands)
 	err := h.parseTmpl("help-global-tmpl", h, time.Duration(0))
 	if err != nil {
 		fmt.Println(err)
@@ -219,21 +260,72 @@ func (h *helpGlobal) print() error {
 	return nil
 }
 
-// HelpCommand is used to display help for command.
+// commandCategory is named section of commands in help output.
+type commandCategory struct {
+	Name     string
+	Commands []command
+}
+
+// categorizeCommands groups visible commands by category, commands without
+// category are returned as primary commands. Categories and commands
+// are sorted by name.
+func categorizeCommands(cmds map[string]*Command) (primary []command, categories []commandCategory) {
+	names := make([]string, 0, len(cmds))
+	for name := range cmds {
+		names = append(names, name)
+	}
+	sort.Strings(names)
 
+	index := make(map[string]int)
+	for _, name := range names {
+		cmd := cmds[name]
+		if cmd.hidden {
+			continue
+		}
+		c := command{
+			Name:        cmd.name,
+			Usage:       cmd.usage,
+			Flags:       cmd.flags,
+			Description: cmd.desc,
+		}
+		if cmd.category == "" {
+			primary = append(primary, c)
+			continue
+		}
+		i, ok := index[cmd.category]
+		if !ok {
+			i = len(categories)
+			index[cmd.category] = i
+			categories = append(categories, commandCategory{Name: cmd.category})
+		}
+		categories[i].Commands = append(categories[i].Commands, c)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	return primary, categories
+}
+
+// HelpCommand is used to display help for command.
+// Custom command help template set with Command.SetHelpTemplate
+// or Application.SetHelpTemplate is executed with helpCommand as data.
 type helpCommand struct {
 	cliTmplParser
-	Command *command
-	Usage   string
-	Flags   []varflag.Flag
+	Command  *command
+	Usage    string
+	Flags    []varflag.Flag
+	Args     []ArgDef
+	Examples []CommandExample
 }
 
 type command struct {
 	Name        string
 	Usage       string
 	SubCommands []command
+	Categories  []commandCategory
 	Flags       varflag.Flags
 	Description string
+	Deprecated  string
 }
 
 func (h *helpCommand) print(sess *Session, cmd *Command) error {
@@ -245,7 +337,9 @@ func (h *helpCommand) print(sess *Session, cmd *Command) error {
 		Flags:       cmd.flags,
 		Description: cmd.Description(),
 	}
-	h.setTemplate(helpCommandTmpl)
+	if cmd.deprecated {
+		h.Command.Deprecated = cmd.deprecationNotice()
+	}
 	usage := []string{""}
 	// usage := []string{filepath.Base(os.Args[0])}
 	usage = append(usage, cmd.parents...)
@@ -256,21 +350,21 @@ func (h *helpCommand) print(sess *Session, cmd *Command) error {
 
 	if cmd.subCommands != nil {
 		usage = append(usage, "[subcommands]")
-		for _, subcmd := range cmd.subCommands {
-			subCmd := command{
-				Name:        subcmd.name,
-				Usage:       subcmd.Usage(),
-				Flags:       subcmd.flags,
-				Description: subcmd.desc,
-			}
-			h.Command.SubCommands = append(h.Command.SubCommands, subCmd)
-		}
+		h.Command.SubCommands, h.Command.Categories = categorizeCommands(cmd.subCommands)
 	}
 
-	if cmd.flags.AcceptsArgs() {
+	if len(cmd.argdefs) > 0 {
+		usage = append(usage, cmd.argsUsage())
+		h.Args = cmd.argdefs
+	} else if !cmd.argsn.any() {
+		if argsUsage := cmd.argsUsage(); argsUsage != "" {
+			usage = append(usage, argsUsage)
+		}
+	} else if cmd.flags.AcceptsArgs() {
 		usage = append(usage, "[args]")
 	}
 	h.Usage = strings.Join(usage, " ")
+	h.Examples = cmd.examples
 	h.Flags = append(h.Flags, cmd.flags.Flags()...)
 	if cmd.parent != nil {
 		h.Flags = append(h.Flags, cmd.parent.flags.Flags()...)
@@ -286,39 +380,54 @@ func (h *helpCommand) print(sess *Session, cmd *Command) error {
 
 var (
 	helpGlobalTmpl = `
- USAGE:
+ {{ funcText "help.usage" }}:
   {{ .Name }} command
   {{ .Name }} command [command-flags] [arguments]
   {{ .Name }} [global-flags] command [command-flags] [arguments]
   {{ .Name }} [global-flags] command ...subcommand [command-flags] [arguments]
 
- COMMANDS:{{ if .PrimaryCommands }}
+ {{ funcText "help.commands" }}:{{ if .PrimaryCommands }}
  {{ range $cmd := .PrimaryCommands }}
  {{ $cmd.Name | funcCmdName }}{{ $cmd.Usage }}{{ end }}{{ end }}
-{{ if .CommandsCategorized }}{{ range $cat, $cmds := .CommandsCategorized }}
- {{ $cat | funcCmdCategory }}{{ range $cmd := $cmds }}
+{{ range $cat := .Categories }}
+ {{ $cat.Name | funcCmdCategory }}{{ range $cmd := $cat.Commands }}
  {{$cmd.Name | funcCmdName }}{{ $cmd.Usage }}{{ end }}
- {{ end }}{{ end }}
+ {{ end }}
 
- GLOBAL FLAGS:{{ if .Flags }}{{ range $flag := .Flags }}{{ if not .Hidden }}
+ {{ funcText "help.global.flags" }}:{{ if .Flags }}{{ range $flag := .Flags }}{{ if not .Hidden }}
  {{funcFlagName $flag.Flag $flag.UsageAliases }} {{ $flag.Usage }}{{ end }}{{ end }}{{ end }}
 `
 
-	helpCommandTmpl = `  COMMAND: {{.Command.Name }}
+	helpCommandTmpl = `  {{ funcText "help.command" }}: {{.Command.Name }}
+  {{ if .Command.Deprecated }}
+  {{ funcText "help.deprecated" }}: {{ .Command.Deprecated }}
+  {{ end }}
   {{ if gt (len .Command.Usage) 0 }}
   {{funcTextBold .Command.Usage}}
   {{ end }}
   {{ if gt (len .Command.Description) 0 }}
   {{.Command.Description}}
   {{ end }}
-  USAGE:
+  {{ funcText "help.usage" }}:
   {{ funcTextBold .Usage }}
 {{ if .Command.SubCommands }}
- {{ print "Subcommands" | funcCmdCategory }}
+ {{ funcText "help.subcommands" | funcCmdCategory }}
 {{ range $cmd := .Command.SubCommands }}
 {{ $cmd.Name | funcCmdName }}{{ $cmd.Usage }}{{ end }}
+{{ end }}{{ range $cat := .Command.Categories }}
+ {{ $cat.Name | funcCmdCategory }}
+{{ range $cmd := $cat.Commands }}
+{{ $cmd.Name | funcCmdName }}{{ $cmd.Usage }}{{ end }}
+{{ end }}{{ if .Args }} {{ funcText "help.arguments" }}:
+{{ range $arg := .Args }}
+ {{ funcFlagName $arg.String "" }} {{ $arg.Usage }}{{ end }}
+{{ end }}
+{{ if .Examples }} {{ funcText "help.examples" }}:
+{{ range $ex := .Examples }}{{ if $ex.Description }}
+  # {{ $ex.Description }}{{ end }}
+  {{ $ex.Cmdline }}
 {{ end }}
-{{ if gt .Command.Flags.Len 0 }} Accepts following flags:
+{{ end }}{{ if gt .Command.Flags.Len 0 }} {{ funcText "help.flags" }}:
 {{ range $flag := .Flags }}{{ if not .Hidden }}
  {{funcFlagName $flag.Flag $flag.UsageAliases }} {{ $flag.Usage }}{{ end }}{{ end }}{{ end }}`
 )
@@ -7,7 +7,11 @@ package happy
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/mkungla/happy/pkg/varflag"
 	"github.com/mkungla/happy/pkg/vars"
@@ -24,6 +28,12 @@ type Command struct {
 	flags       varflag.Flags
 	parent      *Command
 	subCommands map[string]*Command
+	// lazyCommands are factories of subcommands which are created
+	// only when they are dispatched or full command tree is needed
+	lazyCommands map[string]func() *Command
+	// walksTree is set for commands which need full command tree
+	// e.g. docs and completion, all lazy commands are created for them
+	walksTree bool
 
 	beforeAction       ActionWithArgs
 	doAction           ActionWithArgs
@@ -34,10 +44,128 @@ type Command struct {
 	isWrapperCommand    bool
 	allowOnFreshInstall bool
 	skipAddons          bool
+	hidden              bool
 
 	errs []error
 
 	parents []string
+	argdefs []ArgDef
+	argsn   argsRange
+
+	helpTmpl string
+
+	// raw arguments after "--" of executed command
+	rawArgs []string
+
+	examples []CommandExample
+
+	flagCompleters map[string]CompletionFunc
+	argsCompleter  CompletionFunc
+
+	deprecated  bool
+	replacement string
+
+	// addon is name of addon providing the command, actions of
+	// the command can only set options in namespace of the addon
+	addon string
+
+	// timeout is default time limit of Do action
+	timeout time.Duration
+}
+
+// CommandExample is usage example of command shown in help and docs.
+type CommandExample struct {
+	Description string
+	Cmdline     string
+}
+
+// ArgDef declares named positional argument of command.
+type ArgDef struct {
+	Name  string
+	Usage string
+	// Kind values are parsed as, KindInvalid accepts any value as string.
+	Kind vars.Kind
+	// Min is number of values required and Max number of values
+	// accepted for argument, Max -1 accepts any number of values.
+	Min int
+	Max int
+}
+
+func (d ArgDef) String() string {
+	name := "<" + d.Name + ">"
+	if d.Max < 0 || d.Max > 1 {
+		name += "..."
+	}
+	if d.Min == 0 {
+		name = "[" + name + "]"
+	}
+	return name
+}
+
+// argsRange is number of arguments accepted by command,
+// Max -1 accepts any number of arguments.
+type argsRange struct {
+	Min int
+	Max int
+}
+
+// parseArgsRange parses args option spec: "2" exactly two arguments,
+// "1..3" one to three, "1.." at least one and "..2" at most two.
+// Empty spec accepts any number of arguments.
+func parseArgsRange(spec string) (argsRange, error) {
+	r := argsRange{Min: 0, Max: -1}
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return r, nil
+	}
+	invalid := fmt.Errorf("%w: invalid args spec %q, expected N, N..M, N.. or ..M", ErrCommandArgs, spec)
+	minstr, maxstr, isrange := strings.Cut(spec, "..")
+	if !isrange {
+		maxstr = minstr
+	}
+	var err error
+	if minstr != "" {
+		if r.Min, err = strconv.Atoi(minstr); err != nil || r.Min < 0 {
+			return r, invalid
+		}
+	}
+	if maxstr != "" {
+		if r.Max, err = strconv.Atoi(maxstr); err != nil || r.Max < r.Min {
+			return r, invalid
+		}
+	}
+	if minstr == "" && maxstr == "" {
+		return r, invalid
+	}
+	return r, nil
+}
+
+func (r argsRange) any() bool {
+	return r.Min == 0 && r.Max < 0
+}
+
+func (r argsRange) accepts(n int) bool {
+	return n >= r.Min && (r.Max < 0 || n <= r.Max)
+}
+
+func (r argsRange) String() string {
+	plural := func(n int) string {
+		if n == 1 {
+			return "1 argument"
+		}
+		return strconv.Itoa(n) + " arguments"
+	}
+	switch {
+	case r.Min == r.Max && r.Min == 0:
+		return "no arguments"
+	case r.Min == r.Max:
+		return "exactly " + plural(r.Min)
+	case r.Max < 0:
+		return "at least " + plural(r.Min)
+	case r.Min == 0:
+		return "at most " + plural(r.Max)
+	}
+	return fmt.Sprintf("%d to %s", r.Min, plural(r.Max))
 }
 
 func NewCommand(name string, options ...OptionArg) *Command {
@@ -68,6 +196,13 @@ func NewCommand(name string, options ...OptionArg) *Command {
 	c.category = opts.Get("category").String()
 	c.allowOnFreshInstall = opts.Get("allow.on.fresh.install").Bool()
 	c.skipAddons = opts.Get("skip.addons").Bool()
+	c.hidden = opts.Get("hidden").Bool()
+	c.timeout = time.Duration(opts.Get("timeout").Int64())
+	if argsn, err := parseArgsRange(opts.Get("args").String()); err != nil {
+		c.errs = append(c.errs, err)
+	} else {
+		c.argsn = argsn
+	}
 
 	return c
 }
@@ -96,6 +231,55 @@ func (c *Command) Description() string {
 	return c.desc
 }
 
+// Hidden reports whether command is excluded from help, completion and docs.
+func (c *Command) Hidden() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hidden
+}
+
+// Deprecated reports whether command is deprecated.
+func (c *Command) Deprecated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deprecated
+}
+
+// Deprecate marks command deprecated. Deprecated command keeps working
+// but it is hidden from help, completion and docs and warning pointing
+// to replacement command (e.g. "service start") is logged when it is used.
+// Empty replacement notes that command will be removed.
+func (c *Command) Deprecate(replacement string) {
+	if !c.tryLock("Deprecate") {
+		return
+	}
+	defer c.mu.Unlock()
+	c.deprecated = true
+	c.hidden = true
+	c.replacement = strings.TrimSpace(replacement)
+}
+
+// deprecationNotice returns warning shown when deprecated command is used.
+func (c *Command) deprecationNotice() string {
+	if c.replacement == "" {
+		return fmt.Sprintf("command %s is deprecated and will be removed", c.name)
+	}
+	return fmt.Sprintf("command %s is deprecated, use %s instead", c.name, c.replacement)
+}
+
+// warnDeprecated logs deprecation warning for command and its
+// deprecated parent commands.
+func (c *Command) warnDeprecated(session *Session) {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		if cmd.deprecated {
+			session.Log().Deprecated(cmd.deprecationNotice(),
+				slog.String("command", cmd.name),
+				slog.String("replacement", cmd.replacement),
+			)
+		}
+	}
+}
+
 func (c *Command) tryLock(method string) bool {
 	if !c.mu.TryLock() {
 		slog.Warn(
@@ -120,6 +304,97 @@ func (c *Command) AddFlag(f varflag.Flag) {
 	}
 }
 
+// AddArg declares positional argument which is validated before
+// Before and Do actions and accessible with Args.Named.
+// Only last argument can accept variable number of values.
+func (c *Command) AddArg(def ArgDef) {
+	if !c.tryLock("AddArg") {
+		return
+	}
+	defer c.mu.Unlock()
+
+	name, err := vars.ParseKey(def.Name)
+	if err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%w: invalid argument name %q", ErrCommand, def.Name))
+		return
+	}
+	def.Name = name
+	if def.Min < 0 || (def.Max >= 0 && def.Max < def.Min) || def.Max == 0 {
+		c.errs = append(c.errs, fmt.Errorf("%w: invalid arity %d..%d for argument %s", ErrCommand, def.Min, def.Max, def.Name))
+		return
+	}
+	for _, prev := range c.argdefs {
+		if prev.Name == def.Name {
+			c.errs = append(c.errs, fmt.Errorf("%w: argument %s already declared", ErrCommand, def.Name))
+			return
+		}
+		if prev.Min != prev.Max {
+			c.errs = append(c.errs, fmt.Errorf("%w: argument %s can not follow optional or variadic argument %s", ErrCommand, def.Name, prev.Name))
+			return
+		}
+	}
+	c.argdefs = append(c.argdefs, def)
+}
+
+// CompleteFlag registers dynamic completion of flag values
+// used by shell completion scripts.
+func (c *Command) CompleteFlag(name string, fn CompletionFunc) {
+	if !c.tryLock("CompleteFlag") {
+		return
+	}
+	defer c.mu.Unlock()
+	if _, err := c.flags.Get(name); err != nil {
+		c.errs = append(c.errs, fmt.Errorf("%w: completion for unknown flag %s", ErrCommandFlags, name))
+		return
+	}
+	if c.flagCompleters == nil {
+		c.flagCompleters = make(map[string]CompletionFunc)
+	}
+	c.flagCompleters[name] = fn
+}
+
+// CompleteArgs registers dynamic completion of positional
+// arguments used by shell completion scripts.
+func (c *Command) CompleteArgs(fn CompletionFunc) {
+	if !c.tryLock("CompleteArgs") {
+		return
+	}
+	defer c.mu.Unlock()
+	c.argsCompleter = fn
+}
+
+// AddExample adds usage example shown in help and generated docs.
+func (c *Command) AddExample(description, cmdline string) {
+	if !c.tryLock("AddExample") {
+		return
+	}
+	defer c.mu.Unlock()
+	if cmdline == "" {
+		c.errs = append(c.errs, fmt.Errorf("%w: empty example for %s", ErrCommand, c.name))
+		return
+	}
+	c.examples = append(c.examples, CommandExample{Description: description, Cmdline: cmdline})
+}
+
+// SetHelpTemplate overrides help template of command and its sub commands.
+// Template is executed with same data and funcs as default template
+// e.g. {{ .Command.Name }}, {{ .Usage }}, {{ .Flags }} and {{ .Args }}.
+func (c *Command) SetHelpTemplate(tmpl string) {
+	if !c.tryLock("SetHelpTemplate") {
+		return
+	}
+	defer c.mu.Unlock()
+	if err := validateHelpTemplate(tmpl); err != nil {
+		c.errs = append(c.errs, err)
+		return
+	}
+	c.helpTmpl = tmpl
+}
+
+// Before sets action called before Do action. Before actions form a chain:
+// when command is executed Before of root command is called first, then
+// Before of each parent command and last Before of executed command.
+// First error stops the chain and Do action is not called.
 func (c *Command) Before(action ActionWithArgs) {
 	if !c.tryLock("Before") {
 		return
@@ -198,9 +473,92 @@ func (c *Command) AddSubCommand(cmd *Command) {
 		))
 		return
 	}
+	cmd.parent = c
 	c.subCommands[cmd.name] = cmd
 }
 
+// AddLazySubCommand registers subcommand name created by factory only when
+// it is dispatched or when full command tree is needed e.g. for help, docs
+// or completion, so that applications with many commands start fast.
+// Factory must return command with given name.
+func (c *Command) AddLazySubCommand(name string, factory func() *Command) {
+	if !c.tryLock("AddLazySubCommand") {
+		return
+	}
+	defer c.mu.Unlock()
+	if factory == nil {
+		c.errs = append(c.errs, fmt.Errorf("%w: <nil> factory for lazy command %s", ErrCommand, name))
+		return
+	}
+	if _, exists := c.subCommands[name]; exists {
+		c.errs = append(c.errs, fmt.Errorf("%w: command %s already exists in %s", ErrCommand, name, c.name))
+		return
+	}
+	if c.lazyCommands == nil {
+		c.lazyCommands = make(map[string]func() *Command)
+	}
+	c.lazyCommands[name] = factory
+}
+
+// hasSubCommands reports whether command has subcommands or lazy subcommands.
+func (c *Command) hasSubCommands() bool {
+	return len(c.subCommands) > 0 || len(c.lazyCommands) > 0
+}
+
+// hasSubCommand reports whether command has subcommand or lazy subcommand name.
+func (c *Command) hasSubCommand(name string) bool {
+	if _, exists := c.subCommands[name]; exists {
+		return true
+	}
+	_, exists := c.lazyCommands[name]
+	return exists
+}
+
+// loadSubCommand returns subcommand name creating it when it is lazy
+// subcommand, exists is false when there is no such subcommand.
+func (c *Command) loadSubCommand(name string) (cmd *Command, exists bool, err error) {
+	if cmd, exists := c.subCommands[name]; exists {
+		return cmd, true, nil
+	}
+	factory, ok := c.lazyCommands[name]
+	if !ok {
+		return nil, false, nil
+	}
+	delete(c.lazyCommands, name)
+	cmd = factory()
+	if cmd == nil {
+		return nil, false, fmt.Errorf("%w: factory of lazy command %s returned <nil>", ErrCommand, name)
+	}
+	if cmd.name != name {
+		return nil, false, fmt.Errorf("%w: factory of lazy command %s returned command %s", ErrCommand, name, cmd.name)
+	}
+	c.AddSubCommand(cmd)
+	if cmd.parent != c {
+		return nil, false, errors.Join(c.errs...)
+	}
+	return cmd, true, nil
+}
+
+// loadSubCommands creates all lazy subcommands of the command tree.
+func (c *Command) loadSubCommands() error {
+	names := make([]string, 0, len(c.lazyCommands))
+	for name := range c.lazyCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, _, err := c.loadSubCommand(name); err != nil {
+			return err
+		}
+	}
+	for _, sub := range c.subCommands {
+		if err := sub.loadSubCommands(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Verify veifies command,  flags and the sub commands
 //   - verify that commands are valid and have atleast Do function
 //   - verify that subcommand do not shadow flags of any parent command
@@ -215,10 +573,10 @@ func (c *Command) verify() error {
 
 	if c.doAction == nil {
 		if !c.isWrapperCommand {
-			c.isWrapperCommand = len(c.subCommands) > 0
+			c.isWrapperCommand = c.hasSubCommands()
 		}
 
-		if c.subCommands != nil {
+		if c.hasSubCommands() {
 			goto SubCommands
 		} else {
 			return fmt.Errorf("%w: command (%s) must have Do action or atleeast one subcommand", ErrCommand, c.name)
@@ -260,7 +618,25 @@ func (c *Command) getSubCommand(name string) (cmd *Command, exists bool) {
 	return
 }
 
-func (c *Command) callBeforeAction(session *Session) error {
+// callBeforeChain calls Before actions of root command, each parent command
+// and the command itself in that order, first error stops the chain.
+func (c *Command) callBeforeChain(session *Session) error {
+	var chain []*Command
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		chain = append([]*Command{cmd}, chain...)
+	}
+	for _, cmd := range chain {
+		if err := cmd.callBeforeAction(session, cmd == c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// callBeforeAction calls Before action, declared arguments are
+// validated only for active command since parent commands
+// do not own the positional arguments.
+func (c *Command) callBeforeAction(session *Session, active bool) error {
 	if c.beforeAction == nil {
 		return nil
 	}
@@ -269,9 +645,16 @@ func (c *Command) callBeforeAction(session *Session) error {
 		flags: c.flags,
 		argv:  c.flags.Args(),
 		argn:  uint(len(c.flags.Args())),
+		raw:   c.rawArgs,
+	}
+	if active {
+		var err error
+		if args, err = c.args(); err != nil {
+			return err
+		}
 	}
 
-	if err := c.beforeAction(session, args); err != nil {
+	if err := c.beforeAction(session.sandboxed(c.addon), args); err != nil {
 		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
 	}
 	return nil
@@ -282,16 +665,108 @@ func (c *Command) callDoAction(session *Session) error {
 		return nil
 	}
 
-	args := &args{
+	args, err := c.args()
+	if err != nil {
+		return err
+	}
+
+	if err := c.doAction(session.sandboxed(c.addon), args); err != nil {
+		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
+	}
+	return nil
+}
+
+// args returns command arguments validated against declared arguments.
+func (c *Command) args() (*args, error) {
+	a := &args{
 		flags: c.flags,
 		argv:  c.flags.Args(),
 		argn:  uint(len(c.flags.Args())),
+		raw:   c.rawArgs,
+	}
+	if !c.argsn.accepts(len(a.argv)) {
+		return nil, fmt.Errorf("%w: %s expects %s, got %d, usage: %s", ErrCommandArgs, c.name, c.argsn, len(a.argv), c.argsUsage())
+	}
+	if len(c.argdefs) == 0 {
+		return a, nil
 	}
 
-	if err := c.doAction(session, args); err != nil {
-		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
+	a.named = make(map[string][]vars.Value, len(c.argdefs))
+	rest := a.argv
+	for _, def := range c.argdefs {
+		if len(rest) < def.Min {
+			return nil, fmt.Errorf("%w: %s: missing argument %s, usage: %s", ErrCommandArgs, c.name, def.Name, c.argsUsage())
+		}
+		n := len(rest)
+		if def.Max >= 0 && n > def.Max {
+			n = def.Max
+		}
+		values := make([]vars.Value, 0, n)
+		for _, arg := range rest[:n] {
+			if def.Kind == vars.KindInvalid {
+				values = append(values, arg)
+				continue
+			}
+			val, err := vars.NewValueAs(arg.String(), def.Kind)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s: argument %s expects %s, got %q", ErrCommandArgs, c.name, def.Name, def.Kind, arg.String())
+			}
+			values = append(values, val)
+		}
+		a.named[def.Name] = values
+		rest = rest[n:]
 	}
-	return nil
+	if len(rest) > 0 {
+		return nil, fmt.Errorf("%w: %s: unexpected arguments %v, usage: %s", ErrCommandArgs, c.name, rest, c.argsUsage())
+	}
+	return a, nil
+}
+
+// setAddon marks command and its subcommands provided by addon.
+func (c *Command) setAddon(addon string) {
+	c.addon = addon
+	for _, sub := range c.subCommands {
+		sub.setAddon(addon)
+	}
+}
+
+// setRawArgs sets raw arguments for command and its parents.
+func (c *Command) setRawArgs(raw []string) {
+	for cmd := c; cmd != nil; cmd = cmd.parent {
+		cmd.rawArgs = raw
+	}
+}
+
+// splitRawArgs splits argv at first "--", arguments after it
+// are not parsed and are delivered untouched with Args.Raw.
+func splitRawArgs(argv []string) (parse, raw []string) {
+	for i, arg := range argv {
+		if arg == "--" {
+			return argv[:i], argv[i+1:]
+		}
+	}
+	return argv, nil
+}
+
+func (c *Command) argsUsage() string {
+	if len(c.argdefs) == 0 {
+		if c.argsn.Max == 0 {
+			return ""
+		}
+		var usage []string
+		for i := 0; i < c.argsn.Min; i++ {
+			usage = append(usage, "<arg>")
+		}
+		if c.argsn.Max < 0 || c.argsn.Max > c.argsn.Min {
+			usage = append(usage, "[<arg>...]")
+		}
+		return strings.Join(usage, " ")
+	}
+	usage := make([]string, len(c.argdefs))
+	for i, def := range c.argdefs {
+		usage[i] = def.String()
+	}
+	return strings.Join(usage, " ")
 }
 
 func (c *Command) callAfterFailureAction(session *Session, err error) error {
@@ -299,7 +774,7 @@ func (c *Command) callAfterFailureAction(session *Session, err error) error {
 		return nil
 	}
 
-	if err := c.afterFailureAction(session, err); err != nil {
+	if err := c.afterFailureAction(session.sandboxed(c.addon), err); err != nil {
 		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
 	}
 	return nil
@@ -310,7 +785,7 @@ func (c *Command) callAfterSuccessAction(session *Session) error {
 		return nil
 	}
 
-	if err := c.afterSuccessAction(session); err != nil {
+	if err := c.afterSuccessAction(session.sandboxed(c.addon)); err != nil {
 		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
 	}
 	return nil
@@ -321,7 +796,7 @@ func (c *Command) callAfterAlwaysAction(session *Session) error {
 		return nil
 	}
 
-	if err := c.afterAlwaysAction(session); err != nil {
+	if err := c.afterAlwaysAction(session.sandboxed(c.addon)); err != nil {
 		return fmt.Errorf("%w: %s: %w", ErrCommandAction, c.name, err)
 	}
 	return nil
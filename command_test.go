@@ -0,0 +1,248 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestCommandArgs(t *testing.T) {
+	newCmd := func(t *testing.T, argv ...string) *Command {
+		t.Helper()
+		cmd := NewCommand("copy")
+		cmd.AddArg(ArgDef{Name: "count", Usage: "number of copies", Kind: vars.KindInt, Min: 1, Max: 1})
+		cmd.AddArg(ArgDef{Name: "files", Usage: "files to copy", Min: 1, Max: -1})
+		testutils.NoError(t, cmd.Err())
+		testutils.NoError(t, cmd.flags.Parse(append([]string{"copy"}, argv...)))
+		return cmd
+	}
+
+	a, err := newCmd(t, "2", "a.txt", "b.txt").args()
+	testutils.NoError(t, err)
+	testutils.Equal(t, vars.KindInt, a.Named("count").Kind())
+	count, err := a.Named("count").Int()
+	testutils.NoError(t, err)
+	testutils.Equal(t, 2, count)
+	testutils.Equal(t, 2, len(a.NamedAll("files")))
+	testutils.Equal(t, "a.txt", a.Named("files").String())
+	testutils.Equal(t, "", a.Named("unknown").String())
+
+	_, err = newCmd(t, "2").args()
+	testutils.ErrorIs(t, err, ErrCommandArgs)
+	_, err = newCmd(t, "two", "a.txt").args()
+	testutils.ErrorIs(t, err, ErrCommandArgs)
+	testutils.Equal(t, "<count> <files>...", newCmd(t).argsUsage())
+
+	cmd := NewCommand("invalid")
+	cmd.AddArg(ArgDef{Name: "opt", Min: 0, Max: 1})
+	cmd.AddArg(ArgDef{Name: "req", Min: 1, Max: 1})
+	testutils.ErrorIs(t, cmd.Err(), ErrCommand)
+}
+
+func TestCommandCategories(t *testing.T) {
+	root := NewCommand("app")
+	for _, def := range []struct{ name, category string }{
+		{"run", ""},
+		{"users", "Admin"},
+		{"backup", "Admin"},
+		{"beta", "Experimental"},
+		{"debug", "Admin"},
+	} {
+		cmd := NewCommand(def.name, Option("category", def.category), Option("hidden", def.name == "debug"))
+		cmd.Do(func(sess *Session, args Args) error { return nil })
+		root.AddSubCommand(cmd)
+	}
+
+	primary, categories := categorizeCommands(root.subCommands)
+	testutils.Equal(t, 1, len(primary))
+	testutils.Equal(t, "run", primary[0].Name)
+	testutils.Equal(t, 2, len(categories))
+	testutils.Equal(t, "Admin", categories[0].Name)
+	testutils.Equal(t, 2, len(categories[0].Commands))
+	testutils.Equal(t, "backup", categories[0].Commands[0].Name)
+	testutils.Equal(t, "Experimental", categories[1].Name)
+
+	h := helpGlobal{Commands: root.subCommands}
+	h.PrimaryCommands, h.Categories = categorizeCommands(h.Commands)
+	h.setTemplate(helpGlobalTmpl)
+	testutils.NoError(t, h.parseTmpl("help-global-tmpl", &h, 0))
+	out := h.buffer.String()
+	testutils.True(t, strings.Index(out, "ADMIN") < strings.Index(out, "EXPERIMENTAL"), "categories not sorted")
+	testutils.False(t, strings.Contains(out, "debug"), "hidden command in help")
+}
+
+func TestCommandHelpTemplate(t *testing.T) {
+	cmd := NewCommand("deploy")
+	cmd.SetHelpTemplate("{{ .Command.Name }} {{ funcTextBold .Usage }}\nDocs: https://example.com")
+	testutils.NoError(t, cmd.Err())
+
+	cmd.Do(func(sess *Session, args Args) error { return nil })
+	h := helpCommand{}
+	h.setTemplate(cmd.helpTmpl)
+	testutils.NoError(t, h.print(newTestSession(t), cmd))
+	testutils.True(t, strings.HasSuffix(h.buffer.String(), "Docs: https://example.com"), "missing help footer")
+
+	cmd = NewCommand("deploy")
+	cmd.AddExample("deploy to production", "app deploy --env prod")
+	cmd.Do(func(sess *Session, args Args) error { return nil })
+	h = helpCommand{}
+	h.setTemplate(helpCommandTmpl)
+	testutils.NoError(t, h.print(newTestSession(t), cmd))
+	testutils.True(t, strings.Contains(h.buffer.String(), "# deploy to production\n  app deploy --env prod"), "missing example in help")
+
+	invalid := NewCommand("invalid")
+	invalid.SetHelpTemplate("{{ .Command.Name ")
+	testutils.ErrorIs(t, invalid.Err(), ErrCommand)
+}
+
+func TestCommandBeforeChain(t *testing.T) {
+	var calls []string
+	before := func(name string, err error) ActionWithArgs {
+		return func(sess *Session, args Args) error {
+			calls = append(calls, name)
+			return err
+		}
+	}
+	root := NewCommand("app")
+	root.Before(before("app", nil))
+	svc := NewCommand("service")
+	svc.Before(before("service", nil))
+	start := NewCommand("start")
+	start.Before(before("start", nil))
+	start.Do(func(sess *Session, args Args) error { return nil })
+	svc.AddSubCommand(start)
+	root.AddSubCommand(svc)
+	testutils.NoError(t, root.verify())
+
+	sess := newTestSession(t)
+	testutils.NoError(t, start.callBeforeChain(sess))
+	testutils.EqualAny(t, []string{"app", "service", "start"}, calls)
+
+	calls = nil
+	stop := NewCommand("stop")
+	stop.Before(before("stop", nil))
+	stop.Do(func(sess *Session, args Args) error { return nil })
+	failing := NewCommand("failing")
+	failing.Before(before("failing", errors.New("denied")))
+	failing.AddSubCommand(stop)
+	root.AddSubCommand(failing)
+	testutils.ErrorIs(t, stop.callBeforeChain(sess), ErrCommandAction)
+	testutils.EqualAny(t, []string{"app", "failing"}, calls)
+}
+
+func TestCommandDeprecate(t *testing.T) {
+	root := NewCommand("app")
+	old := NewCommand("up", Option("usage", "start services"))
+	old.Deprecate("service start")
+	old.Do(func(sess *Session, args Args) error { return nil })
+	root.AddSubCommand(old)
+
+	gone := NewCommand("legacy")
+	gone.Deprecate("")
+	gone.Do(func(sess *Session, args Args) error { return nil })
+	root.AddSubCommand(gone)
+
+	testutils.NoError(t, root.verify())
+	testutils.True(t, old.Deprecated(), "command should be deprecated")
+	testutils.True(t, old.Hidden(), "deprecated command should be hidden")
+	testutils.Equal(t, "command up is deprecated, use service start instead", old.deprecationNotice())
+	testutils.Equal(t, "command legacy is deprecated and will be removed", gone.deprecationNotice())
+
+	var b strings.Builder
+	testutils.NoError(t, writeCompletion(&b, "bash", root))
+	testutils.False(t, strings.Contains(b.String(), "legacy"), "deprecated command in completion")
+}
+
+func TestCommandArgsRange(t *testing.T) {
+	tests := []struct {
+		spec   string
+		desc   string
+		usage  string
+		accept []int
+		reject []int
+	}{
+		{"", "at least 0 arguments", "[<arg>...]", []int{0, 5}, nil},
+		{"0", "no arguments", "", []int{0}, []int{1}},
+		{"2", "exactly 2 arguments", "<arg> <arg>", []int{2}, []int{1, 3}},
+		{"1..3", "1 to 3 arguments", "<arg> [<arg>...]", []int{1, 3}, []int{0, 4}},
+		{"1..", "at least 1 argument", "<arg> [<arg>...]", []int{1, 10}, []int{0}},
+		{"..1", "at most 1 argument", "[<arg>...]", []int{0, 1}, []int{2}},
+	}
+	for _, tt := range tests {
+		cmd := NewCommand("copy", Option("args", tt.spec))
+		testutils.NoError(t, cmd.Err(), tt.spec)
+		testutils.Equal(t, tt.desc, cmd.argsn.String(), tt.spec)
+		testutils.Equal(t, tt.usage, cmd.argsUsage(), tt.spec)
+		for _, n := range tt.accept {
+			testutils.True(t, cmd.argsn.accepts(n), "%s should accept %d", tt.spec, n)
+		}
+		for _, n := range tt.reject {
+			testutils.False(t, cmd.argsn.accepts(n), "%s should reject %d", tt.spec, n)
+		}
+	}
+
+	for _, spec := range []string{"..", "3..1", "-1", "a..b"} {
+		cmd := NewCommand("copy", Option("args", spec))
+		testutils.Error(t, cmd.Err(), spec)
+	}
+
+	cmd := NewCommand("copy", Option("args", "2"))
+	cmd.Do(func(sess *Session, args Args) error { return nil })
+	testutils.NoError(t, cmd.flags.Parse([]string{"copy", "a"}))
+	err := cmd.callDoAction(newTestSession(t))
+	testutils.ErrorIs(t, err, ErrCommandArgs)
+	testutils.True(t, strings.Contains(err.Error(), "copy expects exactly 2 arguments, got 1, usage: <arg> <arg>"), err.Error())
+}
+
+func TestCommandLazySubCommand(t *testing.T) {
+	var created []string
+	factory := func(name string) func() *Command {
+		return func() *Command {
+			created = append(created, name)
+			cmd := NewCommand(name)
+			cmd.Do(func(sess *Session, args Args) error { return nil })
+			return cmd
+		}
+	}
+	root := NewCommand("app")
+	root.AddLazySubCommand("deploy", factory("deploy"))
+	root.AddLazySubCommand("status", factory("status"))
+	testutils.True(t, root.hasSubCommands())
+	testutils.True(t, root.hasSubCommand("deploy"))
+	testutils.Equal(t, 0, len(created))
+
+	cmd, exists, err := root.loadSubCommand("deploy")
+	testutils.NoError(t, err)
+	testutils.True(t, exists)
+	testutils.Equal(t, "deploy", cmd.name)
+	testutils.Equal(t, root, cmd.parent)
+	testutils.Equal(t, "deploy", strings.Join(created, ","))
+
+	_, exists, err = root.loadSubCommand("missing")
+	testutils.NoError(t, err)
+	testutils.False(t, exists)
+
+	testutils.NoError(t, root.loadSubCommands())
+	testutils.Equal(t, "deploy,status", strings.Join(created, ","))
+	testutils.Equal(t, 2, len(root.subCommands))
+
+	errs := len(root.errs)
+	root.AddLazySubCommand("deploy", factory("deploy"))
+	root.AddLazySubCommand("nil", nil)
+	testutils.Equal(t, errs+2, len(root.errs))
+
+	root = NewCommand("app")
+	root.AddLazySubCommand("deploy", factory("release"))
+	_, _, err = root.loadSubCommand("deploy")
+	testutils.ErrorIs(t, err, ErrCommand)
+	root.AddLazySubCommand("none", func() *Command { return nil })
+	_, _, err = root.loadSubCommand("none")
+	testutils.ErrorIs(t, err, ErrCommand)
+}
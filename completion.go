@@ -0,0 +1,428 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mkungla/happy/pkg/varflag"
+)
+
+// completionCmd is node of command tree used to generate
+// shell completion scripts.
+type completionCmd struct {
+	path  string
+	name  string
+	usage string
+	subs  []*completionCmd
+	flags []completionFlag
+	// dynamicArgs is true when command has args CompletionFunc
+	dynamicArgs bool
+}
+
+type completionFlag struct {
+	name    string
+	short   []string
+	long    []string
+	usage   string
+	choices []string
+	// dynamic is true when flag has CompletionFunc
+	dynamic bool
+}
+
+// CompletionFunc returns completion candidates for flag value or
+// positional argument, e.g. service addresses from the registry.
+// Candidates not starting with toComplete are filtered out.
+type CompletionFunc func(sess *Session, toComplete string) []string
+
+const completeCommandName = "complete-values"
+
+// words returns flag names with leading dashes.
+func (f completionFlag) words() []string {
+	var words []string
+	for _, l := range f.long {
+		words = append(words, "--"+l)
+	}
+	for _, s := range f.short {
+		words = append(words, "-"+s)
+	}
+	return words
+}
+
+func newCompletionFlags(flags []varflag.Flag, completers map[string]CompletionFunc) []completionFlag {
+	var cflags []completionFlag
+	for _, flag := range flags {
+		if flag.Hidden() {
+			continue
+		}
+		cflag := completionFlag{
+			name:  flag.Name(),
+			usage: flag.Usage(),
+		}
+		for _, name := range append([]string{flag.Name()}, flag.Aliases()...) {
+			if len(name) == 1 {
+				cflag.short = append(cflag.short, name)
+			} else if name != "" {
+				cflag.long = append(cflag.long, name)
+			}
+		}
+		if opt, ok := flag.(interface{ Options() []string }); ok {
+			cflag.choices = opt.Options()
+		}
+		_, cflag.dynamic = completers[flag.Name()]
+		cflags = append(cflags, cflag)
+	}
+	sort.Slice(cflags, func(i, j int) bool {
+		return cflags[i].name < cflags[j].name
+	})
+	return cflags
+}
+
+func newCompletionCmd(cmd *Command, parent string) *completionCmd {
+	ccmd := &completionCmd{
+		name:        cmd.name,
+		usage:       cmd.usage,
+		path:        strings.TrimSpace(parent + " " + cmd.name),
+		flags:       newCompletionFlags(cmd.flags.Flags(), cmd.flagCompleters),
+		dynamicArgs: cmd.argsCompleter != nil,
+	}
+	names := make([]string, 0, len(cmd.subCommands))
+	for name, sub := range cmd.subCommands {
+		if sub.hidden {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		ccmd.subs = append(ccmd.subs, newCompletionCmd(cmd.subCommands[name], ccmd.path))
+	}
+	return ccmd
+}
+
+// walk calls fn for command and all its sub commands.
+func (c *completionCmd) walk(fn func(c *completionCmd)) {
+	fn(c)
+	for _, sub := range c.subs {
+		sub.walk(fn)
+	}
+}
+
+// writeCompletion writes completion script for given shell
+// (bash, zsh, fish or powershell) of root command and its sub commands.
+func writeCompletion(w io.Writer, shell string, root *Command) error {
+	tree := newCompletionCmd(root, "")
+	// root flags are global and accepted after any command
+	global := tree.flags
+
+	var b strings.Builder
+	switch shell {
+	case "bash":
+		writeBashCompletion(&b, tree, global)
+	case "zsh":
+		b.WriteString("#compdef " + tree.name + "\n\nautoload -U +X bashcompinit && bashcompinit\n\n")
+		writeBashCompletion(&b, tree, global)
+	case "fish":
+		writeFishCompletion(&b, tree, global)
+	case "powershell":
+		writePowershellCompletion(&b, tree, global)
+	default:
+		return fmt.Errorf("%w: unsupported shell %q, supported shells are bash, zsh, fish and powershell", ErrCommand, shell)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func completionFuncName(name string) string {
+	return "_" + strings.NewReplacer("-", "_", ".", "_").Replace(name) + "_completion"
+}
+
+func writeBashCompletion(b *strings.Builder, tree *completionCmd, global []completionFlag) {
+	fn := completionFuncName(tree.name)
+	fmt.Fprintf(b, "# bash completion for %s\n", tree.name)
+	fmt.Fprintf(b, "%s() {\n", fn)
+	b.WriteString("  local cur prev path words i\n")
+	b.WriteString("  cur=\"${COMP_WORDS[COMP_CWORD]}\"\n")
+	b.WriteString("  prev=\"${COMP_WORDS[COMP_CWORD-1]}\"\n")
+	fmt.Fprintf(b, "  path=%q\n", tree.name)
+
+	var cmdpaths []string
+	tree.walk(func(c *completionCmd) {
+		if c != tree {
+			cmdpaths = append(cmdpaths, fmt.Sprintf("%q", c.path))
+		}
+	})
+	b.WriteString("  for ((i=1; i<COMP_CWORD; i++)); do\n")
+	b.WriteString("    case \"$path ${COMP_WORDS[i]}\" in\n")
+	if len(cmdpaths) > 0 {
+		fmt.Fprintf(b, "      %s) path=\"$path ${COMP_WORDS[i]}\" ;;\n", strings.Join(cmdpaths, "|"))
+	}
+	b.WriteString("    esac\n  done\n\n")
+
+	b.WriteString("  case \"$prev\" in\n")
+	tree.walk(func(c *completionCmd) {
+		for _, f := range c.flags {
+			if f.dynamic {
+				fmt.Fprintf(b, "    %s) COMPREPLY=($(compgen -W \"$(%s %s flag \"$path\" %s \"$cur\" 2>/dev/null)\" -- \"$cur\")); return ;;\n",
+					strings.Join(f.words(), "|"), tree.name, completeCommandName, f.name)
+			} else if len(f.choices) > 0 {
+				fmt.Fprintf(b, "    %s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n",
+					strings.Join(f.words(), "|"), strings.Join(f.choices, " "))
+			}
+		}
+	})
+	b.WriteString("  esac\n\n")
+
+	var globalWords []string
+	for _, f := range global {
+		globalWords = append(globalWords, f.words()...)
+	}
+	b.WriteString("  case \"$path\" in\n")
+	tree.walk(func(c *completionCmd) {
+		var words []string
+		for _, sub := range c.subs {
+			words = append(words, sub.name)
+		}
+		if c != tree {
+			for _, f := range c.flags {
+				words = append(words, f.words()...)
+			}
+		}
+		words = append(words, globalWords...)
+		if c.dynamicArgs {
+			fmt.Fprintf(b, "    %q) words=%q; words=\"$words $(%s %s args \"$path\" - \"$cur\" 2>/dev/null)\" ;;\n",
+				c.path, strings.Join(words, " "), tree.name, completeCommandName)
+			return
+		}
+		fmt.Fprintf(b, "    %q) words=%q ;;\n", c.path, strings.Join(words, " "))
+	})
+	b.WriteString("  esac\n")
+	b.WriteString("  COMPREPLY=($(compgen -W \"$words\" -- \"$cur\"))\n")
+	b.WriteString("}\n")
+	fmt.Fprintf(b, "complete -F %s %s\n", fn, tree.name)
+}
+
+func writeFishCompletion(b *strings.Builder, tree *completionCmd, global []completionFlag) {
+	fmt.Fprintf(b, "# fish completion for %s\n", tree.name)
+	writeFlag := func(cond, path string, f completionFlag) {
+		fmt.Fprintf(b, "complete -c %s", tree.name)
+		if cond != "" {
+			fmt.Fprintf(b, " -n %q", cond)
+		}
+		for _, l := range f.long {
+			fmt.Fprintf(b, " -l %s", l)
+		}
+		for _, s := range f.short {
+			fmt.Fprintf(b, " -s %s", s)
+		}
+		if f.dynamic {
+			fmt.Fprintf(b, " -x -a \"(%s %s flag '%s' %s (commandline -ct))\"", tree.name, completeCommandName, path, f.name)
+		} else if len(f.choices) > 0 {
+			fmt.Fprintf(b, " -x -a %q", strings.Join(f.choices, " "))
+		}
+		if f.usage != "" {
+			fmt.Fprintf(b, " -d %q", f.usage)
+		}
+		b.WriteString("\n")
+	}
+	for _, f := range global {
+		writeFlag("", tree.path, f)
+	}
+	tree.walk(func(c *completionCmd) {
+		cond := "__fish_use_subcommand"
+		if c != tree {
+			cond = "__fish_seen_subcommand_from " + c.name
+			for _, f := range c.flags {
+				writeFlag(cond, c.path, f)
+			}
+			if c.dynamicArgs {
+				fmt.Fprintf(b, "complete -c %s -f -n %q -a \"(%s %s args '%s' - (commandline -ct))\"\n",
+					tree.name, cond, tree.name, completeCommandName, c.path)
+			}
+		}
+		for _, sub := range c.subs {
+			fmt.Fprintf(b, "complete -c %s -f -n %q -a %s", tree.name, cond, sub.name)
+			if sub.usage != "" {
+				fmt.Fprintf(b, " -d %q", sub.usage)
+			}
+			b.WriteString("\n")
+		}
+	})
+}
+
+func writePowershellCompletion(b *strings.Builder, tree *completionCmd, global []completionFlag) {
+	quote := func(words []string) string {
+		quoted := make([]string, len(words))
+		for i, w := range words {
+			quoted[i] = "'" + strings.ReplaceAll(w, "'", "''") + "'"
+		}
+		return "@(" + strings.Join(quoted, ", ") + ")"
+	}
+	var globalWords []string
+	for _, f := range global {
+		globalWords = append(globalWords, f.words()...)
+	}
+
+	fmt.Fprintf(b, "# powershell completion for %s\n", tree.name)
+	fmt.Fprintf(b, "Register-ArgumentCompleter -Native -CommandName '%s' -ScriptBlock {\n", tree.name)
+	b.WriteString("  param($wordToComplete, $commandAst, $cursorPosition)\n")
+	b.WriteString("  $commands = @{\n")
+	tree.walk(func(c *completionCmd) {
+		var words []string
+		for _, sub := range c.subs {
+			words = append(words, sub.name)
+		}
+		if c != tree {
+			for _, f := range c.flags {
+				words = append(words, f.words()...)
+			}
+		}
+		words = append(words, globalWords...)
+		fmt.Fprintf(b, "    '%s' = %s\n", c.path, quote(words))
+	})
+	b.WriteString("  }\n")
+	b.WriteString("  $choices = @{\n")
+	tree.walk(func(c *completionCmd) {
+		for _, f := range c.flags {
+			if len(f.choices) == 0 {
+				continue
+			}
+			for _, w := range f.words() {
+				fmt.Fprintf(b, "    '%s' = %s\n", w, quote(f.choices))
+			}
+		}
+	})
+	b.WriteString("  }\n")
+	b.WriteString("  $dynamicFlags = @{\n")
+	tree.walk(func(c *completionCmd) {
+		for _, f := range c.flags {
+			if !f.dynamic {
+				continue
+			}
+			for _, w := range f.words() {
+				fmt.Fprintf(b, "    '%s' = '%s'\n", w, f.name)
+			}
+		}
+	})
+	b.WriteString("  }\n")
+	var dynamicArgs []string
+	tree.walk(func(c *completionCmd) {
+		if c.dynamicArgs {
+			dynamicArgs = append(dynamicArgs, c.path)
+		}
+	})
+	fmt.Fprintf(b, "  $dynamicArgs = %s\n", quote(dynamicArgs))
+	fmt.Fprintf(b, "  $path = '%s'\n", tree.name)
+	b.WriteString("  $elements = $commandAst.CommandElements | Select-Object -Skip 1 | ForEach-Object { $_.ToString() }\n")
+	b.WriteString("  $prev = ''\n")
+	b.WriteString("  foreach ($element in $elements) {\n")
+	b.WriteString("    if ($element -eq $wordToComplete) { break }\n")
+	b.WriteString("    if ($commands.ContainsKey(\"$path $element\")) { $path = \"$path $element\" }\n")
+	b.WriteString("    $prev = $element\n")
+	b.WriteString("  }\n")
+	b.WriteString("  $words = $commands[$path]\n")
+	fmt.Fprintf(b, "  if ($dynamicArgs -contains $path) { $words += & '%s' %s args $path - $wordToComplete }\n", tree.name, completeCommandName)
+	b.WriteString("  if ($choices.ContainsKey($prev)) { $words = $choices[$prev] }\n")
+	fmt.Fprintf(b, "  if ($dynamicFlags.ContainsKey($prev)) { $words = & '%s' %s flag $path $dynamicFlags[$prev] $wordToComplete }\n", tree.name, completeCommandName)
+	b.WriteString("  $words | Where-Object { $_ -like \"$wordToComplete*\" } | ForEach-Object {\n")
+	b.WriteString("    [System.Management.Automation.CompletionResult]::new($_, $_, 'ParameterValue', $_)\n")
+	b.WriteString("  }\n")
+	b.WriteString("}\n")
+}
+
+// completionCommand returns built-in command which prints shell
+// completion script of root command.
+func completionCommand(root *Command) *Command {
+	cmd := NewCommand(
+		"completion",
+		Option("description", "Generate shell completion script, e.g. source <(app completion bash)"),
+		Option("usage", "generate completion script for bash, zsh, fish or powershell"),
+		Option("category", "GENERAL"),
+		Option("skip.addons", true),
+	)
+	cmd.walksTree = true
+	cmd.Do(func(sess *Session, args Args) error {
+		if len(args.Args()) != 1 {
+			return fmt.Errorf("%w: completion requires shell argument bash, zsh, fish or powershell", ErrCommand)
+		}
+		return writeCompletion(os.Stdout, args.Arg(0).String(), root)
+	})
+	return cmd
+}
+
+// completeCommand returns hidden command called by completion scripts
+// to resolve dynamic completions registered with Command.CompleteFlag
+// and Command.CompleteArgs.
+func completeCommand(root *Command) *Command {
+	cmd := NewCommand(
+		completeCommandName,
+		Option("usage", "print dynamic completion candidates"),
+		Option("hidden", true),
+	)
+	cmd.walksTree = true
+	cmd.AddArg(ArgDef{Name: "kind", Usage: "flag or args", Min: 1, Max: 1})
+	cmd.AddArg(ArgDef{Name: "path", Usage: "command path e.g. \"app deploy\"", Min: 1, Max: 1})
+	cmd.AddArg(ArgDef{Name: "name", Usage: "flag name or - for args", Min: 1, Max: 1})
+	cmd.AddArg(ArgDef{Name: "current", Usage: "word being completed", Min: 0, Max: 1})
+	cmd.Do(func(sess *Session, args Args) error {
+		candidates, err := completeDynamic(
+			sess,
+			root,
+			args.Named("kind").String(),
+			args.Named("path").String(),
+			args.Named("name").String(),
+			args.Named("current").String(),
+		)
+		if err != nil {
+			return err
+		}
+		for _, c := range candidates {
+			fmt.Fprintln(os.Stdout, c)
+		}
+		return nil
+	})
+	return cmd
+}
+
+func completeDynamic(sess *Session, root *Command, kind, path, name, current string) ([]string, error) {
+	cmd := root
+	words := strings.Fields(path)
+	if len(words) > 0 {
+		words = words[1:]
+	}
+	for _, w := range words {
+		sub, ok := cmd.getSubCommand(w)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown command %q in %q", ErrCommand, w, path)
+		}
+		cmd = sub
+	}
+
+	var fn CompletionFunc
+	switch kind {
+	case "flag":
+		for c := cmd; c != nil && fn == nil; c = c.parent {
+			fn = c.flagCompleters[name]
+		}
+	case "args":
+		fn = cmd.argsCompleter
+	default:
+		return nil, fmt.Errorf("%w: unknown completion kind %q", ErrCommand, kind)
+	}
+	if fn == nil {
+		return nil, nil
+	}
+	var res []string
+	for _, c := range fn(sess, current) {
+		if strings.HasPrefix(c, current) {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/varflag"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newTestCompletionRoot(t *testing.T) *Command {
+	t.Helper()
+	root := NewCommand("app")
+	verbose, err := varflag.Bool("verbose", false, "verbose output", "v")
+	testutils.NoError(t, err)
+	root.AddFlag(verbose)
+
+	deploy := NewCommand("deploy", Option("usage", "deploy application"))
+	env, err := varflag.Option("env", []string{"dev"}, []string{"dev", "prod"}, "target environment")
+	testutils.NoError(t, err)
+	deploy.AddFlag(env)
+	deploy.AddExample("deploy to production", "app deploy --env prod")
+	deploy.Do(func(sess *Session, args Args) error { return nil })
+	root.AddSubCommand(deploy)
+
+	internal := NewCommand("internal", Option("hidden", true))
+	internal.Do(func(sess *Session, args Args) error { return nil })
+	root.AddSubCommand(internal)
+	return root
+}
+
+func TestWriteCompletion(t *testing.T) {
+	root := newTestCompletionRoot(t)
+	for _, shell := range []string{"bash", "zsh", "fish", "powershell"} {
+		var b strings.Builder
+		testutils.NoError(t, writeCompletion(&b, shell, root), shell)
+		script := b.String()
+		testutils.True(t, strings.Contains(script, "deploy"), "%s: missing subcommand", shell)
+		testutils.False(t, strings.Contains(script, "internal"), "%s: hidden subcommand in completion", shell)
+		testutils.True(t, strings.Contains(script, "verbose"), "%s: missing global flag", shell)
+		testutils.True(t, strings.Contains(script, "dev prod") || strings.Contains(script, "'dev', 'prod'"),
+			"%s: missing flag choices", shell)
+	}
+
+	var b strings.Builder
+	testutils.ErrorIs(t, writeCompletion(&b, "tcsh", root), ErrCommand)
+}
+
+func TestCompleteDynamic(t *testing.T) {
+	root := newTestCompletionRoot(t)
+	deploy, _ := root.getSubCommand("deploy")
+	addrs := func(sess *Session, toComplete string) []string {
+		return []string{"api.local:8080", "web.local:80", "api.remote:443"}
+	}
+	deploy.CompleteFlag("env", addrs)
+	deploy.CompleteArgs(addrs)
+	root.CompleteFlag("verbose", func(sess *Session, toComplete string) []string {
+		return []string{"true", "false"}
+	})
+
+	sess := newTestSession(t)
+	res, err := completeDynamic(sess, root, "flag", "app deploy", "env", "api")
+	testutils.NoError(t, err)
+	testutils.EqualAny(t, []string{"api.local:8080", "api.remote:443"}, res)
+
+	res, err = completeDynamic(sess, root, "args", "app deploy", "-", "web")
+	testutils.NoError(t, err)
+	testutils.EqualAny(t, []string{"web.local:80"}, res)
+
+	res, err = completeDynamic(sess, root, "flag", "app deploy", "verbose", "")
+	testutils.NoError(t, err)
+	testutils.EqualAny(t, []string{"true", "false"}, res)
+
+	_, err = completeDynamic(sess, root, "flag", "app missing", "env", "")
+	testutils.ErrorIs(t, err, ErrCommand)
+
+	var b strings.Builder
+	testutils.NoError(t, writeCompletion(&b, "bash", root))
+	testutils.True(t, strings.Contains(b.String(), "app complete-values flag \"$path\" env"), "bash: missing dynamic flag completion")
+	testutils.True(t, strings.Contains(b.String(), "app complete-values args \"$path\""), "bash: missing dynamic args completion")
+}
+
+func TestCompleteCommandName(t *testing.T) {
+	root := NewCommand("app")
+	hello := NewCommand("hello")
+	hello.Do(func(sess *Session, args Args) error { return nil })
+	root.AddSubCommand(hello)
+	root.AddSubCommand(completeCommand(root))
+	testutils.NoError(t, root.verify(), "complete command must have valid command name")
+}
@@ -0,0 +1,375 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+)
+
+// configFileName is base name of config files in standard locations.
+const configFileName = "config"
+
+// configParsers parse config file of given extension into
+// options keyed by dot separated option key.
+var configParsers = map[string]func(data []byte) (map[string]any, error){
+	".json": parseConfigJSON,
+	".toml": parseConfigTOML,
+	".yaml": parseConfigYAML,
+	".yml":  parseConfigYAML,
+}
+
+// configExts is lookup order of config files in standard locations.
+var configExts = []string{".json", ".toml", ".yaml", ".yml"}
+
+// configFiles returns config files which exist in standard locations
+// in order they are applied, system wide config file /etc/<slug>/config.*
+// and user config file <user config dir>/<slug>/config.*.
+// When --config flag is set only that file is returned.
+func (a *Application) configFiles() ([]string, error) {
+	if file := a.rootCmd.flag("config").String(); file != "" {
+		if _, err := os.Stat(file); err != nil {
+			return nil, fmt.Errorf("%w: config file %s", ErrApplication, err.Error())
+		}
+		return []string{file}, nil
+	}
+	slug := a.session.Get("app.slug").String()
+	if slug == "" {
+		return nil, nil
+	}
+	var dirs []string
+	if runtime.GOOS != "windows" {
+		dirs = append(dirs, filepath.Join("/etc", slug))
+	}
+	if dir := a.session.Get("app.path.config").String(); dir != "" {
+		dirs = append(dirs, dir)
+	} else if dir, err := os.UserConfigDir(); err == nil {
+		if a.profile != "" && a.profile != "default" {
+			dirs = append(dirs, filepath.Join(dir, instanceSlug(a.session), a.profile))
+		} else {
+			dirs = append(dirs, filepath.Join(dir, instanceSlug(a.session)))
+		}
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		for _, ext := range configExts {
+			file := filepath.Join(dir, configFileName+ext)
+			if _, err := os.Stat(file); err == nil {
+				files = append(files, file)
+				break
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return files, nil
+}
+
+// configEntry is option value read from config file or environment.
+type configEntry struct {
+	key    string
+	value  any
+	source string
+}
+
+// readConfig returns options from config files in order they are applied.
+func (a *Application) readConfig() ([]configEntry, error) {
+	files, err := a.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	var entries []configEntry
+	for _, file := range files {
+		parse, ok := configParsers[strings.ToLower(filepath.Ext(file))]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported config file format %s", ErrApplication, file)
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		values, err := parse(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: config file %s: %s", ErrApplication, file, err.Error())
+		}
+		keys := make([]string, 0, len(values))
+		for key := range values {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		// options of active profile override other options in file
+		var profileEntries []configEntry
+		for _, key := range keys {
+			if strings.HasPrefix(key, profileConfigPrefix) {
+				if optkey, ok := profileConfigKey(a.profile, key); ok {
+					profileEntries = append(profileEntries, configEntry{
+						key:    optkey,
+						value:  a.configValue(optkey, values[key]),
+						source: "file:" + file,
+					})
+				}
+				continue
+			}
+			entries = append(entries, configEntry{
+				key:    key,
+				value:  a.configValue(key, values[key]),
+				source: "file:" + file,
+			})
+		}
+		entries = append(entries, profileEntries...)
+		a.logger.SystemDebug("loaded config from", slog.String("file", file))
+	}
+	return entries, nil
+}
+
+// readEnv returns options from environment variables prefixed with
+// application slug e.g. MYAPP_LOG_LEVEL for log.level option when slug
+// is myapp.
+func (a *Application) readEnv() []configEntry {
+	prefix := envPrefix(a.session.Get("app.slug").String())
+	if prefix == "" {
+		return nil
+	}
+	keys := make(map[string]bool)
+	for key := range a.session.opts.config {
+		if key != "*" {
+			keys[key] = true
+		}
+	}
+	for _, opt := range a.pendingOpts {
+		keys[opt.key] = true
+	}
+	for _, addon := range a.addons {
+		for _, key := range addon.Options() {
+			keys[key] = true
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	var entries []configEntry
+	for _, key := range sorted {
+		name := prefix + "_" + envName(key)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		entries = append(entries, configEntry{
+			key:    key,
+			value:  a.configValue(key, value),
+			source: "env:" + name,
+		})
+	}
+	return entries
+}
+
+// loadConfig applies options from config files, values from config
+// files override defaults and persisted settings.
+func (a *Application) loadConfig() error {
+	entries, err := a.readConfig()
+	if err != nil {
+		return err
+	}
+	var reconfigure bool
+	for _, entry := range entries {
+		if err := a.overrideOption(entry.key, entry.value, entry.source); err != nil {
+			return fmt.Errorf("config file %s: %w", strings.TrimPrefix(entry.source, "file:"), err)
+		}
+		if strings.HasPrefix(entry.key, "log.") {
+			reconfigure = true
+		}
+	}
+	if reconfigure {
+		a.configureLogger()
+	}
+	return nil
+}
+
+// loadEnv applies options from environment variables,
+// values from environment override values from config files.
+func (a *Application) loadEnv() error {
+	var reconfigure bool
+	for _, entry := range a.readEnv() {
+		if err := a.overrideOption(entry.key, entry.value, entry.source); err != nil {
+			return fmt.Errorf("environment variable %s: %w", strings.TrimPrefix(entry.source, "env:"), err)
+		}
+		if strings.HasPrefix(entry.key, "log.") {
+			reconfigure = true
+		}
+	}
+	if reconfigure {
+		a.configureLogger()
+	}
+	return nil
+}
+
+// envPrefix returns environment variable prefix for application slug.
+func envPrefix(slug string) string {
+	return strings.Trim(envName(slug), "_")
+}
+
+// envName returns key in upper case where characters other than
+// letters and digits are replaced with underscore.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// configValue converts value from config file or environment to kind of
+// the option default value when option is known.
+func (a *Application) configValue(key string, value any) any {
+	cnf, ok := a.session.opts.config[key]
+	if !ok {
+		return value
+	}
+	str, isStr := value.(string)
+	if _, isDuration := cnf.value.(time.Duration); isDuration && isStr {
+		if d, err := time.ParseDuration(str); err == nil {
+			return d
+		}
+		return value
+	}
+	def, err := vars.NewValue(cnf.value)
+	if err != nil {
+		return value
+	}
+	val, err := vars.NewValueAs(value, def.Kind())
+	if err != nil {
+		return value
+	}
+	return val.Any()
+}
+
+func parseConfigJSON(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return flattenConfigDoc(doc)
+}
+
+func parseConfigTOML(data []byte) (map[string]any, error) {
+	var doc map[string]any
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return flattenConfigDoc(doc)
+}
+
+// parseConfigYAML parses YAML config file, file must contain
+// single document which is mapping.
+func parseConfigYAML(data []byte) (map[string]any, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	var next any
+	if err := dec.Decode(&next); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: multiple documents", errConfigUnsupported)
+	}
+	return flattenConfigDoc(doc)
+}
+
+// errConfigUnsupported is returned for config values which can not be
+// represented as option value e.g. lists of tables, config file is
+// rejected rather than read into wrong options.
+var errConfigUnsupported = errors.New("unsupported construct")
+
+func flattenConfigDoc(doc map[string]any) (map[string]any, error) {
+	values := make(map[string]any)
+	if err := flattenConfig(values, "", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// flattenConfig adds values of decoded config document into values
+// keyed by dot separated option key, lists are joined into comma
+// separated option value.
+func flattenConfig(values map[string]any, prefix string, doc map[string]any) error {
+	for key, val := range doc {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := val.(type) {
+		case map[string]any:
+			if err := flattenConfig(values, key, v); err != nil {
+				return err
+			}
+		case []any:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				str, err := configListItem(key, item)
+				if err != nil {
+					return err
+				}
+				list = append(list, str)
+			}
+			values[key] = strings.Join(list, ",")
+		case []map[string]any:
+			return fmt.Errorf("%w: %s is list of tables", errConfigUnsupported, key)
+		case json.Number:
+			if i, err := v.Int64(); err == nil {
+				values[key] = int(i)
+			} else if f, err := v.Float64(); err == nil {
+				values[key] = f
+			} else {
+				values[key] = v.String()
+			}
+		case int64:
+			values[key] = int(v)
+		case time.Time:
+			values[key] = v.Format(time.RFC3339Nano)
+		case nil:
+			values[key] = ""
+		default:
+			values[key] = v
+		}
+	}
+	return nil
+}
+
+// configListItem returns list item as string, items which can not be
+// represented in comma separated option value are rejected.
+func configListItem(key string, item any) (string, error) {
+	switch item.(type) {
+	case map[string]any, []any:
+		return "", fmt.Errorf("%w: %s list contains nested values", errConfigUnsupported, key)
+	}
+	str := fmt.Sprint(item)
+	if strings.Contains(str, ",") {
+		return "", fmt.Errorf("%w: %s list item %q contains comma", errConfigUnsupported, key, str)
+	}
+	return str, nil
+}
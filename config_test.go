@@ -0,0 +1,266 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]any, error)
+		data  string
+	}{
+		{"json", parseConfigJSON, `{
+	"app": {"throttle": {"ticks": "250ms"}, "addons": {"disabled": ["a", "b"]}},
+	"log.level": "debug",
+	"cache": {"ttl": 30, "ratio": 0.5, "enabled": true}
+}`},
+		{"toml", parseConfigTOML, `# application config
+log.level = "debug" # inline comment
+
+[app]
+throttle.ticks = '250ms'
+addons.disabled = ["a", "b"]
+
+[cache]
+ttl = 30
+ratio = 0.5
+enabled = true
+`},
+		{"yaml", parseConfigYAML, `---
+# application config
+app:
+  throttle:
+    ticks: 250ms
+  addons:
+    disabled:
+      - a
+      - "b"
+log.level: debug # inline comment
+cache:
+  ttl: 30
+  ratio: 0.5
+  enabled: true
+`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := tt.parse([]byte(tt.data))
+			testutils.NoError(t, err)
+			testutils.Equal(t, 6, len(values))
+			testutils.EqualAny(t, "250ms", values["app.throttle.ticks"])
+			testutils.EqualAny(t, "a,b", values["app.addons.disabled"])
+			testutils.EqualAny(t, "debug", values["log.level"])
+			testutils.EqualAny(t, 30, values["cache.ttl"])
+			testutils.EqualAny(t, 0.5, values["cache.ratio"])
+			testutils.EqualAny(t, true, values["cache.enabled"])
+		})
+	}
+}
+
+func TestParseConfigInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]any, error)
+		data  string
+	}{
+		{"json", parseConfigJSON, `{"log": `},
+		{"toml-bare-string", parseConfigTOML, `level = debug`},
+		{"toml-table", parseConfigTOML, `[[log]]`},
+		{"toml-no-value", parseConfigTOML, `level`},
+		{"toml-duplicate", parseConfigTOML, "level = 1\nlevel = 2"},
+		{"toml-comma-item", parseConfigTOML, `list = ["a,b", "c"]`},
+		{"toml-nested-array", parseConfigTOML, `list = [[1], [2]]`},
+		{"yaml-tab", parseConfigYAML, "log:\n\tlevel: debug"},
+		{"yaml-list", parseConfigYAML, "- debug"},
+		{"yaml-list-of-maps", parseConfigYAML, "items:\n  - name: a\n    v: 1"},
+		{"yaml-list-and-keys", parseConfigYAML, "items:\n  - a\n  b: 1"},
+		{"yaml-indentation", parseConfigYAML, "a: 1\n  b: 2"},
+		{"yaml-flow-comma-item", parseConfigYAML, `list: ["a,b", c]`},
+		{"yaml-duplicate", parseConfigYAML, "a: 1\na: 2"},
+		{"yaml-documents", parseConfigYAML, "a: 1\n---\nb: 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse([]byte(tt.data))
+			testutils.Error(t, err)
+		})
+	}
+}
+
+func TestParseConfigUnsupported(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]any, error)
+		data  string
+	}{
+		{"toml-array-of-tables", parseConfigTOML, "[[log]]"},
+		{"toml-comma-item", parseConfigTOML, `list = ["a,b", "c"]`},
+		{"toml-nested-array", parseConfigTOML, `list = [[1], [2]]`},
+		{"yaml-list-of-maps", parseConfigYAML, "items:\n  - name: a\n    v: 1"},
+		{"yaml-documents", parseConfigYAML, "a: 1\n---\nb: 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.parse([]byte(tt.data))
+			testutils.ErrorIs(t, err, errConfigUnsupported)
+		})
+	}
+}
+
+func TestParseConfigValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func([]byte) (map[string]any, error)
+		data  string
+		key   string
+		want  any
+	}{
+		{"toml-escaped-quote", parseConfigTOML, `msg = "say \"hi\" # not comment"`, "msg", `say "hi" # not comment`},
+		{"toml-array", parseConfigTOML, `list = ["a", 'b', 1]`, "list", "a,b,1"},
+		{"toml-multi-line-array", parseConfigTOML, "list = [\n  \"a\", # first\n  \"b\",\n]", "list", "a,b"},
+		{"toml-inline-table", parseConfigTOML, `log = { level = "debug", file = { path = "/tmp/log" } }`, "log.file.path", "/tmp/log"},
+		{"toml-quoted-key", parseConfigTOML, `"log"."level" = "debug"`, "log.level", "debug"},
+		{"yaml-apostrophe", parseConfigYAML, "msg: don't # comment", "msg", "don't"},
+		{"yaml-hash", parseConfigYAML, "msg: a#b", "msg", "a#b"},
+		{"yaml-escaped-quote", parseConfigYAML, `msg: "say \"hi\" # not comment" # comment`, "msg", `say "hi" # not comment`},
+		{"yaml-single-quote", parseConfigYAML, `msg: 'don''t'`, "msg", "don't"},
+		{"yaml-flow-sequence", parseConfigYAML, `list: [a, "b", 'c'] # comment`, "list", "a,b,c"},
+		{"yaml-url", parseConfigYAML, "url: http://localhost:8080", "url", "http://localhost:8080"},
+		{"yaml-inf", parseConfigYAML, "v: inf", "v", "inf"},
+		{"yaml-comment-parent", parseConfigYAML, "log: # comment\n  level: debug", "log.level", "debug"},
+		{"toml-multi-line-string", parseConfigTOML, `msg = """hello"""`, "msg", "hello"},
+		{"toml-datetime", parseConfigTOML, `at = 2022-01-02T03:04:05Z`, "at", "2022-01-02T03:04:05Z"},
+		{"yaml-flow-mapping", parseConfigYAML, "log: {level: debug}", "log.level", "debug"},
+		{"yaml-block-scalar", parseConfigYAML, "msg: |\n  hello\n  world\n", "msg", "hello\nworld\n"},
+		{"yaml-anchor", parseConfigYAML, "a: &x 1\nb: *x", "b", 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			values, err := tt.parse([]byte(tt.data))
+			testutils.NoError(t, err)
+			testutils.EqualAny(t, tt.want, values[tt.key])
+		})
+	}
+}
+
+func TestAppLoadConfig(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.toml")
+	testutils.NoError(t, os.WriteFile(file, []byte(`
+[log]
+level = "warn"
+
+[app.throttle]
+ticks = "250ms"
+
+[cache]
+ttl = 30
+`), 0600))
+
+	app := New(Option("log.console", false), Option("cache.ttl", 10))
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "--config", file}))
+	testutils.NoError(t, app.loadConfig())
+
+	testutils.Equal(t, "warn", app.session.Get("log.level").String())
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("log.level"))
+	testutils.Equal(t, "default", app.session.OptionSource("log.format"))
+	testutils.Equal(t, "", app.session.OptionSource("unknown"))
+
+	app.activeCmd = app.rootCmd
+	testutils.NoError(t, app.applySettings())
+	app.session.opts.setDefaults()
+	testutils.Equal(t, int64(time.Millisecond*250), app.session.Get("app.throttle.ticks").Int64())
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("app.throttle.ticks"))
+	// file overrides value provided with Option
+	for _, opt := range app.pendingOpts {
+		if opt.key == "cache.ttl" {
+			testutils.EqualAny(t, 30, opt.value)
+		}
+	}
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("cache.ttl"))
+}
+
+func TestAppLoadConfigStandardLocation(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("HOME", dir)
+	slug := fmt.Sprintf("happy-config-test-%d", time.Now().UnixNano())
+	udir, err := os.UserConfigDir()
+	testutils.NoError(t, err)
+	testutils.NoError(t, os.MkdirAll(filepath.Join(udir, slug), 0700))
+	file := filepath.Join(udir, slug, "config.yaml")
+	testutils.NoError(t, os.WriteFile(file, []byte("log:\n  level: error\n"), 0600))
+
+	app := New(Option("log.console", false), Option("app.slug", slug), Option("log.level", "info"))
+	testutils.Equal(t, "option", app.session.OptionSource("log.level"))
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app"}))
+	testutils.NoError(t, app.loadConfig())
+	testutils.Equal(t, "error", app.session.Get("log.level").String())
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("log.level"))
+}
+
+func TestAppLoadConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	invalid := filepath.Join(dir, "config.json")
+	testutils.NoError(t, os.WriteFile(invalid, []byte(`{"app": {"events": {"workers": -1}}}`), 0600))
+	unsupported := filepath.Join(dir, "config.ini")
+	testutils.NoError(t, os.WriteFile(unsupported, []byte(`a=b`), 0600))
+
+	for _, file := range []string{invalid, unsupported, filepath.Join(dir, "missing.json")} {
+		app := New(Option("log.console", false))
+		testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "--config", file}))
+		testutils.Error(t, app.loadConfig())
+	}
+}
+
+func TestAppLoadEnv(t *testing.T) {
+	t.Setenv("HAPPY_ENV_TEST_LOG_LEVEL", "debug")
+	t.Setenv("HAPPY_ENV_TEST_APP_THROTTLE_TICKS", "1s")
+	t.Setenv("HAPPY_ENV_TEST_CACHE_TTL", "30")
+	t.Setenv("HAPPY_ENV_TEST_APP_EVENTS_WORKERS", "2")
+
+	app := New(
+		Option("log.console", false),
+		Option("app.slug", "happy-env-test"),
+		Option("app.events.workers", 1),
+	)
+	addon := NewAddon("cache")
+	addon.Setting("ttl", 10, "ttl in seconds", nil)
+	app.WithAddons(addon)
+	testutils.NoError(t, app.loadEnv())
+
+	testutils.Equal(t, "debug", app.session.Get("log.level").String())
+	testutils.Equal(t, "env:HAPPY_ENV_TEST_LOG_LEVEL", app.session.OptionSource("log.level"))
+	testutils.Equal(t, 2, app.session.Get("app.events.workers").Int())
+	testutils.Equal(t, "env:HAPPY_ENV_TEST_APP_EVENTS_WORKERS", app.session.OptionSource("app.events.workers"))
+
+	app.activeCmd = app.rootCmd
+	testutils.NoError(t, app.applySettings())
+	app.session.opts.setDefaults()
+	testutils.NoError(t, app.registerAddons())
+	testutils.Equal(t, int64(time.Second), app.session.Get("app.throttle.ticks").Int64())
+	testutils.Equal(t, 30, app.session.Get("cache.ttl").Int())
+	testutils.Equal(t, "env:HAPPY_ENV_TEST_CACHE_TTL", app.session.OptionSource("cache.ttl"))
+}
+
+func TestAppLoadEnvInvalid(t *testing.T) {
+	t.Setenv("HAPPY_ENV_TEST_APP_EVENTS_WORKERS", "-1")
+	app := New(Option("log.console", false), Option("app.slug", "happy-env-test"))
+	testutils.ErrorIs(t, app.loadEnv(), ErrOptionValidation)
+}
+
+func TestEnvName(t *testing.T) {
+	testutils.Equal(t, "MY_APP", envPrefix("my-app"))
+	testutils.Equal(t, "MY_APP", envPrefix(".my.app."))
+	testutils.Equal(t, "APP_EVENTS_QUEUE_SIZE", envName("app.events.queue.size"))
+}
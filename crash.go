@@ -0,0 +1,253 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+const (
+	// crashRecentEvents is number of recent events kept for crash report.
+	crashRecentEvents = 50
+	// crashRecentLogs is number of recent log records kept for crash report.
+	crashRecentLogs = 100
+	// crashUploadTimeout is time limit of crash report upload.
+	crashUploadTimeout = 10 * time.Second
+)
+
+// crashReport is written as JSON when application panics.
+type crashReport struct {
+	Time      time.Time `json:"time"`
+	App       string    `json:"app"`
+	Version   string    `json:"version"`
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	PID       int       `json:"pid"`
+	Panic     string    `json:"panic"`
+	// Stack is stack of panicking goroutine,
+	// Goroutines contains stacks of all goroutines.
+	Stack      string              `json:"stack"`
+	Goroutines string              `json:"goroutines"`
+	Session    *SessionDescription `json:"session,omitempty"`
+	Events     []eventRecord       `json:"events,omitempty"`
+	Logs       []hlog.RingRecord   `json:"logs,omitempty"`
+}
+
+// eventHistory keeps last events handled by engine.
+type eventHistory struct {
+	mu      sync.Mutex
+	records []eventRecord
+	next    int
+	full    bool
+}
+
+func newEventHistory(size int) *eventHistory {
+	return &eventHistory{records: make([]eventRecord, size)}
+}
+
+func (h *eventHistory) add(ev Event) {
+	rec := newEventRecord(ev)
+	h.mu.Lock()
+	h.records[h.next] = rec
+	h.next++
+	if h.next == len(h.records) {
+		h.next = 0
+		h.full = true
+	}
+	h.mu.Unlock()
+}
+
+// list returns kept events, oldest first.
+func (h *eventHistory) list() []eventRecord {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.full {
+		return append([]eventRecord(nil), h.records[:h.next]...)
+	}
+	records := make([]eventRecord, 0, len(h.records))
+	records = append(records, h.records[h.next:]...)
+	return append(records, h.records[:h.next]...)
+}
+
+// setupCrashReport installs panic handler when app.crash.report is
+// set, handler is not installed when os.Exit is disabled e.g. in tests.
+func (a *Application) setupCrashReport() {
+	if !a.exitOs || !a.session.Get("app.crash.report").Bool() {
+		return
+	}
+	a.engine.history = newEventHistory(crashRecentEvents)
+	a.engine.onPanic = a.crash
+}
+
+// recoverPanic is deferred in goroutines running application code,
+// panic is passed to panic handler of the application. Without
+// handler panic is not recovered.
+func (e *Engine) recoverPanic() {
+	if e.onPanic == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		e.onPanic(r, debug.Stack())
+	}
+}
+
+// recoverPanic is deferred in goroutines of the application,
+// see Engine.recoverPanic.
+func (a *Application) recoverPanic() {
+	if a.engine == nil || a.engine.onPanic == nil {
+		return
+	}
+	if r := recover(); r != nil {
+		a.engine.onPanic(r, debug.Stack())
+	}
+}
+
+// crash writes and uploads crash report of unrecovered panic
+// and exits with ExitCodePanic.
+func (a *Application) crash(v any, stack []byte) {
+	a.crashOnce.Do(func() {
+		fmt.Fprintf(os.Stderr, "panic: %v\n\n", v)
+		file, err := a.writeCrashReport(v, stack)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write crash report: %s\n%s", err, stack)
+		} else {
+			fmt.Fprintf(os.Stderr, "application crashed, crash report written to %s\n", file)
+		}
+		a.flushCrashLogs()
+		os.Exit(ExitCodePanic)
+	})
+}
+
+// flushCrashLogs writes buffered log records before the process exits
+// on crash since os.Exit skips regular shutdown.
+func (a *Application) flushCrashLogs() {
+	if a.logSampler != nil {
+		a.logSampler.Flush()
+	}
+	if a.logAsync != nil {
+		_ = a.logAsync.Close()
+	}
+}
+
+// newCrashReport returns crash report for panic value v.
+func (a *Application) newCrashReport(v any, stack []byte) *crashReport {
+	report := &crashReport{
+		Time:      time.Now().UTC(),
+		App:       a.session.Get("app.slug").String(),
+		Version:   a.session.Get("app.version").String(),
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		PID:       os.Getpid(),
+		Panic:     fmt.Sprint(v),
+		Stack:     string(stack),
+	}
+	buf := make([]byte, 1<<20)
+	report.Goroutines = string(buf[:runtime.Stack(buf, true)])
+	report.Session = a.describeForCrash()
+	if a.engine != nil && a.engine.history != nil {
+		report.Events = redactEventRecords(a.engine.history.list(), a.session.optionRedactor())
+	}
+	if a.crashLogs != nil {
+		report.Logs = a.crashLogs.Records()
+	}
+	return report
+}
+
+// describeForCrash returns session snapshot, state of crashed
+// application may be inconsistent so failure is ignored.
+func (a *Application) describeForCrash() (desc *SessionDescription) {
+	defer func() {
+		if recover() != nil {
+			desc = nil
+		}
+	}()
+	d := a.session.Describe()
+	return &d
+}
+
+// redactEventRecords masks payload values and errors of records
+// with redactor r, crash reports may be uploaded to remote service.
+func redactEventRecords(records []eventRecord, r *hlog.Redactor) []eventRecord {
+	for i, rec := range records {
+		if rec.Payload != nil {
+			payload := new(vars.Map)
+			rec.Payload.Range(func(v vars.Variable) bool {
+				_ = payload.Store(v.Name(), r.RedactString(v.Name(), v.String()))
+				return true
+			})
+			records[i].Payload = payload
+		}
+		if rec.Err != "" {
+			records[i].Err = r.RedactString("err", rec.Err)
+		}
+	}
+	return records
+}
+
+// writeCrashReport writes crash report to app.crash.dir and uploads
+// it to app.crash.upload when set, it returns path of the report.
+func (a *Application) writeCrashReport(v any, stack []byte) (string, error) {
+	data, err := json.MarshalIndent(a.newCrashReport(v, stack), "", "  ")
+	if err != nil {
+		return "", err
+	}
+	dir := a.session.Get("app.crash.dir").String()
+	if dir == "" {
+		if cache := a.session.Get("app.path.cache").String(); cache != "" {
+			dir = filepath.Join(cache, "crash")
+		} else {
+			dir = filepath.Join(os.TempDir(), instanceSlug(a.session)+"-crash")
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	name := "crash-" + time.Now().UTC().Format("20060102T150405Z") + "-" + strconv.Itoa(os.Getpid()) + ".json"
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return "", err
+	}
+	if url := a.session.Get("app.crash.upload").String(); url != "" {
+		if err := uploadCrashReport(url, data); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to upload crash report: %s\n", err)
+		}
+	}
+	return file, nil
+}
+
+func uploadCrashReport(url string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), crashUploadTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%w: crash report upload failed with status %s", ErrApplication, resp.Status)
+	}
+	return nil
+}
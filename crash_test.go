@@ -0,0 +1,108 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEventHistory(t *testing.T) {
+	h := newEventHistory(2)
+	testutils.Equal(t, 0, len(h.list()))
+	for _, key := range []string{"a", "b", "c"} {
+		h.add(NewEvent("test", key, nil, nil))
+	}
+	records := h.list()
+	testutils.Equal(t, 2, len(records))
+	testutils.Equal(t, "b", records[0].Key)
+	testutils.Equal(t, "c", records[1].Key)
+}
+
+func TestEngineRecoverPanic(t *testing.T) {
+	e := newEngine()
+	var recovered any
+	e.onPanic = func(v any, stack []byte) {
+		recovered = v
+		testutils.True(t, len(stack) > 0, "stack should be captured")
+	}
+	func() {
+		defer e.recoverPanic()
+		panic("boom")
+	}()
+	testutils.EqualAny(t, "boom", recovered)
+
+	// without handler panic is not recovered
+	e.onPanic = nil
+	defer func() {
+		testutils.EqualAny(t, "not recovered", recover())
+	}()
+	func() {
+		defer e.recoverPanic()
+		panic("not recovered")
+	}()
+}
+
+func TestAppWriteCrashReport(t *testing.T) {
+	var uploaded []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutils.Equal(t, http.MethodPost, r.Method)
+		uploaded, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	app := New(
+		Option("log.console", false),
+		Option("app.slug", "crash-test"),
+		Option("app.crash.dir", dir),
+		Option("app.crash.upload", srv.URL),
+	)
+	testutils.NotNil(t, app.crashLogs)
+	app.engine.history = newEventHistory(crashRecentEvents)
+	payload := new(vars.Map)
+	testutils.NoError(t, payload.Store("token", "s3cr3t"))
+	testutils.NoError(t, payload.Store("user", "alice"))
+	app.engine.history.add(NewEvent("test", "before.crash", payload, nil))
+	app.logger.Warn("about to crash")
+
+	file, err := app.writeCrashReport("boom", []byte("goroutine 1 [running]"))
+	testutils.NoError(t, err)
+	testutils.Equal(t, dir, filepath.Dir(file))
+	testutils.True(t, strings.HasPrefix(filepath.Base(file), "crash-"), "unexpected report name %s", file)
+
+	data, err := os.ReadFile(file)
+	testutils.NoError(t, err)
+	testutils.Equal(t, string(data), string(uploaded))
+
+	var report crashReport
+	testutils.NoError(t, json.Unmarshal(data, &report))
+	testutils.Equal(t, "boom", report.Panic)
+	testutils.Equal(t, "crash-test", report.App)
+	testutils.Equal(t, "goroutine 1 [running]", report.Stack)
+	testutils.True(t, report.Goroutines != "", "goroutines should be included")
+	testutils.Equal(t, 1, len(report.Events))
+	testutils.Equal(t, "before.crash", report.Events[0].Key)
+	testutils.False(t, strings.Contains(string(uploaded), "s3cr3t"), "crash report should not contain secret")
+	testutils.Equal(t, hlog.RedactedValue, report.Events[0].Payload.Get("token").String())
+	testutils.Equal(t, "alice", report.Events[0].Payload.Get("user").String())
+	var logged bool
+	for _, rec := range report.Logs {
+		if rec.Message == "about to crash" {
+			logged = true
+		}
+	}
+	testutils.True(t, logged, "recent logs should be included")
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// daemonEnvKey is set for the background process started with --daemon.
+	daemonEnvKey = "HAPPY_DAEMON"
+	// daemonStopTimeout is time stop command waits for daemon to exit.
+	daemonStopTimeout = 10 * time.Second
+)
+
+// daemonPIDFile returns path of the daemon PID file.
+func daemonPIDFile(sess *Session) string {
+	if file := sess.Get("app.daemon.pidfile").String(); file != "" {
+		return file
+	}
+	if cache := sess.Get("app.path.cache").String(); cache != "" {
+		return filepath.Join(cache, "daemon.pid")
+	}
+	return filepath.Join(os.TempDir(), instanceSlug(sess)+".pid")
+}
+
+// readPIDFile returns pid stored in PID file.
+func readPIDFile(file string) (int, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || pid <= 0 {
+		return 0, fmt.Errorf("%w: invalid PID file %s", ErrDaemon, file)
+	}
+	return pid, nil
+}
+
+// runningDaemon returns pid of running daemon, stale PID file is removed.
+func runningDaemon(sess *Session) (int, bool) {
+	file := daemonPIDFile(sess)
+	pid, err := readPIDFile(file)
+	if err != nil {
+		return 0, false
+	}
+	if !processRunning(pid) {
+		_ = os.Remove(file)
+		return 0, false
+	}
+	return pid, true
+}
+
+// daemonize starts application in background without --daemon flag,
+// stdin of the daemon is /dev/null and stdout and stderr are written
+// to log.file when it is set.
+func (a *Application) daemonize() error {
+	if pid, running := runningDaemon(a.session); running {
+		return fmt.Errorf("%w: already running with pid %d", ErrDaemon, pid)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	var args []string
+	for _, arg := range a.args[1:] {
+		if name, _, _ := strings.Cut(strings.TrimLeft(arg, "-"), "="); name == "daemon" && strings.HasPrefix(arg, "-") {
+			continue
+		}
+		args = append(args, arg)
+	}
+
+	outfile := os.DevNull
+	if file := a.session.Get("log.file").String(); file != "" {
+		outfile = file
+	}
+	out, err := os.OpenFile(outfile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	defer out.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), daemonEnvKey+"=1")
+	cmd.Stdout = out
+	cmd.Stderr = out
+	cmd.SysProcAttr = daemonSysProcAttr()
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	a.logger.Ok("daemon started",
+		slog.Int("pid", cmd.Process.Pid),
+		slog.String("pidfile", daemonPIDFile(a.session)),
+	)
+	return cmd.Process.Release()
+}
+
+// startDaemon prepares background process started by daemonize,
+// it sets umask and writes PID file which is removed on exit.
+func (a *Application) startDaemon() error {
+	mask, err := strconv.ParseUint(a.session.Get("app.daemon.umask").String(), 8, 32)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	setUmask(int(mask))
+
+	if pid, running := runningDaemon(a.session); running && pid != os.Getpid() {
+		return fmt.Errorf("%w: already running with pid %d", ErrDaemon, pid)
+	}
+	file := daemonPIDFile(a.session)
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	pid := os.Getpid()
+	if err := os.WriteFile(file, []byte(strconv.Itoa(pid)+"\n"), 0644); err != nil {
+		return fmt.Errorf("%w: %s", ErrDaemon, err.Error())
+	}
+	a.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
+		// PID file may already belong to another daemon
+		if stored, err := readPIDFile(file); err != nil || stored != pid {
+			return nil
+		}
+		if err := os.Remove(file); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	})
+
+	// stderr is written to log file already
+	if a.session.Get("log.file").String() != "" && a.session.Get("log.console").Bool() {
+		if err := a.session.opts.set("log.console", false, true); err != nil {
+			return err
+		}
+		a.configureLogger()
+	}
+	a.logger.SystemDebug("daemon running",
+		slog.Int("pid", pid),
+		slog.String("pidfile", file),
+	)
+	return nil
+}
+
+// daemonCommands returns stop and status commands managing daemon
+// started with --daemon flag.
+func daemonCommands() []*Command {
+	stop := NewCommand(
+		"stop",
+		Option("usage", "stop application daemon"),
+		Option("description", "Stop application daemon started with --daemon flag and wait for it to exit."),
+		Option("category", "DAEMON"),
+	)
+	stop.Do(func(sess *Session, args Args) error {
+		pid, running := runningDaemon(sess)
+		if !running {
+			return fmt.Errorf("%w: not running", ErrDaemon)
+		}
+		if err := terminateProcess(pid); err != nil {
+			return fmt.Errorf("%w: failed to stop %d: %s", ErrDaemon, pid, err.Error())
+		}
+		deadline := time.NewTimer(daemonStopTimeout)
+		defer deadline.Stop()
+		poll := time.NewTicker(100 * time.Millisecond)
+		defer poll.Stop()
+		for processRunning(pid) {
+			select {
+			case <-deadline.C:
+				return fmt.Errorf("%w: %d did not exit in %s", ErrDaemon, pid, daemonStopTimeout)
+			case <-sess.Done():
+				return sess.Err()
+			case <-poll.C:
+			}
+		}
+		fmt.Fprintf(os.Stdout, "stopped (pid %d)\n", pid)
+		return nil
+	})
+
+	status := NewCommand(
+		"status",
+		Option("usage", "print application daemon status"),
+		Option("description", "Print status of application daemon started with --daemon flag, fails when daemon is not running. With --watch renders live status dashboard of the daemon read from its diagnostics service enabled with app.diagnostics.addr."),
+		Option("category", "DAEMON"),
+	)
+	addStatusFlags(status)
+	status.Do(func(sess *Session, args Args) error {
+		pid, running := runningDaemon(sess)
+		if !running {
+			fmt.Fprintln(os.Stdout, "not running")
+			return fmt.Errorf("%w: not running", ErrDaemon)
+		}
+		if args.Flag("watch").Present() {
+			return diagnosticsStatus(sess, args)
+		}
+		fmt.Fprintf(os.Stdout, "running (pid %d)\n", pid)
+		return nil
+	})
+	return []*Command{stop, status}
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix
+
+package happy
+
+import (
+	"os"
+	"syscall"
+)
+
+// daemonSysProcAttr is nil on platforms without sessions.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// setUmask is noop on platforms without umask.
+func setUmask(mask int) {}
+
+// processRunning reports whether process with pid exists.
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
+
+// terminateProcess kills the process since there are no signals
+// to ask process to exit gracefully.
+func terminateProcess(pid int) error {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return p.Kill()
+}
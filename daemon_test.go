@@ -0,0 +1,90 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newDaemonTestApp(t *testing.T) (*Application, string) {
+	t.Helper()
+	pidfile := filepath.Join(t.TempDir(), "app.pid")
+	app := New(
+		Option("log.console", false),
+		Option("app.daemon", true),
+		Option("app.daemon.pidfile", pidfile),
+	)
+	app.exitOs = false
+	return app, pidfile
+}
+
+func TestDaemonCommands(t *testing.T) {
+	sleep, err := exec.LookPath("sleep")
+	if err != nil {
+		t.Skip("sleep not found")
+	}
+	app, pidfile := newDaemonTestApp(t)
+	_, err = app.rootCmd.flags.Get("daemon")
+	testutils.NoError(t, err)
+	cmds := daemonCommands()
+	stop, status := cmds[0], cmds[1]
+
+	testutils.ErrorIs(t, status.callDoAction(app.session), ErrDaemon)
+	testutils.ErrorIs(t, stop.callDoAction(app.session), ErrDaemon)
+
+	proc := exec.Command(sleep, "30")
+	testutils.NoError(t, proc.Start())
+	exited := make(chan struct{})
+	go func() {
+		_ = proc.Wait()
+		close(exited)
+	}()
+	testutils.NoError(t, os.WriteFile(pidfile, []byte(strconv.Itoa(proc.Process.Pid)), 0600))
+
+	testutils.NoError(t, status.callDoAction(app.session))
+	testutils.NoError(t, stop.callDoAction(app.session))
+	<-exited
+	testutils.ErrorIs(t, status.callDoAction(app.session), ErrDaemon)
+	// stale PID file is removed
+	_, err = os.Stat(pidfile)
+	testutils.True(t, os.IsNotExist(err))
+}
+
+func TestDaemonPIDFile(t *testing.T) {
+	app, pidfile := newDaemonTestApp(t)
+	mask := syscall.Umask(0)
+	defer syscall.Umask(mask)
+	testutils.NoError(t, app.startDaemon())
+	testutils.Equal(t, 0o022, syscall.Umask(mask))
+
+	pid, err := readPIDFile(pidfile)
+	testutils.NoError(t, err)
+	testutils.Equal(t, os.Getpid(), pid)
+	running, ok := runningDaemon(app.session)
+	testutils.True(t, ok)
+	testutils.Equal(t, os.Getpid(), running)
+
+	for _, fn := range app.exitFunc {
+		testutils.NoError(t, fn.fn(app.session, 0))
+	}
+	_, err = os.Stat(pidfile)
+	testutils.True(t, os.IsNotExist(err))
+}
+
+func TestDaemonDisabled(t *testing.T) {
+	app := New(Option("log.console", false))
+	_, err := app.rootCmd.flags.Get("daemon")
+	testutils.Error(t, err)
+	testutils.ErrorIs(t, app.session.opts.set("app.daemon.umask", "999", true), ErrOptionValidation)
+}
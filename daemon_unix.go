@@ -0,0 +1,32 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"errors"
+	"syscall"
+)
+
+// daemonSysProcAttr detaches daemon from controlling terminal.
+func daemonSysProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
+
+func setUmask(mask int) {
+	syscall.Umask(mask)
+}
+
+// processRunning reports whether process with pid exists.
+func processRunning(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// terminateProcess asks process to exit gracefully.
+func terminateProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
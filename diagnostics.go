@@ -0,0 +1,188 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// diagnosticsServiceName is name of built-in diagnostics service
+// enabled with app.diagnostics.addr option.
+const diagnosticsServiceName = "diagnostics"
+
+// diagnosticsService serves /debug/pprof, /healthz, /readyz, /debug/engine,
+// /debug/session, /debug/addons, /debug/runtime, /debug/stats and
+// /debug/status endpoints
+// on given local address.
+func diagnosticsService(addr string) *Service {
+	svc := NewService(diagnosticsServiceName)
+
+	var server *http.Server
+
+	svc.OnStart(func(sess *Session) error {
+		laddr, err := diagnosticsListenAddr(addr, sess.Get("app.diagnostics.public").Bool())
+		if err != nil {
+			return err
+		}
+		ln, err := net.Listen("tcp", laddr)
+		if err != nil {
+			return err
+		}
+		server = &http.Server{
+			Handler:           diagnosticsHandler(sess),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				sess.Log().Error("diagnostics server failed", err)
+			}
+		}()
+		sess.Log().Info("diagnostics server listening", slog.String("addr", ln.Addr().String()))
+		return nil
+	})
+
+	svc.OnStop(func(sess *Session) error {
+		if server == nil {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	})
+	return svc
+}
+
+func diagnosticsHandler(sess *Session) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	handleHealth(mux, sess)
+	mux.HandleFunc("/debug/engine", func(w http.ResponseWriter, r *http.Request) {
+		desc := sess.Describe()
+		writeDiagnosticsJSON(w, struct {
+			Uptime   time.Duration `json:"uptime"`
+			Paused   bool          `json:"paused"`
+			TickRate time.Duration `json:"tick_rate"`
+			Events   EventStats    `json:"events"`
+		}{desc.Uptime, desc.Paused, desc.TickRate, desc.Events})
+	})
+	mux.HandleFunc("/debug/session", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.Describe())
+	})
+	// GET returns current log level, PUT or POST with ?level=debug changes it
+	mux.HandleFunc("/debug/loglevel", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPut, http.MethodPost:
+			if err := sess.Set("log.level", r.URL.Query().Get("level")); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeDiagnosticsJSON(w, struct {
+			Level string `json:"level"`
+		}{sess.Log().Level().String()})
+	})
+	mux.HandleFunc("/debug/addons", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.AddonHealth())
+	})
+	mux.HandleFunc("/debug/logmetrics", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.LogMetrics())
+	})
+	mux.HandleFunc("/debug/runtime", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.RuntimeStats())
+	})
+	mux.HandleFunc("/debug/stats", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.stats())
+	})
+	mux.HandleFunc("/debug/status", func(w http.ResponseWriter, r *http.Request) {
+		writeDiagnosticsJSON(w, sess.status())
+	})
+	return mux
+}
+
+func writeDiagnosticsJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// diagnosticsAddr returns address of diagnostics service which commands
+// such as "app stats" read state of running application from.
+func diagnosticsAddr(sess *Session) (string, error) {
+	addr := strings.TrimSpace(sess.Get("app.diagnostics.addr").String())
+	if addr == "" {
+		return "", fmt.Errorf("%w: requires app.diagnostics.addr of running application", ErrCommand)
+	}
+	return addr, nil
+}
+
+// diagnosticsListenAddr returns address diagnostics service listens on.
+// Empty host binds loopback interface, other than loopback hosts are
+// rejected unless public is set since diagnostics expose session and
+// profiling data.
+func diagnosticsListenAddr(addr string, public bool) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" {
+		return net.JoinHostPort("127.0.0.1", port), nil
+	}
+	if public || host == "localhost" {
+		return addr, nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return addr, nil
+	}
+	return "", fmt.Errorf("%w: diagnostics address %q is not loopback, set app.diagnostics.public to serve it", ErrService, addr)
+}
+
+// fetchDiagnostics decodes JSON served on path by diagnostics
+// service listening on addr into v.
+func fetchDiagnostics(ctx context.Context, addr, path string, v any) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+		host = "127.0.0.1"
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	url := "http://" + net.JoinHostPort(host, port) + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, res.Status)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestDiagnosticsHandler(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	handler := diagnosticsHandler(sess)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	testutils.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	sess.readyFunc()
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/session", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	var desc SessionDescription
+	testutils.NoError(t, json.Unmarshal(rec.Body.Bytes(), &desc))
+	testutils.True(t, desc.Ready, "session should be ready")
+	testutils.Equal(t, "block", desc.Config["app.events.overflow"])
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/cmdline", nil))
+	testutils.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestDiagnosticsSessionRedacted(t *testing.T) {
+	defaults, err := getDefaultApplicationConfig()
+	testutils.NoError(t, err)
+	defaults = append(defaults, OptionArg{
+		key:       "api.token",
+		value:     "s3cr3t",
+		kind:      SettingsOption,
+		validator: noopvalidator,
+	})
+	opts, err := NewOptions("config", defaults)
+	testutils.NoError(t, err)
+	testutils.NoError(t, opts.setDefaults())
+	sess := &Session{sessionState: &sessionState{
+		logger: hlog.New(hlog.NewHandler(io.Discard)),
+		opts:   opts,
+	}}
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+
+	rec := httptest.NewRecorder()
+	diagnosticsHandler(sess).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/session", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	testutils.False(t, strings.Contains(rec.Body.String(), "s3cr3t"), "session description should not contain secret")
+	var desc SessionDescription
+	testutils.NoError(t, json.Unmarshal(rec.Body.Bytes(), &desc))
+	testutils.Equal(t, hlog.RedactedValue, desc.Settings["api.token"])
+}
+
+func TestDiagnosticsListenAddr(t *testing.T) {
+	tests := []struct {
+		addr   string
+		public bool
+		want   string
+		err    bool
+	}{
+		{":6060", false, "127.0.0.1:6060", false},
+		{"127.0.0.1:6060", false, "127.0.0.1:6060", false},
+		{"[::1]:6060", false, "[::1]:6060", false},
+		{"localhost:6060", false, "localhost:6060", false},
+		{"0.0.0.0:6060", false, "", true},
+		{"10.0.0.1:6060", false, "", true},
+		{"0.0.0.0:6060", true, "0.0.0.0:6060", false},
+		{"6060", false, "", true},
+	}
+	for _, tt := range tests {
+		got, err := diagnosticsListenAddr(tt.addr, tt.public)
+		if tt.err {
+			testutils.Error(t, err, tt.addr)
+			continue
+		}
+		testutils.NoError(t, err)
+		testutils.Equal(t, tt.want, got)
+	}
+}
+
+func TestDiagnosticsLogLevel(t *testing.T) {
+	app := New()
+	handler := diagnosticsHandler(app.session)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/debug/loglevel?level=debug", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	testutils.True(t, strings.Contains(rec.Body.String(), `"level": "debug"`), rec.Body.String())
+	testutils.Equal(t, hlog.LevelDebug, app.session.Log().Level())
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/loglevel?level=loud", nil))
+	testutils.Equal(t, http.StatusBadRequest, rec.Code)
+
+	testutils.Equal(t, hlog.LevelInfo, moreVerboseLevel(hlog.LevelWarn))
+	testutils.Equal(t, hlog.LevelDebug, moreVerboseLevel(hlog.LevelInfo))
+	testutils.Equal(t, hlog.LevelSystemDebug, moreVerboseLevel(hlog.LevelDebug))
+	testutils.Equal(t, hlog.LevelTrace, moreVerboseLevel(hlog.LevelSystemDebug))
+}
+
+func TestDiagnosticsLogMetrics(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.session.Log().Error("failed", nil, "service", "cache")
+	handler := diagnosticsHandler(app.session)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/logmetrics", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	var samples []hlog.MetricsSample
+	testutils.NoError(t, json.Unmarshal(rec.Body.Bytes(), &samples))
+	testutils.Equal(t, 1, len(samples))
+	testutils.Equal(t, "service=cache", samples[0].Labels)
+	testutils.Equal(t, uint64(1), samples[0].Count)
+}
+
+func TestDiagnosticsAddonHealth(t *testing.T) {
+	var healthErr error
+	addon := NewAddon("db", Option("version", "v1.0.0"))
+	addon.OnHealthCheck(func(sess *Session) error { return healthErr })
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	sess.readyFunc()
+	sess.registerAddonHealth(addon)
+	handler := diagnosticsHandler(sess)
+
+	status := func() (int, []AddonHealth) {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		code := rec.Code
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/addons", nil))
+		var health []AddonHealth
+		testutils.NoError(t, json.Unmarshal(rec.Body.Bytes(), &health))
+		return code, health
+	}
+
+	code, health := status()
+	testutils.Equal(t, http.StatusOK, code)
+	testutils.Equal(t, 1, len(health))
+	testutils.Equal(t, AddonHealthy, health[0].Status)
+	testutils.Equal(t, "v1.0.0", health[0].Version)
+
+	healthErr = fmt.Errorf("%w: replica lag", ErrAddonDegraded)
+	code, health = status()
+	testutils.Equal(t, http.StatusOK, code)
+	testutils.Equal(t, AddonDegraded, health[0].Status)
+
+	healthErr = errors.New("connection refused")
+	code, health = status()
+	testutils.Equal(t, http.StatusServiceUnavailable, code)
+	testutils.Equal(t, AddonFailed, health[0].Status)
+	testutils.Equal(t, "connection refused", health[0].Err)
+}
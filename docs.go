@@ -0,0 +1,366 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mkungla/happy/pkg/varflag"
+)
+
+// docCommand is command reference used to generate documentation.
+type docCommand struct {
+	path        []string
+	usage       string
+	description string
+	flags       []docFlag
+	args        []ArgDef
+	examples    []CommandExample
+	subs        []*docCommand
+}
+
+type docFlag struct {
+	names   []string
+	usage   string
+	defval  string
+	choices []string
+}
+
+func (c *docCommand) name() string {
+	return strings.Join(c.path, " ")
+}
+
+func (c *docCommand) synopsis() string {
+	synopsis := c.name()
+	if len(c.flags) > 0 {
+		synopsis += " [flags]"
+	}
+	if len(c.subs) > 0 {
+		synopsis += " [subcommand]"
+	}
+	for _, arg := range c.args {
+		synopsis += " " + arg.String()
+	}
+	return synopsis
+}
+
+func (c *docCommand) walk(fn func(c *docCommand) error) error {
+	if err := fn(c); err != nil {
+		return err
+	}
+	for _, sub := range c.subs {
+		if err := sub.walk(fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newDocFlags(flags []varflag.Flag) []docFlag {
+	var dflags []docFlag
+	for _, flag := range flags {
+		if flag.Hidden() {
+			continue
+		}
+		dflag := docFlag{
+			names:  []string{flag.Flag()},
+			usage:  flag.Usage(),
+			defval: flag.Default().String(),
+		}
+		for _, alias := range flag.Aliases() {
+			if alias == flag.Name() || alias == "" {
+				continue
+			}
+			if len(alias) == 1 {
+				dflag.names = append(dflag.names, "-"+alias)
+			} else {
+				dflag.names = append(dflag.names, "--"+alias)
+			}
+		}
+		if opt, ok := flag.(interface{ Options() []string }); ok {
+			dflag.choices = opt.Options()
+		}
+		dflags = append(dflags, dflag)
+	}
+	sort.Slice(dflags, func(i, j int) bool {
+		return dflags[i].names[0] < dflags[j].names[0]
+	})
+	return dflags
+}
+
+func newDocCommand(cmd *Command, parent []string) *docCommand {
+	path := make([]string, len(parent), len(parent)+1)
+	copy(path, parent)
+	dcmd := &docCommand{
+		path:        append(path, cmd.name),
+		usage:       cmd.usage,
+		description: cmd.desc,
+		flags:       newDocFlags(cmd.flags.Flags()),
+		args:        cmd.argdefs,
+		examples:    cmd.examples,
+	}
+	names := make([]string, 0, len(cmd.subCommands))
+	for name, sub := range cmd.subCommands {
+		if sub.hidden {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dcmd.subs = append(dcmd.subs, newDocCommand(cmd.subCommands[name], dcmd.path))
+	}
+	return dcmd
+}
+
+func writeMarkdownDocs(w io.Writer, root *Command) error {
+	tree := newDocCommand(root, nil)
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n", tree.name())
+	err := tree.walk(func(c *docCommand) error {
+		if c != tree {
+			fmt.Fprintf(&b, "\n## %s\n", c.name())
+		}
+		if c.usage != "" {
+			fmt.Fprintf(&b, "\n%s\n", c.usage)
+		}
+		if c.description != "" && c.description != c.usage {
+			fmt.Fprintf(&b, "\n%s\n", c.description)
+		}
+		fmt.Fprintf(&b, "\n```\n%s\n```\n", c.synopsis())
+		if len(c.args) > 0 {
+			b.WriteString("\n**Arguments**\n\n")
+			for _, arg := range c.args {
+				fmt.Fprintf(&b, "- `%s` %s\n", arg.String(), arg.Usage)
+			}
+		}
+		if len(c.examples) > 0 {
+			b.WriteString("\n**Examples**\n\n```\n")
+			for i, ex := range c.examples {
+				if i > 0 {
+					b.WriteString("\n")
+				}
+				if ex.Description != "" {
+					fmt.Fprintf(&b, "# %s\n", ex.Description)
+				}
+				fmt.Fprintf(&b, "%s\n", ex.Cmdline)
+			}
+			b.WriteString("```\n")
+		}
+		if len(c.subs) > 0 {
+			b.WriteString("\n**Subcommands**\n\n")
+			for _, sub := range c.subs {
+				fmt.Fprintf(&b, "- [%s](#%s) %s\n", sub.path[len(sub.path)-1], markdownAnchor(sub.name()), sub.usage)
+			}
+		}
+		if len(c.flags) > 0 {
+			title := "Flags"
+			if c == tree {
+				title = "Global flags"
+			}
+			fmt.Fprintf(&b, "\n**%s**\n\n", title)
+			b.WriteString("| flag | default | description |\n")
+			b.WriteString("|------|---------|-------------|\n")
+			for _, f := range c.flags {
+				usage := f.usage
+				if len(f.choices) > 0 {
+					usage += " (one of: " + strings.Join(f.choices, ", ") + ")"
+				}
+				fmt.Fprintf(&b, "| `%s` | %s | %s |\n", strings.Join(f.names, "`, `"), f.defval, usage)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func markdownAnchor(s string) string {
+	return strings.ToLower(strings.ReplaceAll(s, " ", "-"))
+}
+
+// manEscape escapes text for roff.
+func manEscape(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\e`)
+	s = strings.ReplaceAll(s, "-", `\-`)
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.HasPrefix(line, ".") || strings.HasPrefix(line, "'") {
+			line = `\&` + line
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func writeManPage(w io.Writer, c *docCommand, global []docFlag, version string, date time.Time) error {
+	var b strings.Builder
+	title := strings.ToUpper(strings.Join(c.path, "-"))
+	fmt.Fprintf(&b, ".TH %q \"1\" %q %q %q\n", title, date.Format("Jan 2006"), version, c.path[0]+" manual")
+	b.WriteString(".SH NAME\n")
+	fmt.Fprintf(&b, "%s", manEscape(strings.Join(c.path, "-")))
+	if c.usage != "" {
+		fmt.Fprintf(&b, " \\- %s", manEscape(c.usage))
+	}
+	b.WriteString("\n.SH SYNOPSIS\n")
+	fmt.Fprintf(&b, "\\fB%s\\fP", manEscape(c.name()))
+	if len(c.flags) > 0 || len(global) > 0 {
+		b.WriteString(" [flags]")
+	}
+	if len(c.subs) > 0 {
+		b.WriteString(" [subcommand]")
+	}
+	for _, arg := range c.args {
+		b.WriteString(" " + manEscape(arg.String()))
+	}
+	b.WriteString("\n")
+	if len(c.args) > 0 {
+		b.WriteString(".SH ARGUMENTS\n")
+		for _, arg := range c.args {
+			fmt.Fprintf(&b, ".TP\n\\fB%s\\fP\n%s\n", manEscape(arg.String()), manEscape(arg.Usage))
+		}
+	}
+	if c.description != "" {
+		fmt.Fprintf(&b, ".SH DESCRIPTION\n%s\n", manEscape(c.description))
+	}
+	writeFlags := func(section string, flags []docFlag) {
+		if len(flags) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, ".SH %s\n", section)
+		for _, f := range flags {
+			names := make([]string, len(f.names))
+			for i, name := range f.names {
+				names[i] = "\\fB" + manEscape(name) + "\\fP"
+			}
+			fmt.Fprintf(&b, ".TP\n%s\n%s", strings.Join(names, ", "), manEscape(f.usage))
+			if len(f.choices) > 0 {
+				fmt.Fprintf(&b, " (one of: %s)", manEscape(strings.Join(f.choices, ", ")))
+			}
+			if f.defval != "" {
+				fmt.Fprintf(&b, " [default: %s]", manEscape(f.defval))
+			}
+			b.WriteString("\n")
+		}
+	}
+	writeFlags("OPTIONS", c.flags)
+	writeFlags("GLOBAL OPTIONS", global)
+	if len(c.examples) > 0 {
+		b.WriteString(".SH EXAMPLES\n")
+		for _, ex := range c.examples {
+			if ex.Description != "" {
+				fmt.Fprintf(&b, ".PP\n%s\n", manEscape(ex.Description))
+			}
+			fmt.Fprintf(&b, ".PP\n.RS\n\\fB%s\\fP\n.RE\n", manEscape(ex.Cmdline))
+		}
+	}
+	if len(c.subs) > 0 || len(c.path) > 1 {
+		b.WriteString(".SH SEE ALSO\n")
+		var refs []string
+		if len(c.path) > 1 {
+			refs = append(refs, "\\fB"+manEscape(strings.Join(c.path[:len(c.path)-1], "-"))+"\\fP(1)")
+		}
+		for _, sub := range c.subs {
+			refs = append(refs, "\\fB"+manEscape(strings.Join(sub.path, "-"))+"\\fP(1)")
+		}
+		b.WriteString(strings.Join(refs, ", ") + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeManPages writes man page for root command and each sub command
+// into dir, page of command "app sub" is written to app-sub.1.
+func writeManPages(dir string, root *Command, version string) error {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return err
+	}
+	tree := newDocCommand(root, nil)
+	date := time.Now()
+	return tree.walk(func(c *docCommand) error {
+		var global []docFlag
+		if c != tree {
+			global = tree.flags
+		}
+		file, err := os.Create(filepath.Join(dir, strings.Join(c.path, "-")+".1"))
+		if err != nil {
+			return err
+		}
+		err = writeManPage(file, c, global, version, date)
+		return errors.Join(err, file.Close())
+	})
+}
+
+// docsCommand is hidden command generating documentation
+// e.g. in release pipelines with "app docs man ./man".
+func docsCommand(root *Command) *Command {
+	cmd := NewCommand(
+		"docs",
+		Option("usage", "generate man pages or markdown reference"),
+		Option("hidden", true),
+		Option("skip.addons", true),
+	)
+	cmd.walksTree = true
+	cmd.AddArg(ArgDef{Name: "format", Usage: "docs format man or markdown", Min: 1, Max: 1})
+	cmd.AddArg(ArgDef{Name: "dir", Usage: "output directory, markdown is written to stdout when omitted", Min: 0, Max: 1})
+	cmd.Do(func(sess *Session, args Args) error {
+		dir := args.Named("dir").String()
+		switch format := args.Named("format").String(); format {
+		case "man":
+			if dir == "" {
+				dir = "."
+			}
+			return writeManPages(dir, root, sess.Get("app.version").String())
+		case "markdown", "md":
+			if dir == "" {
+				return writeMarkdownDocs(os.Stdout, root)
+			}
+			if err := os.MkdirAll(dir, 0750); err != nil {
+				return err
+			}
+			file, err := os.Create(filepath.Join(dir, root.name+".md"))
+			if err != nil {
+				return err
+			}
+			return errors.Join(writeMarkdownDocs(file, root), file.Close())
+		default:
+			return fmt.Errorf("%w: unknown docs format %q", ErrCommand, format)
+		}
+	})
+	return cmd
+}
+
+// WriteMarkdownDocs writes markdown reference of application commands
+// and flags, e.g. for release pipelines.
+func (a *Application) WriteMarkdownDocs(w io.Writer) error {
+	if a.rootCmd == nil {
+		return fmt.Errorf("%w: root command was not initialized", ErrApplication)
+	}
+	if err := a.rootCmd.loadSubCommands(); err != nil {
+		return err
+	}
+	return writeMarkdownDocs(w, a.rootCmd)
+}
+
+// WriteManPages writes man pages of application commands into dir.
+func (a *Application) WriteManPages(dir string) error {
+	if a.rootCmd == nil {
+		return fmt.Errorf("%w: root command was not initialized", ErrApplication)
+	}
+	if err := a.rootCmd.loadSubCommands(); err != nil {
+		return err
+	}
+	return writeManPages(dir, a.rootCmd, a.session.Get("app.version").String())
+}
@@ -0,0 +1,39 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestWriteDocs(t *testing.T) {
+	root := newTestCompletionRoot(t)
+
+	var b strings.Builder
+	testutils.NoError(t, writeMarkdownDocs(&b, root))
+	md := b.String()
+	testutils.True(t, strings.Contains(md, "## app deploy"), "missing subcommand section")
+	testutils.True(t, strings.Contains(md, "`--env`"), "missing subcommand flag")
+	testutils.True(t, strings.Contains(md, "`--verbose`, `-v`"), "missing global flag")
+	testutils.True(t, strings.Contains(md, "one of: dev, prod"), "missing flag choices")
+	testutils.False(t, strings.Contains(md, "internal"), "hidden command in docs")
+	testutils.True(t, strings.Contains(md, "# deploy to production\napp deploy --env prod\n"), "missing example")
+
+	dir := t.TempDir()
+	testutils.NoError(t, writeManPages(dir, root, "v1.0.0"))
+	page, err := os.ReadFile(filepath.Join(dir, "app-deploy.1"))
+	testutils.NoError(t, err)
+	testutils.True(t, strings.HasPrefix(string(page), `.TH "APP-DEPLOY" "1"`), "invalid man page header")
+	testutils.True(t, strings.Contains(string(page), `\fB\-\-env\fP`), "missing flag in man page")
+	testutils.True(t, strings.Contains(string(page), ".SH GLOBAL OPTIONS"), "missing global options")
+	testutils.True(t, strings.Contains(string(page), ".SH EXAMPLES"), "missing examples")
+	_, err = os.Stat(filepath.Join(dir, "app.1"))
+	testutils.NoError(t, err)
+}
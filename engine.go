@@ -8,12 +8,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mkungla/happy/pkg/address"
 	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/pkg/version"
 	"golang.org/x/exp/slog"
 )
 
@@ -22,8 +26,15 @@ var nooptock = func(sess *Session, delta time.Duration, tps int) error { return
 
 type Engine struct {
 	mu      sync.RWMutex
+	sess    *Session
 	running bool
-	started time.Time
+	paused  bool
+
+	// tick interval, 0 disables ticker. tickChanged is closed
+	// and replaced when interval changes.
+	tickInterval time.Duration
+	tickChanged  chan struct{}
+	started      time.Time
 
 	tickAction ActionTick
 	tockAction ActionTock
@@ -35,15 +46,63 @@ type Engine struct {
 
 	evCancel  context.CancelFunc
 	evContext context.Context
+	evPool    *eventPool
+	journal   *eventJournal
 
 	registry map[string]*serviceContainer
 	events   map[string]Event
+	schemas  map[string]EventSchema
+	// events registered only because service listens for them,
+	// emitter registering such event replaces the placeholder
+	listened map[string]bool
+
+	buses     map[string]*eventBus
+	busScopes map[string]string
+	bridges   []eventBridge
+
+	// events held back while engine is paused
+	pausedEvents []Event
+
+	// validate event payloads against schemas
+	validatePayloads bool
+
+	tracer     Tracer
+	middleware []EventMiddleware
+	watchdog   *watchdog
+	scheduler  *eventScheduler
+	stats      *statsSampler
+
+	// exporters added with AddMetricsExporter and pusher
+	// pushing metrics to them and to built-in exporters.
+	metricsExporters []namedMetricsExporter
+	metricsPusher    *metricsPusher
+
+	// systemd notifications and time of last engine tick
+	// driving systemd watchdog keepalives.
+	notifier *sdNotifier
+	lastTick atomic.Int64
+
+	// recent events and panic handler set when app.crash.report is set
+	history *eventHistory
+	onPanic func(v any, stack []byte)
+
+	// deterministic mode, ticks are advanced manually
+	// and events are delivered synchronously.
+	deterministic bool
+	clock         time.Time
 }
 
 func newEngine() *Engine {
 	engine := &Engine{
 		registry: make(map[string]*serviceContainer),
 		events:   make(map[string]Event),
+		schemas:  make(map[string]EventSchema),
+		listened: make(map[string]bool),
+
+		buses:     make(map[string]*eventBus),
+		busScopes: make(map[string]string),
+
+		scheduler: newEventScheduler(),
 	}
 
 	return engine
@@ -52,21 +111,58 @@ func newEngine() *Engine {
 func (e *Engine) start(sess *Session) error {
 	sess.Log().SystemDebug("starting engine ...")
 	e.started = time.Now()
+	e.lastTick.Store(e.started.UnixNano())
+	e.notifier = newSDNotifier()
+	e.sess = sess
+	e.tickInterval = time.Duration(sess.Get("app.throttle.ticks").Int64())
+	e.tickChanged = make(chan struct{})
+	if sess.Get("app.engine.deterministic").Bool() {
+		sess.Log().SystemDebug("engine is in deterministic mode")
+		e.deterministic = true
+		e.clock = e.started
+		e.tickInterval = 0
+	}
 	if e.tickAction == nil && e.tockAction != nil {
 		return fmt.Errorf("%w: register tick action or move tock logic into tick action", ErrEngine)
 	}
 
+	if err := e.verifyEventBuses(); err != nil {
+		return err
+	}
+
 	var init sync.WaitGroup
 
+	e.watchdog = newWatchdog(time.Duration(sess.Get("app.engine.watchdog").Int64()))
+
 	e.loopStart(sess, &init)
+	go e.watchdog.run(e.ctx, sess)
+	e.stats = newStatsSampler(
+		time.Duration(sess.Get("app.stats.interval").Int64()),
+		sess.Get("app.stats.history").Int(),
+	)
+	go e.stats.run(e.ctx, sess)
+	e.metricsPusher = newMetricsPusher(sess, e.metricsExporters)
+	go e.metricsPusher.run(e.ctx, sess)
+	go sess.monitor.runAlerts(e.ctx, sess, time.Duration(sess.Get("app.monitor.alerts.interval").Int64()))
 
 	e.servicesInit(sess, &init)
 
 	init.Wait()
 
+	e.validatePayloads = version.IsDev(sess.Get("app.version").String())
+
+	if err := e.openJournal(sess); err != nil {
+		return err
+	}
+
 	if e.engineOK {
 		e.startEventDispatcher(sess)
+		if !e.deterministic {
+			go e.scheduler.run(e.evContext, sess)
+		}
 		sess.setReady()
+		e.notifier.notify(sess, "READY=1")
+		go e.runWatchdog(e.ctx, sess)
 	} else {
 		sess.Destroy(fmt.Errorf("%w: starting engine failed", ErrEngine))
 	}
@@ -76,7 +172,221 @@ func (e *Engine) start(sess *Session) error {
 	return nil
 }
 
+// openJournal opens event journal when app.events.journal is set.
+// Relative journal path is resolved against app.path.cache when
+// application filesystem is enabled.
+func (e *Engine) openJournal(sess *Session) error {
+	jpath := sess.Get("app.events.journal").String()
+	if jpath == "" {
+		return nil
+	}
+	if !filepath.IsAbs(jpath) {
+		if cache := sess.Get("app.path.cache").String(); cache != "" {
+			jpath = filepath.Join(cache, jpath)
+		}
+	}
+	journal, err := openEventJournal(jpath)
+	if err != nil {
+		return errors.Join(fmt.Errorf("%w: failed to open event journal", ErrEngine), err)
+	}
+	e.journal = journal
+	sess.setJournal(journal)
+	sess.Log().SystemDebug("event journal", slog.String("file", jpath))
+	return nil
+}
+
+// Pause suspends engine and service ticks and delivery of events
+// until Resume is called. Services are kept running and events in
+// "engine" and "services" scope are still delivered, other events
+// are delivered after engine is resumed.
+func (e *Engine) Pause() error {
+	e.mu.Lock()
+	if !e.running {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: can not pause engine which is not running", ErrEngine)
+	}
+	if e.paused {
+		e.mu.Unlock()
+		return nil
+	}
+	e.paused = true
+	sess := e.sess
+	e.mu.Unlock()
+
+	sess.Log().SystemDebug("engine paused")
+	sess.Dispatch(NewEvent("engine", "paused", nil, nil))
+	return nil
+}
+
+// Resume resumes paused engine and delivers events held back while
+// engine was paused.
+func (e *Engine) Resume() error {
+	e.mu.Lock()
+	if !e.paused {
+		e.mu.Unlock()
+		return nil
+	}
+	e.paused = false
+	held := e.pausedEvents
+	e.pausedEvents = nil
+	sess := e.sess
+	e.mu.Unlock()
+
+	sess.Log().SystemDebug("engine resumed", slog.Int("held.events", len(held)))
+	if e.synchronous() {
+		for _, ev := range held {
+			e.handleEvent(sess, ev)
+		}
+	} else {
+		// held events are handled by event loop in order they were dispatched
+		sess.requeueEvents(held)
+	}
+	sess.Dispatch(NewEvent("engine", "resumed", nil, nil))
+	return nil
+}
+
+// Paused reports whether engine is paused.
+func (e *Engine) Paused() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.paused
+}
+
+// SetTickRate changes interval of engine and service ticks at runtime.
+// Interval 0 disables ticks, which is useful for applications driven
+// purely by events.
+func (e *Engine) SetTickRate(interval time.Duration) error {
+	if interval < 0 {
+		return fmt.Errorf("%w: invalid tick rate %s", ErrEngine, interval)
+	}
+	if e.deterministic {
+		return fmt.Errorf("%w: can not set tick rate in deterministic mode, use Advance", ErrEngine)
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.tickInterval == interval {
+		return nil
+	}
+	e.tickInterval = interval
+	if e.tickChanged != nil {
+		close(e.tickChanged)
+		e.tickChanged = make(chan struct{})
+	}
+	return nil
+}
+
+// TickRate returns current interval of engine and service ticks.
+func (e *Engine) TickRate() time.Duration {
+	interval, _ := e.tickRate()
+	return interval
+}
+
+func (e *Engine) tickRate() (time.Duration, <-chan struct{}) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.tickInterval, e.tickChanged
+}
+
+// Advance advances manual clock of deterministic engine by d and calls
+// tick and tock of the engine and running services synchronously
+// in order of service addresses. Engine is in deterministic mode when
+// app.engine.deterministic option is set, which is useful in tests.
+func (e *Engine) Advance(d time.Duration) error {
+	if d <= 0 {
+		return fmt.Errorf("%w: can not advance clock by %s", ErrEngine, d)
+	}
+	e.mu.Lock()
+	if !e.deterministic || !e.running {
+		e.mu.Unlock()
+		return fmt.Errorf("%w: Advance requires running engine in deterministic mode", ErrEngine)
+	}
+	e.clock = e.clock.Add(d)
+	now := e.clock
+	paused := e.paused
+	sess := e.sess
+	var addrs []string
+	for addr, svcc := range e.registry {
+		if svcc.svc.tickAction != nil && svcc.info.Running() {
+			addrs = append(addrs, addr)
+		}
+	}
+	registry := e.registry
+	e.mu.Unlock()
+
+	// scheduled events are dispatched even when engine is paused,
+	// engine delivers them once resumed.
+	events, _ := e.scheduler.due(now)
+	for _, ev := range events {
+		sess.Dispatch(ev)
+	}
+
+	if paused {
+		return nil
+	}
+
+	if e.tickAction != nil {
+		if err := e.tickAction(sess, now, d); err != nil {
+			sess.Dispatch(NewEvent("engine", "app.tick.err", nil, err))
+			return err
+		}
+		if e.tockAction != nil {
+			if err := e.tockAction(sess, 0, 0); err != nil {
+				sess.Dispatch(NewEvent("engine", "app.tock.err", nil, err))
+				return err
+			}
+		}
+	}
+
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		svcc := registry[addr]
+		if err := svcc.tick(sess, now, d); err != nil {
+			e.serviceStop(sess, addr, err)
+			continue
+		}
+		if err := svcc.tock(sess, 0, 0); err != nil {
+			e.serviceStop(sess, addr, err)
+		}
+	}
+	return nil
+}
+
+// Now returns current time of the engine, in deterministic mode
+// it is time of manual clock.
+func (e *Engine) Now() time.Time {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.deterministic {
+		return e.clock
+	}
+	return time.Now()
+}
+
+// synchronous reports whether events are delivered synchronously.
+func (e *Engine) synchronous() bool {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.deterministic && e.running
+}
+
+// holdEvent holds back non critical events while engine is paused.
+func (e *Engine) holdEvent(ev Event) bool {
+	if ev.Scope() == "engine" || ev.Scope() == "services" {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if !e.paused {
+		return false
+	}
+	e.pausedEvents = append(e.pausedEvents, ev)
+	return true
+}
+
 func (e *Engine) uptime() time.Duration {
+	if e.started.IsZero() {
+		return 0
+	}
 	return time.Since(e.started)
 }
 
@@ -92,7 +402,14 @@ func (e *Engine) onTock(action ActionTock) {
 func (e *Engine) startEventDispatcher(sess *Session) {
 	e.evContext, e.evCancel = context.WithCancel(sess)
 
+	if workers := sess.Get("app.events.workers").Int(); workers > 0 {
+		e.evPool = newEventPool(e, workers, sess.Get("app.events.queue.size").Int())
+		e.evPool.start(e.evContext, sess)
+	}
+	e.startEventBuses(sess)
+
 	go func(sess *Session) {
+		defer e.recoverPanic()
 	evLoop:
 		for {
 			select {
@@ -103,6 +420,11 @@ func (e *Engine) startEventDispatcher(sess *Session) {
 					continue
 				}
 				e.handleEvent(sess, ev)
+				if len(sess.evch) == 0 {
+					for _, ev := range sess.takeCoalesced() {
+						e.handleEvent(sess, ev)
+					}
+				}
 			}
 		}
 	}(sess)
@@ -112,18 +434,96 @@ func (e *Engine) registerEvent(ev Event) error {
 	e.mu.Lock()
 	defer e.mu.Unlock()
 	skey := ev.Scope() + "." + ev.Key()
-	if _, ok := e.events[skey]; ok {
+	if _, ok := e.events[skey]; ok && !e.listened[skey] {
 		return fmt.Errorf("%w: event already registered %s", ErrEngine, skey)
 	}
+	delete(e.listened, skey)
 	e.events[skey] = ev
 	return nil
 }
 
+// registerListenedEvent registers placeholder for event service listens
+// for, placeholder is replaced when emitter registers the event.
+func (e *Engine) registerListenedEvent(scope, key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	skey := scope + "." + key
+	if _, ok := e.events[skey]; ok {
+		return
+	}
+	e.events[skey] = registerEvent(scope, key, "has listener", nil)
+	e.listened[skey] = true
+}
+
+// checkRequest returns error when request event can not be replied
+// because it is not registered or no service listens for it.
+func (e *Engine) checkRequest(ev Event) error {
+	skey := ev.Scope() + "." + ev.Key()
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if _, ok := e.events[skey]; !ok {
+		return fmt.Errorf("%w: request %s is not registered", ErrEngine, skey)
+	}
+	for _, svcc := range e.registry {
+		for _, listener := range svcc.svc.listeners {
+			if eventPatternMatch(listener.lid, ev.Scope(), ev.Key()) {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%w: request %s has no listeners", ErrEngine, skey)
+}
+
+func (e *Engine) registerEventSchema(schema EventSchema) error {
+	if err := e.registerEvent(schema.event()); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.schemas[schema.Scope+"."+schema.Key] = schema
+	return nil
+}
+
+// EventSchemas returns schemas of all registered events sorted by scope and key.
+// Events registered without schema are described by their example payload.
+func (e *Engine) EventSchemas() []EventSchema {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var schemas []EventSchema
+	for skey, ev := range e.events {
+		if schema, ok := e.schemas[skey]; ok {
+			schemas = append(schemas, schema)
+			continue
+		}
+		schemas = append(schemas, schemaFromEvent(ev))
+	}
+	sort.Slice(schemas, func(i, j int) bool {
+		if schemas[i].Scope != schemas[j].Scope {
+			return schemas[i].Scope < schemas[j].Scope
+		}
+		return schemas[i].Key < schemas[j].Key
+	})
+	return schemas
+}
+
 func (e *Engine) handleEvent(sess *Session, ev Event) {
+	e.handleEventOn(sess, ev, nil)
+}
+
+// handleEventOn handles event dispatched to ingress bus,
+// nil bus is main event loop.
+func (e *Engine) handleEventOn(sess *Session, ev Event, ingress *eventBus) {
+	if batch, ok := ev.(*eventBatch); ok {
+		for _, bev := range batch.events {
+			e.handleEventOn(sess, bev, ingress)
+		}
+		return
+	}
 	skey := ev.Scope() + "." + ev.Key()
 
 	e.mu.RLock()
 	_, rev := e.events[skey]
+	schema, hasSchema := e.schemas[skey]
 	registry := e.registry
 	e.mu.RUnlock()
 
@@ -131,26 +531,56 @@ func (e *Engine) handleEvent(sess *Session, ev Event) {
 		sess.Log().NotImplemented("event not registered, ignoring", slog.String("scope", ev.Scope()), slog.String("key", ev.Key()))
 		return
 	}
+	if e.holdEvent(ev) {
+		return
+	}
+	sess.evstats.observe(time.Since(ev.Time()))
+	sess.monitor.eventDelivered(ev.Scope())
+	if e.validatePayloads && hasSchema {
+		if err := schema.Validate(ev.Payload()); err != nil {
+			sess.Log().Error("invalid event payload, ignoring", err)
+			return
+		}
+	}
+	if e.history != nil {
+		e.history.add(ev)
+	}
+	if e.journal != nil {
+		if err := e.journal.append(ev); err != nil {
+			sess.Log().Error("failed to write event journal", err)
+		}
+	}
 	switch ev.Scope() {
 	case "services":
 		switch ev.Key() {
 		case "start.services":
 			payload := ev.Payload()
 			payload.Range(func(v vars.Variable) bool {
-				go e.serviceStart(sess, v.String())
+				if e.deterministic {
+					e.serviceStart(sess, v.String())
+				} else {
+					go e.serviceStart(sess, v.String())
+				}
 				return true
 			})
 		case "stop.services":
 			payload := ev.Payload()
 			payload.Range(func(v vars.Variable) bool {
-				go e.serviceStop(sess, v.String(), nil)
+				if e.deterministic {
+					e.serviceStop(sess, v.String(), nil)
+				} else {
+					go e.serviceStop(sess, v.String(), nil)
+				}
 				return true
 			})
 		}
 	}
-	for _, svcc := range registry {
-		go svcc.handleEvent(sess, ev)
+	ctx, span := e.startEventSpan("happy.event.dispatch", ev)
+	if e.tracer != nil {
+		ev = WithTraceParent(ev, e.tracer.Inject(ctx))
 	}
+	e.publishEvent(sess, ev, registry, ingress)
+	span.End(nil)
 	sess.Log().SystemDebug("event", slog.String("scope", ev.Scope()), slog.String("key", ev.Key()))
 }
 
@@ -172,10 +602,30 @@ func (e *Engine) loopStart(sess *Session, init *sync.WaitGroup) {
 	defer init.Done()
 
 	go func() {
+		defer e.recoverPanic()
 		lastTick := time.Now()
 
-		ttick := time.NewTicker(time.Duration(sess.Get("app.throttle.ticks").Int64()))
-		defer ttick.Stop()
+		interval, changed := e.tickRate()
+		ttick := newEngineTicker(interval)
+		defer ttick.stop()
+
+		// mark engine running only if first tick tock are successful,
+		// without ticker there is nothing to wait for.
+		ready := func() {
+			e.readyCallback.Do(func() {
+				sess.Log().SystemDebug("engine started")
+
+				e.mu.Lock()
+				e.engineOK = true
+				e.mu.Unlock()
+
+				init.Done()
+			})
+		}
+		if interval <= 0 {
+			sess.Log().SystemDebug("engine ticker disabled")
+			ready()
+		}
 
 	engineLoop:
 		for {
@@ -183,31 +633,47 @@ func (e *Engine) loopStart(sess *Session, init *sync.WaitGroup) {
 			case <-e.ctx.Done():
 				sess.Log().SystemDebug("engineLoop ctx Done")
 				break engineLoop
-			case now := <-ttick.C:
-				// mark engine running only if first tick tock are successful
-				e.readyCallback.Do(func() {
-					sess.Log().SystemDebug("engine started")
-
-					e.mu.Lock()
-					e.engineOK = true
-					e.mu.Unlock()
+			case <-changed:
+				prev := interval
+				interval, changed = e.tickRate()
+				ttick.reset(interval)
+				if interval <= 0 {
+					ready()
+				} else if prev <= 0 {
+					// first tick after ticker was disabled should not
+					// report time while it was disabled as delta
+					lastTick = time.Now()
+				}
+			case now := <-ttick.c():
+				ready()
+				e.lastTick.Store(now.UnixNano())
 
-					init.Done()
-				})
+				if e.Paused() {
+					lastTick = now
+					continue
+				}
 
 				delta := now.Sub(lastTick)
 				lastTick = now
+				_, span := e.startSpan(e.ctx, "happy.engine.tick", slog.Duration("delta", delta))
+				wid := e.watchdog.begin("engine.tick", "")
 				if err := e.tickAction(sess, lastTick, delta); err != nil {
+					e.watchdog.end(wid)
+					span.End(err)
 					sess.Log().Error("tick error", err)
 					sess.Dispatch(NewEvent("engine", "app.tick.err", nil, err))
 					break engineLoop
 				}
 				tickDelta := time.Since(lastTick)
 				if err := e.tockAction(sess, tickDelta, 0); err != nil {
+					e.watchdog.end(wid)
+					span.End(err)
 					sess.Log().Error("tock error", err)
 					sess.Dispatch(NewEvent("engine", "app.tock.err", nil, err))
 					break engineLoop
 				}
+				e.watchdog.end(wid)
+				span.End(nil)
 
 			}
 		}
@@ -220,33 +686,28 @@ func (e *Engine) stop(sess *Session) error {
 		return nil
 	}
 	sess.Log().SystemDebug("stopping engine")
+	e.notifier.notify(sess, "STOPPING=1")
 
 	e.ctxCancel()
 	<-e.ctx.Done()
 
 	e.evCancel()
 	<-e.evContext.Done()
-
-	var graceful sync.WaitGroup
-	for u, rsvc := range e.registry {
-		if !rsvc.info.Running() {
-			continue
+	if e.evPool != nil {
+		e.evPool.wait()
+	}
+	e.waitEventBuses()
+	if e.journal != nil {
+		if err := e.journal.close(); err != nil {
+			sess.Log().Error("failed to close event journal", err)
 		}
-		go func(url string, svcc *serviceContainer) {
-			defer graceful.Done()
-			// wait for engine context is canceled which triggers
-			// r.ctx also to be cancelled, however lets wait for the
-			// context done since r.ctx is cancelled after last tickk completes.
-			// so e.xtc is not parent of r.ctx.
-			<-svcc.Done()
-			// lets call stop now we know that tick loop has exited.
-			e.serviceStop(sess, url, nil)
-		}(u, rsvc)
-		graceful.Add(1)
-	}
-	graceful.Wait()
+	}
+
+	// engine context is cancelled so service contexts are cancelled as well
+	// and tick loops exit, stop services in dependency order.
+	err := e.stopServices(sess, time.Duration(sess.Get("app.engine.shutdown.timeout").Int64()))
 	sess.Log().SystemDebug("engine stopped")
-	return nil
+	return err
 }
 
 func (e *Engine) serviceRegister(sess *Session, svc *Service) error {
@@ -293,17 +754,27 @@ func (e *Engine) servicesInit(sess *Session, init *sync.WaitGroup) {
 	for svcaddrstr, svcc := range e.registry {
 		go func(addr string, c *serviceContainer) {
 			defer init.Done()
+			defer e.recoverPanic()
 			if err := c.initialize(sess); err != nil {
 				sess.Log().Error("failed to initialize service", err, slog.String("service", c.info.Addr().String()))
 				return
 			}
+			// register events service emits
+			for _, schema := range c.svc.schemas {
+				if err := e.registerEventSchema(schema); err != nil {
+					sess.Log().Error("failed to register event", err, slog.String("service", c.info.Addr().String()))
+				}
+			}
 			// register events what service listens for
-			for ev, _ := range c.svc.listeners {
-				scope, key, _ := strings.Cut(ev, ".")
-				// we can ignore error because this error is handled
-				// when emitter registers this event. Listening
-				// for unregistered event is not an error.
-				_ = e.registerEvent(registerEvent(scope, key, "has listener", nil))
+			for _, listener := range c.svc.listeners {
+				// wildcard listeners do not register events
+				if listener.lid == "any" || isEventPattern(listener.lid) {
+					continue
+				}
+				scope, key, _ := strings.Cut(listener.lid, ".")
+				// listening for unregistered event is not an error,
+				// emitter initialized later replaces the placeholder.
+				e.registerListenedEvent(scope, key)
 			}
 		}(svcaddrstr, svcc)
 	}
@@ -311,6 +782,7 @@ func (e *Engine) servicesInit(sess *Session, init *sync.WaitGroup) {
 }
 
 func (e *Engine) serviceStart(sess *Session, svcurl string) {
+	defer e.recoverPanic()
 	e.mu.RLock()
 	svcc, ok := e.registry[svcurl]
 	e.mu.RUnlock()
@@ -340,7 +812,9 @@ func (e *Engine) serviceStart(sess *Session, svcurl string) {
 		return
 	}
 
+	_, span := e.startSpan(e.ctx, "happy.service.start", sarg)
 	if err := svcc.start(e.ctx, sess); err != nil {
+		span.End(err)
 		sess.Log().Error(
 			"failed to start service",
 			err,
@@ -348,8 +822,10 @@ func (e *Engine) serviceStart(sess *Session, svcurl string) {
 		)
 		return
 	}
+	span.End(nil)
 
 	go func(svcc *serviceContainer, svcurl string, sarg slog.Attr) {
+		defer e.recoverPanic()
 
 		if svcc.svc.tickAction == nil {
 			<-e.ctx.Done()
@@ -357,8 +833,9 @@ func (e *Engine) serviceStart(sess *Session, svcurl string) {
 			return
 		}
 
-		ttick := time.NewTicker(time.Duration(sess.Get("app.throttle.ticks").Int64()))
-		defer ttick.Stop()
+		interval, changed := e.tickRate()
+		ttick := newEngineTicker(interval)
+		defer ttick.stop()
 
 		lastTick := time.Now()
 		tis := 0
@@ -369,7 +846,18 @@ func (e *Engine) serviceStart(sess *Session, svcurl string) {
 			case <-svcc.ctx.Done():
 				svcc.cancel(nil)
 				break ticker
-			case now := <-ttick.C:
+			case <-changed:
+				prev := interval
+				interval, changed = e.tickRate()
+				ttick.reset(interval)
+				if prev <= 0 && interval > 0 {
+					lastTick = time.Now()
+				}
+			case now := <-ttick.c():
+				if e.Paused() {
+					lastTick = now
+					continue
+				}
 				if lastTick.Truncate(time.Second) == now.Truncate(time.Second) {
 					tis++
 				} else {
@@ -378,32 +866,86 @@ func (e *Engine) serviceStart(sess *Session, svcurl string) {
 				}
 				delta := now.Sub(lastTick)
 				lastTick = now
+				_, span := e.startSpan(svcc.ctx, "happy.service.tick", sarg, slog.Duration("delta", delta))
+				wid := e.watchdog.begin("service.tick", svcurl)
 				if err := svcc.tick(sess, lastTick, delta); err != nil {
+					e.watchdog.end(wid)
+					span.End(err)
 					e.serviceStop(sess, svcurl, err)
 					break ticker
 				}
 				tickDelta := time.Since(lastTick)
 				if err := svcc.tock(sess, tickDelta, tps); err != nil {
+					e.watchdog.end(wid)
+					span.End(err)
 					e.serviceStop(sess, svcurl, err)
 					break ticker
 				}
+				e.watchdog.end(wid)
+				span.End(nil)
 			}
 		}
 	}(svcc, svcurl, sarg)
 }
 
 func (e *Engine) serviceStop(sess *Session, svcurl string, err error) {
+	if serr := e.stopService(sess, svcurl, err); serr != nil {
+		sess.Log().Error("failed to stop service", serr, slog.String("service", svcurl))
+	}
+}
+
+func (e *Engine) stopService(sess *Session, svcurl string, err error) error {
+	defer e.recoverPanic()
 	sarg := slog.String("service", svcurl)
 
 	e.mu.RLock()
 	svcc, ok := e.registry[svcurl]
 	e.mu.RUnlock()
 	if !ok {
-		sess.Log().Warn("no such service to start", sarg)
-		return
+		sess.Log().Warn("no such service to stop", sarg)
+		return nil
 	}
 	sess.Log().SystemDebug("stopping service", sarg)
-	if e := svcc.stop(sess, err); e != nil {
-		sess.Log().Error("failed to stop service", e, sarg)
+	_, span := e.startSpan(context.Background(), "happy.service.stop", sarg)
+	serr := svcc.stop(sess, err)
+	span.End(serr)
+	return serr
+}
+
+// engineTicker is time.Ticker which can be disabled,
+// disabled ticker never fires.
+type engineTicker struct {
+	ticker *time.Ticker
+}
+
+func newEngineTicker(interval time.Duration) *engineTicker {
+	t := &engineTicker{}
+	t.reset(interval)
+	return t
+}
+
+func (t *engineTicker) c() <-chan time.Time {
+	if t.ticker == nil {
+		return nil
+	}
+	return t.ticker.C
+}
+
+func (t *engineTicker) reset(interval time.Duration) {
+	if interval <= 0 {
+		t.stop()
+		return
+	}
+	if t.ticker == nil {
+		t.ticker = time.NewTicker(interval)
+		return
+	}
+	t.ticker.Reset(interval)
+}
+
+func (t *engineTicker) stop() {
+	if t.ticker != nil {
+		t.ticker.Stop()
+		t.ticker = nil
 	}
 }
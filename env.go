@@ -0,0 +1,206 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mkungla/happy/pkg/varflag"
+)
+
+// envInfo describes environment of the application printed by
+// "app env" command, it is meant to be attached to bug reports.
+type envInfo struct {
+	App         string               `json:"app"`
+	Version     string               `json:"version"`
+	Profile     string               `json:"profile"`
+	Instance    string               `json:"instance,omitempty"`
+	InstanceID  string               `json:"instance_id,omitempty"`
+	RunID       string               `json:"run_id,omitempty"`
+	Paths       map[string]string    `json:"paths"`
+	ConfigFiles []string             `json:"config_files,omitempty"`
+	Options     []envOption          `json:"options"`
+	Addons      []AddonHealth        `json:"addons"`
+	Services    []ServiceDescription `json:"services"`
+	Build       envBuild             `json:"build"`
+}
+
+// envOption is option which value was not default.
+type envOption struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type envBuild struct {
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	Module    string `json:"module,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Time      string `json:"time,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+}
+
+// envPaths are options with resolved application paths.
+var envPaths = []string{"wd", "home", "tmp", "cache", "config"}
+
+// env returns environment of the application, values of sensitive
+// options are redacted.
+func (a *Application) env(sess *Session) envInfo {
+	desc := sess.Describe()
+	info := envInfo{
+		App:        sess.Get("app.name").String(),
+		Version:    sess.Get("app.version").String(),
+		Profile:    desc.Profile,
+		Instance:   desc.Instance,
+		InstanceID: desc.InstanceID,
+		RunID:      desc.RunID,
+		Paths:      make(map[string]string),
+		Addons:     desc.Addons,
+		Services:   desc.Services,
+		Build: envBuild{
+			GoVersion: runtime.Version(),
+			OS:        runtime.GOOS,
+			Arch:      runtime.GOARCH,
+		},
+	}
+	for _, name := range envPaths {
+		if path := sess.Get("app.path." + name).String(); path != "" {
+			info.Paths[name] = path
+		}
+	}
+	if files, err := a.configFiles(); err == nil {
+		info.ConfigFiles = files
+	}
+
+	redactor := a.redactor
+	if redactor == nil {
+		redactor = a.newRedactor()
+	}
+	for key, src := range desc.Sources {
+		info.Options = append(info.Options, envOption{
+			Key:    key,
+			Value:  redactor.RedactString(key, sess.Get(key).String()),
+			Source: src,
+		})
+	}
+	sort.Slice(info.Options, func(i, j int) bool {
+		return info.Options[i].Key < info.Options[j].Key
+	})
+
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		info.Build.Module = bi.Main.Path
+		for _, setting := range bi.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				info.Build.Revision = setting.Value
+			case "vcs.time":
+				info.Build.Time = setting.Value
+			case "vcs.modified":
+				info.Build.Modified = setting.Value == "true"
+			}
+		}
+	}
+	return info
+}
+
+// writeEnvTable writes environment of the application as table.
+func writeEnvTable(w io.Writer, info envInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "APPLICATION")
+	fmt.Fprintf(tw, "  name\t%s\n", info.App)
+	fmt.Fprintf(tw, "  version\t%s\n", info.Version)
+	fmt.Fprintf(tw, "  profile\t%s\n", info.Profile)
+	if info.Instance != "" {
+		fmt.Fprintf(tw, "  instance\t%s\n", info.Instance)
+	}
+	if info.InstanceID != "" {
+		fmt.Fprintf(tw, "  instance id\t%s\n", info.InstanceID)
+	}
+	if info.RunID != "" {
+		fmt.Fprintf(tw, "  run id\t%s\n", info.RunID)
+	}
+
+	fmt.Fprintln(tw, "\nBUILD")
+	fmt.Fprintf(tw, "  go\t%s %s/%s\n", info.Build.GoVersion, info.Build.OS, info.Build.Arch)
+	if info.Build.Module != "" {
+		fmt.Fprintf(tw, "  module\t%s\n", info.Build.Module)
+	}
+	if info.Build.Revision != "" {
+		revision := info.Build.Revision
+		if info.Build.Modified {
+			revision += " (modified)"
+		}
+		fmt.Fprintf(tw, "  revision\t%s %s\n", revision, info.Build.Time)
+	}
+
+	fmt.Fprintln(tw, "\nPATHS")
+	for _, name := range envPaths {
+		if path, ok := info.Paths[name]; ok {
+			fmt.Fprintf(tw, "  %s\t%s\n", name, path)
+		}
+	}
+	for _, file := range info.ConfigFiles {
+		fmt.Fprintf(tw, "  config file\t%s\n", file)
+	}
+
+	fmt.Fprintln(tw, "\nOPTIONS")
+	for _, opt := range info.Options {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", opt.Key, opt.Value, opt.Source)
+	}
+
+	fmt.Fprintln(tw, "\nADDONS")
+	for _, addon := range info.Addons {
+		status := string(addon.Status)
+		if addon.Err != "" {
+			status += ": " + addon.Err
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\n", addon.Name, addon.Version, status)
+	}
+
+	fmt.Fprintln(tw, "\nSERVICES")
+	for _, svc := range info.Services {
+		state := "stopped"
+		if svc.Running {
+			state = "running"
+		}
+		if len(svc.Errs) > 0 {
+			state += ": " + strings.Join(svc.Errs, "; ")
+		}
+		fmt.Fprintf(tw, "  %s\t%s\n", svc.Addr, state)
+	}
+	return tw.Flush()
+}
+
+// envCommand is command printing environment of the application
+// e.g. "app env" or "app env --json".
+func envCommand(a *Application) *Command {
+	cmd := NewCommand(
+		"env",
+		Option("usage", "print application environment for support and bug reports"),
+		Option("description", "Print resolved paths, options which are not defaults with their sources, loaded addons, registered services and build info. Values of sensitive options are redacted."),
+	)
+	jsonFlag, _ := varflag.Bool("json", false, "print environment as JSON")
+	cmd.AddFlag(jsonFlag)
+	cmd.Do(func(sess *Session, args Args) error {
+		info := a.env(sess)
+		if args.Flag("json").Present() {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		}
+		return writeEnvTable(os.Stdout, info)
+	})
+	return cmd
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestAppEnv(t *testing.T) {
+	app := New(
+		Option("log.console", false),
+		Option("log.redact", "app.description"),
+		Option("app.description", "internal s3cr3t"),
+		Option("app.name", "Env Test"),
+	)
+	app.activeCmd = app.rootCmd
+	app.WithAddons(NewAddon("mail", Option("version", "v1.2.3")))
+	testutils.NoError(t, app.registerAddons())
+	testutils.NoError(t, app.session.start())
+	defer app.session.Destroy(nil)
+
+	info := app.env(app.session)
+	testutils.Equal(t, "Env Test", info.App)
+	testutils.NotEqual(t, "", info.Build.GoVersion)
+
+	var description *envOption
+	for i, opt := range info.Options {
+		if opt.Key == "app.description" {
+			description = &info.Options[i]
+		}
+	}
+	testutils.NotNil(t, description)
+	testutils.Equal(t, hlog.RedactedValue, description.Value)
+	testutils.Equal(t, "option", description.Source)
+
+	var buf bytes.Buffer
+	testutils.NoError(t, writeEnvTable(&buf, info))
+	out := buf.String()
+	for _, section := range []string{"APPLICATION", "BUILD", "PATHS", "OPTIONS", "ADDONS", "SERVICES"} {
+		testutils.True(t, strings.Contains(out, section), "missing section "+section)
+	}
+	testutils.True(t, strings.Contains(out, "mail"), "missing addon")
+	testutils.False(t, strings.Contains(out, "s3cr3t"), "sensitive option leaked")
+}
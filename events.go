@@ -0,0 +1,639 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// eventPatternMatch reports whether event with given scope and key
+// matches listener pattern "scope.key". Both scope and key part
+// of the pattern can use glob syntax supported by path.Match e.g.
+// "services.*", "*.config.changed" or "*.*". Pattern "any" matches
+// all events.
+func eventPatternMatch(pattern, scope, key string) bool {
+	if pattern == "any" {
+		return true
+	}
+	pscope, pkey, ok := strings.Cut(pattern, ".")
+	if !ok {
+		return false
+	}
+	if !isEventPattern(pattern) {
+		return pscope == scope && pkey == key
+	}
+	if matched, err := path.Match(pscope, scope); err != nil || !matched {
+		return false
+	}
+	matched, err := path.Match(pkey, key)
+	return err == nil && matched
+}
+
+// isEventPattern reports whether listener id contains glob syntax.
+func isEventPattern(lid string) bool {
+	return strings.ContainsAny(lid, "*?[")
+}
+
+type eventListener struct {
+	lid      string
+	priority int
+	seq      int
+	cb       ActionWithEvent
+}
+
+type eventDelivery struct {
+	svcc     *serviceContainer
+	addr     string
+	listener eventListener
+}
+
+// eventDeliveries returns listeners of all services matching the event
+// in deterministic order: by priority, service address and registration order.
+func eventDeliveries(registry map[string]*serviceContainer, ev Event) []eventDelivery {
+	var deliveries []eventDelivery
+	for addr, svcc := range registry {
+		for _, listener := range svcc.svc.listeners {
+			if !eventPatternMatch(listener.lid, ev.Scope(), ev.Key()) {
+				continue
+			}
+			deliveries = append(deliveries, eventDelivery{
+				svcc:     svcc,
+				addr:     addr,
+				listener: listener,
+			})
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		a, b := deliveries[i], deliveries[j]
+		if a.listener.priority != b.listener.priority {
+			return a.listener.priority > b.listener.priority
+		}
+		if a.addr != b.addr {
+			return a.addr < b.addr
+		}
+		return a.listener.seq < b.listener.seq
+	})
+	return deliveries
+}
+
+// EventMiddleware wraps delivery of every event e.g. to filter, enrich,
+// measure or authorize events. Middleware which does not call next
+// drops the event, middleware can pass modified event to next.
+type EventMiddleware func(next ActionWithEvent) ActionWithEvent
+
+// UseEventMiddleware adds middleware to event delivery chain.
+// Middlewares are called in order they were added.
+func (e *Engine) UseEventMiddleware(mw ...EventMiddleware) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.middleware = append(e.middleware, mw...)
+}
+
+// deliverEvent passes the event through middleware chain
+// and delivers it to listeners.
+func (e *Engine) deliverEvent(sess *Session, ev Event, registry map[string]*serviceContainer) {
+	defer e.recoverPanic()
+	e.mu.RLock()
+	middleware := e.middleware
+	e.mu.RUnlock()
+
+	if len(middleware) == 0 {
+		e.deliverToListeners(sess, ev, registry)
+		return
+	}
+	handler := func(sess *Session, ev Event) error {
+		if ev == nil {
+			return nil
+		}
+		e.deliverToListeners(sess, ev, registry)
+		return nil
+	}
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	if err := handler(sess, ev); err != nil {
+		sess.Log().Error(
+			"event middleware failed",
+			err,
+			slog.String("scope", ev.Scope()),
+			slog.String("key", ev.Key()),
+		)
+	}
+}
+
+// deliverToListeners calls listeners matching the event one by one in order.
+// Listener which fails after app.events.retries retries is reported
+// as dead letter. Retries wait app.events.retries.backoff multiplied
+// by number of failed attempts.
+func (e *Engine) deliverToListeners(sess *Session, ev Event, registry map[string]*serviceContainer) {
+	timeout := time.Duration(sess.Get("app.events.handler.timeout").Int64())
+	backoff := time.Duration(sess.Get("app.events.retries.backoff").Int64())
+	if e.deterministic {
+		timeout = 0
+		backoff = 0
+	}
+	retries := sess.Get("app.events.retries").Int()
+	for _, d := range eventDeliveries(registry, ev) {
+		var (
+			err      error
+			attempts int
+		)
+		for attempts <= retries {
+			if attempts > 0 && !retryBackoff(sess, backoff*time.Duration(attempts)) {
+				break
+			}
+			attempts++
+			if err = e.callListener(sess, ev, d, timeout); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			e.deadLetter(sess, ev, d, err, attempts)
+		}
+	}
+}
+
+// retryBackoff waits d before retry, it reports false when
+// session is done before that.
+func retryBackoff(sess *Session, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-sess.Done():
+		return false
+	}
+}
+
+// callListener calls the listener and when timeout is greater than 0
+// stops waiting for the listener after timeout so that slow listener
+// does not stall delivery of the event to other listeners. Timed out
+// listener is retried and reported as dead letter like failed one.
+func (e *Engine) callListener(sess *Session, ev Event, d eventDelivery, timeout time.Duration) (err error) {
+	ctx, span := e.startEventSpan("happy.event.handle", ev,
+		slog.String("service", d.addr),
+		slog.String("listener", d.listener.lid),
+	)
+	started := time.Now()
+	defer func() {
+		sess.monitor.eventHandled(ev.Scope(), time.Since(started), err)
+		span.End(err)
+	}()
+	if e.tracer != nil {
+		ev = WithTraceParent(ev, e.tracer.Inject(ctx))
+	}
+
+	handle := func(sess *Session) error {
+		wid := e.watchdog.begin("event "+ev.Scope()+"."+ev.Key(), d.addr)
+		defer e.watchdog.end(wid)
+		return d.svcc.handleEvent(sess, ev, d.listener)
+	}
+	if timeout <= 0 {
+		return handle(sess)
+	}
+	// session passed to listener is done on timeout
+	// so that listener can stop its work
+	hctx, cancel := context.WithTimeout(sess, timeout)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		defer e.recoverPanic()
+		done <- handle(sess.withContext(hctx))
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-hctx.Done():
+		if err := sess.Err(); err != nil {
+			return err
+		}
+		sess.EventLog(ev).Warn(
+			"event handler timed out",
+			slog.String("service", d.addr),
+			slog.String("listener", d.listener.lid),
+			slog.String("scope", ev.Scope()),
+			slog.String("key", ev.Key()),
+			slog.Duration("timeout", timeout),
+		)
+		return fmt.Errorf("%w: event handler timed out after %s: %w", ErrEngine, timeout, hctx.Err())
+	}
+}
+
+// DeadLetter is event which listener failed to handle.
+type DeadLetter struct {
+	Event    Event
+	Err      error
+	Service  string
+	Listener string
+	Attempts int
+	Time     time.Time
+}
+
+// maxDeadLetters is number of most recent dead letters kept by session.
+const maxDeadLetters = 1000
+
+// deadLetter records failed delivery and dispatches events.dead.letter event
+// so that failures can be handled by other listeners. The event is not
+// waited to be queued since deadLetter is called by event workers.
+func (e *Engine) deadLetter(sess *Session, ev Event, d eventDelivery, err error, attempts int) {
+	dl := DeadLetter{
+		Event:    ev,
+		Err:      err,
+		Service:  d.addr,
+		Listener: d.listener.lid,
+		Attempts: attempts,
+		Time:     time.Now().UTC(),
+	}
+	sess.addDeadLetter(dl)
+	sess.Log().Warn(
+		"event moved to dead letters",
+		slog.String("service", dl.Service),
+		slog.String("listener", dl.Listener),
+		slog.String("scope", ev.Scope()),
+		slog.String("key", ev.Key()),
+		slog.Int("attempts", attempts),
+	)
+	// prevent dead letter loop
+	if ev.Scope() == "events" && ev.Key() == "dead.letter" {
+		return
+	}
+	payload := new(vars.Map)
+	payload.Store("scope", ev.Scope())
+	payload.Store("key", ev.Key())
+	payload.Store("service", dl.Service)
+	payload.Store("listener", dl.Listener)
+	payload.Store("attempts", attempts)
+	payload.Store("err", err.Error())
+	sess.dispatchNoWait(NewEvent("events", "dead.letter", payload, err))
+}
+
+// eventJob is event to be delivered to listeners in registry, job without
+// registry is event dispatched directly to event bus which is handled
+// by the worker of the bus. Job without event only wakes the worker.
+type eventJob struct {
+	ev       Event
+	registry map[string]*serviceContainer
+}
+
+// eventPool delivers events on bounded number of workers.
+// When queue is full dispatch loop blocks until worker becomes available.
+type eventPool struct {
+	engine  *Engine
+	workers int
+	queue   chan eventJob
+	wg      sync.WaitGroup
+	// bus is event bus of the pool, nil for main event bus
+	bus *eventBus
+}
+
+func newEventPool(engine *Engine, workers, size int) *eventPool {
+	if size < 0 {
+		size = 0
+	}
+	return &eventPool{
+		engine:  engine,
+		workers: workers,
+		queue:   make(chan eventJob, size),
+	}
+}
+
+func (p *eventPool) start(ctx context.Context, sess *Session) {
+	p.wg.Add(p.workers)
+	for i := 0; i < p.workers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					p.drain(sess)
+					return
+				case job := <-p.queue:
+					p.run(sess, job)
+				}
+			}
+		}()
+	}
+	sess.Log().SystemDebug(
+		"event worker pool started",
+		slog.Int("workers", p.workers),
+		slog.Int("queue.size", cap(p.queue)),
+	)
+}
+
+// run delivers job and jobs of the bus coalesced while its queue was full.
+func (p *eventPool) run(sess *Session, job eventJob) {
+	p.runJob(sess, job)
+	if p.bus == nil || len(p.queue) > 0 {
+		return
+	}
+	for _, job := range p.bus.takeCoalesced() {
+		p.runJob(sess, job)
+	}
+}
+
+// drain delivers jobs left in the queue when pool is stopped
+// so that events queued before engine stopped are not lost.
+func (p *eventPool) drain(sess *Session) {
+	for {
+		select {
+		case job := <-p.queue:
+			p.run(sess, job)
+		default:
+			return
+		}
+	}
+}
+
+func (p *eventPool) runJob(sess *Session, job eventJob) {
+	switch {
+	case job.ev == nil:
+	case job.registry == nil:
+		p.engine.handleEventOn(sess, job.ev, p.bus)
+	default:
+		p.engine.deliverEvent(sess, job.ev, job.registry)
+	}
+}
+
+func (p *eventPool) enqueue(ctx context.Context, ev Event, registry map[string]*serviceContainer) {
+	p.enqueueJob(ctx, eventJob{ev: ev, registry: registry})
+}
+
+func (p *eventPool) enqueueJob(ctx context.Context, job eventJob) {
+	select {
+	case <-ctx.Done():
+	case p.queue <- job:
+	}
+}
+
+// wait blocks until all workers have exited.
+func (p *eventPool) wait() {
+	p.wg.Wait()
+}
+
+// RequestEvent is event dispatched with Session.DispatchRequest.
+// Listener handling the request can Reply to it, only first reply
+// is delivered to the requester.
+type RequestEvent interface {
+	Event
+	Reply(payload *vars.Map, err error) error
+}
+
+type requestEvent struct {
+	Event
+	replied *atomic.Bool
+	reply   chan Event
+}
+
+func newRequestEvent(ev Event) *requestEvent {
+	return &requestEvent{
+		Event:   ev,
+		replied: new(atomic.Bool),
+		reply:   make(chan Event, 1),
+	}
+}
+
+// Reply sends response event with given payload and error to requester.
+func (r *requestEvent) Reply(payload *vars.Map, err error) error {
+	if !r.replied.CompareAndSwap(false, true) {
+		return fmt.Errorf("%w: request %s.%s already replied", ErrEngine, r.Scope(), r.Key())
+	}
+	r.reply <- NewEvent(r.Scope(), r.Key(), payload, err)
+	return nil
+}
+
+func (r *requestEvent) TraceParent() string {
+	return EventTraceParent(r.Event)
+}
+
+func (r *requestEvent) LogAttrs() []slog.Attr {
+	return EventLogAttrs(r.Event)
+}
+
+func (r *requestEvent) Err() error {
+	if everr, ok := r.Event.(interface{ Err() error }); ok {
+		return everr.Err()
+	}
+	return nil
+}
+
+// OverflowPolicy defines what happens with event dispatched while
+// event queue is full.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock blocks dispatcher until there is room in the queue.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDrop drops the event.
+	OverflowDrop
+	// OverflowCoalesce keeps only latest event with same scope and key
+	// and delivers it once the queue has been drained.
+	OverflowCoalesce
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDrop:
+		return "drop"
+	case OverflowCoalesce:
+		return "coalesce"
+	}
+	return fmt.Sprintf("OverflowPolicy(%d)", p)
+}
+
+func parseOverflowPolicy(s string) (OverflowPolicy, error) {
+	switch s {
+	case "block":
+		return OverflowBlock, nil
+	case "drop":
+		return OverflowDrop, nil
+	case "coalesce":
+		return OverflowCoalesce, nil
+	}
+	return OverflowBlock, fmt.Errorf("%w: invalid overflow policy %q", ErrEngine, s)
+}
+
+// overflowPolicies holds default and per scope overflow policies.
+type overflowPolicies struct {
+	def    OverflowPolicy
+	scopes map[string]OverflowPolicy
+}
+
+// parseOverflowPolicies parses default policy and comma separated list
+// of scope=policy pairs e.g. "metrics=drop,ui=coalesce".
+func parseOverflowPolicies(def, scopes string) (overflowPolicies, error) {
+	var (
+		p   overflowPolicies
+		err error
+	)
+	if p.def, err = parseOverflowPolicy(def); err != nil {
+		return p, err
+	}
+	p.scopes = make(map[string]OverflowPolicy)
+	for _, pair := range strings.Split(scopes, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		scope, policy, ok := strings.Cut(pair, "=")
+		if !ok || scope == "" {
+			return p, fmt.Errorf("%w: invalid scope overflow policy %q", ErrEngine, pair)
+		}
+		if p.scopes[scope], err = parseOverflowPolicy(policy); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+func (p overflowPolicies) policy(scope string) OverflowPolicy {
+	if policy, ok := p.scopes[scope]; ok {
+		return policy
+	}
+	return p.def
+}
+
+// EventStats is snapshot of session event queue metrics.
+type EventStats struct {
+	QueueDepth    int
+	QueueCapacity int
+	// Pending is number of coalesced events waiting for queue to drain.
+	Pending    int
+	Dispatched uint64
+	Dropped    uint64
+	Coalesced  uint64
+	// AvgLatency and MaxLatency are measured from event creation
+	// until engine starts delivering the event.
+	AvgLatency time.Duration
+	MaxLatency time.Duration
+}
+
+type eventStats struct {
+	dispatched atomic.Uint64
+	dropped    atomic.Uint64
+	coalesced  atomic.Uint64
+	delivered  atomic.Uint64
+	latency    atomic.Int64
+	maxLatency atomic.Int64
+}
+
+func (s *eventStats) observe(latency time.Duration) {
+	s.delivered.Add(1)
+	s.latency.Add(int64(latency))
+	for {
+		cur := s.maxLatency.Load()
+		if int64(latency) <= cur || s.maxLatency.CompareAndSwap(cur, int64(latency)) {
+			return
+		}
+	}
+}
+
+// eventBatch is dispatched as single event and expanded by the engine,
+// see Session.DispatchBatch.
+type eventBatch struct {
+	ts     time.Time
+	events []Event
+}
+
+func (b *eventBatch) Time() time.Time    { return b.ts }
+func (b *eventBatch) Scope() string      { return "events" }
+func (b *eventBatch) Key() string        { return "batch" }
+func (b *eventBatch) Payload() *vars.Map { return nil }
+
+// newEventBatch returns batch of non nil events where events which scope
+// has coalesce overflow policy are coalesced to latest event with same
+// scope and key.
+func newEventBatch(events []Event, overflow overflowPolicies) *eventBatch {
+	latest := make(map[string]int)
+	for i, ev := range events {
+		if ev != nil && overflow.policy(ev.Scope()) == OverflowCoalesce {
+			latest[ev.Scope()+"."+ev.Key()] = i
+		}
+	}
+	batch := &eventBatch{ts: time.Now()}
+	for i, ev := range events {
+		if ev == nil {
+			continue
+		}
+		if last, ok := latest[ev.Scope()+"."+ev.Key()]; ok && last != i {
+			continue
+		}
+		batch.events = append(batch.events, ev)
+	}
+	return batch
+}
+
+// WithLogAttrs returns event carrying log attributes e.g. correlation id
+// in its metadata, attributes are added to logs of engine about the event
+// and to logger returned by Session.EventLog in event handlers.
+func WithLogAttrs(ev Event, attrs ...slog.Attr) Event {
+	if ev == nil || len(attrs) == 0 {
+		return ev
+	}
+	// keep request events replyable and trace parent on top
+	switch e := ev.(type) {
+	case *requestEvent:
+		return &requestEvent{
+			Event:   WithLogAttrs(e.Event, attrs...),
+			replied: e.replied,
+			reply:   e.reply,
+		}
+	case *tracedEvent:
+		return &tracedEvent{
+			Event:       WithLogAttrs(e.Event, attrs...),
+			traceparent: e.traceparent,
+		}
+	}
+	parent := EventLogAttrs(ev)
+	if logged, ok := ev.(*loggedEvent); ok {
+		ev = logged.Event
+	}
+	merged := make([]slog.Attr, 0, len(parent)+len(attrs))
+	merged = append(merged, parent...)
+	return &loggedEvent{
+		Event: ev,
+		attrs: append(merged, attrs...),
+	}
+}
+
+// EventLogAttrs returns log attributes carried by event metadata.
+func EventLogAttrs(ev Event) []slog.Attr {
+	if logged, ok := ev.(interface{ LogAttrs() []slog.Attr }); ok {
+		return logged.LogAttrs()
+	}
+	return nil
+}
+
+type loggedEvent struct {
+	Event
+	attrs []slog.Attr
+}
+
+func (ev *loggedEvent) LogAttrs() []slog.Attr {
+	return ev.attrs
+}
+
+func (ev *loggedEvent) Err() error {
+	if everr, ok := ev.Event.(interface{ Err() error }); ok {
+		return everr.Err()
+	}
+	return nil
+}
@@ -0,0 +1,575 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEventPatternMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		scope   string
+		key     string
+		want    bool
+	}{
+		{"any", "services", "start.services", true},
+		{"services.start.services", "services", "start.services", true},
+		{"services.start.services", "services", "stop.services", false},
+		{"services.*", "services", "start.services", true},
+		{"services.*", "engine", "start.services", false},
+		{"*.config.changed", "app", "config.changed", true},
+		{"*.config.changed", "addon", "config.removed", false},
+		{"*.*", "a", "b", true},
+		{"serv?ces.service.*", "services", "service.started", true},
+		{"services", "services", "", false},
+		{"[.key", "[", "key", false},
+	}
+	for _, test := range tests {
+		testutils.Equal(t, test.want, eventPatternMatch(test.pattern, test.scope, test.key),
+			"pattern %q scope %q key %q", test.pattern, test.scope, test.key)
+	}
+}
+
+func TestEventDeliveriesOrder(t *testing.T) {
+	noop := func(sess *Session, ev Event) error { return nil }
+
+	svca := NewService("a")
+	svca.OnEvent("app", "test", noop)
+	svca.OnEventWithPriority(10, "app", "*", noop)
+	svca.OnEvent("app", "other", noop)
+
+	svcb := NewService("b")
+	svcb.OnAnyEvent(noop)
+	svcb.OnEventWithPriority(10, "app", "test", noop)
+	svcb.OnEventWithPriority(-1, "*", "*", noop)
+
+	registry := map[string]*serviceContainer{
+		"happy://host/app/service/b": {svc: svcb},
+		"happy://host/app/service/a": {svc: svca},
+	}
+
+	type delivery struct {
+		addr string
+		seq  int
+	}
+	var got []delivery
+	for _, d := range eventDeliveries(registry, NewEvent("app", "test", nil, nil)) {
+		got = append(got, delivery{d.addr, d.listener.seq})
+	}
+	want := []delivery{
+		{"happy://host/app/service/a", 1},
+		{"happy://host/app/service/b", 1},
+		{"happy://host/app/service/a", 0},
+		{"happy://host/app/service/b", 0},
+		{"happy://host/app/service/b", 2},
+	}
+	testutils.EqualAny(t, want, got)
+}
+
+func TestEngineCallListenerTimeout(t *testing.T) {
+	sess := &Session{sessionState: &sessionState{logger: hlog.New(hlog.NewHandler(io.Discard))}}
+	release := make(chan struct{})
+	defer close(release)
+
+	svc := NewService("slow")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		<-release
+		return nil
+	})
+	d := eventDelivery{
+		svcc:     &serviceContainer{svc: svc},
+		addr:     "happy://host/app/service/slow",
+		listener: svc.listeners[0],
+	}
+
+	e := newEngine()
+	start := time.Now()
+	e.callListener(sess, NewEvent("app", "test", nil, nil), d, 10*time.Millisecond)
+	testutils.True(t, time.Since(start) < time.Second, "callListener did not time out")
+}
+
+func newTestSession(t *testing.T) *Session {
+	t.Helper()
+	defaults, err := getDefaultApplicationConfig()
+	testutils.NoError(t, err)
+	opts, err := NewOptions("config", defaults)
+	testutils.NoError(t, err)
+	testutils.NoError(t, opts.setDefaults())
+	return &Session{sessionState: &sessionState{
+		logger: hlog.New(hlog.NewHandler(io.Discard)),
+		opts:   opts,
+		evch:   make(chan Event, 100),
+	}}
+}
+
+func TestEngineDeadLetter(t *testing.T) {
+	sess := newTestSession(t)
+	testutils.NoError(t, sess.opts.set("app.events.retries", 2, true))
+
+	var calls int
+	svc := NewService("failing")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		calls++
+		return errors.New("failed")
+	})
+	addr, err := address.Parse("happy://host/app/service/failing")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	e := newEngine()
+	e.deliverEvent(sess, NewEvent("app", "test", nil, nil), registry)
+	testutils.Equal(t, 3, calls)
+
+	dls := sess.DeadLetters()
+	testutils.Equal(t, 1, len(dls))
+	testutils.Equal(t, "happy://host/app/service/failing", dls[0].Service)
+	testutils.Equal(t, "app.test", dls[0].Listener)
+	testutils.Equal(t, 3, dls[0].Attempts)
+
+	ev := <-sess.evch
+	testutils.Equal(t, "events", ev.Scope())
+	testutils.Equal(t, "dead.letter", ev.Key())
+	testutils.Equal(t, "app.test", ev.Payload().Get("listener").String())
+}
+
+func TestEventPoolDrainsOnStop(t *testing.T) {
+	sess := newTestSession(t)
+	var delivered atomic.Int32
+	svc := NewService("counter")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		delivered.Add(1)
+		return nil
+	})
+	addr, err := address.Parse("happy://host/app/service/counter")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pool := newEventPool(newEngine(), 2, 10)
+	for i := 0; i < 5; i++ {
+		pool.enqueue(ctx, NewEvent("app", "test", nil, nil), registry)
+	}
+	cancel()
+	pool.start(ctx, sess)
+	pool.wait()
+	testutils.Equal(t, int32(5), delivered.Load(), "queued events should be delivered when pool stops")
+	testutils.Equal(t, 0, len(pool.queue))
+}
+
+func TestEngineRetryBackoff(t *testing.T) {
+	sess := newTestSession(t)
+	testutils.NoError(t, sess.opts.set("app.events.retries.backoff", 20*time.Millisecond, true))
+
+	var calls []time.Time
+	svc := NewService("flaky")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		calls = append(calls, time.Now())
+		if len(calls) < 3 {
+			return errors.New("failed")
+		}
+		return nil
+	})
+	addr, err := address.Parse("happy://host/app/service/flaky")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	e := newEngine()
+	e.deliverEvent(sess, NewEvent("app", "test", nil, nil), registry)
+	testutils.Equal(t, 3, len(calls), "listener should be retried by default")
+	testutils.True(t, calls[1].Sub(calls[0]) >= 20*time.Millisecond, "first retry should wait backoff")
+	testutils.True(t, calls[2].Sub(calls[1]) >= 40*time.Millisecond, "backoff should grow with attempts")
+	testutils.Equal(t, 0, len(sess.DeadLetters()))
+}
+
+func TestEngineDeadLetterQueueFull(t *testing.T) {
+	sess := newTestSession(t)
+	sess.evch = make(chan Event, 1)
+	sess.evch <- NewEvent("app", "queued", nil, nil)
+	testutils.NoError(t, sess.opts.set("app.events.retries", 0, true))
+
+	svc := NewService("failing")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		return errors.New("failed")
+	})
+	addr, err := address.Parse("happy://host/app/service/failing")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	done := make(chan struct{})
+	go func() {
+		newEngine().deliverEvent(sess, NewEvent("app", "test", nil, nil), registry)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("dead letter should not block on full event queue")
+	}
+	testutils.Equal(t, 1, len(sess.DeadLetters()))
+	testutils.Equal(t, uint64(1), sess.evstats.dropped.Load())
+}
+
+func TestEngineListenerTimeout(t *testing.T) {
+	sess := newTestSession(t)
+	testutils.NoError(t, sess.opts.set("app.events.retries", 1, true))
+	testutils.NoError(t, sess.opts.set("app.events.handler.timeout", 10*time.Millisecond, true))
+
+	cancelled := make(chan error, 2)
+	svc := NewService("slow")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		<-sess.Done()
+		cancelled <- sess.Err()
+		return sess.Err()
+	})
+	addr, err := address.Parse("happy://host/app/service/slow")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	e := newEngine()
+	e.deliverEvent(sess, NewEvent("app", "test", nil, nil), registry)
+
+	dls := sess.DeadLetters()
+	testutils.Equal(t, 1, len(dls), "timed out listener should be reported as dead letter")
+	testutils.Equal(t, 2, dls[0].Attempts, "timed out listener should be retried")
+	testutils.ErrorIs(t, dls[0].Err, ErrEngine)
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-cancelled:
+			testutils.ErrorIs(t, err, context.DeadlineExceeded, "listener session should be done on timeout")
+		case <-time.After(time.Second):
+			t.Fatal("listener was not cancelled on timeout")
+		}
+	}
+	testutils.NoError(t, sess.Err(), "timeout must not affect session")
+}
+
+func TestSessionDispatchRequest(t *testing.T) {
+	sess := newTestSession(t)
+	go func() {
+		ev := <-sess.evch
+		req, ok := ev.(RequestEvent)
+		if !ok {
+			return
+		}
+		payload := new(vars.Map)
+		payload.Store("answer", 42)
+		req.Reply(payload, nil)
+		testutils.Error(t, req.Reply(nil, nil), "second reply should fail")
+	}()
+
+	resp, err := sess.DispatchRequest(NewEvent("app", "question", nil, nil))
+	testutils.NoError(t, err)
+	testutils.Equal(t, 42, resp.Payload().Get("answer").Int())
+
+	testutils.NoError(t, sess.opts.set("app.events.request.timeout", time.Millisecond, true))
+	_, err = sess.DispatchRequest(NewEvent("app", "unanswered", nil, nil))
+	testutils.ErrorIs(t, err, ErrEngine)
+}
+
+func TestSessionDispatchRequestNoListener(t *testing.T) {
+	sess := newTestSession(t)
+	sess.engine = newEngine()
+	testutils.NoError(t, sess.engine.registerEvent(registerEvent("app", "question", "", new(vars.Map))))
+
+	started := time.Now()
+	_, err := sess.DispatchRequest(NewEvent("app", "unknown", nil, nil))
+	testutils.ErrorIs(t, err, ErrEngine, "unregistered request should fail")
+	_, err = sess.DispatchRequest(NewEvent("app", "question", nil, nil))
+	testutils.ErrorIs(t, err, ErrEngine, "request without listeners should fail")
+	testutils.True(t, time.Since(started) < time.Second, "request without listeners should fail without waiting")
+	testutils.Equal(t, 0, len(sess.evch), "request without listeners should not be dispatched")
+
+	svc := NewService("answering")
+	svc.OnEvent("app", "question", func(sess *Session, ev Event) error { return nil })
+	addr, err := address.Parse("happy://host/app/service/answering")
+	testutils.NoError(t, err)
+	sess.engine.registry[addr.String()] = svc.container(sess, addr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = sess.DispatchRequestContext(ctx, NewEvent("app", "question", nil, nil))
+	testutils.ErrorIs(t, err, context.DeadlineExceeded, "request should time out with ctx")
+	testutils.ErrorIs(t, err, ErrEngine)
+}
+
+func TestEnginePauseResume(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	testutils.Error(t, e.Pause(), "pause should fail when engine is not running")
+
+	e.running = true
+	e.sess = sess
+	e.events["app.test"] = registerEvent("app", "test", "", new(vars.Map))
+
+	testutils.NoError(t, e.Pause())
+	testutils.True(t, e.Paused(), "engine should be paused")
+	testutils.True(t, e.holdEvent(NewEvent("app", "test", nil, nil)), "app event should be held")
+	testutils.False(t, e.holdEvent(NewEvent("services", "start.services", nil, nil)), "services event should not be held")
+
+	dispatched := sess.evstats.dispatched.Load()
+	testutils.NoError(t, e.Resume())
+	testutils.False(t, e.Paused(), "engine should be resumed")
+	testutils.Equal(t, 0, len(e.pausedEvents))
+	testutils.Equal(t, dispatched+1, sess.evstats.dispatched.Load(), "held events should not be counted as dispatched again")
+
+	var keys []string
+	for len(sess.evch) > 0 {
+		ev := <-sess.evch
+		if batch, ok := ev.(*eventBatch); ok {
+			for _, bev := range batch.events {
+				keys = append(keys, bev.Key())
+			}
+			continue
+		}
+		keys = append(keys, ev.Key())
+	}
+	testutils.EqualAny(t, []string{"paused", "test", "resumed"}, keys, "held events should be requeued before resumed event")
+}
+
+func TestSessionRequeueEventsFullQueue(t *testing.T) {
+	sess := newTestSession(t)
+	sess.evch = make(chan Event, 1)
+	var err error
+	sess.overflow, err = parseOverflowPolicies("drop", "ui=coalesce")
+	testutils.NoError(t, err)
+	sess.evch <- NewEvent("app", "queued", nil, nil)
+
+	done := make(chan struct{})
+	go func() {
+		sess.requeueEvents([]Event{
+			NewEvent("app", "held", nil, nil),
+			NewEvent("ui", "render", nil, nil),
+		})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("requeue should not block on full queue")
+	}
+	testutils.Equal(t, uint64(1), sess.evstats.dropped.Load())
+	testutils.Equal(t, uint64(0), sess.evstats.dispatched.Load(), "held events should not be counted as dispatched again")
+	coalesced := sess.takeCoalesced()
+	testutils.Equal(t, 1, len(coalesced))
+	testutils.Equal(t, "render", coalesced[0].Key())
+}
+
+func TestEngineTickDeltaAfterTickerEnabled(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	e.tickChanged = make(chan struct{})
+	deltas := make(chan time.Duration, 10)
+	e.tickAction = func(sess *Session, ts time.Time, delta time.Duration) error {
+		select {
+		case deltas <- delta:
+		default:
+		}
+		return nil
+	}
+
+	var init sync.WaitGroup
+	e.loopStart(sess, &init)
+	init.Wait()
+	defer e.ctxCancel()
+
+	// ticker is disabled for a while before it is enabled
+	time.Sleep(200 * time.Millisecond)
+	testutils.NoError(t, e.SetTickRate(10*time.Millisecond))
+	select {
+	case delta := <-deltas:
+		testutils.True(t, delta < 200*time.Millisecond, "first tick delta %s should not include time ticker was disabled", delta)
+	case <-time.After(time.Second):
+		t.Fatal("engine did not tick after tick rate was set")
+	}
+}
+
+func TestEngineTickRate(t *testing.T) {
+	e := newEngine()
+	e.tickChanged = make(chan struct{})
+	interval, changed := e.tickRate()
+	testutils.Equal(t, time.Duration(0), interval)
+
+	testutils.Error(t, e.SetTickRate(-time.Second))
+	testutils.NoError(t, e.SetTickRate(time.Millisecond))
+	select {
+	case <-changed:
+	default:
+		t.Error("tick rate change was not signaled")
+	}
+	testutils.Equal(t, time.Millisecond, e.TickRate())
+
+	ticker := newEngineTicker(0)
+	testutils.True(t, ticker.c() == nil, "disabled ticker should not have channel")
+	ticker.reset(e.TickRate())
+	<-ticker.c()
+	ticker.reset(0)
+	testutils.True(t, ticker.c() == nil, "disabled ticker should not have channel")
+}
+
+func TestSessionOverflowPolicies(t *testing.T) {
+	_, err := parseOverflowPolicies("block", "metrics=fast")
+	testutils.ErrorIs(t, err, ErrEngine)
+
+	sess := newTestSession(t)
+	sess.evch = make(chan Event, 1)
+	sess.overflow, err = parseOverflowPolicies("drop", "ui=coalesce")
+	testutils.NoError(t, err)
+
+	sess.Dispatch(NewEvent("app", "first", nil, nil))
+	sess.Dispatch(NewEvent("app", "dropped", nil, nil))
+	sess.Dispatch(NewEvent("ui", "render", nil, nil))
+	sess.Dispatch(NewEvent("ui", "render", nil, nil))
+
+	stats := sess.EventStats()
+	testutils.Equal(t, 1, stats.QueueDepth)
+	testutils.Equal(t, uint64(4), stats.Dispatched)
+	testutils.Equal(t, uint64(1), stats.Dropped)
+	testutils.Equal(t, uint64(1), stats.Coalesced)
+	testutils.Equal(t, 1, stats.Pending)
+
+	testutils.Equal(t, "first", (<-sess.evch).Key())
+	pending := sess.takeCoalesced()
+	testutils.Equal(t, 1, len(pending))
+	testutils.Equal(t, "render", pending[0].Key())
+	testutils.Equal(t, 0, sess.EventStats().Pending)
+}
+
+func TestEngineDeterministic(t *testing.T) {
+	sess := newTestSession(t)
+	testutils.NoError(t, sess.opts.set("app.engine.deterministic", true, true))
+	testutils.NoError(t, sess.start())
+	defer sess.Destroy(nil)
+
+	e := newEngine()
+	sess.engine = e
+
+	var (
+		deltas []time.Duration
+		events []string
+	)
+	svc := NewService("clock")
+	svc.OnTick(func(sess *Session, ts time.Time, delta time.Duration) error {
+		deltas = append(deltas, delta)
+		sess.Dispatch(NewEvent("app", "ticked", nil, nil))
+		return nil
+	})
+	svc.OnEvent("app", "ticked", func(sess *Session, ev Event) error {
+		events = append(events, ev.Key())
+		return nil
+	})
+	testutils.NoError(t, e.serviceRegister(sess, svc))
+	testutils.NoError(t, e.start(sess))
+	defer e.stop(sess)
+	testutils.NoError(t, e.registerEvent(registerEvent("services", "start.services", "", new(vars.Map))))
+
+	start := e.Now()
+	testutils.ErrorIs(t, e.SetTickRate(time.Second), ErrEngine)
+
+	addr, err := address.Parse(sess.Get("app.host.addr").String())
+	testutils.NoError(t, err)
+	svcaddr, err := addr.ResolveService("clock")
+	testutils.NoError(t, err)
+	sess.Dispatch(StartServicesEvent(svcaddr.String()))
+
+	testutils.NoError(t, e.Advance(time.Second))
+	testutils.NoError(t, e.Advance(2*time.Second))
+	testutils.EqualAny(t, []time.Duration{time.Second, 2 * time.Second}, deltas)
+	testutils.EqualAny(t, []string{"ticked", "ticked"}, events)
+	testutils.Equal(t, 3*time.Second, e.Now().Sub(start))
+}
+
+func TestEngineEventMiddleware(t *testing.T) {
+	sess := newTestSession(t)
+
+	var got []string
+	svc := NewService("listener")
+	svc.OnAnyEvent(func(sess *Session, ev Event) error {
+		got = append(got, ev.Key()+":"+ev.Payload().Get("trace").String())
+		return nil
+	})
+	addr, err := address.Parse("happy://host/app/service/listener")
+	testutils.NoError(t, err)
+	registry := map[string]*serviceContainer{
+		addr.String(): svc.container(sess, addr),
+	}
+
+	var order []string
+	e := newEngine()
+	e.UseEventMiddleware(
+		func(next ActionWithEvent) ActionWithEvent {
+			return func(sess *Session, ev Event) error {
+				order = append(order, "filter")
+				if ev.Key() == "private" {
+					return nil
+				}
+				return next(sess, ev)
+			}
+		},
+		func(next ActionWithEvent) ActionWithEvent {
+			return func(sess *Session, ev Event) error {
+				order = append(order, "enrich")
+				payload := new(vars.Map)
+				payload.Store("trace", "abc")
+				return next(sess, NewEvent(ev.Scope(), ev.Key(), payload, nil))
+			}
+		},
+	)
+
+	e.deliverEvent(sess, NewEvent("app", "private", nil, nil), registry)
+	e.deliverEvent(sess, NewEvent("app", "public", nil, nil), registry)
+	testutils.EqualAny(t, []string{"filter", "filter", "enrich"}, order)
+	testutils.EqualAny(t, []string{"public:abc"}, got)
+}
+
+func TestSessionDispatchBatch(t *testing.T) {
+	sess := newTestSession(t)
+	var err error
+	sess.overflow, err = parseOverflowPolicies("block", "progress=coalesce")
+	testutils.NoError(t, err)
+
+	sess.DispatchBatch([]Event{
+		NewEvent("progress", "update", nil, nil),
+		NewEvent("app", "first", nil, nil),
+		nil,
+		NewEvent("progress", "update", nil, nil),
+		NewEvent("app", "second", nil, nil),
+	})
+	sess.DispatchBatch(nil)
+
+	testutils.Equal(t, 1, len(sess.evch))
+	batch, ok := (<-sess.evch).(*eventBatch)
+	testutils.True(t, ok, "expected event batch")
+	var keys []string
+	for _, ev := range batch.events {
+		keys = append(keys, ev.Scope()+"."+ev.Key())
+	}
+	testutils.EqualAny(t, []string{"app.first", "progress.update", "app.second"}, keys)
+
+	stats := sess.EventStats()
+	testutils.Equal(t, uint64(3), stats.Dispatched)
+	testutils.Equal(t, uint64(1), stats.Coalesced)
+}
@@ -0,0 +1,99 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// externalCommandEnvPrefix is prefix of session variables
+// exported to external commands e.g. HAPPY_APP_NAME.
+const externalCommandEnvPrefix = "HAPPY_"
+
+// externalCommand is executable named <app>-<cmd> found on PATH which
+// is executed when unknown subcommand <cmd> is invoked, git-style.
+type externalCommand struct {
+	name string
+	path string
+	args []string
+}
+
+// findExternalCommand looks up external command for first non flag
+// argument of argv when it is not known subcommand of root.
+func findExternalCommand(root *Command, argv []string) *externalCommand {
+	if len(argv) < 2 {
+		return nil
+	}
+	for i, arg := range argv[1:] {
+		if arg == "--" {
+			return nil
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		if _, exists := root.getSubCommand(arg); exists {
+			return nil
+		}
+		path, err := exec.LookPath(externalCommandName(argv[0], arg))
+		if err != nil {
+			return nil
+		}
+		return &externalCommand{
+			name: arg,
+			path: path,
+			args: argv[i+2:],
+		}
+	}
+	return nil
+}
+
+// externalCommandName returns executable name of external command.
+func externalCommandName(bin, cmd string) string {
+	app := strings.TrimSuffix(filepath.Base(bin), ".exe")
+	return app + "-" + cmd
+}
+
+// run executes external command with session variables exported
+// to its environment and returns its exit code.
+func (ec *externalCommand) run(sess *Session) int {
+	sess.Log().SystemDebug("executing external command",
+		slog.String("command", ec.name),
+		slog.String("path", ec.path),
+	)
+	cmd := exec.Command(ec.path, ec.args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(), sessionEnviron(sess)...)
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		sess.Log().Error("external command failed", err, slog.String("command", ec.name))
+		return 1
+	}
+	return 0
+}
+
+// sessionEnviron returns session variables as sorted KEY=value pairs,
+// key app.path.cache is exported as HAPPY_APP_PATH_CACHE.
+func sessionEnviron(sess *Session) []string {
+	replacer := strings.NewReplacer(".", "_", "-", "_")
+	var env []string
+	for _, v := range sess.opts.db.All() {
+		key := externalCommandEnvPrefix + strings.ToUpper(replacer.Replace(v.Name()))
+		env = append(env, key+"="+v.String())
+	}
+	sort.Strings(env)
+	return env
+}
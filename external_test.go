@@ -0,0 +1,51 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestFindExternalCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script plugins are not executable on windows")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "app")
+	plugin := filepath.Join(dir, "app-hello")
+	testutils.NoError(t, os.WriteFile(plugin, []byte("#!/bin/sh\nexit 3\n"), 0700))
+	t.Setenv("PATH", dir)
+
+	root := newTestCompletionRoot(t)
+	ext := findExternalCommand(root, []string{bin, "--verbose", "hello", "world", "--name", "x"})
+	testutils.NotNil(t, ext)
+	testutils.Equal(t, "hello", ext.name)
+	testutils.Equal(t, plugin, ext.path)
+	testutils.EqualAny(t, []string{"world", "--name", "x"}, ext.args)
+
+	testutils.True(t, findExternalCommand(root, []string{bin, "deploy"}) == nil, "known subcommand")
+	testutils.True(t, findExternalCommand(root, []string{bin, "missing"}) == nil, "no executable")
+	testutils.True(t, findExternalCommand(root, []string{bin, "--", "hello"}) == nil, "raw args")
+
+	sess := newTestSession(t)
+	testutils.Equal(t, 3, ext.run(sess))
+}
+
+func TestSessionEnviron(t *testing.T) {
+	sess := newTestSession(t)
+	env := sessionEnviron(sess)
+	found := false
+	for _, kv := range env {
+		if kv == "HAPPY_APP_SLUG=com.github.mkungla.happy.test" {
+			found = true
+		}
+	}
+	testutils.True(t, found, "session variable not exported: %v", env)
+}
@@ -3,9 +3,21 @@ module github.com/mkungla/happy
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/mkungla/bexp/v3 v3.0.1
 	github.com/robfig/cron/v3 v3.0.1
+	go.opentelemetry.io/otel v1.19.0
+	go.opentelemetry.io/otel/sdk v1.19.0
+	go.opentelemetry.io/otel/trace v1.19.0
 	golang.org/x/exp v0.0.0-20221227203929-1b447090c38c
 	golang.org/x/mod v0.7.0
 	golang.org/x/text v0.5.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/go-logr/logr v1.2.4 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	go.opentelemetry.io/otel/metric v1.19.0 // indirect
+	golang.org/x/sys v0.12.0 // indirect
 )
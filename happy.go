@@ -17,6 +17,7 @@ package happy
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"time"
 
 	"github.com/mkungla/happy/pkg/varflag"
@@ -24,22 +25,48 @@ import (
 )
 
 var (
-	ErrApplication      = errors.New("application error")
-	ErrCommand          = errors.New("command error")
-	ErrCommandFlags     = errors.New("command flags error")
-	ErrCommandAction    = errors.New("command action error")
-	ErrInvalidVersion   = errors.New("invalid version")
-	ErrEngine           = errors.New("engine error")
-	ErrSessionDestroyed = errors.New("session destroyed")
-	ErrService          = errors.New("service error")
-	ErrHappy            = errors.New("not so happy")
-	ErrAddon            = errors.New("addon error")
+	ErrApplication       = errors.New("application error")
+	ErrCommand           = errors.New("command error")
+	ErrCommandFlags      = errors.New("command flags error")
+	ErrCommandAction     = errors.New("command action error")
+	ErrCommandArgs       = errors.New("command arguments error")
+	ErrCommandTimeout    = errors.New("command timed out")
+	ErrRunDeadline       = errors.New("run deadline exceeded")
+	ErrSignal            = errors.New("received signal")
+	ErrInvalidVersion    = errors.New("invalid version")
+	ErrEngine            = errors.New("engine error")
+	ErrSessionDestroyed  = errors.New("session destroyed")
+	ErrService           = errors.New("service error")
+	ErrHappy             = errors.New("not so happy")
+	ErrAddon             = errors.New("addon error")
+	ErrAddonIncompatible = fmt.Errorf("%w: incompatible addon", ErrAddon)
+	ErrAddonDegraded     = fmt.Errorf("%w: degraded", ErrAddon)
+	ErrDaemon            = fmt.Errorf("%w: daemon", ErrApplication)
+	ErrInstanceRunning   = fmt.Errorf("%w: another instance is already running", ErrApplication)
 )
 
+// APIVersion is version of addon API provided by this SDK, addons
+// declare API versions they are built for with Addon.RequiresAPI.
+const APIVersion = "v1.0.0"
+
+// ExitCodeTimeout is exit code of application when command
+// exceeds its time limit, same as used by timeout(1).
+const ExitCodeTimeout = 124
+
+// ExitCodeDeadline is exit code of application when app.deadline is
+// exceeded, EX_TEMPFAIL from sysexits.h so that batch jobs can be retried.
+const ExitCodeDeadline = 75
+
+// ExitCodePanic is exit code of application after crash report
+// was written for unrecovered panic, same as used by Go runtime.
+const ExitCodePanic = 2
+
 type Action func(sess *Session) error
 
 // ActionTickFunc is operation set in given minimal time frame it can be executed.
 // You can throttle tick/tocks to cap FPS or for [C|G]PU throttling.
+// Throttle is set with "app.throttle.ticks" option which can be changed
+// at runtime with Session.Set, value 0 disables ticks.
 //
 // Tock is helper called after each tick to separate
 // logic processed in tick and do post processing on tick.
@@ -52,7 +79,9 @@ type ActionWithOptions func(sess *Session, opts *Options) error
 type ActionWithEvent func(sess *Session, ev Event) error
 type ActionMigrate func(ver Version, sess *Session) error
 
-type Assets interface{}
+// ActionUpgrade is called when addon version changes between runs,
+// from is version which was used on previous run.
+type ActionUpgrade func(sess *Session, from, to Version) error
 
 type Event interface {
 	Key() string
@@ -83,12 +112,21 @@ type Args interface {
 	ArgVarDefault(i uint, key string, value any) (vars.Variable, error)
 	Args() []vars.Value
 	Flag(name string) varflag.Flag
+	// Named returns first value of declared argument.
+	Named(name string) vars.Value
+	// NamedAll returns all values of declared argument.
+	NamedAll(name string) []vars.Value
+	// Raw returns arguments after "--" untouched e.g.
+	// "npm start" for "app run -- npm start".
+	Raw() []string
 }
 
 type args struct {
 	argv  []vars.Value
 	argn  uint
 	flags varflag.Flags
+	named map[string][]vars.Value
+	raw   []string
 }
 
 func (a *args) Arg(i uint) vars.Value {
@@ -116,6 +154,21 @@ func (a *args) Args() []vars.Value {
 	return a.argv
 }
 
+func (a *args) Named(name string) vars.Value {
+	if values := a.named[name]; len(values) > 0 {
+		return values[0]
+	}
+	return vars.EmptyValue
+}
+
+func (a *args) NamedAll(name string) []vars.Value {
+	return a.named[name]
+}
+
+func (a *args) Raw() []string {
+	return a.raw
+}
+
 func (a *args) Flag(name string) varflag.Flag {
 	f, err := a.flags.Get(name)
 	if err != nil {
@@ -185,4 +238,33 @@ func GetAPI[A API](sess *Session, addonName string) (api A, err error) {
 	return api, fmt.Errorf("unable to cast %s API to given type", addonName)
 }
 
+// CapabilityInfo describes capability published by addon.
+type CapabilityInfo struct {
+	Name     string `json:"name"`
+	Provider string `json:"provider"`
+	Type     string `json:"type"`
+}
+
+type capability struct {
+	name     string
+	provider string
+	impl     any
+}
+
+// Capability resolves implementation of capability published with
+// Addon.ProvidesCapability as T, which usually is interface e.g.
+//
+//	store, err := happy.Capability[Storage](sess, "storage")
+func Capability[T any](sess *Session, name string) (impl T, err error) {
+	c, ok := sess.capability(name)
+	if !ok {
+		return impl, fmt.Errorf("%w: capability %s is not provided", ErrAddon, name)
+	}
+	if impl, ok = c.impl.(T); !ok {
+		return impl, fmt.Errorf("%w: capability %s provided by %s is %T, not %s",
+			ErrAddon, name, c.provider, c.impl, reflect.TypeOf(&impl).Elem())
+	}
+	return impl, nil
+}
+
 type Version string
@@ -38,8 +38,9 @@ func (s *ApplicationTestSuite) Test(t *testing.T) {
 
 	// Run the application
 	s.App.exitOs = false
-	s.App.exitFunc = append(s.App.exitFunc, func(code int) {
+	s.App.AddExitFunc(0, func(sess *Session, code int) error {
 		testutils.Equal(t, s.exitCode, code)
+		return nil
 	})
 	s.App.Main()
 
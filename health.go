@@ -0,0 +1,210 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// healthServiceName is name of built-in health service
+// enabled with app.health.addr option.
+const healthServiceName = "health"
+
+// HealthStatus is aggregated status of health or readiness report.
+type HealthStatus string
+
+const (
+	HealthOK HealthStatus = "ok"
+	// HealthDegraded is reported when only optional services are failing.
+	HealthDegraded HealthStatus = "degraded"
+	HealthFailing  HealthStatus = "failing"
+)
+
+// ServiceHealth is health or readiness of the service.
+type ServiceHealth struct {
+	Service  string       `json:"service"`
+	Status   HealthStatus `json:"status"`
+	Optional bool         `json:"optional,omitempty"`
+	Err      string       `json:"err,omitempty"`
+}
+
+// HealthReport is health or readiness of the application aggregated from
+// states of the services, see Session.Health and Session.Readiness.
+type HealthReport struct {
+	Status   HealthStatus    `json:"status"`
+	Err      string          `json:"err,omitempty"`
+	Services []ServiceHealth `json:"services,omitempty"`
+}
+
+// Health reports whether application is alive. Services which were
+// started are checked, service fails when it stopped with errors or its
+// health check set with Service.OnHealthCheck returns error. Failing
+// services listed in app.health.optional degrade the application,
+// any other failing service or failed addon fails it.
+func (s *Session) Health() HealthReport {
+	report := s.serviceHealth(true)
+	if err := s.Err(); err != nil {
+		report.fail(fmt.Sprintf("session destroyed: %s", err))
+	}
+	for _, addon := range s.AddonHealth() {
+		if addon.Status == AddonFailed {
+			report.fail(fmt.Sprintf("addon %s failed", addon.Name))
+		}
+	}
+	return report
+}
+
+// Readiness reports whether application is ready to serve. Session must
+// be ready and services which were started must be running and pass
+// readiness check set with Service.OnReadyCheck, failing services listed
+// in app.health.optional degrade readiness.
+func (s *Session) Readiness() HealthReport {
+	report := s.serviceHealth(false)
+	select {
+	case <-s.Ready():
+	default:
+		report.fail("session not ready")
+	}
+	if err := s.Err(); err != nil {
+		report.fail(fmt.Sprintf("session destroyed: %s", err))
+	}
+	return report
+}
+
+// serviceHealth checks services which were started, health checks are
+// called when liveness is set and readiness checks otherwise.
+func (s *Session) serviceHealth(liveness bool) HealthReport {
+	optional := make(map[string]bool)
+	for _, name := range strings.Split(s.Get("app.health.optional").String(), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			optional[name] = true
+		}
+	}
+
+	report := HealthReport{Status: HealthOK}
+	for _, info := range s.serviceInfos() {
+		if info.StartedAt().IsZero() {
+			continue
+		}
+		sh := ServiceHealth{
+			Service:  info.Addr().String(),
+			Status:   HealthOK,
+			Optional: optional[info.Name()] || optional[info.Addr().String()],
+		}
+		check := info.readyCheck
+		if liveness {
+			check = info.healthCheck
+		}
+		switch {
+		case !info.Running():
+			if info.Failed() {
+				sh.Status = HealthFailing
+				sh.Err = "service stopped with errors"
+			} else if !liveness {
+				sh.Status = HealthFailing
+				sh.Err = "service stopped"
+			}
+		case check != nil:
+			if err := check(s.sandboxed(info.addon)); err != nil {
+				sh.Status = HealthFailing
+				sh.Err = err.Error()
+			}
+		}
+		if sh.Status == HealthFailing {
+			if sh.Optional {
+				report.degrade()
+			} else {
+				report.Status = HealthFailing
+			}
+		}
+		report.Services = append(report.Services, sh)
+	}
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Service < report.Services[j].Service
+	})
+	return report
+}
+
+func (r *HealthReport) fail(reason string) {
+	r.Status = HealthFailing
+	if r.Err == "" {
+		r.Err = reason
+	}
+}
+
+func (r *HealthReport) degrade() {
+	if r.Status == HealthOK {
+		r.Status = HealthDegraded
+	}
+}
+
+// healthService serves /healthz and /readyz endpoints for load
+// balancers and orchestrators on given address.
+func healthService(addr string) *Service {
+	svc := NewService(healthServiceName)
+
+	var server *http.Server
+
+	svc.OnStart(func(sess *Session) error {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		mux := http.NewServeMux()
+		handleHealth(mux, sess)
+		server = &http.Server{
+			Handler:           mux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				sess.Log().Error("health server failed", err)
+			}
+		}()
+		sess.Log().Info("health server listening", slog.String("addr", ln.Addr().String()))
+		return nil
+	})
+
+	svc.OnStop(func(sess *Session) error {
+		if server == nil {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	})
+	return svc
+}
+
+// handleHealth registers /healthz and /readyz handlers, they respond
+// with 503 Service Unavailable when report is failing.
+func handleHealth(mux *http.ServeMux, sess *Session) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, sess.Health())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeHealthReport(w, sess.Readiness())
+	})
+}
+
+func writeHealthReport(w http.ResponseWriter, report HealthReport) {
+	w.Header().Set("Content-Type", "application/json")
+	if report.Status == HealthFailing {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestSessionHealth(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	testutils.NoError(t, sess.opts.set("app.health.optional", "cache", true))
+
+	var dbErr, cacheErr, warmErr error
+	register := func(svc *Service) *ServiceInfo {
+		addr, err := address.Parse("happy://host/app/service/" + svc.name)
+		testutils.NoError(t, err)
+		svcc := svc.container(sess, addr)
+		sess.setServiceInfo(&svcc.info)
+		return &svcc.info
+	}
+	db := NewService("db")
+	db.OnHealthCheck(func(sess *Session) error { return dbErr })
+	db.OnReadyCheck(func(sess *Session) error { return warmErr })
+	cache := NewService("cache")
+	cache.OnHealthCheck(func(sess *Session) error { return cacheErr })
+
+	dbInfo := register(db)
+	cacheInfo := register(cache)
+	register(NewService("idle"))
+	dbInfo.started()
+	cacheInfo.started()
+
+	report := sess.Health()
+	testutils.Equal(t, HealthOK, report.Status)
+	testutils.Equal(t, 2, len(report.Services), "services which were not started are not checked")
+
+	report = sess.Readiness()
+	testutils.Equal(t, HealthFailing, report.Status)
+	testutils.Equal(t, "session not ready", report.Err)
+	sess.readyFunc()
+	testutils.Equal(t, HealthOK, sess.Readiness().Status)
+
+	cacheErr = errors.New("connection refused")
+	report = sess.Health()
+	testutils.Equal(t, HealthDegraded, report.Status)
+	testutils.Equal(t, "happy://host/app/service/cache", report.Services[0].Service)
+	testutils.True(t, report.Services[0].Optional)
+	testutils.Equal(t, "connection refused", report.Services[0].Err)
+
+	dbErr = errors.New("replica lag")
+	testutils.Equal(t, HealthFailing, sess.Health().Status)
+	dbErr = nil
+
+	warmErr = errors.New("warming up")
+	testutils.Equal(t, HealthDegraded, sess.Health().Status)
+	testutils.Equal(t, HealthFailing, sess.Readiness().Status)
+	warmErr = nil
+
+	dbInfo.stopped()
+	testutils.Equal(t, HealthDegraded, sess.Health().Status, "stopped service without errors is alive")
+	testutils.Equal(t, HealthFailing, sess.Readiness().Status)
+	dbInfo.addErr(errors.New("crashed"))
+	testutils.Equal(t, HealthFailing, sess.Health().Status)
+}
+
+func TestHealthHandlers(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	mux := http.NewServeMux()
+	handleHealth(mux, sess)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	testutils.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	testutils.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+	var report HealthReport
+	testutils.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	testutils.Equal(t, HealthFailing, report.Status)
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+}
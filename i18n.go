@@ -0,0 +1,225 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	// i18nAssetsDir is directory in application assets with message
+	// catalogs, one JSON file per language e.g. i18n/et.json.
+	i18nAssetsDir = "i18n"
+	// i18nFallback is language used when message is missing
+	// in negotiated language.
+	i18nFallback = "en"
+)
+
+// i18nDefaults are built-in messages used by help output and prompts.
+var i18nDefaults = hlog.MapCatalog{
+	i18nFallback: {
+		"help.usage":           "USAGE",
+		"help.commands":        "COMMANDS",
+		"help.global.flags":    "GLOBAL FLAGS",
+		"help.command":         "COMMAND",
+		"help.deprecated":      "DEPRECATED",
+		"help.subcommands":     "Subcommands",
+		"help.arguments":       "Arguments",
+		"help.examples":        "Examples",
+		"help.flags":           "Accepts following flags",
+		"prompt.confirm.retry": "please answer (y)es or (n)o",
+	},
+}
+
+// translations is message catalog of the session merged from catalogs
+// registered with Application.WithTranslations and catalogs loaded
+// from application assets. It implements hlog.Catalog.
+type translations struct {
+	mu sync.RWMutex
+	// lang is negotiated language of the session
+	lang string
+	// assets are loaded from application assets and override catalogs
+	// registered in code so that they can be customized locally
+	assets   hlog.MapCatalog
+	catalogs []hlog.Catalog
+}
+
+func (t *translations) add(catalog hlog.Catalog) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.catalogs = append(t.catalogs, catalog)
+}
+
+// Lookup returns template of message key in language lang.
+func (t *translations) Lookup(lang, key string) (string, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if tmpl, ok := t.assets.Lookup(lang, key); ok {
+		return tmpl, true
+	}
+	for i := len(t.catalogs) - 1; i >= 0; i-- {
+		if tmpl, ok := t.catalogs[i].Lookup(lang, key); ok {
+			return tmpl, true
+		}
+	}
+	return i18nDefaults.Lookup(lang, key)
+}
+
+// has reports whether there are messages in language lang, catalogs
+// other than hlog.MapCatalog are not considered.
+func (t *translations) has(lang string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if _, ok := t.assets[lang]; ok {
+		return true
+	}
+	for _, catalog := range t.catalogs {
+		if mc, ok := catalog.(hlog.MapCatalog); ok {
+			if _, ok := mc[lang]; ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// negotiate sets language of the session to requested language
+// e.g. et-EE, its base language et or fallback language when
+// there are no messages in requested language.
+func (t *translations) negotiate(requested string) string {
+	lang := i18nFallback
+	requested = strings.ToLower(strings.ReplaceAll(requested, "_", "-"))
+	base, _, _ := strings.Cut(requested, "-")
+	for _, candidate := range []string{requested, base} {
+		if candidate != "" && t.has(candidate) {
+			lang = candidate
+			break
+		}
+	}
+	t.mu.Lock()
+	t.lang = lang
+	t.mu.Unlock()
+	return lang
+}
+
+// empty reports whether there are no catalogs besides built-in messages.
+func (t *translations) empty() bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return len(t.assets) == 0 && len(t.catalogs) == 0
+}
+
+func (t *translations) language() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.lang == "" {
+		return i18nFallback
+	}
+	return t.lang
+}
+
+// translate returns message key rendered in language of the session
+// with args or key itself when it is not found in any catalog.
+func (t *translations) translate(key string, args ...any) string {
+	return hlog.NewTranslator(t, t.language(), i18nFallback).Translate(key, i18nAttrs(args)...)
+}
+
+// i18nAttrs converts key value pairs and slog.Attr arguments to attributes.
+func i18nAttrs(args []any) []slog.Attr {
+	var attrs []slog.Attr
+	for len(args) > 0 {
+		switch v := args[0].(type) {
+		case slog.Attr:
+			attrs = append(attrs, v)
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				attrs = append(attrs, slog.String("!BADKEY", v))
+				args = nil
+				continue
+			}
+			attrs = append(attrs, slog.Any(v, args[1]))
+			args = args[2:]
+		default:
+			attrs = append(attrs, slog.Any("!BADKEY", v))
+			args = args[1:]
+		}
+	}
+	return attrs
+}
+
+// WithTranslations adds message catalog used by Session.T, help output
+// and prompts e.g.
+//
+//	app.WithTranslations(hlog.MapCatalog{"et": {"greeting": "Tere {name}!"}})
+//
+// Catalogs loaded from i18n/<lang>.json files in application assets
+// override messages of catalogs added with WithTranslations.
+func (a *Application) WithTranslations(catalog hlog.Catalog) {
+	a.session.i18n.add(catalog)
+}
+
+// loadTranslations loads message catalogs from application assets and
+// negotiates language of the session from app.lang option or
+// environment. Logged messages are translated with session catalog
+// unless log catalog was set with SetLogCatalog.
+func (a *Application) loadTranslations() error {
+	assets := hlog.MapCatalog{}
+	entries, err := fs.ReadDir(a.session.assets, i18nAssetsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: translations: %s", ErrApplication, err.Error())
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || path.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		file := path.Join(i18nAssetsDir, entry.Name())
+		data, err := fs.ReadFile(a.session.assets, file)
+		if err != nil {
+			return fmt.Errorf("%w: translations %s: %s", ErrApplication, file, err.Error())
+		}
+		values, err := parseConfigJSON(data)
+		if err != nil {
+			return fmt.Errorf("%w: translations %s: %s", ErrApplication, file, err.Error())
+		}
+		lang := strings.ToLower(strings.TrimSuffix(entry.Name(), ".json"))
+		messages := make(map[string]string, len(values))
+		for key, value := range values {
+			messages[key] = fmt.Sprint(value)
+		}
+		assets[lang] = messages
+	}
+	a.session.i18n.mu.Lock()
+	a.session.i18n.assets = assets
+	a.session.i18n.mu.Unlock()
+
+	requested := a.session.Get("app.lang").String()
+	if requested == "" {
+		requested = hlog.LanguageFromEnv()
+	}
+	lang := a.session.i18n.negotiate(requested)
+	a.logger.SystemDebug("translations",
+		slog.String("lang", lang),
+		slog.Int("asset.catalogs", len(assets)),
+	)
+
+	if a.logTranslator == nil && !a.session.i18n.empty() {
+		loglang := a.session.Get("log.lang").String()
+		if loglang == "" {
+			loglang = lang
+		}
+		a.logTranslator = hlog.NewTranslator(a.session.i18n, loglang, i18nFallback)
+		a.configureLogger()
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/sdk/testutils"
+	"golang.org/x/exp/slog"
+)
+
+func TestTranslationsNegotiate(t *testing.T) {
+	tr := &translations{}
+	tr.add(hlog.MapCatalog{"et": {"greeting": "Tere {name}!"}})
+
+	testutils.Equal(t, "et", tr.negotiate("et_EE"))
+	testutils.Equal(t, "Tere Marko!", tr.translate("greeting", "name", "Marko"))
+	testutils.Equal(t, "USAGE", tr.translate("help.usage"))
+
+	testutils.Equal(t, "en", tr.negotiate("fr-FR"))
+	testutils.Equal(t, "greeting", tr.translate("greeting", slog.String("name", "Marko")))
+	testutils.Equal(t, "missing.key", tr.translate("missing.key"))
+}
+
+func TestAppTranslations(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.lang", "et-EE"))
+	app.WithTranslations(hlog.MapCatalog{
+		"en": {"greeting": "Hello {name}!", "farewell": "Bye!"},
+		"et": {"greeting": "Tere {name}!", "farewell": "Head aega!"},
+	})
+	app.WithAssets(fstest.MapFS{
+		"i18n/et.json": {Data: []byte(`{"farewell": "Nägemist!", "help": {"usage": "KASUTUS"}}`)},
+	})
+	testutils.NoError(t, app.loadTranslations())
+
+	sess := app.session
+	testutils.Equal(t, "et", sess.Language())
+	testutils.Equal(t, "Tere Marko!", sess.T("greeting", "name", "Marko"))
+	testutils.Equal(t, "Nägemist!", sess.T("farewell"))
+	testutils.Equal(t, "KASUTUS", sess.T("help.usage"))
+	testutils.Equal(t, "COMMANDS", sess.T("help.commands"))
+	testutils.NotNil(t, app.logTranslator)
+}
+
+func TestSessionTranslateZeroValue(t *testing.T) {
+	sess := &Session{}
+	testutils.Equal(t, "en", sess.Language())
+	testutils.Equal(t, "please answer (y)es or (n)o", sess.T("prompt.confirm.retry"))
+	testutils.Equal(t, "unknown", sess.T("unknown"))
+}
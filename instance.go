@@ -0,0 +1,212 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// instanceLock is held by the only running instance of the application
+// when app.instance.single is set. When app.instance.forward is set it
+// also accepts arguments forwarded by instances launched later.
+type instanceLock struct {
+	file   string
+	socket string
+	lock   *os.File
+	ln     net.Listener
+}
+
+// instanceNameRe matches names of isolated instances selected with
+// --instance flag.
+var instanceNameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// instanceSlug returns app.slug suffixed with app.instance.name, it names
+// directories, files and services of isolated application instance.
+func instanceSlug(sess *Session) string {
+	slug := sess.Get("app.slug").String()
+	if name := sess.Get("app.instance.name").String(); name != "" {
+		return slug + "-" + name
+	}
+	return slug
+}
+
+// applyInstance selects isolated instance of the application from
+// --instance flag, instance has its own config, cache and temp dirs and
+// host address e.g. happy://host/app-2 so that services, instance lock
+// and daemon of instances do not collide.
+func (a *Application) applyInstance() error {
+	if flag := a.rootCmd.flag("instance"); flag.Present() {
+		if err := a.overrideOption("app.instance.name", flag.String(), "flag"); err != nil {
+			return err
+		}
+	}
+	name := a.session.Get("app.instance.name").String()
+	if name == "" {
+		return nil
+	}
+	hostaddr, err := address.Parse(a.session.Get("app.host.addr").String())
+	if err != nil {
+		return err
+	}
+	addr := fmt.Sprintf("happy://%s/%s-%s", hostaddr.Host, hostaddr.Instance, name)
+	if err := a.session.opts.set("app.host.addr", addr, true); err != nil {
+		return err
+	}
+	a.logger.SystemDebug("using instance",
+		slog.String("instance", name),
+		slog.String("addr", addr),
+	)
+	return nil
+}
+
+// instanceKey returns file name key derived from application address.
+func instanceKey(addr string) string {
+	return strings.Trim(strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '_'
+	}, strings.TrimPrefix(addr, "happy://")), "_")
+}
+
+// instancePaths returns lock file and socket path of the application
+// instance, files are in application cache dir or temp dir when
+// application filesystem is not enabled.
+func instancePaths(sess *Session) (file, socket string) {
+	dir := sess.Get("app.path.cache").String()
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	key := instanceKey(sess.Get("app.host.addr").String())
+	return filepath.Join(dir, key+".lock"), filepath.Join(dir, key+".sock")
+}
+
+// acquireInstance acquires instance lock, when other instance holds
+// the lock arguments are forwarded to it if app.instance.forward
+// is set and forwarded is true, otherwise ErrInstanceRunning is returned.
+func (a *Application) acquireInstance() (forwarded bool, err error) {
+	file, socket := instancePaths(a.session)
+	f, err := os.OpenFile(file, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return false, fmt.Errorf("%w: instance lock: %s", ErrApplication, err.Error())
+	}
+	if err := lockFile(f); err != nil {
+		data, _ := os.ReadFile(file)
+		f.Close()
+		if a.session.Get("app.instance.forward").Bool() {
+			if err := forwardArgs(socket, a.args[1:]); err != nil {
+				return false, fmt.Errorf("%w: failed to forward arguments: %s", ErrInstanceRunning, err.Error())
+			}
+			a.logger.Info("forwarded arguments to running instance")
+			return true, nil
+		}
+		if pid := strings.TrimSpace(string(data)); pid != "" {
+			return false, fmt.Errorf("%w (pid %s)", ErrInstanceRunning, pid)
+		}
+		return false, ErrInstanceRunning
+	}
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+
+	inst := &instanceLock{file: file, lock: f}
+	if a.session.Get("app.instance.forward").Bool() {
+		_ = os.Remove(socket)
+		ln, err := net.Listen("unix", socket)
+		if err != nil {
+			inst.release()
+			return false, fmt.Errorf("%w: instance socket: %s", ErrApplication, err.Error())
+		}
+		inst.socket = socket
+		inst.ln = ln
+	}
+	a.instance = inst
+	a.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
+		return inst.release()
+	})
+	a.logger.SystemDebug("acquired instance lock", slog.String("file", file))
+	return false, nil
+}
+
+// serve dispatches app.instance.args event for arguments forwarded
+// by other instances until listener is closed.
+func (inst *instanceLock) serve(sess *Session) {
+	if inst == nil || inst.ln == nil {
+		return
+	}
+	for {
+		conn, err := inst.ln.Accept()
+		if err != nil {
+			return
+		}
+		var args []string
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		err = json.NewDecoder(conn).Decode(&args)
+		conn.Close()
+		if err != nil {
+			sess.Log().Warn("invalid arguments forwarded by instance", slog.String("err", err.Error()))
+			continue
+		}
+		payload := new(vars.Map)
+		_ = payload.Store("argc", len(args))
+		for i, arg := range args {
+			_ = payload.Store("arg."+strconv.Itoa(i), arg)
+		}
+		sess.Dispatch(NewEvent("app", "instance.args", payload, nil))
+	}
+}
+
+func (inst *instanceLock) release() error {
+	var errs []error
+	if inst.ln != nil {
+		errs = append(errs, inst.ln.Close())
+		_ = os.Remove(inst.socket)
+	}
+	// lock file is not removed, other instance may be
+	// waiting for lock of the same file
+	errs = append(errs, unlockFile(inst.lock), inst.lock.Close())
+	return errors.Join(errs...)
+}
+
+// forwardArgs sends arguments to instance listening on socket.
+func forwardArgs(socket string, args []string) error {
+	conn, err := net.DialTimeout("unix", socket, 5*time.Second)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if args == nil {
+		args = []string{}
+	}
+	return json.NewEncoder(conn).Encode(args)
+}
+
+// InstanceArgs returns arguments forwarded by other instance
+// from payload of app.instance.args event.
+func InstanceArgs(ev Event) []string {
+	payload := ev.Payload()
+	if payload == nil {
+		return nil
+	}
+	argc := payload.Get("argc").Int()
+	args := make([]string, 0, argc)
+	for i := 0; i < argc; i++ {
+		args = append(args, payload.Get("arg."+strconv.Itoa(i)).String())
+	}
+	return args
+}
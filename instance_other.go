@@ -0,0 +1,18 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix && !windows
+
+package happy
+
+import "os"
+
+// lockFile is noop on platforms without file locks.
+func lockFile(f *os.File) error {
+	return nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
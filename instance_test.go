@@ -0,0 +1,103 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newInstanceTestApp(t *testing.T, dir, host string, forward bool) *Application {
+	t.Helper()
+	app := New(
+		Option("log.console", false),
+		Option("app.host.addr", host),
+		Option("app.instance.single", true),
+		Option("app.instance.forward", forward),
+	)
+	app.exitOs = false
+	testutils.NoError(t, app.session.opts.db.Store("app.path.cache", dir))
+	return app
+}
+
+func TestInstanceSingle(t *testing.T) {
+	dir := t.TempDir()
+	host := "happy://localhost/instance-test"
+	first := newInstanceTestApp(t, dir, host, false)
+	forwarded, err := first.acquireInstance()
+	testutils.NoError(t, err)
+	testutils.False(t, forwarded)
+
+	second := newInstanceTestApp(t, dir, host, false)
+	_, err = second.acquireInstance()
+	testutils.ErrorIs(t, err, ErrInstanceRunning)
+	testutils.True(t, strings.Contains(err.Error(), fmt.Sprintf("pid %d", os.Getpid())), "error should contain pid: %s", err)
+
+	testutils.NoError(t, first.instance.release())
+	third := newInstanceTestApp(t, dir, host, false)
+	_, err = third.acquireInstance()
+	testutils.NoError(t, err)
+	testutils.NoError(t, third.instance.release())
+}
+
+func TestInstanceForward(t *testing.T) {
+	dir := t.TempDir()
+	host := "happy://localhost/instance-fwd"
+	first := newInstanceTestApp(t, dir, host, true)
+	_, err := first.acquireInstance()
+	testutils.NoError(t, err)
+	defer first.instance.release()
+
+	sess := newTestSession(t)
+	go first.instance.serve(sess)
+
+	second := newInstanceTestApp(t, dir, host, true)
+	forwarded, err := second.acquireInstance()
+	testutils.NoError(t, err)
+	testutils.True(t, forwarded)
+
+	select {
+	case ev := <-sess.evch:
+		testutils.Equal(t, "app", ev.Scope())
+		testutils.Equal(t, "instance.args", ev.Key())
+		testutils.Equal(t, strings.Join(os.Args[1:], " "), strings.Join(InstanceArgs(ev), " "))
+	case <-time.After(5 * time.Second):
+		t.Fatal("forwarded arguments were not dispatched")
+	}
+}
+
+func TestAppInstanceName(t *testing.T) {
+	host := "happy://localhost/test-app"
+	newApp := func(args ...string) *Application {
+		app := New(Option("log.console", false), Option("app.host.addr", host), Option("app.slug", "test-app"))
+		testutils.NoError(t, app.rootCmd.flags.Parse(append([]string{"app"}, args...)))
+		return app
+	}
+
+	app := newApp()
+	testutils.NoError(t, app.applyInstance())
+	testutils.Equal(t, host, app.session.Get("app.host.addr").String())
+	testutils.Equal(t, "test-app", instanceSlug(app.session))
+	lock, _ := instancePaths(app.session)
+
+	app = newApp("--instance", "2")
+	testutils.NoError(t, app.applyInstance())
+	testutils.Equal(t, "2", app.session.Get("app.instance.name").String())
+	testutils.Equal(t, "flag", app.session.OptionSource("app.instance.name"))
+	testutils.Equal(t, host+"-2", app.session.Get("app.host.addr").String())
+	testutils.Equal(t, "test-app-2", instanceSlug(app.session))
+	lock2, _ := instancePaths(app.session)
+	testutils.NotEqual(t, lock, lock2)
+
+	app = newApp("--instance", "Bad_Name")
+	testutils.ErrorIs(t, app.applyInstance(), ErrOptionValidation)
+}
@@ -0,0 +1,134 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+// eventRecord is single line in event journal.
+type eventRecord struct {
+	Time    time.Time `json:"time"`
+	Scope   string    `json:"scope"`
+	Key     string    `json:"key"`
+	Payload *vars.Map `json:"payload,omitempty"`
+	Err     string    `json:"err,omitempty"`
+}
+
+// eventJournal is append-only log of events delivered by the engine.
+// Each event is written as JSON object on its own line.
+type eventJournal struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func openEventJournal(path string) (*eventJournal, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &eventJournal{
+		path: path,
+		file: file,
+	}, nil
+}
+
+func newEventRecord(ev Event) eventRecord {
+	rec := eventRecord{
+		Time:    ev.Time(),
+		Scope:   ev.Scope(),
+		Key:     ev.Key(),
+		Payload: ev.Payload(),
+	}
+	if everr, ok := ev.(interface{ Err() error }); ok && everr.Err() != nil {
+		rec.Err = everr.Err().Error()
+	}
+	return rec
+}
+
+func (j *eventJournal) append(ev Event) error {
+	data, err := json.Marshal(newEventRecord(ev))
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("%w: event journal is closed", ErrEngine)
+	}
+	_, err = j.file.Write(data)
+	return err
+}
+
+// replay reads events from journal which were recorded at or after since
+// and match given listener pattern.
+func (j *eventJournal) replay(since time.Time, pattern string) ([]Event, error) {
+	if pattern == "" {
+		pattern = "any"
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	file, err := os.Open(j.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+	for scanner.Scan() {
+		var rec eventRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return events, fmt.Errorf("%w: corrupted event journal %s: %w", ErrEngine, j.path, err)
+		}
+		if rec.Time.Before(since) || !eventPatternMatch(pattern, rec.Scope, rec.Key) {
+			continue
+		}
+		var everr error
+		if rec.Err != "" {
+			everr = errors.New(rec.Err)
+		}
+		events = append(events, &happyEvent{
+			ts:      rec.Time,
+			scope:   rec.Scope,
+			key:     rec.Key,
+			err:     everr,
+			payload: rec.Payload,
+		})
+	}
+	return events, scanner.Err()
+}
+
+func (j *eventJournal) close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	err := j.file.Close()
+	j.file = nil
+	return err
+}
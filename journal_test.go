@@ -0,0 +1,48 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEventJournalReplay(t *testing.T) {
+	journal, err := openEventJournal(filepath.Join(t.TempDir(), "events", "journal"))
+	testutils.NoError(t, err)
+	defer journal.close()
+
+	payload := new(vars.Map)
+	testutils.NoError(t, payload.Store("name", "svc"))
+
+	start := time.Now()
+	testutils.NoError(t, journal.append(NewEvent("services", "service.started", payload, nil)))
+	testutils.NoError(t, journal.append(NewEvent("app", "config.changed", nil, errors.New("failed"))))
+	testutils.NoError(t, journal.append(NewEvent("services", "service.stopped", nil, nil)))
+
+	all, err := journal.replay(start, "")
+	testutils.NoError(t, err)
+	testutils.Equal(t, 3, len(all))
+
+	svcs, err := journal.replay(start, "services.*")
+	testutils.NoError(t, err)
+	testutils.Equal(t, 2, len(svcs))
+	testutils.Equal(t, "service.started", svcs[0].Key())
+	testutils.Equal(t, "svc", svcs[0].Payload().Get("name").String())
+
+	app, err := journal.replay(start, "app.config.changed")
+	testutils.NoError(t, err)
+	testutils.Equal(t, 1, len(app))
+	testutils.Equal(t, "failed", app[0].(*happyEvent).Err().Error())
+
+	future, err := journal.replay(time.Now().Add(time.Hour), "")
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, len(future))
+}
@@ -7,6 +7,8 @@ package happy
 import (
 	"math"
 
+	"github.com/mkungla/happy/pkg/hlog"
+
 	"golang.org/x/exp/slog"
 )
 
@@ -16,6 +18,7 @@ type Level int8
 
 const (
 	// Happy Log Levels
+	LogLevelTrace          LogLevel = LogLevel(slog.LevelDebug - 2)
 	LogLevelSystemDebug    LogLevel = LogLevel(slog.LevelDebug - 1)
 	LogLevelDebug          LogLevel = LogLevel(slog.LevelDebug)
 	LogLevelInfo           LogLevel = LogLevel(slog.LevelInfo)
@@ -33,3 +36,17 @@ const (
 	LogLevelBUG            LogLevel = 1000
 	LogLevelAlways         LogLevel = math.MaxInt32
 )
+
+// moreVerboseLevel returns next more verbose level
+// used when log verbosity is raised at runtime.
+func moreVerboseLevel(lvl hlog.Level) hlog.Level {
+	switch {
+	case lvl > hlog.LevelInfo:
+		return hlog.LevelInfo
+	case lvl > hlog.LevelDebug:
+		return hlog.LevelDebug
+	case lvl > hlog.LevelSystemDebug:
+		return hlog.LevelSystemDebug
+	}
+	return hlog.LevelTrace
+}
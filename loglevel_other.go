@@ -0,0 +1,12 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix
+
+package happy
+
+// handleLogLevelSignals is noop on platforms without SIGUSR1.
+func handleLogLevelSignals(a *Application) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"golang.org/x/exp/slog"
+)
+
+// handleLogLevelSignals raises log verbosity one step on SIGUSR1
+// and restores level from log.level option on SIGUSR2 until
+// returned stop func is called. Signals with handlers registered
+// with Application.OnSignal are ignored.
+func handleLogLevelSignals(a *Application) (stop func()) {
+	sess := a.session
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGUSR1, syscall.SIGUSR2)
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				if a.hasSignalHandler(s) {
+					continue
+				}
+				lvl, err := hlog.ParseLevel(sess.Get("log.level").String())
+				if err != nil {
+					continue
+				}
+				if s == syscall.SIGUSR1 {
+					lvl = moreVerboseLevel(sess.Log().Level())
+				}
+				if err := sess.Log().SetLevel(lvl); err != nil {
+					continue
+				}
+				sess.Log().Notice("log level changed", slog.String("level", lvl.String()))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
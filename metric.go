@@ -0,0 +1,218 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// MetricKind is kind of metric recorded with Monitor.
+type MetricKind string
+
+const (
+	MetricCounter   MetricKind = "counter"
+	MetricGauge     MetricKind = "gauge"
+	MetricHistogram MetricKind = "histogram"
+)
+
+// DefaultHistogramBuckets are upper bounds of histogram buckets suitable
+// for durations in seconds, used when Monitor.Histogram has no buckets.
+var DefaultHistogramBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Counter is monotonically increasing metric e.g. number of requests.
+type Counter struct {
+	v atomic.Uint64
+}
+
+// Inc increments counter by one.
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add increments counter by n.
+func (c *Counter) Add(n uint64) {
+	c.v.Add(n)
+}
+
+// Value returns current value of the counter.
+func (c *Counter) Value() uint64 {
+	return c.v.Load()
+}
+
+// Gauge is metric which can go up and down e.g. number of connections.
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+// Set sets gauge to v.
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+// Add adds delta to the gauge, delta can be negative.
+func (g *Gauge) Add(delta float64) {
+	for {
+		old := g.bits.Load()
+		if g.bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+// Value returns current value of the gauge.
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+// Histogram counts observed values e.g. request durations in buckets.
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     float64
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefaultHistogramBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds value to the histogram.
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.count++
+	h.sum += v
+	if i := sort.SearchFloat64s(h.buckets, v); i < len(h.buckets) {
+		h.counts[i]++
+	}
+}
+
+// HistogramBucket is number of observed values less than
+// or equal to upper bound of the bucket.
+type HistogramBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      uint64  `json:"count"`
+}
+
+// Metric is snapshot of metric recorded with Monitor. Value is value of
+// counter or gauge, histograms have Count, Sum and cumulative Buckets.
+type Metric struct {
+	Name    string            `json:"name"`
+	Kind    MetricKind        `json:"kind"`
+	Value   float64           `json:"value"`
+	Count   uint64            `json:"count,omitempty"`
+	Sum     float64           `json:"sum,omitempty"`
+	Buckets []HistogramBucket `json:"buckets,omitempty"`
+}
+
+func (h *Histogram) snapshot(name string) Metric {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	m := Metric{
+		Name:    name,
+		Kind:    MetricHistogram,
+		Count:   h.count,
+		Sum:     h.sum,
+		Buckets: make([]HistogramBucket, len(h.buckets)),
+	}
+	var cumulative uint64
+	for i, le := range h.buckets {
+		cumulative += h.counts[i]
+		m.Buckets[i] = HistogramBucket{UpperBound: le, Count: cumulative}
+	}
+	return m
+}
+
+// metricName returns name with characters not allowed
+// in metric names replaced with underscore.
+func metricName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r == ':' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// monitorMetric returns metric name creating it with create when it does
+// not exist, ok is false when name is used by metric of other kind.
+func monitorMetric[T any](m *Monitor, name string, create func() *T) (metric *T, ok bool) {
+	if m == nil {
+		return create(), false
+	}
+	name = metricName(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if existing, exists := m.metrics[name]; exists {
+		metric, ok = existing.(*T)
+		if !ok {
+			return create(), false
+		}
+		return metric, true
+	}
+	metric = create()
+	m.metrics[name] = metric
+	return metric, true
+}
+
+// Counter returns counter name creating it on first call. Characters
+// of the name not allowed by exporters e.g. dots are replaced with
+// underscore. Counter is not exported when name is used by metric of
+// other kind.
+func (m *Monitor) Counter(name string) *Counter {
+	c, _ := monitorMetric(m, name, func() *Counter { return &Counter{} })
+	return c
+}
+
+// Gauge returns gauge name creating it on first call, see Monitor.Counter.
+func (m *Monitor) Gauge(name string) *Gauge {
+	g, _ := monitorMetric(m, name, func() *Gauge { return &Gauge{} })
+	return g
+}
+
+// Histogram returns histogram name creating it with buckets on first call,
+// DefaultHistogramBuckets are used when buckets are not given. See
+// Monitor.Counter.
+func (m *Monitor) Histogram(name string, buckets ...float64) *Histogram {
+	h, _ := monitorMetric(m, name, func() *Histogram { return newHistogram(buckets) })
+	return h
+}
+
+// Metrics returns snapshot of metrics recorded with Monitor sorted by name.
+func (m *Monitor) Metrics() []Metric {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	metrics := make([]Metric, 0, len(m.metrics))
+	for name, metric := range m.metrics {
+		switch v := metric.(type) {
+		case *Counter:
+			metrics = append(metrics, Metric{Name: name, Kind: MetricCounter, Value: float64(v.Value())})
+		case *Gauge:
+			metrics = append(metrics, Metric{Name: name, Kind: MetricGauge, Value: v.Value()})
+		case *Histogram:
+			metrics = append(metrics, v.snapshot(name))
+		}
+	}
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].Name < metrics[j].Name
+	})
+	return metrics
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestMonitorMetrics(t *testing.T) {
+	m := newMonitor()
+	m.Counter("cache.hits").Inc()
+	m.Counter("cache.hits").Add(2)
+	testutils.Equal(t, uint64(3), m.Counter("cache_hits").Value())
+
+	g := m.Gauge("connections")
+	g.Set(5)
+	g.Add(-1.5)
+	testutils.Equal(t, 3.5, g.Value())
+
+	h := m.Histogram("request.seconds", 1, 0.1)
+	for _, v := range []float64{0.05, 0.5, 0.7, 3} {
+		h.Observe(v)
+	}
+
+	// name used by other kind is not exported
+	m.Gauge("cache.hits").Set(100)
+
+	metrics := m.Metrics()
+	testutils.Equal(t, 3, len(metrics))
+	testutils.Equal(t, "cache_hits", metrics[0].Name)
+	testutils.Equal(t, MetricCounter, metrics[0].Kind)
+	testutils.Equal(t, 3.0, metrics[0].Value)
+	testutils.Equal(t, MetricGauge, metrics[1].Kind)
+
+	hist := metrics[2]
+	testutils.Equal(t, "request_seconds", hist.Name)
+	testutils.Equal(t, uint64(4), hist.Count)
+	testutils.Equal(t, 4.25, hist.Sum)
+	testutils.Equal(t, 2, len(hist.Buckets))
+	testutils.Equal(t, HistogramBucket{UpperBound: 0.1, Count: 1}, hist.Buckets[0])
+	testutils.Equal(t, HistogramBucket{UpperBound: 1, Count: 3}, hist.Buckets[1])
+
+	var disabled *Monitor
+	disabled.Counter("x").Inc()
+	testutils.Equal(t, 0, len(disabled.Metrics()))
+}
+
+func TestMetricsMonitorExport(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	sess.monitor = newMonitor()
+	sess.Monitor().Counter("jobs.done").Add(7)
+	sess.Monitor().Histogram("job.seconds", 1).Observe(0.5)
+
+	var b bytes.Buffer
+	testutils.NoError(t, writeMetrics(&b, sess))
+	body := b.String()
+	for _, line := range []string{
+		"# TYPE jobs_done counter",
+		"jobs_done 7",
+		"# TYPE job_seconds histogram",
+		`job_seconds_bucket{le="1"} 1`,
+		`job_seconds_bucket{le="+Inf"} 1`,
+		"job_seconds_sum 0.5",
+		"job_seconds_count 1",
+	} {
+		testutils.True(t, strings.Contains(body, line+"\n"), "missing: "+line)
+	}
+}
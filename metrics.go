@@ -0,0 +1,269 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// metricsServiceName is name of built-in metrics service
+// enabled with app.metrics.addr option.
+const metricsServiceName = "metrics"
+
+// metricsService serves engine, service, event bus, cron and runtime
+// metrics in Prometheus text format on /metrics of given address.
+func metricsService(addr string) *Service {
+	svc := NewService(metricsServiceName)
+
+	var server *http.Server
+
+	svc.OnStart(func(sess *Session) error {
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		server = &http.Server{
+			Handler:           metricsHandler(sess),
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+		go func() {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				sess.Log().Error("metrics server failed", err)
+			}
+		}()
+		sess.Log().Info("metrics server listening", slog.String("addr", ln.Addr().String()))
+		return nil
+	})
+
+	svc.OnStop(func(sess *Session) error {
+		if server == nil {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return server.Shutdown(ctx)
+	})
+	return svc
+}
+
+func metricsHandler(sess *Session) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if err := writeMetrics(w, sess); err != nil {
+			sess.Log().Error("failed to write metrics", err)
+		}
+	})
+	return mux
+}
+
+// busStats is snapshot of event bus queue.
+type busStats struct {
+	name          string
+	workers       int
+	queueDepth    int
+	queueCapacity int
+}
+
+// busStats returns stats of main event bus and of buses added
+// with AddEventBus sorted by name.
+func (e *Engine) busStats() []busStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	stats := []busStats{{name: MainEventBus}}
+	if e.evPool != nil {
+		stats[0].workers = e.evPool.workers
+		stats[0].queueDepth = len(e.evPool.queue)
+		stats[0].queueCapacity = cap(e.evPool.queue)
+	}
+	for _, bus := range e.buses {
+		bs := busStats{name: bus.name, workers: bus.workers}
+		if bus.pool != nil {
+			bs.queueDepth = len(bus.pool.queue)
+			bs.queueCapacity = cap(bus.pool.queue)
+		}
+		stats = append(stats, bs)
+	}
+	sort.Slice(stats[1:], func(i, j int) bool {
+		return stats[i+1].name < stats[j+1].name
+	})
+	return stats
+}
+
+// cronStats is snapshot of cron jobs of the service.
+type cronStats struct {
+	service  string
+	jobs     int
+	runs     uint64
+	failures uint64
+}
+
+// cronStats returns stats of services having cron jobs sorted by address.
+func (e *Engine) cronStats() []cronStats {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	var stats []cronStats
+	for addr, svcc := range e.registry {
+		svcc.mu.Lock()
+		cron := svcc.cron
+		svcc.mu.Unlock()
+		if cron == nil {
+			continue
+		}
+		stats = append(stats, cronStats{
+			service:  addr,
+			jobs:     len(cron.lib.Entries()),
+			runs:     cron.runs.Load(),
+			failures: cron.failures.Load(),
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].service < stats[j].service
+	})
+	return stats
+}
+
+// metricsWriter writes metrics in Prometheus text exposition format.
+type metricsWriter struct {
+	w *bufio.Writer
+}
+
+// family writes HELP and TYPE lines of metric family.
+func (mw *metricsWriter) family(name, typ, help string) {
+	fmt.Fprintf(mw.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// sample writes sample of metric, labels are given as name value pairs.
+func (mw *metricsWriter) sample(name string, value float64, labels ...string) {
+	mw.w.WriteString(name)
+	if len(labels) > 1 {
+		mw.w.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				mw.w.WriteByte(',')
+			}
+			fmt.Fprintf(mw.w, "%s=\"%s\"", labels[i], metricsLabelEscaper.Replace(labels[i+1]))
+		}
+		mw.w.WriteByte('}')
+	}
+	fmt.Fprintf(mw.w, " %g\n", value)
+}
+
+// metric writes metric family with single sample.
+func (mw *metricsWriter) metric(name, typ, help string, value float64) {
+	mw.family(name, typ, help)
+	mw.sample(name, value)
+}
+
+// metricsLabelEscaper escapes label values as required by text format.
+var metricsLabelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writeMetrics writes metrics of the session in Prometheus text format.
+func writeMetrics(w io.Writer, sess *Session) error {
+	mw := &metricsWriter{w: bufio.NewWriter(w)}
+	desc := sess.Describe()
+
+	mw.metric("happy_engine_uptime_seconds", "gauge", "Time since engine started.", desc.Uptime.Seconds())
+	mw.metric("happy_engine_paused", "gauge", "Whether engine is paused.", boolMetric(desc.Paused))
+	mw.metric("happy_engine_tick_rate_seconds", "gauge", "Interval of engine ticks.", desc.TickRate.Seconds())
+	mw.metric("happy_session_ready", "gauge", "Whether session is ready.", boolMetric(desc.Ready))
+
+	mw.metric("happy_events_dispatched_total", "counter", "Events dispatched to the session.", float64(desc.Events.Dispatched))
+	mw.metric("happy_events_dropped_total", "counter", "Events dropped because event queue was full.", float64(desc.Events.Dropped))
+	mw.metric("happy_events_coalesced_total", "counter", "Events replaced by newer event with the same key.", float64(desc.Events.Coalesced))
+	mw.metric("happy_events_queue_depth", "gauge", "Events waiting in session event queue.", float64(desc.Events.QueueDepth))
+	mw.metric("happy_events_queue_capacity", "gauge", "Capacity of session event queue.", float64(desc.Events.QueueCapacity))
+	mw.metric("happy_events_pending", "gauge", "Coalesced events waiting for event queue to drain.", float64(desc.Events.Pending))
+	mw.metric("happy_events_latency_avg_seconds", "gauge", "Average time from event creation until delivery.", desc.Events.AvgLatency.Seconds())
+	mw.metric("happy_events_latency_max_seconds", "gauge", "Maximum time from event creation until delivery.", desc.Events.MaxLatency.Seconds())
+
+	if sess.engine != nil {
+		buses := sess.engine.busStats()
+		mw.family("happy_bus_workers", "gauge", "Workers delivering events of the event bus.")
+		for _, bus := range buses {
+			mw.sample("happy_bus_workers", float64(bus.workers), "bus", bus.name)
+		}
+		mw.family("happy_bus_queue_depth", "gauge", "Events waiting to be delivered on the event bus.")
+		for _, bus := range buses {
+			mw.sample("happy_bus_queue_depth", float64(bus.queueDepth), "bus", bus.name)
+		}
+		mw.family("happy_bus_queue_capacity", "gauge", "Capacity of event bus queue.")
+		for _, bus := range buses {
+			mw.sample("happy_bus_queue_capacity", float64(bus.queueCapacity), "bus", bus.name)
+		}
+	}
+
+	mw.family("happy_service_up", "gauge", "Whether service is running.")
+	for _, svc := range desc.Services {
+		mw.sample("happy_service_up", boolMetric(svc.Running), "service", svc.Addr)
+	}
+	mw.family("happy_service_errors", "gauge", "Errors recorded by the service.")
+	for _, svc := range desc.Services {
+		mw.sample("happy_service_errors", float64(len(svc.Errs)), "service", svc.Addr)
+	}
+
+	if sess.engine != nil {
+		crons := sess.engine.cronStats()
+		mw.family("happy_cron_jobs", "gauge", "Cron jobs scheduled by the service.")
+		for _, c := range crons {
+			mw.sample("happy_cron_jobs", float64(c.jobs), "service", c.service)
+		}
+		mw.family("happy_cron_runs_total", "counter", "Cron job runs of the service.")
+		for _, c := range crons {
+			mw.sample("happy_cron_runs_total", float64(c.runs), "service", c.service)
+		}
+		mw.family("happy_cron_failures_total", "counter", "Cron job runs of the service which returned error.")
+		for _, c := range crons {
+			mw.sample("happy_cron_failures_total", float64(c.failures), "service", c.service)
+		}
+	}
+
+	for _, m := range sess.Monitor().Metrics() {
+		mw.family(m.Name, string(m.Kind), "Application "+string(m.Kind)+" recorded with Monitor.")
+		if m.Kind != MetricHistogram {
+			mw.sample(m.Name, m.Value)
+			continue
+		}
+		for _, b := range m.Buckets {
+			mw.sample(m.Name+"_bucket", float64(b.Count), "le", strconv.FormatFloat(b.UpperBound, 'g', -1, 64))
+		}
+		mw.sample(m.Name+"_bucket", float64(m.Count), "le", "+Inf")
+		mw.sample(m.Name+"_sum", m.Sum)
+		mw.sample(m.Name+"_count", float64(m.Count))
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	mw.family("go_info", "gauge", "Information about the Go environment.")
+	mw.sample("go_info", 1, "version", runtime.Version())
+	mw.metric("go_goroutines", "gauge", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	mw.metric("go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.", float64(mem.Alloc))
+	mw.metric("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.", float64(mem.Sys))
+	mw.metric("go_memstats_heap_objects", "gauge", "Number of allocated objects.", float64(mem.HeapObjects))
+	mw.metric("go_gc_cycles_total", "counter", "Number of completed GC cycles.", float64(mem.NumGC))
+	mw.metric("go_gc_pause_seconds_total", "counter", "Total GC pause time.", time.Duration(mem.PauseTotalNs).Seconds())
+
+	return mw.w.Flush()
+}
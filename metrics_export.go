@@ -0,0 +1,418 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+var ErrMetricsExport = errors.New("metrics export error")
+
+const (
+	metricsExportTimeout = 10 * time.Second
+	otlpMetricsPath      = "/v1/metrics"
+	// statsdMaxPacket keeps StatsD datagrams below common network MTU.
+	statsdMaxPacket = 1432
+)
+
+// MetricsExporter pushes metrics to external system, see
+// Application.AddMetricsExporter. ExportMetrics is called every
+// app.metrics.export.interval and once more when engine stops, calls
+// are never concurrent. Exporters implementing io.Closer are closed after
+// last export. Counters and histograms are cumulative since
+// application started.
+type MetricsExporter interface {
+	ExportMetrics(ctx context.Context, metrics []Metric) error
+}
+
+// namedMetricsExporter is exporter with name used in logs.
+type namedMetricsExporter struct {
+	name     string
+	exporter MetricsExporter
+}
+
+// metricsPusher pushes metrics to exporters on interval.
+// Methods of nil pusher are no-op so export can be disabled.
+type metricsPusher struct {
+	interval  time.Duration
+	exporters []namedMetricsExporter
+}
+
+// newMetricsPusher returns pusher for exporters added with
+// AddMetricsExporter and exporters enabled with app.metrics.statsd.addr
+// and app.metrics.otlp.endpoint, it returns nil when there are none.
+func newMetricsPusher(sess *Session, exporters []namedMetricsExporter) *metricsPusher {
+	if addr := sess.Get("app.metrics.statsd.addr").String(); addr != "" {
+		exporters = append(exporters, namedMetricsExporter{
+			name:     "statsd",
+			exporter: newStatsDExporter(addr, sess.Get("app.metrics.statsd.prefix").String()),
+		})
+	}
+	if endpoint := sess.Get("app.metrics.otlp.endpoint").String(); endpoint != "" {
+		headers := make(map[string]string)
+		for _, header := range strings.Split(sess.Get("app.metrics.otlp.headers").String(), ",") {
+			if k, v, ok := strings.Cut(header, "="); ok {
+				headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+			}
+		}
+		exporters = append(exporters, namedMetricsExporter{
+			name: "otlp",
+			exporter: newOTLPMetricsExporter(endpoint, headers, map[string]string{
+				"service.name":    sess.Get("app.slug").String(),
+				"service.version": sess.Get("app.version").String(),
+			}),
+		})
+	}
+	if len(exporters) == 0 {
+		return nil
+	}
+	return &metricsPusher{
+		interval:  time.Duration(sess.Get("app.metrics.export.interval").Int64()),
+		exporters: exporters,
+	}
+}
+
+// run pushes metrics every interval until ctx is done,
+// metrics are pushed once more before it returns.
+func (p *metricsPusher) run(ctx context.Context, sess *Session) {
+	if p == nil {
+		return
+	}
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), metricsExportTimeout)
+			p.push(ctx, sess)
+			cancel()
+			for _, exp := range p.exporters {
+				if closer, ok := exp.exporter.(io.Closer); ok {
+					_ = closer.Close()
+				}
+			}
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(ctx, metricsExportTimeout)
+			p.push(ctx, sess)
+			cancel()
+		}
+	}
+}
+
+func (p *metricsPusher) push(ctx context.Context, sess *Session) {
+	metrics := exportedMetrics(sess)
+	for _, exp := range p.exporters {
+		if err := exp.exporter.ExportMetrics(ctx, metrics); err != nil {
+			sess.Log().Warn("failed to export metrics",
+				slog.String("exporter", exp.name),
+				slog.String("err", err.Error()))
+		}
+	}
+}
+
+// exportedMetrics returns engine, event and runtime metrics
+// followed by metrics recorded with Monitor.
+func exportedMetrics(sess *Session) []Metric {
+	desc := sess.Describe()
+	running := 0
+	for _, svc := range desc.Services {
+		if svc.Running {
+			running++
+		}
+	}
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	metrics := []Metric{
+		{Name: "happy_engine_uptime_seconds", Kind: MetricGauge, Value: desc.Uptime.Seconds()},
+		{Name: "happy_session_ready", Kind: MetricGauge, Value: boolMetric(desc.Ready)},
+		{Name: "happy_services_running", Kind: MetricGauge, Value: float64(running)},
+		{Name: "happy_events_dispatched_total", Kind: MetricCounter, Value: float64(desc.Events.Dispatched)},
+		{Name: "happy_events_dropped_total", Kind: MetricCounter, Value: float64(desc.Events.Dropped)},
+		{Name: "happy_events_queue_depth", Kind: MetricGauge, Value: float64(desc.Events.QueueDepth)},
+		{Name: "go_goroutines", Kind: MetricGauge, Value: float64(runtime.NumGoroutine())},
+		{Name: "go_memstats_alloc_bytes", Kind: MetricGauge, Value: float64(mem.Alloc)},
+		{Name: "go_memstats_sys_bytes", Kind: MetricGauge, Value: float64(mem.Sys)},
+		{Name: "go_gc_cycles_total", Kind: MetricCounter, Value: float64(mem.NumGC)},
+	}
+	return append(metrics, sess.Monitor().Metrics()...)
+}
+
+// statsdExporter pushes metrics to StatsD over UDP. Counters and
+// histogram count and sum are sent as deltas since previous export,
+// histogram buckets are not exported.
+type statsdExporter struct {
+	addr   string
+	prefix string
+	conn   net.Conn
+	// values of counters at previous export
+	last map[string]float64
+}
+
+func newStatsDExporter(addr, prefix string) *statsdExporter {
+	return &statsdExporter{
+		addr:   addr,
+		prefix: prefix,
+		last:   make(map[string]float64),
+	}
+}
+
+func (e *statsdExporter) ExportMetrics(ctx context.Context, metrics []Metric) error {
+	if e.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "udp", e.addr)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrMetricsExport, err.Error())
+		}
+		e.conn = conn
+	}
+	var packet bytes.Buffer
+	var errs []error
+	send := func() {
+		if packet.Len() == 0 {
+			return
+		}
+		if _, err := e.conn.Write(packet.Bytes()); err != nil {
+			errs = append(errs, err)
+		}
+		packet.Reset()
+	}
+	for _, line := range e.lines(metrics) {
+		if packet.Len() > 0 && packet.Len()+len(line)+1 > statsdMaxPacket {
+			send()
+		}
+		if packet.Len() > 0 {
+			packet.WriteByte('\n')
+		}
+		packet.WriteString(line)
+	}
+	send()
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrMetricsExport, errors.Join(errs...))
+	}
+	return nil
+}
+
+// lines returns StatsD lines of metrics.
+func (e *statsdExporter) lines(metrics []Metric) []string {
+	var lines []string
+	counter := func(name string, value float64) {
+		if delta := value - e.last[name]; delta > 0 {
+			lines = append(lines, e.prefix+name+":"+formatStatsDValue(delta)+"|c")
+		}
+		e.last[name] = value
+	}
+	for _, m := range metrics {
+		switch m.Kind {
+		case MetricCounter:
+			counter(m.Name, m.Value)
+		case MetricGauge:
+			// negative values are relative changes in StatsD,
+			// reset gauge to zero first to set it.
+			if m.Value < 0 {
+				lines = append(lines, e.prefix+m.Name+":0|g")
+			}
+			lines = append(lines, e.prefix+m.Name+":"+formatStatsDValue(m.Value)+"|g")
+		case MetricHistogram:
+			counter(m.Name+".count", float64(m.Count))
+			counter(m.Name+".sum", m.Sum)
+		}
+	}
+	return lines
+}
+
+// Close closes connection to StatsD server.
+func (e *statsdExporter) Close() error {
+	if e.conn == nil {
+		return nil
+	}
+	return e.conn.Close()
+}
+
+func formatStatsDValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// otlpMetricsExporter pushes metrics to OpenTelemetry collector using
+// OTLP/HTTP JSON protocol with cumulative temporality, failed export is
+// not retried since next export carries the same cumulative values.
+type otlpMetricsExporter struct {
+	endpoint string
+	headers  map[string]string
+	resource map[string]string
+	started  time.Time
+	client   *http.Client
+}
+
+func newOTLPMetricsExporter(endpoint string, headers, resource map[string]string) *otlpMetricsExporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.Contains(strings.SplitN(endpoint, "://", 2)[1], "/") {
+		endpoint += otlpMetricsPath
+	}
+	return &otlpMetricsExporter{
+		endpoint: endpoint,
+		headers:  headers,
+		resource: resource,
+		started:  time.Now(),
+		client:   http.DefaultClient,
+	}
+}
+
+func (e *otlpMetricsExporter) ExportMetrics(ctx context.Context, metrics []Metric) error {
+	body, err := json.Marshal(e.payload(time.Now(), metrics))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMetricsExport, err.Error())
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMetricsExport, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrMetricsExport, err.Error())
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%w: collector responded %s", ErrMetricsExport, resp.Status)
+	}
+	return nil
+}
+
+// payload returns OTLP metrics data of metrics observed at now.
+func (e *otlpMetricsExporter) payload(now time.Time, metrics []Metric) otlpMetricsData {
+	start := strconv.FormatInt(e.started.UnixNano(), 10)
+	ts := strconv.FormatInt(now.UnixNano(), 10)
+	scope := otlpScopeMetrics{Scope: otlpMetricsScope{Name: "github.com/mkungla/happy"}}
+	for _, m := range metrics {
+		om := otlpMetric{Name: m.Name}
+		switch m.Kind {
+		case MetricCounter:
+			om.Sum = &otlpSum{
+				AggregationTemporality: otlpCumulative,
+				IsMonotonic:            true,
+				DataPoints:             []otlpNumberDataPoint{{StartTimeUnixNano: start, TimeUnixNano: ts, AsDouble: m.Value}},
+			}
+		case MetricGauge:
+			om.Gauge = &otlpGauge{
+				DataPoints: []otlpNumberDataPoint{{TimeUnixNano: ts, AsDouble: m.Value}},
+			}
+		case MetricHistogram:
+			dp := otlpHistogramDataPoint{
+				StartTimeUnixNano: start,
+				TimeUnixNano:      ts,
+				Count:             strconv.FormatUint(m.Count, 10),
+				Sum:               m.Sum,
+			}
+			// OTLP bucket counts are not cumulative and have
+			// extra bucket for values above last bound.
+			var prev uint64
+			for _, b := range m.Buckets {
+				dp.ExplicitBounds = append(dp.ExplicitBounds, b.UpperBound)
+				dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(b.Count-prev, 10))
+				prev = b.Count
+			}
+			dp.BucketCounts = append(dp.BucketCounts, strconv.FormatUint(m.Count-prev, 10))
+			om.Histogram = &otlpHistogram{
+				AggregationTemporality: otlpCumulative,
+				DataPoints:             []otlpHistogramDataPoint{dp},
+			}
+		default:
+			continue
+		}
+		scope.Metrics = append(scope.Metrics, om)
+	}
+
+	resource := otlpMetricsResource{}
+	for k, v := range e.resource {
+		resource.Attributes = append(resource.Attributes, otlpMetricsAttr{
+			Key:   k,
+			Value: otlpMetricsAttrValue{StringValue: v},
+		})
+	}
+	return otlpMetricsData{ResourceMetrics: []otlpResourceMetrics{{
+		Resource:     resource,
+		ScopeMetrics: []otlpScopeMetrics{scope},
+	}}}
+}
+
+// otlpCumulative is AGGREGATION_TEMPORALITY_CUMULATIVE.
+const otlpCumulative = 2
+
+// OTLP/HTTP JSON encoding of metrics data.
+type (
+	otlpMetricsData struct {
+		ResourceMetrics []otlpResourceMetrics `json:"resourceMetrics"`
+	}
+	otlpResourceMetrics struct {
+		Resource     otlpMetricsResource `json:"resource"`
+		ScopeMetrics []otlpScopeMetrics  `json:"scopeMetrics"`
+	}
+	otlpMetricsResource struct {
+		Attributes []otlpMetricsAttr `json:"attributes"`
+	}
+	otlpMetricsAttr struct {
+		Key   string               `json:"key"`
+		Value otlpMetricsAttrValue `json:"value"`
+	}
+	otlpMetricsAttrValue struct {
+		StringValue string `json:"stringValue"`
+	}
+	otlpScopeMetrics struct {
+		Scope   otlpMetricsScope `json:"scope"`
+		Metrics []otlpMetric     `json:"metrics"`
+	}
+	otlpMetricsScope struct {
+		Name string `json:"name"`
+	}
+	otlpMetric struct {
+		Name      string         `json:"name"`
+		Sum       *otlpSum       `json:"sum,omitempty"`
+		Gauge     *otlpGauge     `json:"gauge,omitempty"`
+		Histogram *otlpHistogram `json:"histogram,omitempty"`
+	}
+	otlpSum struct {
+		AggregationTemporality int                   `json:"aggregationTemporality"`
+		IsMonotonic            bool                  `json:"isMonotonic"`
+		DataPoints             []otlpNumberDataPoint `json:"dataPoints"`
+	}
+	otlpGauge struct {
+		DataPoints []otlpNumberDataPoint `json:"dataPoints"`
+	}
+	otlpNumberDataPoint struct {
+		StartTimeUnixNano string  `json:"startTimeUnixNano,omitempty"`
+		TimeUnixNano      string  `json:"timeUnixNano"`
+		AsDouble          float64 `json:"asDouble"`
+	}
+	otlpHistogram struct {
+		AggregationTemporality int                      `json:"aggregationTemporality"`
+		DataPoints             []otlpHistogramDataPoint `json:"dataPoints"`
+	}
+	otlpHistogramDataPoint struct {
+		StartTimeUnixNano string    `json:"startTimeUnixNano"`
+		TimeUnixNano      string    `json:"timeUnixNano"`
+		Count             string    `json:"count"`
+		Sum               float64   `json:"sum"`
+		BucketCounts      []string  `json:"bucketCounts"`
+		ExplicitBounds    []float64 `json:"explicitBounds"`
+	}
+)
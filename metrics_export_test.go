@@ -0,0 +1,127 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestStatsDExporter(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	testutils.NoError(t, err)
+	defer pc.Close()
+
+	exp := newStatsDExporter(pc.LocalAddr().String(), "app.")
+	defer exp.Close()
+	read := func() []string {
+		buf := make([]byte, statsdMaxPacket)
+		testutils.NoError(t, pc.SetReadDeadline(time.Now().Add(time.Second)))
+		n, _, err := pc.ReadFrom(buf)
+		testutils.NoError(t, err)
+		return strings.Split(string(buf[:n]), "\n")
+	}
+
+	metrics := []Metric{
+		{Name: "jobs_total", Kind: MetricCounter, Value: 5},
+		{Name: "temperature", Kind: MetricGauge, Value: -2.5},
+		{Name: "latency", Kind: MetricHistogram, Count: 2, Sum: 0.5},
+	}
+	testutils.NoError(t, exp.ExportMetrics(context.Background(), metrics))
+	testutils.Equal(t, strings.Join([]string{
+		"app.jobs_total:5|c",
+		"app.temperature:0|g",
+		"app.temperature:-2.5|g",
+		"app.latency.count:2|c",
+		"app.latency.sum:0.5|c",
+	}, ","), strings.Join(read(), ","))
+
+	metrics[0].Value = 8
+	metrics[1].Value = 1
+	testutils.NoError(t, exp.ExportMetrics(context.Background(), metrics))
+	testutils.Equal(t, "app.jobs_total:3|c,app.temperature:1|g", strings.Join(read(), ","),
+		"counters should be sent as deltas and unchanged counters skipped")
+}
+
+func TestOTLPMetricsExporter(t *testing.T) {
+	var got otlpMetricsData
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		testutils.Equal(t, otlpMetricsPath, r.URL.Path)
+		testutils.Equal(t, "secret", r.Header.Get("Authorization"))
+		testutils.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+	}))
+	defer srv.Close()
+
+	exp := newOTLPMetricsExporter(srv.URL, map[string]string{"Authorization": "secret"}, map[string]string{"service.name": "app"})
+	testutils.NoError(t, exp.ExportMetrics(context.Background(), []Metric{
+		{Name: "jobs_total", Kind: MetricCounter, Value: 5},
+		{Name: "temperature", Kind: MetricGauge, Value: 21},
+		{Name: "latency", Kind: MetricHistogram, Count: 4, Sum: 1.5, Buckets: []HistogramBucket{
+			{UpperBound: 0.1, Count: 1},
+			{UpperBound: 1, Count: 3},
+		}},
+	}))
+
+	testutils.Equal(t, 1, len(got.ResourceMetrics))
+	rm := got.ResourceMetrics[0]
+	testutils.Equal(t, "service.name", rm.Resource.Attributes[0].Key)
+	metrics := rm.ScopeMetrics[0].Metrics
+	testutils.Equal(t, 3, len(metrics))
+	testutils.NotNil(t, metrics[0].Sum)
+	testutils.True(t, metrics[0].Sum.IsMonotonic)
+	testutils.Equal(t, 5.0, metrics[0].Sum.DataPoints[0].AsDouble)
+	testutils.NotNil(t, metrics[1].Gauge)
+	hist := metrics[2].Histogram.DataPoints[0]
+	testutils.Equal(t, "4", hist.Count)
+	testutils.Equal(t, "1,2,1", strings.Join(hist.BucketCounts, ","), "bucket counts should not be cumulative")
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	testutils.ErrorIs(t, exp.ExportMetrics(context.Background(), nil), ErrMetricsExport)
+}
+
+type testMetricsExporter struct {
+	exported chan []Metric
+}
+
+func (e *testMetricsExporter) ExportMetrics(ctx context.Context, metrics []Metric) error {
+	e.exported <- metrics
+	return nil
+}
+
+func TestMetricsPusher(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	testutils.True(t, newMetricsPusher(sess, nil) == nil, "pusher without exporters should be disabled")
+
+	sess.monitor = newMonitor()
+	sess.Monitor().Counter("jobs_total").Inc()
+	exp := &testMetricsExporter{exported: make(chan []Metric, 1)}
+	pusher := &metricsPusher{
+		interval:  time.Hour,
+		exporters: []namedMetricsExporter{{name: "test", exporter: exp}},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pusher.run(ctx, sess)
+
+	metrics := <-exp.exported
+	names := make(map[string]MetricKind)
+	for _, m := range metrics {
+		names[m.Name] = m.Kind
+	}
+	testutils.Equal(t, MetricCounter, names["happy_events_dispatched_total"])
+	testutils.Equal(t, MetricGauge, names["go_goroutines"])
+	testutils.Equal(t, MetricCounter, names["jobs_total"], "monitor metrics should be exported")
+}
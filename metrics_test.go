@@ -0,0 +1,68 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	sess.engine = newEngine()
+	testutils.NoError(t, sess.engine.addEventBus("metrics", 2, 10, "telemetry"))
+
+	svc := NewService("reports")
+	svc.Cron(func(schedule CronScheduler) {
+		schedule.Job("@every 1h", func(sess *Session) error {
+			return errors.New("failed")
+		})
+	})
+	addr, err := address.Parse("happy://host/app/service/reports")
+	testutils.NoError(t, err)
+	svcc := svc.container(sess, addr)
+	testutils.NoError(t, svcc.initialize(sess))
+	sess.engine.registry[addr.String()] = svcc
+	svcc.cron.lib.Entries()[0].Job.Run()
+
+	sess.Dispatch(NewEvent("app", "test", nil, nil))
+
+	rec := httptest.NewRecorder()
+	metricsHandler(sess).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	testutils.Equal(t, http.StatusOK, rec.Code)
+	testutils.True(t, strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4"))
+
+	body := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE happy_events_dispatched_total counter",
+		"happy_events_dispatched_total 1",
+		"happy_events_queue_depth 1",
+		`happy_bus_workers{bus="main"} 0`,
+		`happy_bus_workers{bus="metrics"} 2`,
+		`happy_cron_jobs{service="happy://host/app/service/reports"} 1`,
+		`happy_cron_runs_total{service="happy://host/app/service/reports"} 1`,
+		`happy_cron_failures_total{service="happy://host/app/service/reports"} 1`,
+		"# TYPE go_goroutines gauge",
+	} {
+		testutils.True(t, strings.Contains(body, line+"\n"), "missing: "+line)
+	}
+}
+
+func TestMetricsWriterLabels(t *testing.T) {
+	var b strings.Builder
+	mw := &metricsWriter{w: bufio.NewWriter(&b)}
+	mw.sample("happy_test", 1.5, "name", "a\"b\\c\nd", "kind", "x")
+	testutils.NoError(t, mw.w.Flush())
+	testutils.Equal(t, `happy_test{name="a\"b\\c\nd",kind="x"} 1.5`+"\n", b.String())
+}
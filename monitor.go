@@ -0,0 +1,134 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Monitor collects statistics of the running application,
+// see Session.Monitor. Methods of nil monitor are no-op.
+type Monitor struct {
+	mu      sync.RWMutex
+	started time.Time
+	scopes  map[string]*scopeCounters
+	// counters, gauges and histograms recorded by the application
+	metrics map[string]any
+	// alert rules by name
+	alerts map[string]*alertState
+}
+
+// scopeCounters are counters of events of single scope.
+type scopeCounters struct {
+	events     uint64
+	handled    uint64
+	failed     uint64
+	latency    time.Duration
+	maxLatency time.Duration
+}
+
+// EventScopeStats is event throughput of single event scope.
+type EventScopeStats struct {
+	Scope string `json:"scope"`
+	// Events is number of events delivered and Rate
+	// events per second since application started.
+	Events uint64  `json:"events"`
+	Rate   float64 `json:"rate"`
+	// Handled is number of listener calls and Failed number
+	// of calls which returned error or timed out.
+	Handled uint64 `json:"handled"`
+	Failed  uint64 `json:"failed"`
+	// AvgLatency and MaxLatency are durations of listener calls.
+	AvgLatency time.Duration `json:"avg_latency"`
+	MaxLatency time.Duration `json:"max_latency"`
+}
+
+func newMonitor() *Monitor {
+	return &Monitor{
+		started: time.Now(),
+		scopes:  make(map[string]*scopeCounters),
+		metrics: make(map[string]any),
+		alerts:  make(map[string]*alertState),
+	}
+}
+
+func (m *Monitor) scope(name string) *scopeCounters {
+	sc, ok := m.scopes[name]
+	if !ok {
+		sc = &scopeCounters{}
+		m.scopes[name] = sc
+	}
+	return sc
+}
+
+// eventDelivered counts event delivered to listeners.
+func (m *Monitor) eventDelivered(scope string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.scope(scope).events++
+}
+
+// eventHandled records duration and result of listener call.
+func (m *Monitor) eventHandled(scope string, took time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	sc := m.scope(scope)
+	sc.handled++
+	if err != nil {
+		sc.failed++
+	}
+	sc.latency += took
+	if took > sc.maxLatency {
+		sc.maxLatency = took
+	}
+}
+
+// EventScopes returns event throughput per event scope sorted by number
+// of events, busiest scope first, to identify chatty or slow subsystems.
+func (m *Monitor) EventScopes() []EventScopeStats {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	elapsed := time.Since(m.started).Seconds()
+	stats := make([]EventScopeStats, 0, len(m.scopes))
+	for name, sc := range m.scopes {
+		s := EventScopeStats{
+			Scope:      name,
+			Events:     sc.events,
+			Handled:    sc.handled,
+			Failed:     sc.failed,
+			MaxLatency: sc.maxLatency,
+		}
+		if elapsed > 0 {
+			s.Rate = float64(sc.events) / elapsed
+		}
+		if sc.handled > 0 {
+			s.AvgLatency = sc.latency / time.Duration(sc.handled)
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Events != stats[j].Events {
+			return stats[i].Events > stats[j].Events
+		}
+		return stats[i].Scope < stats[j].Scope
+	})
+	return stats
+}
+
+// Monitor returns monitor collecting statistics of the application.
+func (s *Session) Monitor() *Monitor {
+	return s.monitor
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestMonitorEventScopes(t *testing.T) {
+	var disabled *Monitor
+	disabled.eventDelivered("app")
+	testutils.Equal(t, 0, len(disabled.EventScopes()))
+
+	m := newMonitor()
+	m.started = time.Now().Add(-10 * time.Second)
+	for i := 0; i < 20; i++ {
+		m.eventDelivered("metrics")
+	}
+	m.eventDelivered("app")
+	m.eventHandled("app", 10*time.Millisecond, nil)
+	m.eventHandled("app", 30*time.Millisecond, errors.New("failed"))
+
+	scopes := m.EventScopes()
+	testutils.Equal(t, 2, len(scopes))
+	testutils.Equal(t, "metrics", scopes[0].Scope, "busiest scope should be first")
+	testutils.Equal(t, uint64(20), scopes[0].Events)
+	testutils.True(t, scopes[0].Rate > 1.9 && scopes[0].Rate <= 2, "rate")
+
+	app := scopes[1]
+	testutils.Equal(t, uint64(1), app.Events)
+	testutils.Equal(t, uint64(2), app.Handled)
+	testutils.Equal(t, uint64(1), app.Failed)
+	testutils.Equal(t, 20*time.Millisecond, app.AvgLatency)
+	testutils.Equal(t, 30*time.Millisecond, app.MaxLatency)
+
+	var b strings.Builder
+	testutils.NoError(t, writeStatsTable(&b, statsInfo{
+		Runtime: []RuntimeStats{{Goroutines: 3, OpenFiles: -1}},
+		Events:  scopes,
+	}))
+	out := b.String()
+	testutils.True(t, strings.Contains(strings.Join(strings.Fields(out), " "), "goroutines 3"), out)
+	testutils.False(t, strings.Contains(out, "open files"), out)
+	testutils.True(t, strings.Contains(out, "metrics"), out)
+}
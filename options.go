@@ -7,10 +7,13 @@ package happy
 import (
 	"errors"
 	"fmt"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/hlog"
 	"github.com/mkungla/happy/pkg/vars"
 	"github.com/mkungla/happy/pkg/version"
 	"golang.org/x/mod/semver"
@@ -57,6 +60,7 @@ var (
 	ErrOption           = errors.New("option error")
 	ErrOptionReadOnly   = fmt.Errorf("%w: readonly option", ErrOption)
 	ErrOptionValidation = fmt.Errorf("%w: validation failed", ErrOption)
+	ErrOptionSandbox    = fmt.Errorf("%w: outside of addon namespace", ErrOption)
 )
 
 // Opt creates option for given key value pair
@@ -290,21 +294,482 @@ func getDefaultApplicationConfig() ([]OptionArg, error) {
 		{
 			key:   "app.throttle.ticks",
 			value: time.Duration(time.Millisecond * 100),
-			desc:  "Interfal target for system and service ticks",
-			kind:  ReadOnlyOption | SettingsOption,
+			desc:  "Interfal target for system and service ticks, 0 disables ticks. Can be changed at runtime",
+			kind:  SettingsOption,
 			validator: func(key string, val vars.Value) error {
 				v, err := val.Int64()
 				if err != nil {
 					return err
 				}
-				if v < 1 {
+				if v < 0 {
 					return fmt.Errorf(
-						"%w: invalid throttle value %s(%d - %v), must be greater that 1",
+						"%w: invalid throttle value %s(%d - %v), must be 0 or greater",
 						ErrOptionValidation, val.Kind(), v, val.Any())
 				}
 				return nil
 			},
 		},
+		{
+			key:   "app.events.workers",
+			value: 0,
+			desc:  "Number of workers delivering events to listeners, 0 delivers each event in its own goroutine",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.events.queue.size",
+			value: 100,
+			desc:  "Size of the event worker pool queue, dispatch blocks when queue is full",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:       "app.events.handler.timeout",
+			value:     time.Duration(0),
+			desc:      "Max duration to wait for single event handler, 0 waits forever",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "app.events.retries",
+			value: 2,
+			desc:  "Number of times failed event listener is retried before event is moved to dead letters",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.events.retries.backoff",
+			value: time.Duration(50 * time.Millisecond),
+			desc:  "Duration to wait before retrying failed event listener, wait grows with each attempt, 0 retries immediately",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.events.request.timeout",
+			value: time.Duration(time.Second * 30),
+			desc:  "Max duration Session.DispatchRequest waits for reply when context has no deadline",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 1 {
+					return fmt.Errorf("%w: %s must be greater than 0", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.events.overflow",
+			value: "block",
+			desc:  "What to do with event dispatched while event queue is full: block, drop or coalesce",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if _, err := parseOverflowPolicy(val.String()); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.events.overflow.scopes",
+			value: "",
+			desc:  "Per scope overflow policies e.g. metrics=drop,ui=coalesce",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if _, err := parseOverflowPolicies("block", val.String()); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.stats.interval",
+			value: time.Duration(0),
+			desc:  "Interval of sampling memory, goroutine, GC and open file stats, see Session.RuntimeStats, 0 disables sampling",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.stats.history",
+			value: 120,
+			desc:  "Number of runtime stats samples kept in history",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 1 {
+					return fmt.Errorf("%w: %s must be at least 1", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.exit.timeout",
+			value: time.Duration(time.Second * 10),
+			desc:  "Time to wait all exit funcs added with AddExitFunc to complete, 0 waits without limit",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.settings.debounce",
+			value: time.Duration(time.Millisecond * 500),
+			desc:  "Time to wait for further changes of user.* settings before saving them, 0 saves on every change",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.engine.shutdown.timeout",
+			value: time.Duration(time.Second * 10),
+			desc:  "Time to wait services of each shutdown phase to stop, 0 waits without limit",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.engine.watchdog",
+			value: time.Duration(0),
+			desc:  "Report tick, tock and event handlers running longer than this duration, 0 disables watchdog",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.deadline",
+			value: time.Duration(0),
+			desc:  "Max duration of entire application run, services are drained and application exits with ExitCodeDeadline when exceeded, 0 disables the limit",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:       "app.engine.deterministic",
+			value:     false,
+			desc:      "Deterministic engine mode for tests, ticks are advanced manually with Engine.Advance and events are delivered synchronously",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.daemon",
+			value:     false,
+			desc:      "Add --daemon flag running application in background and stop and status commands managing it",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.daemon.pidfile",
+			value:     "",
+			desc:      "PID file of the daemon, defaults to daemon.pid in application cache dir or <slug>.pid in temp dir",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "app.daemon.umask",
+			value: "0022",
+			desc:  "File mode creation mask of the daemon in octal",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if _, err := strconv.ParseUint(val.String(), 8, 32); err != nil {
+					return fmt.Errorf("%w: %s must be octal file mode got %s", ErrOptionValidation, key, val.String())
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.instance.name",
+			value: "",
+			desc:  "Name of isolated application instance e.g. 2, instance has separate config, cache and temp dirs and host address, see --instance flag",
+			kind:  ReadOnlyOption,
+			validator: func(key string, val vars.Value) error {
+				if name := val.String(); name != "" && !instanceNameRe.MatchString(name) {
+					return fmt.Errorf("%w: %s must contain only lowercase letters, digits and dashes got %q", ErrOptionValidation, key, name)
+				}
+				return nil
+			},
+		},
+		{
+			key:       "app.instance.single",
+			value:     false,
+			desc:      "Allow only single running instance of the application, instance is locked with lock file keyed by app.host.addr",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.instance.forward",
+			value:     false,
+			desc:      "Forward arguments of second instance to running instance as app.instance.args event instead of failing, requires app.instance.single",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.windows.service",
+			value:     false,
+			desc:      "Add service command installing, uninstalling and running application as Windows service, ignored on other platforms",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.crash.report",
+			value:     true,
+			desc:      "Write crash report with stack, session snapshot and recent events and logs when application panics",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.crash.dir",
+			value:     "",
+			desc:      "Directory of crash reports, defaults to crash dir in application cache dir or temp dir",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.crash.upload",
+			value:     "",
+			desc:      "URL crash reports are uploaded to with HTTP POST request e.g. https://crash.example.com/reports",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.assets.override",
+			value:     "",
+			desc:      "Comma separated directories overriding application assets, later directories have higher priority",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.lang",
+			value:     "",
+			desc:      "Language of translated messages e.g. et or et-EE, empty uses LC_ALL, LC_MESSAGES or LANG",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.banner",
+			value:     true,
+			desc:      "Print banner set with Banner before command output",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.shell",
+			value:     false,
+			desc:      "Add shell command providing interactive shell which executes commands within running session",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.diagnostics.addr",
+			value:     "",
+			desc:      "Local address e.g. 127.0.0.1:6060 of diagnostics service serving pprof, health and engine stats, empty host binds loopback, empty disables the service",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.diagnostics.public",
+			value:     false,
+			desc:      "Allow diagnostics service to listen on other than loopback address",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.metrics.addr",
+			value:     "",
+			desc:      "Address e.g. :9090 of metrics service serving engine, service, event bus, cron and runtime metrics in Prometheus text format on /metrics, empty disables the service",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.health.addr",
+			value:     "",
+			desc:      "Address e.g. :8086 of health service serving /healthz and /readyz for load balancers and orchestrators, empty disables the service",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.health.optional",
+			value:     "",
+			desc:      "Comma separated names or addresses of services which failing only degrades health and readiness",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "app.monitor.alerts.interval",
+			value: time.Duration(time.Second * 5),
+			desc:  "Interval of evaluating alert rules added with Monitor.AddAlertRule, 0 disables alerts",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.metrics.export.interval",
+			value: time.Duration(time.Second * 10),
+			desc:  "Interval of pushing metrics to StatsD, OTLP collector and exporters added with Application.AddMetricsExporter",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v <= 0 {
+					return fmt.Errorf("%w: %s must be greater than 0", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "app.metrics.statsd.addr",
+			value: "",
+			desc:  "Address e.g. 127.0.0.1:8125 of StatsD server metrics are pushed to over UDP, empty disables StatsD export",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if val.String() == "" {
+					return nil
+				}
+				if _, _, err := net.SplitHostPort(val.String()); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:       "app.metrics.statsd.prefix",
+			value:     "",
+			desc:      "Prefix of metric names pushed to StatsD e.g. myapp.",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "app.metrics.otlp.endpoint",
+			value: "",
+			desc:  "Push metrics to OpenTelemetry collector using OTLP/HTTP e.g. http://localhost:4318, empty disables OTLP export",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				endpoint := val.String()
+				if endpoint == "" || strings.HasPrefix(endpoint, "http://") || strings.HasPrefix(endpoint, "https://") {
+					return nil
+				}
+				return fmt.Errorf("%w: %s must be http or https url got %q", ErrOptionValidation, key, endpoint)
+			},
+		},
+		{
+			key:       "app.metrics.otlp.headers",
+			value:     "",
+			desc:      "Comma separated key=value headers sent to OTLP metrics collector",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.addons.disabled",
+			value:     "",
+			desc:      "Comma separated names of addons which are disabled, their commands are hidden and services are not started",
+			kind:      SettingsOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "app.events.journal",
+			value:     "",
+			desc:      "Path to append-only event journal file used by Session.Replay, relative path is resolved against app.path.cache",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
 		{
 			key:   "app.version",
 			value: version.Current(),
@@ -332,9 +797,9 @@ func getDefaultApplicationConfig() ([]OptionArg, error) {
 		{
 			key:       "log.level",
 			value:     LogLevelTask,
-			desc:      "Log level for applicaton",
-			kind:      ReadOnlyOption | SettingsOption,
-			validator: noopvalidator,
+			desc:      "Log level for applicaton, level name or number which can be changed at runtime",
+			kind:      SettingsOption,
+			validator: logLevelValidator,
 		},
 		{
 			key:       "log.source",
@@ -364,6 +829,269 @@ func getDefaultApplicationConfig() ([]OptionArg, error) {
 			kind:      ReadOnlyOption | ConfigOption,
 			validator: noopvalidator,
 		},
+		{
+			key:   "log.redact",
+			value: "",
+			desc:  "comma separated list of attr key patterns e.g. db.*,*_key to redact in addition to password, token, secret etc.",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if _, err := hlog.NewRedactor(strings.Split(val.String(), ",")...); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.scopes",
+			value: "",
+			desc:  "log level overrides for logger scopes e.g. service=cache:debug,addon=db:warn, can be changed at runtime",
+			kind:  ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if _, err := hlog.ParseScopeLevels(val.String()); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:       "log.console",
+			value:     true,
+			desc:      "write logs to stdout",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.format",
+			value:     "text",
+			desc:      "format of stdout logs: text, json or console",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logFormatValidator,
+		},
+		{
+			key:       "log.file",
+			value:     "",
+			desc:      "write logs also to this file, file is reopened on SIGHUP",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.file.level",
+			value:     "",
+			desc:      "minimum level of file logs e.g. debug, empty uses log.level",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logLevelValidator,
+		},
+		{
+			key:       "log.file.format",
+			value:     "text",
+			desc:      "format of file logs: text or json",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logFormatValidator,
+		},
+		{
+			key:   "log.remote",
+			value: "",
+			desc:  "send logs to remote collector e.g. tcp://127.0.0.1:5170 or udp://127.0.0.1:514",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				if val.String() == "" {
+					return nil
+				}
+				if _, err := hlog.NewNetWriter(val.String()); err != nil {
+					return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+				}
+				return nil
+			},
+		},
+		{
+			key:       "log.otlp.endpoint",
+			value:     "",
+			desc:      "export logs to OpenTelemetry collector using OTLP/HTTP e.g. http://localhost:4318",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.otlp.level",
+			value:     "",
+			desc:      "minimum level of exported logs e.g. info, empty uses log.level",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logLevelValidator,
+		},
+		{
+			key:       "log.otlp.headers",
+			value:     "",
+			desc:      "comma separated key=value headers sent to OTLP collector",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.remote.level",
+			value:     "",
+			desc:      "minimum level of remote logs e.g. warn, empty uses log.level",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logLevelValidator,
+		},
+		{
+			key:       "log.remote.format",
+			value:     "json",
+			desc:      "format of remote logs: text or json",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logFormatValidator,
+		},
+		{
+			key:   "log.max.size",
+			value: 0,
+			desc:  "rotate log file when it reaches this size in megabytes, 0 disables size based rotation",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.max.age",
+			value: time.Duration(0),
+			desc:  "rotate log file when it is older than this duration, 0 disables age based rotation",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.max.backups",
+			value: 0,
+			desc:  "number of rotated log files to keep, 0 keeps all",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.dedup",
+			value: time.Duration(0),
+			desc:  "suppress identical log messages repeated within this duration and report count of suppressed ones, 0 disables",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.dedup.burst",
+			value: 1,
+			desc:  "number of identical log messages passed within log.dedup duration",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:   "log.sample.debug",
+			value: 0,
+			desc:  "log only every Nth debug message, 0 logs all",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:       "log.async",
+			value:     false,
+			desc:      "write logs in background, logs are flushed when session is destroyed",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "log.async.queue",
+			value: 1024,
+			desc:  "number of buffered log records when log.async is enabled, records are dropped when buffer is full",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int()
+				if err != nil {
+					return err
+				}
+				if v < 1 {
+					return fmt.Errorf("%w: %s must be positive", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
+		{
+			key:       "log.metrics",
+			value:     "service,addon",
+			desc:      "comma separated attr keys used as labels of logged records counters, empty disables counting",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.error.causes",
+			value:     false,
+			desc:      "log unwrapped error chain of logged errors",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.error.stack",
+			value:     "",
+			desc:      "attach stack trace to logged errors when log level is this level or lower e.g. debug, empty disables",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: logLevelValidator,
+		},
+		{
+			key:       "log.lang",
+			value:     "",
+			desc:      "language of logged messages translated with catalog, empty uses LC_ALL, LC_MESSAGES or LANG",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:       "log.compress",
+			value:     false,
+			desc:      "compress rotated log files with gzip",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
 		{
 			key:   "app.host.addr",
 			value: addr.String(),
@@ -383,6 +1111,24 @@ func getDefaultApplicationConfig() ([]OptionArg, error) {
 	return configOpts, nil
 }
 
+func logFormatValidator(key string, val vars.Value) error {
+	switch val.String() {
+	case "text", "json", "console":
+		return nil
+	}
+	return fmt.Errorf("%w: %s must be text, json or console got %q", ErrOptionValidation, key, val.String())
+}
+
+func logLevelValidator(key string, val vars.Value) error {
+	if val.String() == "" {
+		return nil
+	}
+	if _, err := hlog.ParseLevel(val.String()); err != nil {
+		return fmt.Errorf("%w: %s %s", ErrOptionValidation, key, err.Error())
+	}
+	return nil
+}
+
 func getDefaultCommandOpts() []OptionArg {
 	opts := []OptionArg{
 		{
@@ -420,6 +1166,39 @@ func getDefaultCommandOpts() []OptionArg {
 			kind:      ReadOnlyOption | ConfigOption,
 			validator: noopvalidator,
 		},
+		{
+			key:       "hidden",
+			value:     false,
+			desc:      "Hidden command is executable but excluded from help, completion and docs",
+			kind:      ReadOnlyOption | ConfigOption,
+			validator: noopvalidator,
+		},
+		{
+			key:   "args",
+			value: "",
+			desc:  "Number of arguments command accepts: N exactly, N..M range, N.. at least N or ..M at most M, empty accepts any",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				_, err := parseArgsRange(val.String())
+				return err
+			},
+		},
+		{
+			key:   "timeout",
+			value: time.Duration(0),
+			desc:  "Default time limit for Do action of command, can be overridden with --timeout flag, 0 disables the limit",
+			kind:  ReadOnlyOption | ConfigOption,
+			validator: func(key string, val vars.Value) error {
+				v, err := val.Int64()
+				if err != nil {
+					return err
+				}
+				if v < 0 {
+					return fmt.Errorf("%w: %s can not be negative", ErrOptionValidation, key)
+				}
+				return nil
+			},
+		},
 	}
 	return opts
 }
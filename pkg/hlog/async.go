@@ -0,0 +1,175 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+const asyncDefaultQueueSize = 1024
+
+// AsyncConfig configures AsyncHandler.
+type AsyncConfig struct {
+	// QueueSize is number of records buffered before
+	// records are dropped or Handle blocks, defaults to 1024.
+	QueueSize int
+	// Block makes Handle wait for free space in full
+	// queue instead of dropping the record.
+	Block bool
+}
+
+// AsyncHandler passes records to wrapped handler in background
+// goroutine so that log I/O does not block callers. Flush waits
+// until queued records are written, Close flushes and stops the
+// writer after which records are written synchronously.
+type AsyncHandler struct {
+	w *asyncWriter
+	h slog.Handler
+}
+
+// NewAsyncHandler returns handler writing records to h asynchronously.
+func NewAsyncHandler(h slog.Handler, cnf AsyncConfig) *AsyncHandler {
+	if cnf.QueueSize <= 0 {
+		cnf.QueueSize = asyncDefaultQueueSize
+	}
+	w := &asyncWriter{
+		cnf:     cnf,
+		queue:   make(chan asyncRecord, cnf.QueueSize),
+		flush:   make(chan chan struct{}),
+		done:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	go w.run()
+	return &AsyncHandler{w: w, h: h}
+}
+
+// Enabled reports whether the wrapped handler handles records at the given level.
+func (h *AsyncHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+// Handle queues record, record is dropped when queue
+// is full unless AsyncConfig.Block is set.
+func (h *AsyncHandler) Handle(r slog.Record) error {
+	return h.w.enqueue(asyncRecord{h: h.h, r: r.Clone()})
+}
+
+func (h *AsyncHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &AsyncHandler{w: h.w, h: h.h.WithAttrs(attrs)}
+}
+
+func (h *AsyncHandler) WithGroup(name string) slog.Handler {
+	return &AsyncHandler{w: h.w, h: h.h.WithGroup(name)}
+}
+
+// Flush waits until queued records are written.
+func (h *AsyncHandler) Flush() {
+	h.w.sync()
+}
+
+// Close flushes queued records and stops background writer.
+func (h *AsyncHandler) Close() error {
+	h.w.close()
+	return nil
+}
+
+// Dropped returns number of records dropped because queue was full.
+func (h *AsyncHandler) Dropped() int64 {
+	h.w.mu.RLock()
+	defer h.w.mu.RUnlock()
+	return h.w.dropped
+}
+
+type asyncRecord struct {
+	h slog.Handler
+	r slog.Record
+}
+
+type asyncWriter struct {
+	cnf     AsyncConfig
+	queue   chan asyncRecord
+	flush   chan chan struct{}
+	done    chan struct{}
+	stopped chan struct{}
+
+	// mu is held for reading while enqueuing so that
+	// close does not race with senders.
+	mu      sync.RWMutex
+	closed  bool
+	dropped int64
+}
+
+func (w *asyncWriter) enqueue(rec asyncRecord) error {
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		return rec.h.Handle(rec.r)
+	}
+	if w.cnf.Block {
+		w.queue <- rec
+		w.mu.RUnlock()
+		return nil
+	}
+	select {
+	case w.queue <- rec:
+		w.mu.RUnlock()
+	default:
+		w.mu.RUnlock()
+		w.mu.Lock()
+		w.dropped++
+		w.mu.Unlock()
+	}
+	return nil
+}
+
+func (w *asyncWriter) sync() {
+	ack := make(chan struct{})
+	select {
+	case w.flush <- ack:
+		<-ack
+	case <-w.stopped:
+	}
+}
+
+func (w *asyncWriter) close() {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		<-w.stopped
+		return
+	}
+	w.closed = true
+	w.mu.Unlock()
+	close(w.done)
+	<-w.stopped
+}
+
+func (w *asyncWriter) run() {
+	defer close(w.stopped)
+	drain := func() {
+		for {
+			select {
+			case rec := <-w.queue:
+				_ = rec.h.Handle(rec.r)
+			default:
+				return
+			}
+		}
+	}
+	for {
+		select {
+		case rec := <-w.queue:
+			_ = rec.h.Handle(rec.r)
+		case ack := <-w.flush:
+			drain()
+			close(ack)
+		case <-w.done:
+			drain()
+			return
+		}
+	}
+}
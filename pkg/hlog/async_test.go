@@ -0,0 +1,89 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+// syncBuffer is writer safe for concurrent use.
+type syncBuffer struct {
+	mu sync.Mutex
+	b  strings.Builder
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestAsyncHandler(t *testing.T) {
+	var buf syncBuffer
+	h := NewAsyncHandler(Config{}.NewHandler(&buf), AsyncConfig{QueueSize: 16, Block: true})
+	logger := New(h).With("service", "cache")
+	for i := 0; i < 100; i++ {
+		logger.Info("tick", slog.Int("n", i))
+	}
+	h.Flush()
+	out := buf.String()
+	if got := strings.Count(out, "tick"); got != 100 {
+		t.Errorf("expected 100 records after flush, got %d", got)
+	}
+	if !strings.Contains(out, "service=cache n=99") {
+		t.Errorf("missing last record in %q", out)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("after close")
+	if !strings.Contains(buf.String(), "after close") {
+		t.Error("records should be written synchronously after close")
+	}
+	if h.Dropped() != 0 {
+		t.Errorf("blocking handler should not drop records, dropped %d", h.Dropped())
+	}
+}
+
+func TestAsyncHandlerDrop(t *testing.T) {
+	block := make(chan struct{})
+	var buf syncBuffer
+	inner := Config{}.NewHandler(&blockingWriter{w: &buf, block: block})
+	h := NewAsyncHandler(inner, AsyncConfig{QueueSize: 1})
+	logger := New(h)
+	for i := 0; i < 10; i++ {
+		logger.Info("storm")
+	}
+	close(block)
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Dropped() == 0 {
+		t.Error("expected dropped records when queue is full")
+	}
+	if got := int64(strings.Count(buf.String(), "storm")) + h.Dropped(); got != 10 {
+		t.Errorf("written and dropped records should add up to 10 got %d", got)
+	}
+}
+
+type blockingWriter struct {
+	w     *syncBuffer
+	block chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	<-b.block
+	return b.w.Write(p)
+}
@@ -0,0 +1,239 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Theme is set of ANSI SGR styles e.g. "1;31" for bold red used by
+// ConsoleHandler. Empty style renders text as is.
+type Theme struct {
+	// Levels maps level to style of its label, levels
+	// without style use style of closest lower level.
+	Levels  map[Level]string
+	Time    string
+	Message string
+	Key     string
+	Value   string
+	Source  string
+}
+
+// DefaultTheme returns theme used by ConsoleHandler when none is set.
+func DefaultTheme() Theme {
+	return Theme{
+		Levels: map[Level]string{
+			LevelTrace:       "2;34",
+			LevelSystemDebug: "34",
+			LevelDebug:       "37",
+			LevelInfo:        "36",
+			LevelTask:        "34",
+			LevelOk:          "32",
+			LevelNotice:      "1;36",
+			LevelWarn:        "33",
+			LevelIssue:       "1;33",
+			LevelError:       "1;31",
+			LevelOut:         "",
+		},
+		Time:   "2",
+		Key:    "90",
+		Value:  "3",
+		Source: "2",
+	}
+}
+
+// levelStyle returns style of l or closest lower level.
+func (t Theme) levelStyle(l Level) string {
+	if st, ok := t.Levels[l]; ok {
+		return st
+	}
+	var (
+		style string
+		found bool
+		best  Level
+	)
+	for lvl, st := range t.Levels {
+		if lvl <= l && (!found || lvl > best) {
+			best, style, found = lvl, st, true
+		}
+	}
+	return style
+}
+
+// ConsoleConfig configures ConsoleHandler.
+type ConsoleConfig struct {
+	// Level is minimum level of records, defaults to info.
+	Level slog.Leveler
+	// Source level enables source locations when it is debug
+	// or lower, defaults to Level.
+	Source slog.Leveler
+	// Colors enables colored output, see ColorEnabled.
+	Colors bool
+	// Theme used when Colors is enabled, defaults to DefaultTheme.
+	Theme *Theme
+	// TimeFormat defaults to 15:04:05.000, "-" omits time.
+	TimeFormat string
+}
+
+// ConsoleHandler is human friendly handler for terminals writing
+// records as level label, time, message and compact key=value
+// attributes. Source location is added in debug mode when source
+// level is debug or lower and source of record is known.
+type ConsoleHandler struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	cnf    ConsoleConfig
+	theme  Theme
+	prefix string
+	attrs  []byte
+}
+
+// NewConsoleHandler returns ConsoleHandler writing to w.
+func NewConsoleHandler(w io.Writer, cnf ConsoleConfig) *ConsoleHandler {
+	if cnf.Level == nil {
+		cnf.Level = slog.LevelInfo
+	}
+	if cnf.Source == nil {
+		cnf.Source = cnf.Level
+	}
+	if cnf.TimeFormat == "" {
+		cnf.TimeFormat = "15:04:05.000"
+	}
+	theme := DefaultTheme()
+	if cnf.Theme != nil {
+		theme = *cnf.Theme
+	}
+	return &ConsoleHandler{mu: &sync.Mutex{}, w: w, cnf: cnf, theme: theme}
+}
+
+// ColorEnabled reports whether colored output should be written to w,
+// it is true when w is terminal, NO_COLOR is not set and TERM is not dumb.
+func ColorEnabled(w io.Writer) bool {
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if strings.EqualFold(os.Getenv("TERM"), "dumb") {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+func (h *ConsoleHandler) Enabled(level slog.Level) bool {
+	return level >= h.cnf.Level.Level()
+}
+
+func (h *ConsoleHandler) Handle(r slog.Record) error {
+	if !h.Enabled(r.Level) {
+		return nil
+	}
+	lvl := Level(r.Level)
+	var buf []byte
+	if lvl != LevelOut {
+		buf = h.style(buf, h.theme.levelStyle(lvl), padLabel(lvl.String()))
+		buf = append(buf, ' ')
+		if h.cnf.TimeFormat != "-" && !r.Time.IsZero() {
+			buf = h.style(buf, h.theme.Time, r.Time.Format(h.cnf.TimeFormat))
+			buf = append(buf, ' ')
+		}
+	}
+	buf = h.style(buf, h.theme.Message, r.Message)
+	if len(h.attrs) > 0 {
+		buf = append(buf, h.attrs...)
+	}
+	r.Attrs(func(a slog.Attr) {
+		buf = h.appendAttr(buf, h.prefix, a)
+	})
+	if h.cnf.Source.Level() <= slog.LevelDebug {
+		if file, line := r.SourceLine(); file != "" {
+			buf = append(buf, ' ')
+			buf = h.style(buf, h.theme.Source, filepath.Base(file)+":"+strconv.Itoa(line))
+		}
+	}
+	buf = append(buf, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i := currentInterruptor(); i != nil {
+		i.Interrupt()
+		defer i.Resume()
+	}
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *ConsoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]byte(nil), h.attrs...)
+	for _, a := range attrs {
+		h2.attrs = h.appendAttr(h2.attrs, h.prefix, a)
+	}
+	return &h2
+}
+
+func (h *ConsoleHandler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func (h *ConsoleHandler) appendAttr(buf []byte, prefix string, a slog.Attr) []byte {
+	if a.Key == "" {
+		return buf
+	}
+	v := a.Value.Resolve()
+	if v.Kind() == slog.GroupKind {
+		for _, ga := range v.Group() {
+			buf = h.appendAttr(buf, prefix+a.Key+".", ga)
+		}
+		return buf
+	}
+	buf = append(buf, ' ')
+	buf = h.style(buf, h.theme.Key, prefix+a.Key+"=")
+	var s string
+	switch v.Kind() {
+	case slog.TimeKind:
+		s = v.Time().Format(time.RFC3339)
+	default:
+		s = v.String()
+	}
+	if needsQuoting(s) || s == "" {
+		s = strconv.Quote(s)
+	}
+	return h.style(buf, h.theme.Value, s)
+}
+
+func (h *ConsoleHandler) style(buf []byte, style, s string) []byte {
+	if !h.cnf.Colors || style == "" || s == "" {
+		return append(buf, s...)
+	}
+	buf = append(buf, "\033["...)
+	buf = append(buf, style...)
+	buf = append(buf, 'm')
+	buf = append(buf, s...)
+	return append(buf, "\033[0m"...)
+}
+
+func padLabel(label string) string {
+	if len(label) < 7 {
+		return label + strings.Repeat(" ", 7-len(label))
+	}
+	return label
+}
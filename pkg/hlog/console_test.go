@@ -0,0 +1,56 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestConsoleHandler(t *testing.T) {
+	var buf bytes.Buffer
+	h := NewConsoleHandler(&buf, ConsoleConfig{TimeFormat: "-"})
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "started", 0, nil)
+	r.AddAttrs(slog.String("addr", ":8080"), slog.String("name", "my app"))
+	if err := h.WithGroup("http").WithAttrs([]slog.Attr{slog.Int("workers", 2)}).Handle(r); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "info    started http.workers=2 http.addr=:8080 http.name=\"my app\"\n"; got != want {
+		t.Errorf("got %q want %q", got, want)
+	}
+
+	buf.Reset()
+	theme := Theme{Levels: map[Level]string{LevelInfo: "32", LevelError: "31"}}
+	h = NewConsoleHandler(&buf, ConsoleConfig{Colors: true, Theme: &theme, TimeFormat: "-"})
+	if err := h.Handle(slog.NewRecord(time.Time{}, slog.LevelWarn, "careful", 0, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); !strings.HasPrefix(got, "\033[32mwarn   \033[0m careful") {
+		t.Errorf("warn should use info style got %q", got)
+	}
+	if h.Enabled(slog.LevelDebug) {
+		t.Error("debug should not be enabled by default")
+	}
+}
+
+func TestConsoleHandlerSource(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(NewConsoleHandler(&buf, ConsoleConfig{Level: slog.LevelDebug}))
+	logger.Debug("with source")
+	if !strings.Contains(buf.String(), "console_test.go:") {
+		t.Errorf("missing source location in %q", buf.String())
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	var buf bytes.Buffer
+	if ColorEnabled(&buf) {
+		t.Error("colors should not be enabled for buffer")
+	}
+}
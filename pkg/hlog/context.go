@@ -4,7 +4,11 @@
 
 package hlog
 
-import "context"
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
 
 type contextKey struct{}
 
@@ -28,3 +32,61 @@ func FromContext(ctx context.Context) *Logger {
 func Ctx(ctx context.Context) *Logger {
 	return FromContext(ctx).WithContext(ctx)
 }
+
+type attrsContextKey struct{}
+
+// ContextWith returns context carrying attributes from args in addition
+// to attributes of ctx, args are converted to attributes as in Logger.Log.
+// Records logged with context e.g. Ctx(ctx).Info(...) include the
+// attributes when logger handler is wrapped with NewContextHandler,
+// which is useful for correlation ids of requests and events.
+func ContextWith(ctx context.Context, args ...any) context.Context {
+	parent := ContextAttrs(ctx)
+	attrs := make([]slog.Attr, len(parent), len(parent)+len(args))
+	copy(attrs, parent)
+	var attr slog.Attr
+	for len(args) > 0 {
+		attr, args = argsToAttr(args)
+		attrs = append(attrs, attr)
+	}
+	return context.WithValue(ctx, attrsContextKey{}, attrs)
+}
+
+// ContextAttrs returns attributes added to ctx with ContextWith.
+func ContextAttrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(attrsContextKey{}).([]slog.Attr)
+	return attrs
+}
+
+// NewContextHandler wraps h so that attributes added with ContextWith
+// to context of the record are added to the record.
+func NewContextHandler(h slog.Handler) slog.Handler {
+	return &contextHandler{h: h}
+}
+
+type contextHandler struct {
+	h slog.Handler
+}
+
+func (h *contextHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+func (h *contextHandler) Handle(r slog.Record) error {
+	if attrs := ContextAttrs(r.Context); len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.h.Handle(r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h: h.h.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h: h.h.WithGroup(name)}
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContextWith(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(NewContextHandler(Config{}.NewHandler(&buf)))
+	ctx := NewContext(context.Background(), logger)
+	ctx = ContextWith(ctx, "request_id", "r-1")
+	child := ContextWith(ctx, "user", "john")
+
+	Ctx(child).Info("handled", "status", 200)
+	Ctx(ctx).Info("parent")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines got %q", buf.String())
+	}
+	if !strings.HasSuffix(lines[0], "handled status=200 request_id=r-1 user=john") {
+		t.Errorf("unexpected child record %q", lines[0])
+	}
+	if !strings.HasSuffix(lines[1], "parent request_id=r-1") {
+		t.Errorf("parent context should not see child attrs %q", lines[1])
+	}
+	if len(ContextAttrs(context.Background())) != 0 {
+		t.Error("expected no attrs in empty context")
+	}
+}
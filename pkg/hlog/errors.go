@@ -0,0 +1,105 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// maxStackDepth is maximum number of frames in captured stack trace.
+const maxStackDepth = 32
+
+// ErrorConfig configures details attached by Logger.Error.
+type ErrorConfig struct {
+	// Causes adds unwrapped error chain as causes group with
+	// one attribute per wrapped error e.g. causes.1="*fs.PathError: ...".
+	Causes bool
+	// Stack attaches stack trace of Error call as stack attribute
+	// when logger level is StackLevel or lower, so e.g. StackLevel
+	// LevelDebug attaches stacks only when debug logs are enabled.
+	Stack      bool
+	StackLevel Level
+}
+
+// WithErrorConfig returns logger which logs errors passed
+// to Error with details configured by cnf in single record.
+func (l *Logger) WithErrorConfig(cnf ErrorConfig) *Logger {
+	l2 := *l
+	l2.errcnf = &cnf
+	return &l2
+}
+
+// errorAttrs returns attributes describing err, skip is
+// number of stack frames to skip when capturing stack.
+func (l *Logger) errorAttrs(err error, skip int) []slog.Attr {
+	attrs := []slog.Attr{slog.String("err", err.Error())}
+	if l.errcnf.Causes {
+		if causes := errorCauses(err); len(causes) > 0 {
+			attrs = append(attrs, slog.Group("causes", causes...))
+		}
+	}
+	if l.errcnf.Stack && l.Level() <= l.errcnf.StackLevel {
+		attrs = append(attrs, slog.String("stack", stackTrace(skip+1)))
+	}
+	return attrs
+}
+
+// errorCauses returns errors wrapped by err in order they are unwrapped,
+// errors joined with errors.Join are walked depth first.
+func errorCauses(err error) []slog.Attr {
+	var (
+		causes []slog.Attr
+		walk   func(err error)
+	)
+	walk = func(err error) {
+		var wrapped []error
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			if u := e.Unwrap(); u != nil {
+				wrapped = []error{u}
+			}
+		case interface{ Unwrap() []error }:
+			wrapped = e.Unwrap()
+		}
+		for _, w := range wrapped {
+			causes = append(causes, slog.String(
+				strconv.Itoa(len(causes)+1),
+				fmt.Sprintf("%T: %s", w, w.Error()),
+			))
+			walk(w)
+		}
+	}
+	walk(err)
+	return causes
+}
+
+// stackTrace returns stack of the caller formatted one
+// "function file:line" frame per line.
+func stackTrace(skip int) string {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+	var b strings.Builder
+	for {
+		frame, more := frames.Next()
+		if b.Len() > 0 {
+			b.WriteByte('\n')
+		}
+		b.WriteString(frame.Function)
+		b.WriteByte(' ')
+		b.WriteString(frame.File)
+		b.WriteByte(':')
+		b.WriteString(strconv.Itoa(frame.Line))
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestLoggerErrorConfig(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := &slog.LevelVar{}
+	logger := NewLeveled(Config{JSON: true}.NewHandler(&buf), lvl).
+		WithErrorConfig(ErrorConfig{Causes: true, Stack: true, StackLevel: LevelDebug})
+
+	err := fmt.Errorf("load config: %w", errors.Join(io.EOF, errors.New("bad")))
+	logger.Error("failed", err, "file", "app.yaml")
+
+	out := buf.String()
+	if strings.Count(out, "\n") != 1 {
+		t.Errorf("expected single record got %q", out)
+	}
+	for _, want := range []string{
+		`"file":"app.yaml"`,
+		`"causes":{"1":"*errors.joinError: EOF\nbad","2":"*errors.errorString: EOF","3":"*errors.errorString: bad"}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %q", want, out)
+		}
+	}
+	if strings.Contains(out, `"stack"`) {
+		t.Errorf("stack should be attached only at debug level %q", out)
+	}
+
+	buf.Reset()
+	lvl.Set(slog.LevelDebug)
+	logger.With("service", "cache").Error("failed", io.EOF)
+	out = buf.String()
+	if !strings.Contains(out, `"stack":"github.com/mkungla/happy/pkg/hlog.TestLoggerErrorConfig`) {
+		t.Errorf("stack should start at caller of Error %q", out)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"errors"
+
+	"golang.org/x/exp/slog"
+)
+
+// FanoutHandler writes each record to all handlers enabled for
+// record level, e.g. console, file and remote handler each with
+// its own level and format.
+type FanoutHandler struct {
+	handlers []slog.Handler
+}
+
+// NewFanoutHandler returns handler writing records to all handlers.
+func NewFanoutHandler(handlers ...slog.Handler) *FanoutHandler {
+	return &FanoutHandler{handlers: handlers}
+}
+
+// Enabled reports whether any of the handlers handles records at level.
+func (h *FanoutHandler) Enabled(level slog.Level) bool {
+	for _, handler := range h.handlers {
+		if handler.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle passes record to each handler enabled for record level,
+// all handlers are called even if some of them fail.
+func (h *FanoutHandler) Handle(r slog.Record) error {
+	var errs []error
+	for _, handler := range h.handlers {
+		if !handler.Enabled(r.Level) {
+			continue
+		}
+		if err := handler.Handle(r.Clone()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *FanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return NewFanoutHandler(handlers...)
+}
+
+func (h *FanoutHandler) WithGroup(name string) slog.Handler {
+	handlers := make([]slog.Handler, len(h.handlers))
+	for i, handler := range h.handlers {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return NewFanoutHandler(handlers...)
+}
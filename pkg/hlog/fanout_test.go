@@ -0,0 +1,70 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bufio"
+	"bytes"
+	"net"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestFanoutHandler(t *testing.T) {
+	var console, file bytes.Buffer
+	l := New(NewFanoutHandler(
+		Config{Options: slog.HandlerOptions{Level: slog.Level(LevelWarn)}}.NewHandler(&console),
+		Config{JSON: true, Options: slog.HandlerOptions{Level: slog.Level(LevelDebug)}}.NewHandler(&file),
+	)).With("service", "cache")
+
+	if !l.Enabled(LevelDebug) {
+		t.Fatal("fanout should be enabled when any handler is enabled")
+	}
+	l.Debug("miss")
+	l.Warn("evicted")
+
+	if strings.Contains(console.String(), "miss") || !strings.Contains(console.String(), "evicted") {
+		t.Errorf("unexpected console output %q", console.String())
+	}
+	if n := strings.Count(file.String(), `"service":"cache"`); n != 2 {
+		t.Errorf("expected 2 json records with attrs got %d: %q", n, file.String())
+	}
+}
+
+func TestNetWriter(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Skip("tcp listener not available: ", err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString('\n')
+		lines <- line
+	}()
+
+	w, err := NewNetWriter("tcp://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	New(Config{JSON: true}.NewHandler(w)).Info("remote")
+	if line := <-lines; !strings.Contains(line, `"msg":"remote"`) {
+		t.Errorf("unexpected remote line %q", line)
+	}
+
+	for _, addr := range []string{"http://localhost", "tcp://", "::"} {
+		if _, err := NewNetWriter(addr); err == nil {
+			t.Errorf("expected error for %q", addr)
+		}
+	}
+}
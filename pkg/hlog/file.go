@@ -0,0 +1,233 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrFile = errors.New("log file error")
+
+// rotatedTimeFormat is timestamp suffix of rotated log files.
+const rotatedTimeFormat = "20060102T150405.000"
+
+// FileConfig configures FileWriter.
+type FileConfig struct {
+	// Path of the log file, parent directory is created when missing.
+	Path string
+	// MaxSize in bytes after which file is rotated, 0 disables size rotation.
+	MaxSize int64
+	// MaxAge after which file is rotated, 0 disables age rotation.
+	MaxAge time.Duration
+	// MaxBackups is number of rotated files to keep, 0 keeps all.
+	MaxBackups int
+	// Compress rotated files with gzip.
+	Compress bool
+}
+
+// FileWriter is io.Writer writing to log file which is rotated by
+// size or age. Rotated files are renamed to <path>.<timestamp> and
+// optionally compressed. Use it as writer of handler e.g.
+//
+//	w, err := hlog.NewFileWriter(hlog.FileConfig{Path: "app.log", MaxSize: 10 << 20})
+//	logger := hlog.New(hlog.Config{}.NewHandler(w))
+type FileWriter struct {
+	mu     sync.Mutex
+	cnf    FileConfig
+	file   *os.File
+	size   int64
+	opened time.Time
+	// compressing rotated files
+	wg sync.WaitGroup
+}
+
+// NewFileWriter opens log file for appending.
+func NewFileWriter(cnf FileConfig) (*FileWriter, error) {
+	if cnf.Path == "" {
+		return nil, fmt.Errorf("%w: path is empty", ErrFile)
+	}
+	if cnf.MaxSize < 0 || cnf.MaxAge < 0 || cnf.MaxBackups < 0 {
+		return nil, fmt.Errorf("%w: rotation limits can not be negative", ErrFile)
+	}
+	w := &FileWriter{cnf: cnf}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Write writes p to log file rotating it first when
+// write would exceed MaxSize or file is older than MaxAge.
+func (w *FileWriter) Write(p []byte) (n int, err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return 0, fmt.Errorf("%w: %s is closed", ErrFile, w.cnf.Path)
+	}
+	if w.needsRotation(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates log file immediately.
+func (w *FileWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.rotate()
+}
+
+// Reopen closes and reopens log file, use it after log file was
+// moved by external tool e.g. logrotate.
+func (w *FileWriter) Reopen() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("%w: %s", ErrFile, err.Error())
+		}
+		w.file = nil
+	}
+	return w.open()
+}
+
+// Close closes log file and waits rotated files to be compressed.
+func (w *FileWriter) Close() error {
+	w.mu.Lock()
+	var err error
+	if w.file != nil {
+		err = w.file.Close()
+		w.file = nil
+	}
+	w.mu.Unlock()
+	w.wg.Wait()
+	return err
+}
+
+func (w *FileWriter) needsRotation(n int64) bool {
+	if w.cnf.MaxSize > 0 && w.size > 0 && w.size+n > w.cnf.MaxSize {
+		return true
+	}
+	return w.cnf.MaxAge > 0 && time.Since(w.opened) >= w.cnf.MaxAge
+}
+
+func (w *FileWriter) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.cnf.Path), 0750); err != nil {
+		return fmt.Errorf("%w: %s", ErrFile, err.Error())
+	}
+	f, err := os.OpenFile(w.cnf.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrFile, err.Error())
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return fmt.Errorf("%w: %s", ErrFile, err.Error())
+	}
+	w.file = f
+	w.size = info.Size()
+	w.opened = time.Now()
+	// age of existing file counts from its creation which is not
+	// portable, modification time is good enough approximation
+	// for files which are written continuously.
+	if w.size > 0 {
+		w.opened = info.ModTime()
+	}
+	return nil
+}
+
+func (w *FileWriter) rotate() error {
+	if w.file != nil {
+		if err := w.file.Close(); err != nil {
+			return fmt.Errorf("%w: %s", ErrFile, err.Error())
+		}
+		w.file = nil
+	}
+	rotated := w.cnf.Path + "." + time.Now().Format(rotatedTimeFormat)
+	if err := os.Rename(w.cnf.Path, rotated); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrFile, err.Error())
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if w.cnf.Compress {
+			if err := compressFile(rotated); err != nil {
+				fmt.Fprintf(os.Stderr, "hlog: failed to compress %s: %s\n", rotated, err)
+			}
+		}
+		w.removeBackups()
+	}()
+	return nil
+}
+
+// backups returns rotated log files sorted from oldest to newest.
+func (w *FileWriter) backups() []string {
+	matches, err := filepath.Glob(w.cnf.Path + ".*")
+	if err != nil {
+		return nil
+	}
+	prefix := filepath.Base(w.cnf.Path) + "."
+	var backups []string
+	for _, m := range matches {
+		ts := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), prefix), ".gz")
+		if _, err := time.Parse(rotatedTimeFormat, ts); err == nil {
+			backups = append(backups, m)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
+
+func (w *FileWriter) removeBackups() {
+	if w.cnf.MaxBackups == 0 {
+		return
+	}
+	backups := w.backups()
+	for len(backups) > w.cnf.MaxBackups {
+		_ = os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix
+
+package hlog
+
+// ReopenOnSIGHUP is noop on platforms without SIGHUP.
+func (w *FileWriter) ReopenOnSIGHUP() (stop func()) {
+	return func() {}
+}
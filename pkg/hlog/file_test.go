@@ -0,0 +1,86 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileWriterRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "app.log")
+	w, err := NewFileWriter(FileConfig{Path: path, MaxSize: 10, MaxBackups: 2, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := w.Write([]byte("0123456789")); err != nil {
+			t.Fatal(err)
+		}
+		// rotated names have millisecond precision
+		time.Sleep(2 * time.Millisecond)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups := w.backups()
+	if len(backups) != 2 {
+		t.Fatalf("expected 2 backups got %v", backups)
+	}
+	for _, b := range backups {
+		if !strings.HasSuffix(b, ".gz") {
+			t.Errorf("backup %s is not compressed", b)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "0123456789" {
+		t.Errorf("unexpected log file content %q", data)
+	}
+}
+
+func TestFileWriterReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	w, err := NewFileWriter(FileConfig{Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	l := New(Config{}.NewHandler(w))
+	l.Info("before")
+
+	moved := path + ".moved"
+	if err := os.Rename(path, moved); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Reopen(); err != nil {
+		t.Fatal(err)
+	}
+	l.Info("after")
+
+	before, _ := os.ReadFile(moved)
+	after, _ := os.ReadFile(path)
+	if !strings.Contains(string(before), "before") || strings.Contains(string(before), "after") {
+		t.Errorf("unexpected moved file content %q", before)
+	}
+	if !strings.Contains(string(after), "after") {
+		t.Errorf("unexpected reopened file content %q", after)
+	}
+}
+
+func TestFileWriterInvalidConfig(t *testing.T) {
+	if _, err := NewFileWriter(FileConfig{}); err == nil {
+		t.Error("expected error for empty path")
+	}
+	if _, err := NewFileWriter(FileConfig{Path: filepath.Join(t.TempDir(), "a.log"), MaxSize: -1}); err == nil {
+		t.Error("expected error for negative size")
+	}
+}
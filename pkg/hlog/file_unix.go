@@ -0,0 +1,38 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package hlog
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// ReopenOnSIGHUP reopens log file each time process receives SIGHUP
+// until returned stop func is called.
+func (w *FileWriter) ReopenOnSIGHUP() (stop func()) {
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-sig:
+				if err := w.Reopen(); err != nil {
+					fmt.Fprintf(os.Stderr, "hlog: %s\n", err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
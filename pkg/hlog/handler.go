@@ -200,6 +200,10 @@ func (h *Handler) handle(r slog.Record) error {
 
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	if i := currentInterruptor(); i != nil {
+		i.Interrupt()
+		defer i.Resume()
+	}
 	_, err := h.w.Write(*state.buf)
 	return err
 }
@@ -17,7 +17,7 @@ import (
 var defaultLogger atomic.Value
 
 func init() {
-	defaultLogger.Store(New(Config{}.NewHandler(os.Stdout)))
+	defaultLogger.Store(New(NewContextHandler(Config{}.NewHandler(os.Stdout))))
 }
 
 // Default returns the default Logger.
@@ -44,11 +44,22 @@ func WithGroup(name string) *Logger {
 
 func New(h slog.Handler) *Logger { return &Logger{slog: slog.New(h)} }
 
+// NewLeveled returns Logger which level can be changed with
+// Logger.SetLevel, lvl must be the level used by handler h.
+func NewLeveled(h slog.Handler, lvl *slog.LevelVar) *Logger {
+	return &Logger{slog: slog.New(h), lvl: lvl}
+}
+
 // Debug calls Logger.Debug on the default logger.
 func Debug(msg string, args ...any) {
 	Default().LogDepth(0, LevelDebug, msg, args...)
 }
 
+// Trace calls Logger.Trace on the default logger.
+func Trace(msg string, args ...any) {
+	Default().LogDepth(0, LevelTrace, msg, args...)
+}
+
 // Debug calls Logger.Debug on the default logger.
 func SystemDebug(msg string, args ...any) {
 	Default().LogDepth(0, LevelSystemDebug, msg, args...)
@@ -0,0 +1,263 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package hlogtest provides log recorder to test logging behavior of
+// services and commands.
+//
+//	rec := hlogtest.NewRecorder(t)
+//	svc.Start(rec.Logger())
+//	rec.AssertLogged(hlog.LevelWarn, "cache miss", "key", "users")
+package hlogtest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"golang.org/x/exp/slog"
+)
+
+// TB is subset of testing.TB used by Recorder.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Record is captured log record. Attrs are flattened, attributes
+// in groups have keys prefixed with group names e.g. http.status.
+type Record struct {
+	Time    time.Time
+	Level   hlog.Level
+	Message string
+	Attrs   map[string]slog.Value
+}
+
+// Attr returns value of attribute with key.
+func (r Record) Attr(key string) (slog.Value, bool) {
+	v, ok := r.Attrs[key]
+	return v, ok
+}
+
+// String returns record in level message key=value form.
+func (r Record) String() string {
+	var b strings.Builder
+	b.WriteString(r.Level.String())
+	b.WriteByte(' ')
+	b.WriteString(r.Message)
+	for _, k := range sortedKeys(r.Attrs) {
+		fmt.Fprintf(&b, " %s=%s", k, r.Attrs[k])
+	}
+	return b.String()
+}
+
+// Recorder captures records of all levels logged with its Logger.
+type Recorder struct {
+	t       TB
+	mu      sync.Mutex
+	records []Record
+}
+
+// NewRecorder returns Recorder reporting failed assertions to t.
+func NewRecorder(t TB) *Recorder {
+	return &Recorder{t: t}
+}
+
+// Logger returns logger writing to recorder.
+func (r *Recorder) Logger() *hlog.Logger {
+	return hlog.New(r.Handler())
+}
+
+// Handler returns handler writing to recorder.
+func (r *Recorder) Handler() slog.Handler {
+	return &handler{rec: r}
+}
+
+// Records returns copy of captured records.
+func (r *Recorder) Records() []Record {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Record(nil), r.records...)
+}
+
+// Len returns number of captured records.
+func (r *Recorder) Len() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.records)
+}
+
+// Reset removes captured records.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = nil
+}
+
+// Find returns records with level and message matching given attributes
+// in key value pairs e.g. Find(hlog.LevelInfo, "started", "port", 8080).
+// Attribute values are compared by their string form.
+func (r *Recorder) Find(level hlog.Level, msg string, attrs ...any) []Record {
+	want := pairs(attrs)
+	var found []Record
+	for _, rec := range r.Records() {
+		if rec.Level == level && rec.Message == msg && rec.matches(want) {
+			found = append(found, rec)
+		}
+	}
+	return found
+}
+
+// AssertLogged fails the test when no record matches, see Find.
+func (r *Recorder) AssertLogged(level hlog.Level, msg string, attrs ...any) bool {
+	if len(r.Find(level, msg, attrs...)) > 0 {
+		return true
+	}
+	r.t.Helper()
+	r.t.Errorf("expected %s record %q %v to be logged, got:\n%s", level, msg, attrs, r.dump())
+	return false
+}
+
+// AssertNotLogged fails the test when any record matches, see Find.
+func (r *Recorder) AssertNotLogged(level hlog.Level, msg string, attrs ...any) bool {
+	found := r.Find(level, msg, attrs...)
+	if len(found) == 0 {
+		return true
+	}
+	r.t.Helper()
+	r.t.Errorf("expected %s record %q %v not to be logged, got %d", level, msg, attrs, len(found))
+	return false
+}
+
+// AssertCount fails the test when number of records
+// at given level differs from n.
+func (r *Recorder) AssertCount(level hlog.Level, n int) bool {
+	count := 0
+	for _, rec := range r.Records() {
+		if rec.Level == level {
+			count++
+		}
+	}
+	if count == n {
+		return true
+	}
+	r.t.Helper()
+	r.t.Errorf("expected %d %s records, got %d:\n%s", n, level, count, r.dump())
+	return false
+}
+
+func (r *Recorder) add(rec Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, rec)
+}
+
+func (r *Recorder) dump() string {
+	records := r.Records()
+	if len(records) == 0 {
+		return "  no records"
+	}
+	lines := make([]string, len(records))
+	for i, rec := range records {
+		lines[i] = "  " + rec.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (r Record) matches(want map[string]string) bool {
+	for k, v := range want {
+		got, ok := r.Attrs[k]
+		if !ok || got.String() != v {
+			return false
+		}
+	}
+	return true
+}
+
+func pairs(args []any) map[string]string {
+	want := make(map[string]string)
+	for len(args) > 0 {
+		switch a := args[0].(type) {
+		case slog.Attr:
+			want[a.Key] = a.Value.Resolve().String()
+			args = args[1:]
+		case string:
+			if len(args) == 1 {
+				want[a] = ""
+				return want
+			}
+			want[a] = slog.AnyValue(args[1]).Resolve().String()
+			args = args[2:]
+		default:
+			want[fmt.Sprint(a)] = ""
+			args = args[1:]
+		}
+	}
+	return want
+}
+
+func sortedKeys(m map[string]slog.Value) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type handler struct {
+	rec    *Recorder
+	prefix string
+	attrs  []slog.Attr
+}
+
+func (h *handler) Enabled(slog.Level) bool { return true }
+
+func (h *handler) Handle(r slog.Record) error {
+	rec := Record{
+		Time:    r.Time,
+		Level:   hlog.Level(r.Level),
+		Message: r.Message,
+		Attrs:   make(map[string]slog.Value),
+	}
+	for _, a := range h.attrs {
+		flatten(rec.Attrs, "", a)
+	}
+	r.Attrs(func(a slog.Attr) {
+		flatten(rec.Attrs, h.prefix, a)
+	})
+	h.rec.add(rec)
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	h2.attrs = append([]slog.Attr(nil), h.attrs...)
+	for _, a := range attrs {
+		if h.prefix != "" {
+			a.Key = h.prefix + a.Key
+		}
+		h2.attrs = append(h2.attrs, a)
+	}
+	return &h2
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	h2 := *h
+	h2.prefix = h.prefix + name + "."
+	return &h2
+}
+
+func flatten(attrs map[string]slog.Value, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.GroupKind {
+		for _, ga := range v.Group() {
+			flatten(attrs, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	attrs[prefix+a.Key] = v
+}
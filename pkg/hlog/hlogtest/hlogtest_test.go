@@ -0,0 +1,62 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlogtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"golang.org/x/exp/slog"
+)
+
+type fakeTB struct {
+	errors []string
+}
+
+func (f *fakeTB) Helper() {}
+
+func (f *fakeTB) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	rec := NewRecorder(t)
+	logger := rec.Logger().With("service", "cache")
+	logger.Debug("miss", "key", "users")
+	logger.WithGroup("http").Warn("slow", slog.Int("status", 200))
+
+	if rec.Len() != 2 {
+		t.Fatalf("expected 2 records got %d", rec.Len())
+	}
+	rec.AssertLogged(hlog.LevelDebug, "miss", "service", "cache", "key", "users")
+	rec.AssertLogged(hlog.LevelWarn, "slow", "http.status", 200)
+	rec.AssertNotLogged(hlog.LevelError, "miss")
+	rec.AssertCount(hlog.LevelWarn, 1)
+
+	rec.Reset()
+	if rec.Len() != 0 {
+		t.Error("expected no records after reset")
+	}
+}
+
+func TestRecorderFailures(t *testing.T) {
+	tb := &fakeTB{}
+	rec := NewRecorder(tb)
+	rec.Logger().Info("started", "port", 8080)
+
+	if rec.AssertLogged(hlog.LevelInfo, "started", "port", 9090) {
+		t.Error("assertion with wrong attr should fail")
+	}
+	if rec.AssertNotLogged(hlog.LevelInfo, "started") {
+		t.Error("assertion for logged record should fail")
+	}
+	if rec.AssertCount(hlog.LevelInfo, 2) {
+		t.Error("assertion with wrong count should fail")
+	}
+	if len(tb.errors) != 3 {
+		t.Errorf("expected 3 reported errors got %d", len(tb.errors))
+	}
+}
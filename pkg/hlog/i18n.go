@@ -0,0 +1,168 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"os"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// Catalog provides message templates for message keys.
+type Catalog interface {
+	// Lookup returns template of message key in language lang.
+	Lookup(lang, key string) (string, bool)
+}
+
+// MapCatalog is Catalog of templates by language and message key e.g.
+//
+//	hlog.MapCatalog{"et": {"cache.miss": "võtit {key} pole vahemälus"}}
+type MapCatalog map[string]map[string]string
+
+// Lookup returns template of message key in language lang.
+func (c MapCatalog) Lookup(lang, key string) (string, bool) {
+	tmpl, ok := c[lang][key]
+	return tmpl, ok
+}
+
+// Translator resolves logged messages which are message keys in
+// catalog to localized messages. Template placeholders {name} are
+// replaced with values of record attributes with same key, e.g.
+// logger.Info("cache.miss", "key", "users") is logged with message
+// "võtit users pole vahemälus" when language is et. Messages which
+// are not found in catalog are logged as is.
+type Translator struct {
+	mu       sync.RWMutex
+	catalog  Catalog
+	lang     string
+	fallback string
+}
+
+// NewTranslator returns Translator using catalog in language lang
+// and fallback language when message is missing in lang.
+func NewTranslator(catalog Catalog, lang, fallback string) *Translator {
+	return &Translator{catalog: catalog, lang: lang, fallback: fallback}
+}
+
+// SetLanguage changes language of translated messages.
+func (t *Translator) SetLanguage(lang string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.lang = lang
+}
+
+// Language returns current language.
+func (t *Translator) Language() string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.lang
+}
+
+// Translate returns message key rendered in current language with
+// values of attrs or key itself when it is not found in catalog.
+func (t *Translator) Translate(key string, attrs ...slog.Attr) string {
+	t.mu.RLock()
+	lang, fallback := t.lang, t.fallback
+	t.mu.RUnlock()
+	tmpl, ok := t.catalog.Lookup(lang, key)
+	if !ok && fallback != "" {
+		tmpl, ok = t.catalog.Lookup(fallback, key)
+	}
+	if !ok {
+		return key
+	}
+	return renderMessage(tmpl, attrs)
+}
+
+// Handler wraps h so that messages are translated before reaching h.
+func (t *Translator) Handler(h slog.Handler) slog.Handler {
+	return &translateHandler{h: h, t: t}
+}
+
+// LanguageFromEnv returns language from LC_ALL, LC_MESSAGES or LANG
+// environment variable e.g. "et" for et_EE.UTF-8 or empty string.
+func LanguageFromEnv() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := os.Getenv(env)
+		if v == "" || v == "C" || v == "POSIX" {
+			continue
+		}
+		v, _, _ = strings.Cut(v, ".")
+		v, _, _ = strings.Cut(v, "_")
+		return strings.ToLower(v)
+	}
+	return ""
+}
+
+// renderMessage replaces {name} placeholders in tmpl
+// with values of attrs, unknown placeholders are kept.
+func renderMessage(tmpl string, attrs []slog.Attr) string {
+	if !strings.Contains(tmpl, "{") {
+		return tmpl
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(tmpl, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(tmpl[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(tmpl[:start])
+		name := tmpl[start+1 : end]
+		replaced := false
+		for i := len(attrs) - 1; i >= 0; i-- {
+			if attrs[i].Key == name {
+				b.WriteString(attrs[i].Value.Resolve().String())
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			b.WriteString(tmpl[start : end+1])
+		}
+		tmpl = tmpl[end+1:]
+	}
+	b.WriteString(tmpl)
+	return b.String()
+}
+
+type translateHandler struct {
+	h     slog.Handler
+	t     *Translator
+	attrs []slog.Attr
+}
+
+func (h *translateHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+func (h *translateHandler) Handle(r slog.Record) error {
+	attrs := h.attrs[:len(h.attrs):len(h.attrs)]
+	r.Attrs(func(a slog.Attr) {
+		attrs = append(attrs, a)
+	})
+	// Message is plain field so record copy keeps
+	// attributes and source of original record.
+	r.Message = h.t.Translate(r.Message, attrs...)
+	return h.h.Handle(r)
+}
+
+func (h *translateHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &translateHandler{
+		h:     h.h.WithAttrs(attrs),
+		t:     h.t,
+		attrs: append(h.attrs[:len(h.attrs):len(h.attrs)], attrs...),
+	}
+}
+
+func (h *translateHandler) WithGroup(name string) slog.Handler {
+	return &translateHandler{h: h.h.WithGroup(name), t: h.t, attrs: h.attrs}
+}
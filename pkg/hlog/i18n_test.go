@@ -0,0 +1,51 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestTranslator(t *testing.T) {
+	catalog := MapCatalog{
+		"en": {"cache.miss": "key {key} not in cache {name}", "cache.hit": "cache hit"},
+		"et": {"cache.miss": "võtit {key} pole vahemälus {name}"},
+	}
+	tr := NewTranslator(catalog, "et", "en")
+	var buf bytes.Buffer
+	logger := New(tr.Handler(Config{}.NewHandler(&buf))).With("name", "users")
+
+	logger.Info("cache.miss", "key", "u1")
+	logger.Info("cache.hit")
+	logger.Info("not a key")
+	tr.SetLanguage("en")
+	logger.Info("cache.miss", "key", "u2")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	for i, want := range []string{
+		`"võtit u1 pole vahemälus users" name=users key=u1`,
+		`"cache hit" name=users`,
+		`"not a key" name=users`,
+		`"key u2 not in cache users" name=users key=u2`,
+	} {
+		if i >= len(lines) || !strings.HasSuffix(lines[i], want) {
+			t.Errorf("line %d: want suffix %s in %q", i, want, buf.String())
+		}
+	}
+	if got := renderMessage("{a} and {missing", nil); got != "{a} and {missing" {
+		t.Errorf("unexpected render %q", got)
+	}
+}
+
+func TestLanguageFromEnv(t *testing.T) {
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "et_EE.UTF-8")
+	if got := LanguageFromEnv(); got != "et" {
+		t.Errorf("got %q want et", got)
+	}
+}
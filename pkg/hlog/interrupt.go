@@ -0,0 +1,38 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import "sync/atomic"
+
+// Interruptor is notified around each log write so that live terminal
+// output e.g. progress bars can clear itself before log line is written
+// and redraw after it.
+type Interruptor interface {
+	Interrupt()
+	Resume()
+}
+
+type interruptorHolder struct {
+	i Interruptor
+}
+
+var interruptor atomic.Pointer[interruptorHolder]
+
+// SetInterruptor sets Interruptor notified by text handlers,
+// nil removes current Interruptor.
+func SetInterruptor(i Interruptor) {
+	if i == nil {
+		interruptor.Store(nil)
+		return
+	}
+	interruptor.Store(&interruptorHolder{i: i})
+}
+
+func currentInterruptor() Interruptor {
+	if h := interruptor.Load(); h != nil {
+		return h.i
+	}
+	return nil
+}
@@ -5,14 +5,20 @@
 package hlog
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"sync"
 
 	"golang.org/x/exp/slog"
 )
 
+var ErrLevel = errors.New("log level error")
+
 const (
 	// level mappings
+	levelTrace          = slog.LevelDebug - 2
 	levelSystemDebug    = slog.LevelDebug - 1
 	levelDebug          = slog.LevelDebug
 	levelInfo           = slog.LevelInfo
@@ -27,6 +33,7 @@ const (
 	levelOut            = slog.LevelError + 1
 
 	// Levels
+	LevelTrace          Level = Level(levelTrace)
 	LevelSystemDebug    Level = Level(levelSystemDebug)
 	LevelDebug          Level = Level(slog.LevelDebug)
 	LevelInfo           Level = Level(levelInfo)
@@ -43,12 +50,66 @@ const (
 
 type Level slog.Level
 
+// builtinLevels are levels known by ParseLevel in addition
+// to levels registered with RegisterLevel.
+var builtinLevels = []Level{
+	LevelTrace, LevelSystemDebug, LevelDebug, LevelInfo, LevelTask, LevelOk,
+	LevelNotice, LevelWarn, LevelNotImplemented, LevelDeprecated,
+	LevelIssue, LevelError, LevelOut,
+}
+
+type customLevel struct {
+	name string
+	fg   Color
+}
+
+var (
+	customLevelsMu sync.RWMutex
+	customLevels   = make(map[Level]customLevel)
+)
+
+// RegisterLevel registers app specific level e.g. audit with name used
+// by handlers and ParseLevel and fg color of level label. Level and name
+// must not clash with built-in or already registered levels.
+func RegisterLevel(level Level, name string, fg Color) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || strings.ContainsAny(name, " =:,") {
+		return fmt.Errorf("%w: invalid level name %q", ErrLevel, name)
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return fmt.Errorf("%w: level name %q can not be number", ErrLevel, name)
+	}
+	for _, l := range builtinLevels {
+		if l == level || l.String() == name {
+			return fmt.Errorf("%w: level %d %q clashes with built-in level %s", ErrLevel, level, name, l)
+		}
+	}
+	customLevelsMu.Lock()
+	defer customLevelsMu.Unlock()
+	for l, cl := range customLevels {
+		if l == level || cl.name == name {
+			return fmt.Errorf("%w: level %d %q already registered as %d %q", ErrLevel, level, name, l, cl.name)
+		}
+	}
+	customLevels[level] = customLevel{name: name, fg: fg}
+	return nil
+}
+
+func lookupCustomLevel(l Level) (customLevel, bool) {
+	customLevelsMu.RLock()
+	defer customLevelsMu.RUnlock()
+	cl, ok := customLevels[l]
+	return cl, ok
+}
+
 // Level returns the receiver.
 // It implements slog.Leveler.
 func (l Level) Level() Level { return l }
 
 func (l Level) String() string {
 	switch l {
+	case LevelTrace:
+		return "trace"
 	case LevelSystemDebug:
 		return "system"
 	case LevelDebug:
@@ -74,10 +135,42 @@ func (l Level) String() string {
 	case LevelOut:
 		return "out"
 	default:
+		if cl, ok := lookupCustomLevel(l); ok {
+			return cl.name
+		}
 		return slog.Level(l).String()
 	}
 }
 
+// ParseLevel parses level name as returned by Level.String
+// or numeric level value.
+func ParseLevel(s string) (Level, error) {
+	name := strings.ToLower(strings.TrimSpace(s))
+	for _, l := range builtinLevels {
+		if l.String() == name {
+			return l, nil
+		}
+	}
+	customLevelsMu.RLock()
+	for l, cl := range customLevels {
+		if cl.name == name {
+			customLevelsMu.RUnlock()
+			return l, nil
+		}
+	}
+	customLevelsMu.RUnlock()
+	switch name {
+	case "warning":
+		return LevelWarn, nil
+	case "deprecated":
+		return LevelDeprecated, nil
+	}
+	if v, err := strconv.Atoi(name); err == nil {
+		return Level(v), nil
+	}
+	return 0, fmt.Errorf("%w: unknown log level %q", ErrLevel, s)
+}
+
 func (l Level) Label() string {
 	return fmt.Sprintf(" %-8s ", l.String())
 }
@@ -88,6 +181,9 @@ func (l Level) ColorLabel() string {
 		fg, bg Color
 	)
 	switch l {
+	case LevelTrace:
+		label = "trace"
+		fg, bg = FgBlue, 0
 	case LevelSystemDebug:
 		label = "system"
 		fg, bg = FgBlue, 0
@@ -126,6 +222,10 @@ func (l Level) ColorLabel() string {
 		label = "out"
 		fg, bg = FgWhite, 0
 	default:
+		if cl, ok := lookupCustomLevel(l); ok {
+			label, fg = cl.name, cl.fg
+			break
+		}
 		label = slog.Level(l).String()
 		fg, bg = FgRed, BgBlack
 	}
@@ -139,10 +239,26 @@ func (l Level) MarshalJSON() ([]byte, error) {
 	return strconv.AppendQuote(nil, l.String()), nil
 }
 
+// UnmarshalJSON parses level encoded with MarshalJSON.
+func (l *Level) UnmarshalJSON(data []byte) error {
+	s, err := strconv.Unquote(string(data))
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrLevel, err.Error())
+	}
+	lvl, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = lvl
+	return nil
+}
+
 func (l Level) color() (start []byte) {
 	start = []byte{'\033', '['}
 	var fg Color
 	switch l {
+	case LevelTrace:
+		fg = FgBlue
 	case LevelSystemDebug:
 		fg = FgBlue
 	case LevelDebug:
@@ -170,6 +286,9 @@ func (l Level) color() (start []byte) {
 		fg = FgWhite
 	default:
 		fg = FgRed
+		if cl, ok := lookupCustomLevel(l); ok {
+			fg = cl.fg
+		}
 	}
 	switch fgc := (fg & fgMask) >> fgShift; {
 	case fgc <= 7:
@@ -5,6 +5,7 @@
 package hlog
 
 import (
+	"errors"
 	"testing"
 
 	"golang.org/x/exp/slog"
@@ -25,7 +26,8 @@ func TestLevelString(t *testing.T) {
 		{LevelInfo + 1, "task"},
 		{LevelInfo - 3, "DEBUG+1"},
 		{LevelDebug, "debug"},
-		{LevelDebug - 2, "DEBUG-2"},
+		{LevelDebug - 2, "trace"},
+		{LevelDebug - 3, "DEBUG-3"},
 	} {
 		got := test.in.String()
 		if got != test.want {
@@ -49,3 +51,65 @@ func TestLevelVar(t *testing.T) {
 	}
 
 }
+
+func TestParseLevel(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want Level
+	}{
+		{"debug", LevelDebug},
+		{"INFO", LevelInfo},
+		{"warning", LevelWarn},
+		{"system", LevelSystemDebug},
+		{"depr", LevelDeprecated},
+		{"-4", LevelDebug},
+	} {
+		got, err := ParseLevel(test.in)
+		if err != nil {
+			t.Errorf("%s: %s", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %s, want %s", test.in, got, test.want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected error for unknown level")
+	}
+}
+
+func TestRegisterLevel(t *testing.T) {
+	audit := LevelError + 5
+	if err := RegisterLevel(audit, "Audit", FgMagenta); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		customLevelsMu.Lock()
+		delete(customLevels, audit)
+		customLevelsMu.Unlock()
+	}()
+	if got := audit.String(); got != "audit" {
+		t.Errorf("got %s, want audit", got)
+	}
+	if lvl, err := ParseLevel("audit"); err != nil || lvl != audit {
+		t.Errorf("ParseLevel(audit) = %v, %v", lvl, err)
+	}
+	if lvl, err := ParseLevel("trace"); err != nil || lvl != LevelTrace {
+		t.Errorf("ParseLevel(trace) = %v, %v", lvl, err)
+	}
+	for _, test := range []struct {
+		level Level
+		name  string
+	}{
+		{audit, "other"},
+		{audit - 10, "audit"},
+		{LevelWarn, "alarm"},
+		{LevelWarn + 10, "debug"},
+		{LevelWarn + 10, ""},
+		{LevelWarn + 10, "12"},
+	} {
+		if err := RegisterLevel(test.level, test.name, 0); !errors.Is(err, ErrLevel) {
+			t.Errorf("RegisterLevel(%d, %q) expected ErrLevel got %v", test.level, test.name, err)
+		}
+	}
+}
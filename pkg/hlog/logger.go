@@ -6,6 +6,7 @@ package hlog
 
 import (
 	"context"
+	"fmt"
 	"strings"
 	"time"
 
@@ -16,6 +17,34 @@ import (
 
 type Logger struct {
 	slog *slog.Logger
+	// lvl is level of handler which can be changed at runtime
+	lvl *slog.LevelVar
+	// errcnf when set logs errors with details in single record
+	errcnf *ErrorConfig
+}
+
+// SetLevel changes minimum level of the logger at runtime,
+// logger must be created with NewLeveled.
+func (l *Logger) SetLevel(level Level) error {
+	if l.lvl == nil {
+		return fmt.Errorf("%w: logger level can not be changed, use NewLeveled", ErrLevel)
+	}
+	l.lvl.Set(slog.Level(level))
+	return nil
+}
+
+// Level returns current minimum level of the logger
+// created with NewLeveled or LevelInfo otherwise.
+func (l *Logger) Level() Level {
+	if l.lvl == nil {
+		return LevelInfo
+	}
+	return Level(l.lvl.Level())
+}
+
+// Trace logs at LevelTrace.
+func (l *Logger) Trace(msg string, args ...any) {
+	l.LogDepth(0, LevelTrace, msg, args...)
 }
 
 // Debug logs at LevelDebug.
@@ -76,8 +105,19 @@ func (l *Logger) Issue(msg string, args ...any) {
 
 // Error logs at LevelError.
 // If err is non-nil, Error appends Any(ErrorKey, err)
-// to the list of attributes.
+// to the list of attributes. Logger created with WithErrorConfig
+// logs err, its causes and stack with args in single record.
 func (l *Logger) Error(msg string, err error, args ...any) {
+	if err != nil && l.errcnf != nil {
+		if !l.Enabled(LevelError) {
+			return
+		}
+		for _, attr := range l.errorAttrs(err, 1) {
+			args = append(args, attr)
+		}
+		l.LogDepth(0, LevelError, msg, args...)
+		return
+	}
 	// Would need to have workaround for this allocation
 	if err != nil {
 		errmsgs := strings.Split(err.Error(), "\n")
@@ -97,6 +137,9 @@ func (l *Logger) Println(msg string, args ...any) {
 // Handler returns l's Handler.
 func (l *Logger) Handler() slog.Handler { return l.slog.Handler() }
 
+// Slog returns *slog.Logger writing to handler of l.
+func (l *Logger) Slog() *slog.Logger { return l.slog }
+
 // Context returns l's context.
 func (l *Logger) Context() context.Context { return l.slog.Context() }
 
@@ -115,7 +158,7 @@ func (l *Logger) With(args ...any) *Logger {
 		attr, args = argsToAttr(args)
 		attrs = append(attrs, attr)
 	}
-	return New(l.slog.Handler().WithAttrs(attrs))
+	return &Logger{slog: slog.New(l.slog.Handler().WithAttrs(attrs)), lvl: l.lvl, errcnf: l.errcnf}
 }
 
 // WithGroup returns a new Logger that starts a group. The keys of all
@@ -124,7 +167,7 @@ func (l *Logger) With(args ...any) *Logger {
 // The new Logger's handler is the result of calling WithGroup on the receiver's
 // handler.
 func (l *Logger) WithGroup(name string) *Logger {
-	return New(l.Handler().WithGroup(name))
+	return &Logger{slog: slog.New(l.Handler().WithGroup(name)), lvl: l.lvl, errcnf: l.errcnf}
 }
 
 // WithContext returns a new Logger with the same handler
@@ -7,6 +7,7 @@ package hlog
 import (
 	"bytes"
 	"context"
+	"errors"
 	"io"
 	"log"
 	"path/filepath"
@@ -409,3 +410,26 @@ func attrsSlice(r slog.Record) []slog.Attr {
 	r.Attrs(func(a slog.Attr) { s = append(s, a) })
 	return s
 }
+
+func TestLoggerSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	lvl := &slog.LevelVar{}
+	l := NewLeveled(Config{Options: slog.HandlerOptions{Level: lvl}}.NewHandler(&buf), lvl)
+	scoped := l.With("service", "cache")
+
+	scoped.Debug("hidden")
+	if err := l.SetLevel(LevelDebug); err != nil {
+		t.Fatal(err)
+	}
+	if l.Level() != LevelDebug || scoped.Level() != LevelDebug {
+		t.Errorf("level not shared with derived logger")
+	}
+	scoped.Debug("visible")
+	if strings.Contains(buf.String(), "hidden") || !strings.Contains(buf.String(), "visible") {
+		t.Errorf("unexpected output %q", buf.String())
+	}
+
+	if err := New(Config{}.NewHandler(&buf)).SetLevel(LevelDebug); !errors.Is(err, ErrLevel) {
+		t.Errorf("expected ErrLevel got %v", err)
+	}
+}
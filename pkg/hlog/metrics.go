@@ -0,0 +1,178 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// MetricsSample is number of records logged at level
+// by loggers with given scope labels.
+type MetricsSample struct {
+	Level Level `json:"level"`
+	// Labels are scope attributes in key=value form
+	// separated by commas e.g. service=cache.
+	Labels string `json:"labels,omitempty"`
+	Count  uint64 `json:"count"`
+}
+
+// Metrics counts records per level and scope so that e.g. error
+// rate of services can be monitored without log scraping. Scope is
+// value of attribute with one of the label keys added with
+// logger.With or to the record.
+type Metrics struct {
+	keys   []string
+	mu     sync.Mutex
+	counts map[metricsKey]uint64
+}
+
+type metricsKey struct {
+	level  Level
+	labels string
+}
+
+// NewMetrics returns Metrics labeling counters with given attribute keys
+// e.g. NewMetrics("service", "addon").
+func NewMetrics(keys ...string) *Metrics {
+	return &Metrics{keys: keys, counts: make(map[metricsKey]uint64)}
+}
+
+// Handler wraps h so that records passed to h are counted.
+func (m *Metrics) Handler(h slog.Handler) slog.Handler {
+	return &metricsHandler{h: h, m: m}
+}
+
+// Count returns number of records logged at level with given
+// labels in format of MetricsSample.Labels, empty labels sums
+// counts of all scopes.
+func (m *Metrics) Count(level Level, labels string) uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if labels != "" {
+		return m.counts[metricsKey{level, labels}]
+	}
+	var total uint64
+	for k, c := range m.counts {
+		if k.level == level {
+			total += c
+		}
+	}
+	return total
+}
+
+// Snapshot returns counters sorted by level and labels.
+func (m *Metrics) Snapshot() []MetricsSample {
+	m.mu.Lock()
+	samples := make([]MetricsSample, 0, len(m.counts))
+	for k, c := range m.counts {
+		samples = append(samples, MetricsSample{Level: k.level, Labels: k.labels, Count: c})
+	}
+	m.mu.Unlock()
+	sort.Slice(samples, func(i, j int) bool {
+		if samples[i].Level != samples[j].Level {
+			return samples[i].Level < samples[j].Level
+		}
+		return samples[i].Labels < samples[j].Labels
+	})
+	return samples
+}
+
+func (m *Metrics) add(level Level, labels string) {
+	m.mu.Lock()
+	m.counts[metricsKey{level, labels}]++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) isLabel(key string) bool {
+	for _, k := range m.keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// labels returns labels in key order, record labels override scope labels.
+func (m *Metrics) labels(scope map[string]string, r slog.Record) string {
+	var values map[string]string
+	r.Attrs(func(a slog.Attr) {
+		if m.isLabel(a.Key) {
+			if values == nil {
+				values = make(map[string]string, len(scope)+1)
+				for k, v := range scope {
+					values[k] = v
+				}
+			}
+			values[a.Key] = a.Value.Resolve().String()
+		}
+	})
+	if values == nil {
+		values = scope
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	var labels []string
+	for _, k := range m.keys {
+		if v, ok := values[k]; ok {
+			labels = append(labels, k+"="+v)
+		}
+	}
+	return strings.Join(labels, ",")
+}
+
+type metricsHandler struct {
+	h     slog.Handler
+	m     *Metrics
+	group string
+	scope map[string]string
+}
+
+func (h *metricsHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+func (h *metricsHandler) Handle(r slog.Record) error {
+	if h.h.Enabled(r.Level) {
+		// record attributes in groups are not used as labels
+		attrs := r
+		if h.group != "" {
+			attrs = slog.Record{}
+		}
+		h.m.add(Level(r.Level), h.m.labels(h.scope, attrs))
+	}
+	return h.h.Handle(r)
+}
+
+func (h *metricsHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &metricsHandler{h: h.h.WithAttrs(attrs), m: h.m, group: h.group, scope: h.scope}
+	// only top level attributes are used as labels
+	if h.group != "" {
+		return h2
+	}
+	copied := false
+	for _, a := range attrs {
+		if !h.m.isLabel(a.Key) {
+			continue
+		}
+		if !copied {
+			h2.scope = make(map[string]string, len(h.scope)+1)
+			for k, v := range h.scope {
+				h2.scope[k] = v
+			}
+			copied = true
+		}
+		h2.scope[a.Key] = a.Value.Resolve().String()
+	}
+	return h2
+}
+
+func (h *metricsHandler) WithGroup(name string) slog.Handler {
+	return &metricsHandler{h: h.h.WithGroup(name), m: h.m, group: h.group + name + ".", scope: h.scope}
+}
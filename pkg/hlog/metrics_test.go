@@ -0,0 +1,44 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics("service", "addon")
+	logger := New(m.Handler(Config{}.NewHandler(io.Discard)))
+	cache := logger.With("service", "cache", "addon", "db")
+
+	cache.Error("failed", errors.New("boom"))
+	cache.Error("failed", errors.New("boom"))
+	logger.Error("failed", nil, "service", "http")
+	logger.Info("started")
+	logger.Debug("not enabled")
+	cache.WithGroup("req").Warn("slow", "service", "ignored")
+
+	if got := m.Count(LevelError, "service=cache,addon=db"); got != 2 {
+		t.Errorf("expected 2 cache errors got %d", got)
+	}
+	if got := m.Count(LevelError, "service=http"); got != 1 {
+		t.Errorf("expected 1 http error got %d", got)
+	}
+	if got := m.Count(LevelError, ""); got != 3 {
+		t.Errorf("expected 3 errors in total got %d", got)
+	}
+	if got := m.Count(LevelDebug, ""); got != 0 {
+		t.Errorf("disabled records should not be counted got %d", got)
+	}
+	if got := m.Count(LevelWarn, "service=cache,addon=db"); got != 1 {
+		t.Errorf("grouped record attrs should not be labels got %d", got)
+	}
+	samples := m.Snapshot()
+	if len(samples) != 4 || samples[0].Level != LevelInfo || samples[0].Labels != "" {
+		t.Errorf("unexpected snapshot %+v", samples)
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+	"time"
+)
+
+var ErrNet = errors.New("log network error")
+
+const netDialTimeout = 5 * time.Second
+
+// NetWriter is io.Writer sending log lines to remote collector over
+// tcp, udp or unix socket. Connection is established on first write
+// and re-established on next write after failure.
+type NetWriter struct {
+	mu      sync.Mutex
+	network string
+	addr    string
+	conn    net.Conn
+}
+
+// NewNetWriter returns writer for address in form network://host:port
+// e.g. tcp://127.0.0.1:5170, udp://127.0.0.1:514 or unix:///run/log.sock.
+func NewNetWriter(address string) (*NetWriter, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrNet, err.Error())
+	}
+	w := &NetWriter{network: u.Scheme}
+	switch u.Scheme {
+	case "tcp", "tcp4", "tcp6", "udp", "udp4", "udp6":
+		w.addr = u.Host
+	case "unix", "unixgram":
+		w.addr = u.Path
+	default:
+		return nil, fmt.Errorf("%w: unsupported network %q in %s", ErrNet, u.Scheme, address)
+	}
+	if w.addr == "" {
+		return nil, fmt.Errorf("%w: missing address in %s", ErrNet, address)
+	}
+	return w, nil
+}
+
+func (w *NetWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		conn, err := net.DialTimeout(w.network, w.addr, netDialTimeout)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %s", ErrNet, err.Error())
+		}
+		w.conn = conn
+	}
+	n, err := w.conn.Write(p)
+	if err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return n, fmt.Errorf("%w: %s", ErrNet, err.Error())
+	}
+	return n, nil
+}
+
+// Close closes connection to remote collector.
+func (w *NetWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,435 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+var ErrOTLP = errors.New("otlp export error")
+
+const (
+	otlpDefaultBatchSize     = 512
+	otlpDefaultFlushInterval = 5 * time.Second
+	otlpDefaultTimeout       = 10 * time.Second
+	otlpDefaultMaxRetries    = 5
+	otlpDefaultQueueSize     = 4096
+	otlpRetryBackoff         = 500 * time.Millisecond
+	otlpLogsPath             = "/v1/logs"
+)
+
+// OTLPConfig configures OTLPHandler.
+type OTLPConfig struct {
+	// Endpoint of OTLP/HTTP collector e.g. http://localhost:4318,
+	// /v1/logs is appended when endpoint has no path.
+	Endpoint string
+	// Headers sent with each export request e.g. authorization.
+	Headers map[string]string
+	// Resource attributes e.g. service.name.
+	Resource map[string]string
+	// Level is minimum level of exported records, nil exports LevelInfo and above.
+	Level slog.Leveler
+	// BatchSize is maximum number of records in single export request.
+	BatchSize int
+	// FlushInterval is maximum time record waits in batch.
+	FlushInterval time.Duration
+	// Timeout of single export request.
+	Timeout time.Duration
+	// MaxRetries of failed export request, retryable are network
+	// errors and 429, 502, 503 and 504 responses.
+	MaxRetries int
+	// QueueSize is number of records buffered for export, records
+	// are dropped when queue is full.
+	QueueSize int
+	// Client used for export requests, http.DefaultClient when nil.
+	Client *http.Client
+}
+
+// OTLPHandler exports log records to OpenTelemetry collector using
+// OTLP/HTTP JSON protocol. Records are batched and exported in background,
+// Close flushes pending records.
+type OTLPHandler struct {
+	exp    *otlpExporter
+	attrs  []otlpKeyValue
+	prefix string
+}
+
+// NewOTLPHandler returns handler exporting records to OTLP collector.
+func NewOTLPHandler(cnf OTLPConfig) (*OTLPHandler, error) {
+	if cnf.Endpoint == "" {
+		return nil, fmt.Errorf("%w: endpoint is empty", ErrOTLP)
+	}
+	endpoint := strings.TrimSuffix(cnf.Endpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("%w: endpoint must be http or https url got %q", ErrOTLP, cnf.Endpoint)
+	}
+	if !strings.Contains(strings.SplitN(endpoint, "://", 2)[1], "/") {
+		endpoint += otlpLogsPath
+	}
+	if cnf.BatchSize <= 0 {
+		cnf.BatchSize = otlpDefaultBatchSize
+	}
+	if cnf.FlushInterval <= 0 {
+		cnf.FlushInterval = otlpDefaultFlushInterval
+	}
+	if cnf.Timeout <= 0 {
+		cnf.Timeout = otlpDefaultTimeout
+	}
+	if cnf.MaxRetries < 0 {
+		cnf.MaxRetries = 0
+	} else if cnf.MaxRetries == 0 {
+		cnf.MaxRetries = otlpDefaultMaxRetries
+	}
+	if cnf.QueueSize <= 0 {
+		cnf.QueueSize = otlpDefaultQueueSize
+	}
+	if cnf.Client == nil {
+		cnf.Client = http.DefaultClient
+	}
+	exp := &otlpExporter{
+		cnf:      cnf,
+		endpoint: endpoint,
+		queue:    make(chan otlpLogRecord, cnf.QueueSize),
+		flush:    make(chan chan struct{}),
+		done:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go exp.run()
+	return &OTLPHandler{exp: exp}, nil
+}
+
+// Enabled reports whether the handler handles records at the given level.
+func (h *OTLPHandler) Enabled(level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.exp.cnf.Level != nil {
+		minLevel = h.exp.cnf.Level.Level()
+	}
+	return level >= minLevel
+}
+
+// Handle queues record for export, record is dropped
+// when queue is full or handler is closed.
+func (h *OTLPHandler) Handle(r slog.Record) error {
+	if !h.Enabled(r.Level) {
+		return nil
+	}
+	rec := otlpLogRecord{
+		TimeUnixNano:   strconv.FormatInt(r.Time.UnixNano(), 10),
+		SeverityNumber: otlpSeverity(r.Level),
+		SeverityText:   Level(r.Level).String(),
+		Body:           otlpAnyValue{StringValue: &r.Message},
+		Attributes:     append([]otlpKeyValue(nil), h.attrs...),
+	}
+	r.Attrs(func(a slog.Attr) {
+		rec.Attributes = appendOTLPAttr(rec.Attributes, h.prefix, a)
+	})
+	return h.exp.enqueue(rec)
+}
+
+func (h *OTLPHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &OTLPHandler{
+		exp:    h.exp,
+		attrs:  append([]otlpKeyValue(nil), h.attrs...),
+		prefix: h.prefix,
+	}
+	for _, a := range attrs {
+		h2.attrs = appendOTLPAttr(h2.attrs, h.prefix, a)
+	}
+	return h2
+}
+
+func (h *OTLPHandler) WithGroup(name string) slog.Handler {
+	return &OTLPHandler{
+		exp:    h.exp,
+		attrs:  h.attrs,
+		prefix: h.prefix + name + ".",
+	}
+}
+
+// Flush exports queued records and waits until export completes.
+func (h *OTLPHandler) Flush() {
+	h.exp.sync()
+}
+
+// Close flushes pending records and stops the exporter.
+func (h *OTLPHandler) Close() error {
+	h.exp.close()
+	return nil
+}
+
+// Dropped returns number of records dropped because queue was full
+// or export failed after retries.
+func (h *OTLPHandler) Dropped() int64 {
+	h.exp.mu.Lock()
+	defer h.exp.mu.Unlock()
+	return h.exp.dropped
+}
+
+type otlpExporter struct {
+	cnf      OTLPConfig
+	endpoint string
+	queue    chan otlpLogRecord
+	flush    chan chan struct{}
+	done     chan struct{}
+	stopped  chan struct{}
+
+	mu      sync.Mutex
+	closed  bool
+	dropped int64
+}
+
+func (e *otlpExporter) enqueue(rec otlpLogRecord) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return fmt.Errorf("%w: handler is closed", ErrOTLP)
+	}
+	select {
+	case e.queue <- rec:
+		return nil
+	default:
+		e.dropped++
+		return nil
+	}
+}
+
+func (e *otlpExporter) sync() {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+		<-ack
+	case <-e.stopped:
+	}
+}
+
+func (e *otlpExporter) close() {
+	e.mu.Lock()
+	if e.closed {
+		e.mu.Unlock()
+		<-e.stopped
+		return
+	}
+	e.closed = true
+	e.mu.Unlock()
+	close(e.done)
+	<-e.stopped
+}
+
+func (e *otlpExporter) run() {
+	defer close(e.stopped)
+	ticker := time.NewTicker(e.cnf.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]otlpLogRecord, 0, e.cnf.BatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.export(batch); err != nil {
+			e.mu.Lock()
+			e.dropped += int64(len(batch))
+			e.mu.Unlock()
+		}
+		batch = batch[:0]
+	}
+	drain := func() {
+		for {
+			select {
+			case rec := <-e.queue:
+				batch = append(batch, rec)
+				if len(batch) >= e.cnf.BatchSize {
+					export()
+				}
+			default:
+				export()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case rec := <-e.queue:
+			batch = append(batch, rec)
+			if len(batch) >= e.cnf.BatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-e.flush:
+			drain()
+			close(ack)
+		case <-e.done:
+			drain()
+			return
+		}
+	}
+}
+
+// export sends batch retrying retryable failures with exponential backoff.
+func (e *otlpExporter) export(batch []otlpLogRecord) error {
+	payload := otlpLogsData{ResourceLogs: []otlpResourceLogs{{
+		Resource: otlpResource{Attributes: otlpStringAttrs(e.cnf.Resource)},
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: "github.com/mkungla/happy/pkg/hlog"},
+			LogRecords: batch,
+		}},
+	}}}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrOTLP, err.Error())
+	}
+
+	backoff := otlpRetryBackoff
+	for attempt := 0; ; attempt++ {
+		retry, err := e.send(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= e.cnf.MaxRetries {
+			return err
+		}
+		select {
+		case <-time.After(backoff):
+		case <-e.done:
+			// do not delay shutdown with backoff, try once more
+		}
+		backoff *= 2
+	}
+}
+
+func (e *otlpExporter) send(body []byte) (retry bool, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.cnf.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrOTLP, err.Error())
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.cnf.Headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.cnf.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("%w: %s", ErrOTLP, err.Error())
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		return true, fmt.Errorf("%w: collector responded %s", ErrOTLP, resp.Status)
+	}
+	return false, fmt.Errorf("%w: collector responded %s", ErrOTLP, resp.Status)
+}
+
+// otlpSeverity maps level to OTLP severity number, slog levels
+// DEBUG, INFO, WARN and ERROR map to OTLP DEBUG, INFO, WARN and ERROR.
+func otlpSeverity(level slog.Level) int {
+	sev := int(level) + 9
+	if sev < 1 {
+		return 1
+	}
+	if sev > 24 {
+		return 24
+	}
+	return sev
+}
+
+func appendOTLPAttr(attrs []otlpKeyValue, prefix string, a slog.Attr) []otlpKeyValue {
+	if a.Key == "" {
+		return attrs
+	}
+	v := a.Value.Resolve()
+	if v.Kind() == slog.GroupKind {
+		for _, ga := range v.Group() {
+			attrs = appendOTLPAttr(attrs, prefix+a.Key+".", ga)
+		}
+		return attrs
+	}
+	return append(attrs, otlpKeyValue{Key: prefix + a.Key, Value: otlpValue(v)})
+}
+
+func otlpValue(v slog.Value) otlpAnyValue {
+	switch v.Kind() {
+	case slog.BoolKind:
+		b := v.Bool()
+		return otlpAnyValue{BoolValue: &b}
+	case slog.Int64Kind:
+		i := strconv.FormatInt(v.Int64(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case slog.Uint64Kind:
+		i := strconv.FormatUint(v.Uint64(), 10)
+		return otlpAnyValue{IntValue: &i}
+	case slog.Float64Kind:
+		f := v.Float64()
+		return otlpAnyValue{DoubleValue: &f}
+	}
+	s := v.String()
+	return otlpAnyValue{StringValue: &s}
+}
+
+func otlpStringAttrs(m map[string]string) []otlpKeyValue {
+	attrs := make([]otlpKeyValue, 0, len(m))
+	for k, v := range m {
+		v := v
+		attrs = append(attrs, otlpKeyValue{Key: k, Value: otlpAnyValue{StringValue: &v}})
+	}
+	return attrs
+}
+
+// OTLP/HTTP JSON encoding of logs data.
+type (
+	otlpLogsData struct {
+		ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+	}
+	otlpResourceLogs struct {
+		Resource  otlpResource    `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpResource struct {
+		Attributes []otlpKeyValue `json:"attributes"`
+	}
+	otlpScopeLogs struct {
+		Scope      otlpScope       `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+	otlpScope struct {
+		Name string `json:"name"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber"`
+		SeverityText   string         `json:"severityText"`
+		Body           otlpAnyValue   `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+	}
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		IntValue    *string  `json:"intValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+)
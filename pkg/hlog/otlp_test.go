@@ -0,0 +1,113 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestOTLPHandler(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests int
+		records  []otlpLogRecord
+		resource []otlpKeyValue
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Authorization") != "token" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// first request fails with retryable status
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var data otlpLogsData
+		if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for _, rl := range data.ResourceLogs {
+			resource = rl.Resource.Attributes
+			for _, sl := range rl.ScopeLogs {
+				records = append(records, sl.LogRecords...)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	h, err := NewOTLPHandler(OTLPConfig{
+		Endpoint:      srv.URL,
+		Headers:       map[string]string{"Authorization": "token"},
+		Resource:      map[string]string{"service.name": "test"},
+		FlushInterval: time.Hour,
+		Level:         slog.Level(LevelDebug),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := New(h).WithGroup("req").With("id", 7)
+	l.Debug("debug")
+	l.Warn("warn", "ok", true)
+	New(h).SystemDebug("filtered")
+	if err := h.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if requests != 2 {
+		t.Errorf("expected retry, got %d requests", requests)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records got %d", len(records))
+	}
+	if len(resource) != 1 || resource[0].Key != "service.name" {
+		t.Errorf("unexpected resource %+v", resource)
+	}
+	warn := records[1]
+	if *warn.Body.StringValue != "warn" || warn.SeverityNumber != 13 || warn.SeverityText != "warn" {
+		t.Errorf("unexpected record %+v", warn)
+	}
+	if len(warn.Attributes) != 2 || warn.Attributes[0].Key != "req.id" || *warn.Attributes[0].Value.IntValue != "7" ||
+		warn.Attributes[1].Key != "req.ok" || !*warn.Attributes[1].Value.BoolValue {
+		t.Errorf("unexpected attributes %+v", warn.Attributes)
+	}
+	if h.Dropped() != 0 {
+		t.Errorf("expected no dropped records got %d", h.Dropped())
+	}
+}
+
+func TestOTLPSeverity(t *testing.T) {
+	for _, test := range []struct {
+		in   Level
+		want int
+	}{
+		{LevelSystemDebug, 4},
+		{LevelDebug, 5},
+		{LevelInfo, 9},
+		{LevelWarn, 13},
+		{LevelError, 17},
+		{LevelAll, 1},
+	} {
+		if got := otlpSeverity(slog.Level(test.in)); got != test.want {
+			t.Errorf("%s: got %d want %d", test.in, got, test.want)
+		}
+	}
+	if _, err := NewOTLPHandler(OTLPConfig{Endpoint: "localhost:4318"}); err == nil {
+		t.Error("expected error for endpoint without scheme")
+	}
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// RedactedValue replaces redacted values.
+const RedactedValue = "*****"
+
+// DefaultRedactKeys are attribute key patterns redacted by NewRedactor.
+var DefaultRedactKeys = []string{
+	"password", "passwd", "token", "secret", "secret.*", "*.secret",
+	"*.password", "*.token", "api_key", "apikey", "authorization",
+}
+
+// Redactor masks sensitive values before records reach handlers.
+// Values of attributes whose key (including group prefix e.g. db.password)
+// matches key pattern are replaced with RedactedValue, parts of string
+// values and messages matching registered detectors are masked.
+type Redactor struct {
+	mu        sync.RWMutex
+	keys      []string
+	detectors []*regexp.Regexp
+}
+
+// NewRedactor returns Redactor with DefaultRedactKeys and given
+// additional key patterns, patterns are matched case insensitively
+// with path.Match syntax e.g. secret.* or *_token.
+func NewRedactor(keys ...string) (*Redactor, error) {
+	r := &Redactor{}
+	if err := r.AddKeys(DefaultRedactKeys...); err != nil {
+		return nil, err
+	}
+	if err := r.AddKeys(keys...); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// AddKeys adds attribute key patterns to redact.
+func (r *Redactor) AddKeys(patterns ...string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range patterns {
+		p = strings.ToLower(strings.TrimSpace(p))
+		if p == "" {
+			continue
+		}
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid redact key pattern %q: %w", p, err)
+		}
+		r.keys = append(r.keys, p)
+	}
+	return nil
+}
+
+// AddDetector registers detector, parts of messages and string
+// values matching re are masked e.g. bearer tokens or card numbers.
+func (r *Redactor) AddDetector(re *regexp.Regexp) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.detectors = append(r.detectors, re)
+}
+
+// Handler wraps h so that records are redacted before reaching h.
+func (r *Redactor) Handler(h slog.Handler) slog.Handler {
+	return &redactHandler{h: h, r: r}
+}
+
+// RedactString returns value of key redacted same way as attribute
+// values of log records e.g. when printing configuration.
+func (r *Redactor) RedactString(key, value string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.sensitiveKey(key) {
+		return RedactedValue
+	}
+	value, _ = r.mask(value)
+	return value
+}
+
+func (r *Redactor) sensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, p := range r.keys {
+		if ok, _ := path.Match(p, key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) mask(s string) (string, bool) {
+	masked := false
+	for _, re := range r.detectors {
+		if re.MatchString(s) {
+			s = re.ReplaceAllLiteralString(s, RedactedValue)
+			masked = true
+		}
+	}
+	return s, masked
+}
+
+// redact returns redacted attr and reports whether it was changed.
+func (r *Redactor) redact(prefix string, a slog.Attr) (slog.Attr, bool) {
+	if r.sensitiveKey(prefix + a.Key) {
+		return slog.String(a.Key, RedactedValue), true
+	}
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.GroupKind:
+		group := v.Group()
+		var (
+			attrs   []slog.Attr
+			changed bool
+		)
+		for i, ga := range group {
+			ra, ok := r.redact(prefix+a.Key+".", ga)
+			if ok && !changed {
+				changed = true
+				attrs = append(attrs, group[:i]...)
+			}
+			if changed {
+				attrs = append(attrs, ra)
+			}
+		}
+		if changed {
+			return slog.Group(a.Key, attrs...), true
+		}
+	case slog.StringKind, slog.AnyKind:
+		if s, ok := r.mask(v.String()); ok {
+			return slog.String(a.Key, s), true
+		}
+	}
+	return a, false
+}
+
+type redactHandler struct {
+	h      slog.Handler
+	r      *Redactor
+	prefix string
+}
+
+func (h *redactHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+// Handle passes record through unchanged when nothing is redacted,
+// otherwise redacted copy of record is passed without source location.
+func (h *redactHandler) Handle(r slog.Record) error {
+	h.r.mu.RLock()
+	msg, changed := h.r.mask(r.Message)
+	var attrs []slog.Attr
+	r.Attrs(func(a slog.Attr) {
+		ra, ok := h.r.redact(h.prefix, a)
+		changed = changed || ok
+		attrs = append(attrs, ra)
+	})
+	h.r.mu.RUnlock()
+	if !changed {
+		return h.h.Handle(r)
+	}
+	r2 := slog.NewRecord(r.Time, r.Level, msg, 0, r.Context)
+	r2.AddAttrs(attrs...)
+	return h.h.Handle(r2)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h.r.mu.RLock()
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i], _ = h.r.redact(h.prefix, a)
+	}
+	h.r.mu.RUnlock()
+	return &redactHandler{h: h.h.WithAttrs(redacted), r: h.r, prefix: h.prefix}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{h: h.h.WithGroup(name), r: h.r, prefix: h.prefix + name + "."}
+}
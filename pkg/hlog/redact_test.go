@@ -0,0 +1,67 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestRedactor(t *testing.T) {
+	var buf bytes.Buffer
+	r, err := NewRedactor("db.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddDetector(regexp.MustCompile(`Bearer [A-Za-z0-9.]+`))
+	logger := New(r.Handler(Config{JSON: true}.NewHandler(&buf)))
+
+	logger.With("Password", "hunter2").WithGroup("db").Info(
+		"auth header Bearer abc.def",
+		slog.String("dsn", "postgres://u:p@h"),
+	)
+	logger.Info("plain",
+		slog.Group("secret", slog.String("value", "s3cr3t")),
+		slog.String("user", "john"),
+		slog.String("header", "Bearer xyz"),
+	)
+
+	out := buf.String()
+	for _, leaked := range []string{"hunter2", "abc.def", "postgres://", "s3cr3t", "xyz"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("%s leaked in %q", leaked, out)
+		}
+	}
+	for _, want := range []string{"auth header " + RedactedValue, `"user":"john"`, `"plain"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %q", want, out)
+		}
+	}
+	if _, err := NewRedactor("[invalid"); err == nil {
+		t.Error("expected error for invalid key pattern")
+	}
+}
+
+func TestRedactString(t *testing.T) {
+	r, err := NewRedactor("db.*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.AddDetector(regexp.MustCompile(`Bearer [A-Za-z0-9.]+`))
+	for _, tt := range []struct{ key, value, want string }{
+		{"db.dsn", "postgres://u:p@h", RedactedValue},
+		{"app.token", "t0k3n", RedactedValue},
+		{"header", "Bearer abc.def", RedactedValue},
+		{"user", "john", "john"},
+	} {
+		if got := r.RedactString(tt.key, tt.value); got != tt.want {
+			t.Errorf("RedactString(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// RingRecord is log record kept by Ring.
+type RingRecord struct {
+	Time    time.Time `json:"time"`
+	Level   Level     `json:"level"`
+	Message string    `json:"msg"`
+	// Attrs are record attributes, keys of attributes
+	// in groups are prefixed with group name e.g. http.status.
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// Ring keeps last records logged through its handler, e.g. to attach
+// recent logs to crash reports.
+type Ring struct {
+	mu      sync.Mutex
+	records []RingRecord
+	next    int
+	full    bool
+}
+
+// NewRing returns Ring keeping last size records.
+func NewRing(size int) *Ring {
+	if size < 1 {
+		size = 1
+	}
+	return &Ring{records: make([]RingRecord, size)}
+}
+
+// Handler returns handler adding records of all levels to the ring.
+func (r *Ring) Handler() slog.Handler {
+	return &ringHandler{r: r}
+}
+
+// Records returns kept records, oldest first.
+func (r *Ring) Records() []RingRecord {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.full {
+		return append([]RingRecord(nil), r.records[:r.next]...)
+	}
+	records := make([]RingRecord, 0, len(r.records))
+	records = append(records, r.records[r.next:]...)
+	return append(records, r.records[:r.next]...)
+}
+
+func (r *Ring) add(rec RingRecord) {
+	r.mu.Lock()
+	r.records[r.next] = rec
+	r.next++
+	if r.next == len(r.records) {
+		r.next = 0
+		r.full = true
+	}
+	r.mu.Unlock()
+}
+
+type ringHandler struct {
+	r     *Ring
+	group string
+	attrs map[string]string
+}
+
+func (h *ringHandler) Enabled(level slog.Level) bool {
+	return true
+}
+
+func (h *ringHandler) Handle(r slog.Record) error {
+	rec := RingRecord{
+		Time:    r.Time,
+		Level:   Level(r.Level),
+		Message: r.Message,
+	}
+	if len(h.attrs) > 0 || r.NumAttrs() > 0 {
+		rec.Attrs = make(map[string]string, len(h.attrs)+r.NumAttrs())
+		for k, v := range h.attrs {
+			rec.Attrs[k] = v
+		}
+		r.Attrs(func(a slog.Attr) {
+			ringAttr(rec.Attrs, h.group, a)
+		})
+	}
+	h.r.add(rec)
+	return nil
+}
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &ringHandler{r: h.r, group: h.group, attrs: make(map[string]string, len(h.attrs)+len(attrs))}
+	for k, v := range h.attrs {
+		h2.attrs[k] = v
+	}
+	for _, a := range attrs {
+		ringAttr(h2.attrs, h.group, a)
+	}
+	return h2
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	return &ringHandler{r: h.r, group: h.group + name + ".", attrs: h.attrs}
+}
+
+func ringAttr(attrs map[string]string, prefix string, a slog.Attr) {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.GroupKind {
+		for _, ga := range v.Group() {
+			ringAttr(attrs, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	attrs[prefix+a.Key] = v.String()
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"errors"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestRing(t *testing.T) {
+	ring := NewRing(3)
+	logger := New(ring.Handler())
+	logger.Info("first")
+	if records := ring.Records(); len(records) != 1 || records[0].Message != "first" {
+		t.Fatalf("expected first record got %v", records)
+	}
+
+	logger.With("service", "cache").Info("second")
+	logger.WithGroup("req").Warn("third", slog.Int("status", 500), slog.Group("user", slog.String("id", "u1")))
+	logger.Error("fourth", errors.New("boom"))
+
+	records := ring.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records got %d", len(records))
+	}
+	for i, msg := range []string{"second", "third", "fourth"} {
+		if records[i].Message != msg {
+			t.Errorf("record %d: expected %q got %q", i, msg, records[i].Message)
+		}
+	}
+	if got := records[0].Attrs["service"]; got != "cache" {
+		t.Errorf("expected service attr cache got %q", got)
+	}
+	if got := records[1].Attrs["req.status"]; got != "500" {
+		t.Errorf("expected req.status attr 500 got %q", got)
+	}
+	if got := records[1].Attrs["req.user.id"]; got != "u1" {
+		t.Errorf("expected req.user.id attr u1 got %q", got)
+	}
+	if records[2].Level != LevelError {
+		t.Errorf("expected error level got %s", records[2].Level)
+	}
+}
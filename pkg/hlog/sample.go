@@ -0,0 +1,163 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// maxSampleKeys is number of tracked messages after which
+// expired entries are pruned.
+const maxSampleKeys = 1024
+
+// SamplerConfig configures Sampler.
+type SamplerConfig struct {
+	// Window during which identical messages are deduplicated,
+	// 0 disables deduplication.
+	Window time.Duration
+	// Burst is number of identical messages passed within Window,
+	// defaults to 1.
+	Burst int
+	// DebugRate passes every DebugRate'th debug and lower level
+	// record, 0 or 1 passes all records.
+	DebugRate int
+}
+
+// Sampler protects log sinks from error storms. Records with same
+// level and message are passed Burst times within Window and rest of
+// them are suppressed, number of suppressed records is logged with
+// attribute repeated=N when message occurs after window has passed
+// or Flush is called. High frequency debug logs are sampled with DebugRate.
+type Sampler struct {
+	cnf    SamplerConfig
+	mu     sync.Mutex
+	seen   map[string]*sampleState
+	debugN atomic.Uint64
+}
+
+type sampleState struct {
+	start      time.Time
+	count      int
+	suppressed int
+	level      slog.Level
+	msg        string
+	h          slog.Handler
+}
+
+// NewSampler returns Sampler.
+func NewSampler(cnf SamplerConfig) *Sampler {
+	if cnf.Burst < 1 {
+		cnf.Burst = 1
+	}
+	return &Sampler{cnf: cnf, seen: make(map[string]*sampleState)}
+}
+
+// Handler wraps h so that records are sampled before reaching h.
+func (s *Sampler) Handler(h slog.Handler) slog.Handler {
+	return &sampledHandler{h: h, s: s}
+}
+
+// Flush logs number of suppressed records for all messages.
+func (s *Sampler) Flush() {
+	s.mu.Lock()
+	var pending []sampleState
+	for key, st := range s.seen {
+		if st.suppressed > 0 {
+			pending = append(pending, *st)
+		}
+		delete(s.seen, key)
+	}
+	s.mu.Unlock()
+	for _, st := range pending {
+		st.report()
+	}
+}
+
+// allow reports whether record should be passed, summary
+// is not nil when suppressed records should be reported first.
+func (s *Sampler) allow(h slog.Handler, r slog.Record) (ok bool, summary *sampleState) {
+	if s.cnf.DebugRate > 1 && r.Level < slog.LevelInfo {
+		if (s.debugN.Add(1)-1)%uint64(s.cnf.DebugRate) != 0 {
+			return false, nil
+		}
+	}
+	if s.cnf.Window <= 0 {
+		return true, nil
+	}
+	now := r.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	key := r.Level.String() + ":" + r.Message
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, found := s.seen[key]
+	if found && now.Sub(st.start) < s.cnf.Window {
+		st.count++
+		if st.count <= s.cnf.Burst {
+			return true, nil
+		}
+		st.suppressed++
+		st.h = h
+		return false, nil
+	}
+	if found && st.suppressed > 0 {
+		prev := *st
+		summary = &prev
+	}
+	if !found && len(s.seen) >= maxSampleKeys {
+		s.prune(now)
+	}
+	s.seen[key] = &sampleState{start: now, count: 1, level: r.Level, msg: r.Message}
+	return true, summary
+}
+
+// prune removes expired entries without suppressed records.
+func (s *Sampler) prune(now time.Time) {
+	for key, st := range s.seen {
+		if st.suppressed == 0 && now.Sub(st.start) >= s.cnf.Window {
+			delete(s.seen, key)
+		}
+	}
+}
+
+func (st sampleState) report() {
+	r := slog.NewRecord(time.Now(), st.level, st.msg, 0, nil)
+	r.AddAttrs(slog.Int("repeated", st.suppressed))
+	_ = st.h.Handle(r)
+}
+
+type sampledHandler struct {
+	h slog.Handler
+	s *Sampler
+}
+
+func (h *sampledHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(level)
+}
+
+func (h *sampledHandler) Handle(r slog.Record) error {
+	ok, summary := h.s.allow(h.h, r)
+	if summary != nil {
+		summary.report()
+	}
+	if !ok {
+		return nil
+	}
+	return h.h.Handle(r)
+}
+
+func (h *sampledHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &sampledHandler{h: h.h.WithAttrs(attrs), s: h.s}
+}
+
+func (h *sampledHandler) WithGroup(name string) slog.Handler {
+	return &sampledHandler{h: h.h.WithGroup(name), s: h.s}
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestSamplerDedup(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSampler(SamplerConfig{Window: time.Hour, Burst: 2})
+	logger := New(s.Handler(Config{}.NewHandler(&buf)))
+
+	for i := 0; i < 10; i++ {
+		logger.Info("storm")
+	}
+	logger.Info("other")
+	if got := strings.Count(buf.String(), "storm"); got != 2 {
+		t.Errorf("expected 2 storm messages before flush, got %d: %q", got, buf.String())
+	}
+	s.Flush()
+	out := buf.String()
+	if !strings.Contains(out, "repeated=8") {
+		t.Errorf("missing repeated=8 in %q", out)
+	}
+	if !strings.Contains(out, "other") {
+		t.Errorf("missing other in %q", out)
+	}
+}
+
+func TestSamplerDebugRate(t *testing.T) {
+	var buf bytes.Buffer
+	s := NewSampler(SamplerConfig{DebugRate: 5})
+	logger := New(s.Handler(Config{
+		Options: slog.HandlerOptions{Level: slog.Level(LevelAll)},
+	}.NewHandler(&buf)))
+
+	for i := 0; i < 20; i++ {
+		logger.Debug("tick")
+		logger.Warn("storm-warning")
+	}
+	out := buf.String()
+	if got := strings.Count(out, "tick"); got != 4 {
+		t.Errorf("expected 4 sampled debug messages, got %d", got)
+	}
+	if got := strings.Count(out, "storm-warning"); got != 20 {
+		t.Errorf("expected all 20 warnings, got %d", got)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// LevelAll is lowest possible level, handlers wrapped with
+// ScopeLevels.Handler should use it to leave filtering to wrapper.
+const LevelAll Level = math.MinInt
+
+// ScopeLevels holds log level overrides for logger scopes. Scope is
+// attribute added to logger with With, e.g. logger.With("service", "cache")
+// logs at debug level with override service=cache:debug while other
+// loggers log at base level. Overrides can be changed at runtime.
+type ScopeLevels struct {
+	mu    sync.RWMutex
+	base  slog.Leveler
+	rules map[string]slog.Level
+}
+
+// NewScopeLevels returns ScopeLevels using base level
+// for loggers without matching override.
+func NewScopeLevels(base slog.Leveler) *ScopeLevels {
+	return &ScopeLevels{base: base}
+}
+
+// Set replaces all overrides with overrides from spec in form
+// key=value:level separated by commas e.g. "service=cache:debug,addon=db:warn".
+// Empty spec removes all overrides.
+func (s *ScopeLevels) Set(spec string) error {
+	rules, err := ParseScopeLevels(spec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = rules
+	return nil
+}
+
+// SetLevel sets override for single scope.
+func (s *ScopeLevels) SetLevel(key, value string, level Level) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.rules == nil {
+		s.rules = make(map[string]slog.Level)
+	}
+	s.rules[key+"="+value] = slog.Level(level)
+}
+
+// String returns overrides in format accepted by Set.
+func (s *ScopeLevels) String() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var rules []string
+	for scope, lvl := range s.rules {
+		rules = append(rules, scope+":"+Level(lvl).String())
+	}
+	sort.Strings(rules)
+	return strings.Join(rules, ",")
+}
+
+// Handler wraps h so that records are filtered by level of the
+// logger scope. Only scopes added with WithAttrs are considered
+// since record attributes are not known when level is checked.
+func (s *ScopeLevels) Handler(h slog.Handler) slog.Handler {
+	return &scopedHandler{h: h, levels: s}
+}
+
+// level returns lowest override matching any of the scopes or base level.
+func (s *ScopeLevels) level(scopes []string) slog.Level {
+	lvl := s.base.Level()
+	if len(scopes) == 0 {
+		return lvl
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	matched := false
+	for _, scope := range scopes {
+		if l, ok := s.rules[scope]; ok && (!matched || l < lvl) {
+			lvl = l
+			matched = true
+		}
+	}
+	return lvl
+}
+
+// ParseScopeLevels parses level overrides in format accepted by ScopeLevels.Set.
+func ParseScopeLevels(spec string) (map[string]slog.Level, error) {
+	rules := make(map[string]slog.Level)
+	for _, rule := range strings.Split(spec, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		scope, lvlstr, ok := strings.Cut(rule, ":")
+		key, value, hasValue := strings.Cut(scope, "=")
+		if !ok || !hasValue || key == "" || value == "" {
+			return nil, fmt.Errorf("invalid scope level %q, expected key=value:level", rule)
+		}
+		lvl, err := ParseLevel(lvlstr)
+		if err != nil {
+			return nil, err
+		}
+		rules[strings.TrimSpace(key)+"="+strings.TrimSpace(value)] = slog.Level(lvl)
+	}
+	return rules, nil
+}
+
+type scopedHandler struct {
+	h      slog.Handler
+	levels *ScopeLevels
+	group  string
+	scopes []string
+}
+
+func (h *scopedHandler) Enabled(level slog.Level) bool {
+	return level >= h.levels.level(h.scopes) && h.h.Enabled(level)
+}
+
+func (h *scopedHandler) Handle(r slog.Record) error {
+	if r.Level < h.levels.level(h.scopes) {
+		return nil
+	}
+	return h.h.Handle(r)
+}
+
+func (h *scopedHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := &scopedHandler{
+		h:      h.h.WithAttrs(attrs),
+		levels: h.levels,
+		group:  h.group,
+		scopes: append([]string(nil), h.scopes...),
+	}
+	for _, attr := range attrs {
+		h2.scopes = appendScopes(h2.scopes, h.group, attr)
+	}
+	return h2
+}
+
+func (h *scopedHandler) WithGroup(name string) slog.Handler {
+	return &scopedHandler{
+		h:      h.h.WithGroup(name),
+		levels: h.levels,
+		group:  h.group + name + ".",
+		scopes: h.scopes,
+	}
+}
+
+func appendScopes(scopes []string, prefix string, attr slog.Attr) []string {
+	v := attr.Value.Resolve()
+	if v.Kind() == slog.GroupKind {
+		for _, a := range v.Group() {
+			scopes = appendScopes(scopes, prefix+attr.Key+".", a)
+		}
+		return scopes
+	}
+	return append(scopes, prefix+attr.Key+"="+v.String())
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package hlog
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestScopeLevels(t *testing.T) {
+	var buf bytes.Buffer
+	base := &slog.LevelVar{}
+	levels := NewScopeLevels(base)
+	if err := levels.Set("service=cache:debug"); err != nil {
+		t.Fatal(err)
+	}
+	root := New(levels.Handler(Config{
+		Options: slog.HandlerOptions{Level: slog.Level(LevelAll)},
+	}.NewHandler(&buf)))
+	cache := root.With("service", "cache")
+	db := root.With("service", "db")
+
+	cache.Debug("cache-debug")
+	db.Debug("db-debug")
+	root.Debug("root-debug")
+	db.Info("db-info")
+
+	out := buf.String()
+	for _, want := range []string{"cache-debug", "db-info"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %q", want, out)
+		}
+	}
+	for _, unwanted := range []string{"db-debug", "root-debug"} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("unexpected %s in %q", unwanted, out)
+		}
+	}
+
+	// runtime change
+	buf.Reset()
+	if err := levels.Set("service=cache:warn"); err != nil {
+		t.Fatal(err)
+	}
+	cache.Info("cache-info")
+	if buf.Len() > 0 {
+		t.Errorf("expected no output got %q", buf.String())
+	}
+	if got := levels.String(); got != "service=cache:warn" {
+		t.Errorf("got %q", got)
+	}
+
+	// groups are part of scope key
+	levels.SetLevel("addon.name", "db", LevelDebug)
+	root.WithGroup("addon").With("name", "db").Debug("addon-debug")
+	if !strings.Contains(buf.String(), "addon-debug") {
+		t.Errorf("missing grouped scope output %q", buf.String())
+	}
+}
+
+func TestParseScopeLevels(t *testing.T) {
+	rules, err := ParseScopeLevels("service=cache:debug, addon=db:warn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules["addon=db"] != slog.Level(LevelWarn) {
+		t.Errorf("unexpected rules %v", rules)
+	}
+	for _, spec := range []string{"service:debug", "service=cache", "=x:debug", "service=cache:loud"} {
+		if _, err := ParseScopeLevels(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build go1.21
+
+package hlog
+
+import (
+	"context"
+	stdslog "log/slog"
+
+	"golang.org/x/exp/slog"
+)
+
+// StdLogger returns standard library *slog.Logger writing to
+// handler of l, for libraries which accept log/slog logger.
+func (l *Logger) StdLogger() *stdslog.Logger {
+	return stdslog.New(NewStdHandler(l.Handler()))
+}
+
+// NewStdHandler adapts h to log/slog Handler. Source location
+// of records is not passed to h.
+func NewStdHandler(h slog.Handler) stdslog.Handler {
+	if fh, ok := h.(*fromStdHandler); ok {
+		return fh.h
+	}
+	return &stdHandler{h: h}
+}
+
+// FromStdHandler adapts log/slog Handler h so that it can be used
+// with hlog e.g. hlog.New(hlog.FromStdHandler(h)). Source location
+// of records is not passed to h.
+func FromStdHandler(h stdslog.Handler) slog.Handler {
+	if sh, ok := h.(*stdHandler); ok {
+		return sh.h
+	}
+	return &fromStdHandler{h: h}
+}
+
+type stdHandler struct {
+	h slog.Handler
+}
+
+func (h *stdHandler) Enabled(_ context.Context, level stdslog.Level) bool {
+	return h.h.Enabled(slog.Level(level))
+}
+
+func (h *stdHandler) Handle(ctx context.Context, r stdslog.Record) error {
+	rec := slog.NewRecord(r.Time, slog.Level(r.Level), r.Message, 0, ctx)
+	r.Attrs(func(a stdslog.Attr) bool {
+		rec.AddAttrs(fromStdAttr(a))
+		return true
+	})
+	return h.h.Handle(rec)
+}
+
+func (h *stdHandler) WithAttrs(attrs []stdslog.Attr) stdslog.Handler {
+	as := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		as[i] = fromStdAttr(a)
+	}
+	return &stdHandler{h: h.h.WithAttrs(as)}
+}
+
+func (h *stdHandler) WithGroup(name string) stdslog.Handler {
+	return &stdHandler{h: h.h.WithGroup(name)}
+}
+
+type fromStdHandler struct {
+	h stdslog.Handler
+}
+
+func (h *fromStdHandler) Enabled(level slog.Level) bool {
+	return h.h.Enabled(context.Background(), stdslog.Level(level))
+}
+
+func (h *fromStdHandler) Handle(r slog.Record) error {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	rec := stdslog.NewRecord(r.Time, stdslog.Level(r.Level), r.Message, 0)
+	r.Attrs(func(a slog.Attr) {
+		rec.AddAttrs(toStdAttr(a))
+	})
+	return h.h.Handle(ctx, rec)
+}
+
+func (h *fromStdHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	as := make([]stdslog.Attr, len(attrs))
+	for i, a := range attrs {
+		as[i] = toStdAttr(a)
+	}
+	return &fromStdHandler{h: h.h.WithAttrs(as)}
+}
+
+func (h *fromStdHandler) WithGroup(name string) slog.Handler {
+	return &fromStdHandler{h: h.h.WithGroup(name)}
+}
+
+func toStdAttr(a slog.Attr) stdslog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.StringKind:
+		return stdslog.String(a.Key, v.String())
+	case slog.Int64Kind:
+		return stdslog.Int64(a.Key, v.Int64())
+	case slog.Uint64Kind:
+		return stdslog.Uint64(a.Key, v.Uint64())
+	case slog.Float64Kind:
+		return stdslog.Float64(a.Key, v.Float64())
+	case slog.BoolKind:
+		return stdslog.Bool(a.Key, v.Bool())
+	case slog.DurationKind:
+		return stdslog.Duration(a.Key, v.Duration())
+	case slog.TimeKind:
+		return stdslog.Time(a.Key, v.Time())
+	case slog.GroupKind:
+		group := v.Group()
+		as := make([]any, len(group))
+		for i, ga := range group {
+			as[i] = toStdAttr(ga)
+		}
+		return stdslog.Group(a.Key, as...)
+	default:
+		return stdslog.Any(a.Key, v.Any())
+	}
+}
+
+func fromStdAttr(a stdslog.Attr) slog.Attr {
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case stdslog.KindString:
+		return slog.String(a.Key, v.String())
+	case stdslog.KindInt64:
+		return slog.Int64(a.Key, v.Int64())
+	case stdslog.KindUint64:
+		return slog.Uint64(a.Key, v.Uint64())
+	case stdslog.KindFloat64:
+		return slog.Float64(a.Key, v.Float64())
+	case stdslog.KindBool:
+		return slog.Bool(a.Key, v.Bool())
+	case stdslog.KindDuration:
+		return slog.Duration(a.Key, v.Duration())
+	case stdslog.KindTime:
+		return slog.Time(a.Key, v.Time())
+	case stdslog.KindGroup:
+		group := v.Group()
+		as := make([]slog.Attr, len(group))
+		for i, ga := range group {
+			as[i] = fromStdAttr(ga)
+		}
+		return slog.Group(a.Key, as...)
+	default:
+		return slog.Any(a.Key, v.Any())
+	}
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build go1.21
+
+package hlog
+
+import (
+	"bytes"
+	"context"
+	stdslog "log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+func TestStdLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(Config{JSON: true}.NewHandler(&buf))
+	std := logger.StdLogger().With("service", "cache").WithGroup("req")
+	std.Warn("slow", "took", time.Second, stdslog.Group("user", "id", 7))
+
+	out := buf.String()
+	for _, want := range []string{
+		`"level":"warn"`, `"msg":"slow"`, `"service":"cache"`,
+		`"req":{"took":1000000000,"user":{"id":7}}`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %s in %q", want, out)
+		}
+	}
+	if std.Enabled(context.Background(), stdslog.LevelDebug) {
+		t.Error("debug should not be enabled")
+	}
+}
+
+func TestFromStdHandler(t *testing.T) {
+	var buf bytes.Buffer
+	std := stdslog.NewTextHandler(&buf, &stdslog.HandlerOptions{Level: stdslog.LevelDebug})
+	logger := New(FromStdHandler(std)).With("service", "cache")
+	logger.Debug("miss", slog.Group("key", slog.String("name", "users")), slog.Bool("hit", false))
+
+	if got := buf.String(); !strings.Contains(got, `level=DEBUG msg=miss service=cache key.name=users hit=false`) {
+		t.Errorf("unexpected output %q", got)
+	}
+	if NewStdHandler(FromStdHandler(std)) != stdslog.Handler(std) {
+		t.Error("adapting adapted handler back should unwrap it")
+	}
+}
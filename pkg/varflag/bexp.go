@@ -75,5 +75,7 @@ func (f *BexpFlag) Unset() {
 
 	f.variable, _ = vars.New(f.name, strings.Join(defaults, "|"), false)
 	f.isPresent = false
+	f.parsed = false
+	f.in = nil
 	f.val = defaults
 }
@@ -67,5 +67,7 @@ func (f *BoolFlag) Unset() {
 	defer f.mu.Unlock()
 	f.variable = f.defval
 	f.isPresent = false
+	f.parsed = false
+	f.in = nil
 	f.val = f.variable.Bool()
 }
@@ -194,6 +194,8 @@ func (f *Common) Unset() {
 		f.variable, _ = vars.EmptyNamedVariable(f.name)
 	}
 	f.isPresent = false
+	f.parsed = false
+	f.in = nil
 }
 
 // Present reports whether flag was set in commandline.
@@ -153,6 +153,24 @@ func (s *FlagSet) Sets() []Flags {
 	return s.sets
 }
 
+// Reset unsets all flags and sub sets so that
+// flag set can be parsed again e.g. in interactive shell.
+func (s *FlagSet) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, flag := range s.flags {
+		flag.Unset()
+	}
+	for _, set := range s.sets {
+		if r, ok := set.(interface{ Reset() }); ok {
+			r.Reset()
+		}
+	}
+	s.present = false
+	s.args = nil
+	s.pos = 0
+}
+
 // Parse all flags recursively.
 func (s *FlagSet) Parse(args []string) error {
 	s.mu.Lock()
@@ -6,6 +6,7 @@ package varflag
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/mkungla/happy/sdk/testutils"
@@ -192,3 +193,31 @@ func TestShaddowFlags(t *testing.T) {
 		t.Error("f2 value should val2 got", f2.Value())
 	}
 }
+
+func TestFlagSetReset(t *testing.T) {
+	global, err := NewFlagSet(filepath.Base(os.Args[0]), 0)
+	testutils.NoError(t, err)
+	v, _ := Bool("verbose", false, "increase verbosity", "v")
+	global.Add(v)
+	cmd, err := NewFlagSet("cmd", -1)
+	testutils.NoError(t, err)
+	name, _ := New("name", "default", "name flag")
+	env, _ := Option("env", []string{"dev"}, []string{"dev", "prod"}, "environment")
+	cmd.Add(name, env)
+	global.AddSet(cmd)
+
+	testutils.NoError(t, global.Parse([]string{os.Args[0], "cmd", "--name", "first", "--env", "prod", "-v", "arg"}))
+	testutils.Equal(t, "first", name.String())
+	testutils.Equal(t, 1, len(cmd.Args()))
+
+	global.Reset()
+	testutils.False(t, v.Present(), "verbose should be unset")
+	testutils.False(t, cmd.Present(), "cmd should not be present after reset")
+	testutils.Equal(t, "default", name.String())
+
+	testutils.NoError(t, global.Parse([]string{os.Args[0], "cmd", "--name", "second"}))
+	testutils.Equal(t, "second", name.String())
+	testutils.Equal(t, "dev", env.String())
+	testutils.False(t, v.Present(), "verbose should not be present")
+	testutils.Equal(t, 0, len(cmd.Args()))
+}
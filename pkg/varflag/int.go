@@ -70,5 +70,7 @@ func (f *IntFlag) Unset() {
 	defer f.mu.Unlock()
 	f.variable = f.defval
 	f.isPresent = false
+	f.parsed = false
+	f.in = nil
 	f.val = f.variable.Int()
 }
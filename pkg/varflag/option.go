@@ -53,6 +53,17 @@ func OptionFunc(name string, value []string, opts []string, usage string, aliase
 	}
 }
 
+// Unset the option flag value so that flag can be parsed again.
+func (f *OptionFlag) Unset() {
+	f.Common.Unset()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for opt := range f.opts {
+		f.opts[opt] = false
+	}
+	f.val = nil
+}
+
 // Parse the OptionFlag.
 func (f *OptionFlag) Parse(args []string) (ok bool, err error) {
 	if f.parsed {
@@ -117,3 +128,15 @@ func (f *OptionFlag) Value() []string {
 	defer f.mu.RUnlock()
 	return f.val
 }
+
+// Options returns sorted list of options this flag accepts.
+func (f *OptionFlag) Options() []string {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	opts := make([]string, 0, len(f.opts))
+	for o := range f.opts {
+		opts = append(opts, o)
+	}
+	sort.Strings(opts)
+	return opts
+}
@@ -71,5 +71,7 @@ func (f *UintFlag) Unset() {
 	defer f.mu.Unlock()
 	f.variable = f.defval
 	f.isPresent = false
+	f.parsed = false
+	f.in = nil
 	f.val = f.variable.Uint()
 }
@@ -87,3 +87,85 @@ func Prerelease(v string) string {
 func IsDev(v string) bool {
 	return Prerelease(v) == PRE
 }
+
+// Constraint is set of version requirements e.g. ">=v1.2.0, <v2"
+// which version must satisfy. Supported operators are =, !=, >,
+// >=, <, <=, ^ (same major version) and ~ (same minor version),
+// version without operator must match exactly.
+type Constraint struct {
+	raw  string
+	reqs []requirement
+}
+
+type requirement struct {
+	op string
+	v  string
+}
+
+// ParseConstraint parses comma separated version requirements,
+// empty string is constraint which any version satisfies.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{raw: strings.TrimSpace(s)}
+	if c.raw == "" {
+		return c, nil
+	}
+	for _, part := range strings.Split(c.raw, ",") {
+		part = strings.TrimSpace(part)
+		op := strings.TrimRight(part[:len(part)-len(strings.TrimLeft(part, "=!<>^~"))], " ")
+		switch op {
+		case "", "=", "!=", ">", ">=", "<", "<=", "^", "~":
+		default:
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: unknown operator %s", s, op)
+		}
+		v := strings.TrimSpace(part[len(op):])
+		if !strings.HasPrefix(v, "v") {
+			v = "v" + v
+		}
+		if !semver.IsValid(v) {
+			return Constraint{}, fmt.Errorf("invalid version constraint %q: invalid version %s", s, v)
+		}
+		if op == "" {
+			op = "="
+		}
+		c.reqs = append(c.reqs, requirement{op: op, v: v})
+	}
+	return c, nil
+}
+
+// Check reports whether v satisfies all requirements of the constraint.
+func (c Constraint) Check(v Version) bool {
+	s := v.String()
+	if !semver.IsValid(s) {
+		return len(c.reqs) == 0
+	}
+	for _, req := range c.reqs {
+		cmp := semver.Compare(s, req.v)
+		var ok bool
+		switch req.op {
+		case "=":
+			ok = cmp == 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case ">=":
+			ok = cmp >= 0
+		case "<":
+			ok = cmp < 0
+		case "<=":
+			ok = cmp <= 0
+		case "^":
+			ok = cmp >= 0 && semver.Major(s) == semver.Major(req.v)
+		case "~":
+			ok = cmp >= 0 && semver.MajorMinor(s) == semver.MajorMinor(req.v)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (c Constraint) String() string {
+	return c.raw
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+// profileConfigPrefix is prefix of config file options which apply
+// only when named profile is active e.g. profiles.prod.log.level.
+const profileConfigPrefix = "profiles."
+
+// Profile registers named set of option overrides applied when the
+// profile is selected with --profile flag e.g.
+//
+//	app.Profile("prod", happy.Option("log.level", "warn"))
+//
+// Profile options override options provided to New and persisted
+// settings, while config files, environment and flags override profile
+// options. Config files can also set options for a profile in
+// profiles.<name> section. Active profile is available with
+// Session.Profile.
+func (a *Application) Profile(name string, opts ...OptionArg) {
+	if a.profiles == nil {
+		a.profiles = make(map[string][]OptionArg)
+	}
+	a.profiles[name] = append(a.profiles[name], opts...)
+}
+
+// applyProfile records active profile in the session and
+// applies options registered for it.
+func (a *Application) applyProfile() error {
+	a.session.mu.Lock()
+	a.session.profile = a.profile
+	a.session.mu.Unlock()
+
+	opts, ok := a.profiles[a.profile]
+	if !ok {
+		return nil
+	}
+	for _, opt := range opts {
+		if err := a.overrideOption(opt.key, opt.value, "profile:"+a.profile); err != nil {
+			return err
+		}
+	}
+	a.logger.SystemDebug("applied profile options",
+		slog.String("profile", a.profile),
+		slog.Int("options", len(opts)),
+	)
+	return nil
+}
+
+// profileConfigKey returns option key of config file option in
+// profiles.<name> section, ok is false for options of other profiles.
+func profileConfigKey(profile, key string) (optkey string, ok bool) {
+	name, optkey, found := strings.Cut(strings.TrimPrefix(key, profileConfigPrefix), ".")
+	if !found || name != profile {
+		return "", false
+	}
+	return optkey, true
+}
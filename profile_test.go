@@ -0,0 +1,59 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestAppProfile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.yaml")
+	testutils.NoError(t, os.WriteFile(file, []byte(`
+log:
+  format: json
+profiles:
+  prod:
+    log:
+      level: error
+  dev:
+    log:
+      level: debug
+`), 0600))
+
+	app := New(Option("log.console", false), Option("log.level", "info"))
+	app.Profile("prod", Option("log.level", "warn"), Option("cache.ttl", 60))
+	app.Profile("dev", Option("log.level", "debug"))
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "--profile", "prod", "--config", file}))
+	app.profile = app.rootCmd.flag("profile").Var().String()
+
+	testutils.NoError(t, app.applyProfile())
+	testutils.Equal(t, "prod", app.session.Profile())
+	testutils.Equal(t, "warn", app.session.Get("log.level").String())
+	testutils.Equal(t, "profile:prod", app.session.OptionSource("log.level"))
+	testutils.Equal(t, "profile:prod", app.session.OptionSource("cache.ttl"))
+
+	// profile section in config file overrides profile registered in code
+	testutils.NoError(t, app.loadConfig())
+	testutils.Equal(t, "error", app.session.Get("log.level").String())
+	testutils.Equal(t, "json", app.session.Get("log.format").String())
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("log.level"))
+	for _, opt := range app.pendingOpts {
+		testutils.False(t, opt.key == "profiles.dev.log.level", "options of other profiles should be ignored")
+	}
+}
+
+func TestProfileConfigKey(t *testing.T) {
+	key, ok := profileConfigKey("prod", "profiles.prod.log.level")
+	testutils.True(t, ok, "prod profile key")
+	testutils.Equal(t, "log.level", key)
+	_, ok = profileConfigKey("prod", "profiles.dev.log.level")
+	testutils.False(t, ok, "dev profile key")
+	_, ok = profileConfigKey("prod", "profiles.prod")
+	testutils.False(t, ok, "missing option key")
+}
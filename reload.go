@@ -0,0 +1,119 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// reload re-reads config files and environment and applies changed
+// options to the session. Event config.changed is dispatched for each
+// changed option and running services which opted in with
+// Service.RestartOnReload are restarted. Options in app.* namespace,
+// except log.*, are read once on startup and changing them requires
+// restart of the application. Options removed from config keep their
+// current value.
+func (a *Application) reload() (changed []string) {
+	entries, err := a.readConfig()
+	if err != nil {
+		a.logger.Error("reload failed, keeping current config", err)
+		return nil
+	}
+	// environment overrides config files
+	latest := make(map[string]configEntry)
+	for _, entry := range append(entries, a.readEnv()...) {
+		latest[entry.key] = entry
+	}
+	keys := make([]string, 0, len(latest))
+	for key := range latest {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var reconfigure bool
+	for _, key := range keys {
+		entry := latest[key]
+		if !a.session.Has(key) {
+			continue
+		}
+		prev := a.session.Get(key)
+		next, err := vars.NewValue(entry.value)
+		if err != nil || prev.String() == next.String() {
+			continue
+		}
+		if strings.HasPrefix(key, "app.") {
+			a.logger.Warn("option changed, restart required to apply it",
+				slog.String("key", key),
+				slog.String("source", entry.source),
+			)
+			continue
+		}
+		if err := a.session.opts.set(key, entry.value, true); err != nil {
+			a.logger.Error("failed to reload option", err, slog.String("key", key))
+			continue
+		}
+		a.session.setOptionSource(key, entry.source)
+		changed = append(changed, key)
+		if strings.HasPrefix(key, "log.") {
+			reconfigure = true
+		}
+
+		payload := new(vars.Map)
+		_ = payload.Store("key", key)
+		_ = payload.Store("value", next.Any())
+		_ = payload.Store("previous", prev.Any())
+		_ = payload.Store("source", entry.source)
+		a.session.Dispatch(NewEvent("config", "changed", payload, nil))
+	}
+	if reconfigure {
+		a.configureLogger()
+	}
+	a.logger.Info("config reloaded", slog.Int("changed", len(changed)))
+	if len(changed) > 0 && a.engine != nil {
+		a.engine.restartOnReload(a.session, changed)
+	}
+	return changed
+}
+
+// reloadKeyMatch reports whether changed option key matches pattern,
+// pattern can be option key or glob e.g. "log.*" or "myaddon.*".
+func reloadKeyMatch(pattern, key string) bool {
+	if pattern == key {
+		return true
+	}
+	if !strings.ContainsAny(pattern, "*?[") {
+		return false
+	}
+	matched, err := path.Match(pattern, key)
+	return err == nil && matched
+}
+
+// restartOnReload restarts running services which opted into
+// restart on reload and watch any of changed options.
+func (e *Engine) restartOnReload(sess *Session, changed []string) {
+	e.mu.RLock()
+	var restart []string
+	for svcurl, svcc := range e.registry {
+		if svcc.info.Running() && svcc.svc.reloadsOn(changed) {
+			restart = append(restart, svcurl)
+		}
+	}
+	e.mu.RUnlock()
+	sort.Strings(restart)
+
+	for _, svcurl := range restart {
+		sess.Log().Info("restarting service after reload", slog.String("service", svcurl))
+		if err := e.stopService(sess, svcurl, nil); err != nil {
+			sess.Log().Error("failed to stop service for reload", err, slog.String("service", svcurl))
+			continue
+		}
+		e.serviceStart(sess, svcurl)
+	}
+}
@@ -0,0 +1,12 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix
+
+package happy
+
+// handleReloadSignal is noop on platforms without SIGHUP.
+func handleReloadSignal(a *Application) (stop func()) {
+	return func() {}
+}
@@ -0,0 +1,122 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestServiceReloadsOn(t *testing.T) {
+	svc := NewService("test")
+	testutils.False(t, svc.reloadsOn([]string{"log.level"}), "service did not opt in")
+
+	svc.RestartOnReload()
+	testutils.True(t, svc.reloadsOn([]string{"cache.ttl"}), "restarts on any change")
+	testutils.False(t, svc.reloadsOn(nil), "nothing changed")
+
+	svc = NewService("test")
+	svc.RestartOnReload("log.*", "cache.ttl")
+	testutils.True(t, svc.reloadsOn([]string{"log.level"}), "log.* should match log.level")
+	testutils.True(t, svc.reloadsOn([]string{"cache.ttl"}), "cache.ttl should match")
+	testutils.False(t, svc.reloadsOn([]string{"cache.size", "app.name"}), "no matching keys")
+}
+
+func TestAppReload(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "app.toml")
+	write := func(data string) {
+		testutils.NoError(t, os.WriteFile(file, []byte(data), 0600))
+	}
+	write(`
+[log]
+level = "info"
+
+[cache]
+ttl = 10
+`)
+
+	app := New(Option("log.console", false))
+	addon := NewAddon("cache")
+	addon.Setting("ttl", 1, "ttl in seconds", nil)
+	app.WithAddons(addon)
+	testutils.NoError(t, app.rootCmd.flags.Parse([]string{"app", "--config", file}))
+	testutils.NoError(t, app.loadConfig())
+	app.activeCmd = app.rootCmd
+	testutils.NoError(t, app.applySettings())
+	testutils.NoError(t, app.registerAddons())
+	testutils.Equal(t, 10, app.session.Get("cache.ttl").Int())
+	app.session.evch = make(chan Event, 10)
+
+	testutils.Equal(t, 0, len(app.reload()))
+
+	write(`
+[log]
+level = "debug"
+
+[app]
+name = "changed"
+
+[cache]
+ttl = 30
+`)
+	changed := app.reload()
+	testutils.EqualAny(t, []string{"cache.ttl", "log.level"}, changed)
+	testutils.Equal(t, 30, app.session.Get("cache.ttl").Int())
+	testutils.Equal(t, "debug", app.session.Get("log.level").String())
+	testutils.NotEqual(t, "changed", app.session.Get("app.name").String())
+	testutils.Equal(t, "file:"+file, app.session.OptionSource("cache.ttl"))
+
+	ev := <-app.session.evch
+	testutils.Equal(t, "config", ev.Scope())
+	testutils.Equal(t, "changed", ev.Key())
+	testutils.Equal(t, "cache.ttl", ev.Payload().Get("key").String())
+	testutils.Equal(t, 10, ev.Payload().Get("previous").Int())
+	testutils.Equal(t, 30, ev.Payload().Get("value").Int())
+	ev = <-app.session.evch
+	testutils.Equal(t, "log.level", ev.Payload().Get("key").String())
+}
+
+func TestEngineRestartOnReload(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	var cancel context.CancelFunc
+	e.ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	starts := make(map[string]int)
+	for _, name := range []string{"watching", "other", "any"} {
+		name := name
+		svc := NewService(name)
+		svc.OnStart(func(sess *Session) error {
+			starts[name]++
+			return nil
+		})
+		switch name {
+		case "watching":
+			svc.RestartOnReload("cache.*")
+		case "other":
+			svc.RestartOnReload("log.level")
+		case "any":
+			svc.RestartOnReload()
+		}
+		addr, err := address.Parse("happy://host/app/service/" + name)
+		testutils.NoError(t, err)
+		e.registry[addr.String()] = svc.container(sess, addr)
+		e.serviceStart(sess, addr.String())
+	}
+
+	e.restartOnReload(sess, []string{"cache.ttl"})
+	testutils.Equal(t, 2, starts["watching"])
+	testutils.Equal(t, 1, starts["other"])
+	testutils.Equal(t, 2, starts["any"])
+	for _, svcc := range e.registry {
+		testutils.True(t, svcc.info.Running(), "service should be running")
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handleReloadSignal reloads config of the application on SIGHUP until
+// returned stop func is called, unless SIGHUP handler was registered
+// with Application.OnSignal.
+func handleReloadSignal(a *Application) (stop func()) {
+	sig := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case s := <-sig:
+				if a.hasSignalHandler(s) {
+					continue
+				}
+				a.logger.Notice("received SIGHUP, reloading config")
+				a.reload()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sig)
+		close(done)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// ScheduledEvent is event scheduled with Session.DispatchAt
+// or Session.DispatchAfter.
+type ScheduledEvent struct {
+	at    time.Time
+	seq   uint64
+	ev    Event
+	index int
+	sched *eventScheduler
+}
+
+// At returns time when event is dispatched.
+func (s *ScheduledEvent) At() time.Time {
+	return s.at
+}
+
+// Event returns scheduled event.
+func (s *ScheduledEvent) Event() Event {
+	return s.ev
+}
+
+// Cancel cancels the scheduled event and reports whether it was
+// cancelled before it was dispatched.
+func (s *ScheduledEvent) Cancel() bool {
+	if s == nil || s.sched == nil {
+		return false
+	}
+	return s.sched.cancel(s)
+}
+
+type scheduledEvents []*ScheduledEvent
+
+func (q scheduledEvents) Len() int { return len(q) }
+func (q scheduledEvents) Less(i, j int) bool {
+	if q[i].at.Equal(q[j].at) {
+		return q[i].seq < q[j].seq
+	}
+	return q[i].at.Before(q[j].at)
+}
+func (q scheduledEvents) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+func (q *scheduledEvents) Push(x any) {
+	item := x.(*ScheduledEvent)
+	item.index = len(*q)
+	*q = append(*q, item)
+}
+func (q *scheduledEvents) Pop() any {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	item.index = -1
+	*q = old[:n-1]
+	return item
+}
+
+// eventScheduler holds events scheduled for later dispatch.
+type eventScheduler struct {
+	mu    sync.Mutex
+	queue scheduledEvents
+	seq   uint64
+	wake  chan struct{}
+}
+
+func newEventScheduler() *eventScheduler {
+	return &eventScheduler{
+		wake: make(chan struct{}, 1),
+	}
+}
+
+func (s *eventScheduler) schedule(at time.Time, ev Event) *ScheduledEvent {
+	s.mu.Lock()
+	s.seq++
+	item := &ScheduledEvent{
+		at:    at,
+		seq:   s.seq,
+		ev:    ev,
+		sched: s,
+	}
+	heap.Push(&s.queue, item)
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return item
+}
+
+func (s *eventScheduler) cancel(item *ScheduledEvent) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if item.index < 0 || item.index >= len(s.queue) || s.queue[item.index] != item {
+		return false
+	}
+	heap.Remove(&s.queue, item.index)
+	return true
+}
+
+// due removes and returns events scheduled at or before now
+// and time of next scheduled event.
+func (s *eventScheduler) due(now time.Time) (events []Event, next time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.queue) > 0 && !s.queue[0].at.After(now) {
+		events = append(events, heap.Pop(&s.queue).(*ScheduledEvent).ev)
+	}
+	if len(s.queue) > 0 {
+		next = s.queue[0].at
+	}
+	return events, next
+}
+
+// run dispatches scheduled events when they are due until ctx is done.
+func (s *eventScheduler) run(ctx context.Context, sess *Session) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	for {
+		events, next := s.due(time.Now())
+		for _, ev := range events {
+			sess.Dispatch(ev)
+		}
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(wait)
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
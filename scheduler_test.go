@@ -0,0 +1,52 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEventScheduler(t *testing.T) {
+	sched := newEventScheduler()
+	now := time.Now()
+	sched.schedule(now.Add(2*time.Second), NewEvent("app", "third", nil, nil))
+	sched.schedule(now.Add(time.Second), NewEvent("app", "first", nil, nil))
+	sched.schedule(now.Add(time.Second), NewEvent("app", "second", nil, nil))
+	canceled := sched.schedule(now.Add(time.Second), NewEvent("app", "canceled", nil, nil))
+
+	testutils.True(t, canceled.Cancel(), "scheduled event should be cancelled")
+	testutils.False(t, canceled.Cancel(), "event can be cancelled only once")
+
+	events, next := sched.due(now)
+	testutils.Equal(t, 0, len(events))
+	testutils.Equal(t, now.Add(time.Second), next)
+
+	events, next = sched.due(now.Add(2 * time.Second))
+	var keys []string
+	for _, ev := range events {
+		keys = append(keys, ev.Key())
+	}
+	testutils.EqualAny(t, []string{"first", "second", "third"}, keys)
+	testutils.True(t, next.IsZero(), "no events should be left")
+}
+
+func TestSessionDispatchAfter(t *testing.T) {
+	sess := newTestSession(t)
+	testutils.True(t, sess.DispatchAfter(time.Millisecond, NewEvent("app", "none", nil, nil)) == nil,
+		"scheduling without engine should fail")
+
+	sess.engine = newEngine()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go sess.engine.scheduler.run(ctx, sess)
+
+	sess.DispatchAfter(time.Hour, NewEvent("app", "later", nil, nil)).Cancel()
+	sess.DispatchAfter(5*time.Millisecond, NewEvent("app", "soon", nil, nil))
+	testutils.Equal(t, "soon", (<-sess.evch).Key())
+}
@@ -0,0 +1,218 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+// EventSchema describes event and payload fields it carries.
+type EventSchema struct {
+	Scope       string
+	Key         string
+	Description string
+	Fields      []EventField
+}
+
+// EventField describes single payload field of the event.
+// Kind vars.KindInvalid accepts value of any kind.
+type EventField struct {
+	Name        string
+	Kind        vars.Kind
+	Required    bool
+	Description string
+}
+
+// Validate reports error when payload is missing required field
+// or field is not of expected kind.
+func (schema EventSchema) Validate(payload *vars.Map) error {
+	var errs []error
+	for _, field := range schema.Fields {
+		if payload == nil || !payload.Has(field.Name) {
+			if field.Required {
+				errs = append(errs, fmt.Errorf("%w: %s.%s missing required payload field %s",
+					ErrEngine, schema.Scope, schema.Key, field.Name))
+			}
+			continue
+		}
+		v := payload.Get(field.Name)
+		if field.Kind != vars.KindInvalid && v.Kind() != field.Kind {
+			errs = append(errs, fmt.Errorf("%w: %s.%s payload field %s must be %s got %s",
+				ErrEngine, schema.Scope, schema.Key, field.Name, field.Kind, v.Kind()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (schema EventSchema) event() Event {
+	example := new(vars.Map)
+	for _, field := range schema.Fields {
+		example.Store(field.Name, field.Kind.String())
+	}
+	return registerEvent(schema.Scope, schema.Key, schema.Description, example)
+}
+
+// schemaFromEvent describes registered event which has no explicit schema
+// using its example payload.
+func schemaFromEvent(ev Event) EventSchema {
+	schema := EventSchema{
+		Scope: ev.Scope(),
+		Key:   ev.Key(),
+	}
+	if payload := ev.Payload(); payload != nil {
+		payload.Range(func(v vars.Variable) bool {
+			if v.Name() == "happy.app.event.description" {
+				schema.Description = v.String()
+				return true
+			}
+			schema.Fields = append(schema.Fields, EventField{
+				Name: v.Name(),
+				Kind: v.Kind(),
+			})
+			return true
+		})
+	}
+	sort.Slice(schema.Fields, func(i, j int) bool {
+		return schema.Fields[i].Name < schema.Fields[j].Name
+	})
+	return schema
+}
+
+// WriteEventsMarkdown writes markdown documentation of given events.
+func WriteEventsMarkdown(w io.Writer, schemas []EventSchema) error {
+	var b strings.Builder
+	b.WriteString("# Events\n")
+	for _, schema := range schemas {
+		fmt.Fprintf(&b, "\n## %s.%s\n", schema.Scope, schema.Key)
+		if schema.Description != "" {
+			fmt.Fprintf(&b, "\n%s\n", schema.Description)
+		}
+		if len(schema.Fields) == 0 {
+			continue
+		}
+		b.WriteString("\n| field | kind | required | description |\n")
+		b.WriteString("|-------|------|----------|-------------|\n")
+		for _, field := range schema.Fields {
+			kind := "any"
+			if field.Kind != vars.KindInvalid {
+				kind = field.Kind.String()
+			}
+			fmt.Fprintf(&b, "| %s | %s | %t | %s |\n", field.Name, kind, field.Required, field.Description)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// EventInfo describes event in application event catalog
+// with addons which emit or listen to it.
+type EventInfo struct {
+	EventSchema
+	// EmittedBy are names of addons declaring that they emit the event.
+	EmittedBy []string
+	// ListenedBy are names of addons listening to the event,
+	// listener patterns which do not match any registered event
+	// are listed as events with pattern as scope and key.
+	ListenedBy []string
+}
+
+// Events returns catalog of registered events sorted by scope and key
+// with addons emitting and listening to them, so that integration
+// points of the application can be discovered.
+func (a *Application) Events() []EventInfo {
+	var infos []EventInfo
+	index := make(map[string]int)
+	for _, schema := range a.engine.EventSchemas() {
+		index[schema.Scope+"."+schema.Key] = len(infos)
+		infos = append(infos, EventInfo{EventSchema: schema})
+	}
+	for _, addon := range a.addons {
+		name := addon.info.Name
+		emits := make(map[string]bool)
+		for _, ev := range addon.events {
+			emits[ev.Scope()+"."+ev.Key()] = true
+		}
+		for _, schema := range addon.schemas {
+			emits[schema.Scope+"."+schema.Key] = true
+		}
+		for id := range emits {
+			if i, ok := index[id]; ok {
+				infos[i].EmittedBy = append(infos[i].EmittedBy, name)
+			}
+		}
+		for _, lid := range addon.listenPatterns() {
+			matched := false
+			for i := range infos {
+				if eventPatternMatch(lid, infos[i].Scope, infos[i].Key) {
+					infos[i].ListenedBy = append(infos[i].ListenedBy, name)
+					matched = true
+				}
+			}
+			if matched {
+				continue
+			}
+			if i, ok := index[lid]; ok {
+				infos[i].ListenedBy = append(infos[i].ListenedBy, name)
+				continue
+			}
+			scope, key, _ := strings.Cut(lid, ".")
+			index[lid] = len(infos)
+			infos = append(infos, EventInfo{
+				EventSchema: EventSchema{Scope: scope, Key: key},
+				ListenedBy:  []string{name},
+			})
+		}
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		if infos[i].Scope != infos[j].Scope {
+			return infos[i].Scope < infos[j].Scope
+		}
+		return infos[i].Key < infos[j].Key
+	})
+	return infos
+}
+
+// writeEventsTable writes event catalog as table.
+func writeEventsTable(w io.Writer, infos []EventInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "EVENT\tEMITTED BY\tLISTENED BY\tDESCRIPTION")
+	list := func(names []string) string {
+		if len(names) == 0 {
+			return "-"
+		}
+		return strings.Join(names, ",")
+	}
+	for _, info := range infos {
+		fmt.Fprintf(tw, "%s.%s\t%s\t%s\t%s\n",
+			info.Scope, info.Key, list(info.EmittedBy), list(info.ListenedBy), info.Description)
+	}
+	return tw.Flush()
+}
+
+// eventsCommand is command listing event catalog of the application
+// e.g. "app events list".
+func eventsCommand(a *Application) *Command {
+	cmd := NewCommand(
+		"events",
+		Option("usage", "inspect events of the application"),
+	)
+	list := NewCommand(
+		"list",
+		Option("usage", "list events with addons emitting and listening to them"),
+	)
+	list.Do(func(sess *Session, args Args) error {
+		return writeEventsTable(os.Stdout, a.Events())
+	})
+	cmd.AddSubCommand(list)
+	return cmd
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEventSchemaValidate(t *testing.T) {
+	schema := EventSchema{
+		Scope:       "app",
+		Key:         "user.created",
+		Description: "triggered when user is created",
+		Fields: []EventField{
+			{Name: "id", Kind: vars.KindInt, Required: true},
+			{Name: "name", Kind: vars.KindString},
+			{Name: "meta"},
+		},
+	}
+
+	valid := new(vars.Map)
+	testutils.NoError(t, valid.Store("id", 1))
+	testutils.NoError(t, valid.Store("meta", true))
+	testutils.NoError(t, schema.Validate(valid))
+
+	testutils.ErrorIs(t, schema.Validate(nil), ErrEngine)
+	testutils.ErrorIs(t, schema.Validate(new(vars.Map)), ErrEngine)
+
+	invalid := new(vars.Map)
+	testutils.NoError(t, invalid.Store("id", 1))
+	testutils.NoError(t, invalid.Store("name", 2))
+	testutils.ErrorIs(t, schema.Validate(invalid), ErrEngine)
+}
+
+func TestEngineEventSchemas(t *testing.T) {
+	e := newEngine()
+	testutils.NoError(t, e.registerEventSchema(EventSchema{
+		Scope:  "app",
+		Key:    "user.created",
+		Fields: []EventField{{Name: "id", Kind: vars.KindInt, Required: true}},
+	}))
+	testutils.NoError(t, e.registerEvent(registerEvent("app", "config.changed", "config was changed", nil)))
+	testutils.Error(t, e.registerEventSchema(EventSchema{Scope: "app", Key: "user.created"}))
+
+	schemas := e.EventSchemas()
+	testutils.Equal(t, 2, len(schemas))
+	testutils.Equal(t, "config.changed", schemas[0].Key)
+	testutils.Equal(t, "config was changed", schemas[0].Description)
+	testutils.Equal(t, 0, len(schemas[0].Fields))
+	testutils.Equal(t, "user.created", schemas[1].Key)
+
+	var doc strings.Builder
+	testutils.NoError(t, WriteEventsMarkdown(&doc, schemas))
+	testutils.True(t, strings.Contains(doc.String(), "## app.user.created"))
+	testutils.True(t, strings.Contains(doc.String(), "| id | int | true |  |"))
+}
+
+func TestEngineSchemaReplacesListenerPlaceholder(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+
+	listener := NewService("listener")
+	listener.OnEvent("jobs", "done", func(sess *Session, ev Event) error { return nil })
+	emitter := NewService("emitter")
+	emitter.EmitsSchema(EventSchema{
+		Scope:  "jobs",
+		Key:    "done",
+		Fields: []EventField{{Name: "id", Kind: vars.KindInt, Required: true}},
+	})
+	laddr, err := address.Parse("happy://host/app/service/listener")
+	testutils.NoError(t, err)
+	eaddr, err := address.Parse("happy://host/app/service/emitter")
+	testutils.NoError(t, err)
+
+	// listener service is initialized before emitter service
+	var init sync.WaitGroup
+	e.registry = map[string]*serviceContainer{laddr.String(): listener.container(sess, laddr)}
+	e.servicesInit(sess, &init)
+	init.Wait()
+	e.registry = map[string]*serviceContainer{eaddr.String(): emitter.container(sess, eaddr)}
+	e.servicesInit(sess, &init)
+	init.Wait()
+
+	schemas := e.EventSchemas()
+	testutils.Equal(t, 1, len(schemas))
+	testutils.Equal(t, "done", schemas[0].Key)
+	testutils.Equal(t, 1, len(schemas[0].Fields), "emitter schema should replace listener placeholder")
+	testutils.Error(t, e.registerEventSchema(EventSchema{Scope: "jobs", Key: "done"}), "emitted event should not be registered twice")
+}
+
+func TestAppEvents(t *testing.T) {
+	app := New()
+	app.activeCmd = app.rootCmd
+
+	jobs := NewAddon("jobs")
+	jobs.EmitsSchema(EventSchema{Scope: "jobs", Key: "done", Description: "job finished"})
+	jobs.Emits("jobs", "failed", "job failed", nil)
+	notify := NewAddon("notify")
+	svc := NewService("notifier")
+	svc.OnEvent("jobs", "*", func(sess *Session, ev Event) error { return nil })
+	notify.ProvidesService(svc)
+	notify.ListensTo("billing", "invoice.paid")
+	app.WithAddons(jobs, notify)
+	testutils.NoError(t, app.registerAddons())
+
+	events := make(map[string]EventInfo)
+	for _, info := range app.Events() {
+		events[info.Scope+"."+info.Key] = info
+	}
+	done := events["jobs.done"]
+	testutils.Equal(t, "job finished", done.Description)
+	testutils.Equal(t, "jobs", strings.Join(done.EmittedBy, ","))
+	testutils.Equal(t, "notify", strings.Join(done.ListenedBy, ","))
+	testutils.Equal(t, "notify", strings.Join(events["jobs.failed"].ListenedBy, ","))
+	paid, ok := events["billing.invoice.paid"]
+	testutils.True(t, ok, "listened event without emitter should be in catalog")
+	testutils.Equal(t, 0, len(paid.EmittedBy))
+
+	var buf bytes.Buffer
+	testutils.NoError(t, writeEventsTable(&buf, app.Events()))
+	testutils.True(t, strings.Contains(buf.String(), "jobs.done"), buf.String())
+}
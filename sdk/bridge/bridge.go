@@ -0,0 +1,157 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package bridge provides service which bridges events between happy
+// applications over external message broker such as NATS or Redis.
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// OriginKey is payload key holding id of the node where
+// remote event originates from.
+const OriginKey = "happy.bridge.origin"
+
+// Broker is minimal interface of message broker,
+// implement it as thin adapter on top of NATS, Redis or other client.
+type Broker interface {
+	// Publish publishes data to subject.
+	Publish(ctx context.Context, subject string, data []byte) error
+	// Subscribe calls handler for every message published to subject
+	// until ctx is done.
+	Subscribe(ctx context.Context, subject string, handler func(data []byte)) error
+}
+
+// Config configures event bridge service.
+type Config struct {
+	// Name of the service, defaults to "event-bridge".
+	Name string
+	// NodeID identifies this application instance,
+	// defaults to hostname and process id.
+	NodeID string
+	// Subject used to exchange events, defaults to "happy.events".
+	Subject string
+	// Scopes of local events published to the broker.
+	Scopes []string
+}
+
+type message struct {
+	Node    string    `json:"node"`
+	Time    time.Time `json:"time"`
+	Scope   string    `json:"scope"`
+	Key     string    `json:"key"`
+	Payload *vars.Map `json:"payload,omitempty"`
+	Err     string    `json:"err,omitempty"`
+}
+
+// Service returns service which publishes local events of configured
+// scopes to the broker and dispatches events published by other nodes
+// to the local bus. Remote events are marked with OriginKey payload
+// field and are never published back to the broker.
+func Service(broker Broker, config Config) *happy.Service {
+	if config.Name == "" {
+		config.Name = "event-bridge"
+	}
+	if config.Subject == "" {
+		config.Subject = "happy.events"
+	}
+	if config.NodeID == "" {
+		host, _ := os.Hostname()
+		config.NodeID = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+
+	svc := happy.NewService(config.Name)
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+
+	svc.OnStart(func(sess *happy.Session) error {
+		ctx, cancel = context.WithCancel(sess)
+		return broker.Subscribe(ctx, config.Subject, func(data []byte) {
+			ev, err := decode(config.NodeID, data)
+			if err != nil {
+				sess.Log().Warn("event bridge received invalid message", slog.String("err", err.Error()))
+				return
+			}
+			if ev != nil {
+				sess.Dispatch(ev)
+			}
+		})
+	})
+
+	svc.OnStop(func(sess *happy.Session) error {
+		if cancel != nil {
+			cancel()
+		}
+		return nil
+	})
+
+	for _, scope := range config.Scopes {
+		svc.OnEvent(scope, "*", func(sess *happy.Session, ev happy.Event) error {
+			if ctx == nil || isRemote(ev) {
+				return nil
+			}
+			data, err := encode(config.NodeID, ev)
+			if err != nil {
+				return err
+			}
+			return broker.Publish(ctx, config.Subject, data)
+		})
+	}
+	return svc
+}
+
+func isRemote(ev happy.Event) bool {
+	payload := ev.Payload()
+	return payload != nil && payload.Has(OriginKey)
+}
+
+func encode(node string, ev happy.Event) ([]byte, error) {
+	msg := message{
+		Node:    node,
+		Time:    ev.Time(),
+		Scope:   ev.Scope(),
+		Key:     ev.Key(),
+		Payload: ev.Payload(),
+	}
+	if everr, ok := ev.(interface{ Err() error }); ok && everr.Err() != nil {
+		msg.Err = everr.Err().Error()
+	}
+	return json.Marshal(msg)
+}
+
+// decode returns remote event or nil when message originates from node.
+func decode(node string, data []byte) (happy.Event, error) {
+	var msg message
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	if msg.Node == node {
+		return nil, nil
+	}
+	payload := msg.Payload
+	if payload == nil {
+		payload = new(vars.Map)
+	}
+	if err := payload.Store(OriginKey, msg.Node); err != nil {
+		return nil, err
+	}
+	var everr error
+	if msg.Err != "" {
+		everr = errors.New(msg.Err)
+	}
+	return happy.NewEvent(msg.Scope, msg.Key, payload, everr), nil
+}
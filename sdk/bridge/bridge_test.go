@@ -0,0 +1,34 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package bridge
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	payload := new(vars.Map)
+	payload.Store("count", 3)
+	data, err := encode("node-a", happy.NewEvent("jobs", "done", payload, errors.New("partial")))
+	testutils.NoError(t, err)
+
+	ev, err := decode("node-a", data)
+	testutils.NoError(t, err)
+	testutils.True(t, ev == nil, "own events must be ignored")
+
+	ev, err = decode("node-b", data)
+	testutils.NoError(t, err)
+	testutils.Equal(t, "jobs", ev.Scope())
+	testutils.Equal(t, "done", ev.Key())
+	testutils.Equal(t, 3, ev.Payload().Get("count").Int())
+	testutils.Equal(t, "node-a", ev.Payload().Get(OriginKey).String())
+	testutils.True(t, isRemote(ev), "decoded event must be marked as remote")
+	testutils.False(t, isRemote(happy.NewEvent("jobs", "done", payload, nil)), "local event is not remote")
+}
@@ -3,6 +3,9 @@
 // See the LICENSE file.
 
 // Package cli provides utilities for happy command line interfaces.
+//
+// Questions of prompts and wizards can be message keys, they are
+// translated with Session.T and printed as is when not found in catalog.
 package cli
 
 import (
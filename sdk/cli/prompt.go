@@ -0,0 +1,282 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mkungla/happy"
+)
+
+var (
+	ErrPrompt         = errors.New("prompt error")
+	ErrNonInteractive = fmt.Errorf("%w: session is not interactive", ErrPrompt)
+)
+
+// prompter reads user input for prompts. It is package level so that
+// consecutive prompts share buffered input.
+type prompter struct {
+	mu   sync.Mutex
+	in   *bufio.Reader
+	out  io.Writer
+	echo func(on bool)
+}
+
+var prompts = &prompter{
+	in:   bufio.NewReader(os.Stdin),
+	out:  os.Stdout,
+	echo: ttyEcho,
+}
+
+// ttyEcho toggles terminal echo when stdin is a terminal.
+func ttyEcho(on bool) {
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return
+	}
+	arg := "-echo"
+	if on {
+		arg = "echo"
+	}
+	cmd := exec.Command("stty", arg)
+	cmd.Stdin = os.Stdin
+	_ = cmd.Run()
+}
+
+func (p *prompter) readLine(sess *happy.Session) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	res := make(chan result, 1)
+	go func() {
+		line, err := p.in.ReadString('\n')
+		if errors.Is(err, io.EOF) && line != "" {
+			err = nil
+		}
+		res <- result{strings.TrimSpace(line), err}
+	}()
+	select {
+	case <-sess.Done():
+		return "", fmt.Errorf("%w: %s", ErrPrompt, sess.Err())
+	case r := <-res:
+		if r.err != nil {
+			return "", fmt.Errorf("%w: %s", ErrPrompt, r.err)
+		}
+		return r.line, nil
+	}
+}
+
+// ask prints question and reads answers until validate accepts one.
+func (p *prompter) ask(sess *happy.Session, question string, validate func(string) error) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		fmt.Fprint(p.out, question)
+		line, err := p.readLine(sess)
+		if err != nil {
+			return "", err
+		}
+		if validate == nil {
+			return line, nil
+		}
+		if err := validate(line); err != nil {
+			fmt.Fprintln(p.out, err.Error())
+			continue
+		}
+		return line, nil
+	}
+}
+
+// Confirm asks yes or no question. When session is not interactive
+// def is returned.
+func Confirm(sess *happy.Session, question string, def bool) (bool, error) {
+	question = sess.T(question)
+	if !sess.Interactive() {
+		return def, nil
+	}
+	hint := "[y/N]"
+	if def {
+		hint = "[Y/n]"
+	}
+	answer := def
+	_, err := prompts.ask(sess, fmt.Sprintf("%s %s: ", question, hint), func(s string) error {
+		switch strings.ToLower(s) {
+		case "":
+			answer = def
+		case "y", "yes":
+			answer = true
+		case "n", "no":
+			answer = false
+		default:
+			return errors.New(sess.T("prompt.confirm.retry"))
+		}
+		return nil
+	})
+	return answer, err
+}
+
+// Input asks for text input. Empty answer returns def. Optional validate
+// func is called until it accepts the answer. When session is not
+// interactive def is returned, or ErrNonInteractive when def is empty.
+func Input(sess *happy.Session, question, def string, validate func(string) error) (string, error) {
+	question = sess.T(question)
+	if !sess.Interactive() {
+		if def == "" {
+			return "", fmt.Errorf("%w: %s", ErrNonInteractive, question)
+		}
+		return def, nil
+	}
+	q := question + ": "
+	if def != "" {
+		q = fmt.Sprintf("%s [%s]: ", question, def)
+	}
+	answer, err := prompts.ask(sess, q, func(s string) error {
+		if s == "" && def != "" {
+			s = def
+		}
+		if validate != nil {
+			return validate(s)
+		}
+		return nil
+	})
+	if err == nil && answer == "" {
+		answer = def
+	}
+	return answer, err
+}
+
+// Password asks for secret input without echoing it to the terminal.
+// It returns ErrNonInteractive when session is not interactive.
+func Password(sess *happy.Session, question string) (string, error) {
+	question = sess.T(question)
+	if !sess.Interactive() {
+		return "", fmt.Errorf("%w: %s", ErrNonInteractive, question)
+	}
+	prompts.echo(false)
+	defer func() {
+		prompts.echo(true)
+		fmt.Fprintln(prompts.out)
+	}()
+	return prompts.ask(sess, question+": ", nil)
+}
+
+// Select asks user to choose one of the options and returns chosen option.
+// def is index of default option or -1 to require selection.
+// When session is not interactive default option is returned.
+func Select(sess *happy.Session, question string, options []string, def int) (string, error) {
+	question = sess.T(question)
+	if len(options) == 0 {
+		return "", fmt.Errorf("%w: no options to select from", ErrPrompt)
+	}
+	if def >= len(options) {
+		return "", fmt.Errorf("%w: invalid default option %d", ErrPrompt, def)
+	}
+	if !sess.Interactive() {
+		if def < 0 {
+			return "", fmt.Errorf("%w: %s", ErrNonInteractive, question)
+		}
+		return options[def], nil
+	}
+
+	q := selectQuestion(question, options)
+	if def >= 0 {
+		q += fmt.Sprintf(" [%d]", def+1)
+	}
+	selected := def
+	_, err := prompts.ask(sess, q+": ", func(s string) error {
+		if s == "" && def >= 0 {
+			selected = def
+			return nil
+		}
+		i, err := parseSelection(s, options)
+		if err != nil {
+			return err
+		}
+		selected = i
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return options[selected], nil
+}
+
+// MultiSelect asks user to choose any number of options separated by comma.
+// When session is not interactive options at defs are returned.
+func MultiSelect(sess *happy.Session, question string, options []string, defs []int) ([]string, error) {
+	question = sess.T(question)
+	if len(options) == 0 {
+		return nil, fmt.Errorf("%w: no options to select from", ErrPrompt)
+	}
+	var defaults []string
+	for _, i := range defs {
+		if i < 0 || i >= len(options) {
+			return nil, fmt.Errorf("%w: invalid default option %d", ErrPrompt, i)
+		}
+		defaults = append(defaults, options[i])
+	}
+	if !sess.Interactive() {
+		return defaults, nil
+	}
+
+	q := selectQuestion(question, options)
+	if len(defs) > 0 {
+		nums := make([]string, len(defs))
+		for i, d := range defs {
+			nums[i] = strconv.Itoa(d + 1)
+		}
+		q += " [" + strings.Join(nums, ",") + "]"
+	}
+	selected := defaults
+	_, err := prompts.ask(sess, q+": ", func(s string) error {
+		if s == "" {
+			selected = defaults
+			return nil
+		}
+		selected = nil
+		for _, sel := range strings.Split(s, ",") {
+			i, err := parseSelection(strings.TrimSpace(sel), options)
+			if err != nil {
+				return err
+			}
+			selected = append(selected, options[i])
+		}
+		return nil
+	})
+	return selected, err
+}
+
+func selectQuestion(question string, options []string) string {
+	var b strings.Builder
+	b.WriteString(question)
+	b.WriteString("\n")
+	for i, opt := range options {
+		fmt.Fprintf(&b, "  %d) %s\n", i+1, opt)
+	}
+	b.WriteString("select")
+	return b.String()
+}
+
+// parseSelection accepts option number or option value.
+func parseSelection(s string, options []string) (int, error) {
+	if i, err := strconv.Atoi(s); err == nil && i > 0 && i <= len(options) {
+		return i - 1, nil
+	}
+	for i, opt := range options {
+		if opt == s {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("invalid selection %q", s)
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func withPromptInput(t *testing.T, input string) {
+	t.Helper()
+	prev := prompts
+	prompts = &prompter{
+		in:   bufio.NewReader(strings.NewReader(input)),
+		out:  io.Discard,
+		echo: func(bool) {},
+	}
+	t.Cleanup(func() { prompts = prev })
+}
+
+func TestPrompts(t *testing.T) {
+	sess := new(happy.Session)
+	withPromptInput(t, "maybe\ny\n\nab\nabc\nsecret\nprod\n9\n2\n1, c\n")
+
+	ok, err := Confirm(sess, "continue", false)
+	testutils.NoError(t, err)
+	testutils.True(t, ok, "expected confirmation after invalid answer")
+
+	name, err := Input(sess, "name", "happy", nil)
+	testutils.NoError(t, err)
+	testutils.Equal(t, "happy", name)
+
+	name, err = Input(sess, "name", "", func(s string) error {
+		if len(s) < 3 {
+			return errors.New("too short")
+		}
+		return nil
+	})
+	testutils.NoError(t, err)
+	testutils.Equal(t, "abc", name)
+
+	pass, err := Password(sess, "password")
+	testutils.NoError(t, err)
+	testutils.Equal(t, "secret", pass)
+
+	env, err := Select(sess, "environment", []string{"dev", "prod"}, 0)
+	testutils.NoError(t, err)
+	testutils.Equal(t, "prod", env)
+
+	env, err = Select(sess, "environment", []string{"dev", "prod"}, -1)
+	testutils.NoError(t, err)
+	testutils.Equal(t, "prod", env)
+
+	opts, err := MultiSelect(sess, "features", []string{"a", "b", "c"}, nil)
+	testutils.NoError(t, err)
+	testutils.EqualAny(t, []string{"a", "c"}, opts)
+
+	_, err = Input(sess, "eof", "", nil)
+	testutils.ErrorIs(t, err, ErrPrompt)
+}
@@ -0,0 +1,276 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+var ErrWizardCanceled = fmt.Errorf("%w: wizard canceled", ErrPrompt)
+
+// WizardBack is answer which returns wizard to previous step.
+const WizardBack = "<"
+
+type wizardStepKind uint8
+
+const (
+	wizardInput wizardStepKind = iota
+	wizardPassword
+	wizardSelect
+	wizardConfirm
+)
+
+type wizardStep struct {
+	kind     wizardStepKind
+	key      string
+	question string
+	def      string
+	options  []string
+	validate func(string) error
+}
+
+// Wizard walks user through sequential prompts, answering WizardBack
+// to any prompt returns to previous step. After last step summary of
+// answers is shown and user is asked to confirm them.
+// Use NewWizard to create wizard for init or setup commands.
+type Wizard struct {
+	title string
+	steps []wizardStep
+	errs  []error
+}
+
+// NewWizard returns wizard with given title.
+func NewWizard(title string) *Wizard {
+	return &Wizard{title: title}
+}
+
+func (w *Wizard) add(step wizardStep) *Wizard {
+	if _, err := vars.ParseKey(step.key); err != nil {
+		w.errs = append(w.errs, fmt.Errorf("%w: invalid wizard step key %q", ErrPrompt, step.key))
+		return w
+	}
+	for _, s := range w.steps {
+		if s.key == step.key {
+			w.errs = append(w.errs, fmt.Errorf("%w: duplicate wizard step %q", ErrPrompt, step.key))
+			return w
+		}
+	}
+	w.steps = append(w.steps, step)
+	return w
+}
+
+// Input adds text input step, see Input.
+func (w *Wizard) Input(key, question, def string, validate func(string) error) *Wizard {
+	return w.add(wizardStep{kind: wizardInput, key: key, question: question, def: def, validate: validate})
+}
+
+// Password adds secret input step which is masked in summary.
+func (w *Wizard) Password(key, question string) *Wizard {
+	return w.add(wizardStep{kind: wizardPassword, key: key, question: question})
+}
+
+// Select adds step to choose one of the options, def is index of
+// default option or -1 to require selection.
+func (w *Wizard) Select(key, question string, options []string, def int) *Wizard {
+	step := wizardStep{kind: wizardSelect, key: key, question: question, options: options}
+	if len(options) == 0 || def >= len(options) {
+		w.errs = append(w.errs, fmt.Errorf("%w: invalid options for wizard step %q", ErrPrompt, key))
+		return w
+	}
+	if def >= 0 {
+		step.def = options[def]
+	}
+	return w.add(step)
+}
+
+// Confirm adds yes or no step, answer is stored as bool.
+func (w *Wizard) Confirm(key, question string, def bool) *Wizard {
+	return w.add(wizardStep{kind: wizardConfirm, key: key, question: question, def: strconv.FormatBool(def)})
+}
+
+// Run runs the wizard and returns confirmed answers keyed by step keys.
+// When session is not interactive defaults are used without prompting
+// and ErrNonInteractive is returned if step has no default.
+// ErrWizardCanceled is returned when user rejects the summary.
+func (w *Wizard) Run(sess *happy.Session) (*vars.Map, error) {
+	if err := errors.Join(w.errs...); err != nil {
+		return nil, err
+	}
+	answers := make([]string, len(w.steps))
+	for i, step := range w.steps {
+		answers[i] = step.def
+	}
+
+	if !sess.Interactive() {
+		for i, step := range w.steps {
+			if answers[i] == "" {
+				return nil, fmt.Errorf("%w: %s", ErrNonInteractive, step.question)
+			}
+		}
+		return w.result(answers)
+	}
+
+	if w.title != "" {
+		fmt.Fprintln(prompts.out, w.title)
+	}
+	fmt.Fprintf(prompts.out, "(answer %s to return to previous step)\n", WizardBack)
+
+	for i := 0; ; {
+		if i == len(w.steps) {
+			fmt.Fprintln(prompts.out, w.summary(answers))
+			answer, err := prompts.ask(sess, "apply? [y/n]: ", func(s string) error {
+				switch strings.ToLower(s) {
+				case "y", "yes", "n", "no", WizardBack:
+					return nil
+				}
+				return errors.New("please answer (y)es, (n)o or " + WizardBack)
+			})
+			if err != nil {
+				return nil, err
+			}
+			switch strings.ToLower(answer) {
+			case "y", "yes":
+				return w.result(answers)
+			case WizardBack:
+				i--
+				continue
+			}
+			return nil, ErrWizardCanceled
+		}
+
+		answer, err := w.ask(sess, w.steps[i], answers[i])
+		if err != nil {
+			return nil, err
+		}
+		if answer == WizardBack {
+			if i > 0 {
+				i--
+			}
+			continue
+		}
+		answers[i] = answer
+		i++
+	}
+}
+
+// ask prompts single step and returns normalized answer or WizardBack.
+func (w *Wizard) ask(sess *happy.Session, step wizardStep, current string) (string, error) {
+	var (
+		q      string
+		accept func(s string) (string, error)
+	)
+	step.question = sess.T(step.question)
+	switch step.kind {
+	case wizardConfirm:
+		def, _ := strconv.ParseBool(current)
+		hint := "[y/N]"
+		if def {
+			hint = "[Y/n]"
+		}
+		q = fmt.Sprintf("%s %s: ", step.question, hint)
+		accept = func(s string) (string, error) {
+			switch strings.ToLower(s) {
+			case "":
+				return strconv.FormatBool(def), nil
+			case "y", "yes":
+				return "true", nil
+			case "n", "no":
+				return "false", nil
+			}
+			return "", errors.New(sess.T("prompt.confirm.retry"))
+		}
+	case wizardSelect:
+		q = selectQuestion(step.question, step.options)
+		if current != "" {
+			q += " [" + current + "]"
+		}
+		q += ": "
+		accept = func(s string) (string, error) {
+			if s == "" && current != "" {
+				return current, nil
+			}
+			i, err := parseSelection(s, step.options)
+			if err != nil {
+				return "", err
+			}
+			return step.options[i], nil
+		}
+	default:
+		q = step.question + ": "
+		if current != "" && step.kind != wizardPassword {
+			q = fmt.Sprintf("%s [%s]: ", step.question, current)
+		}
+		accept = func(s string) (string, error) {
+			if s == "" {
+				s = current
+			}
+			if s == "" && step.validate == nil {
+				return "", errors.New("value is required")
+			}
+			if step.validate != nil {
+				if err := step.validate(s); err != nil {
+					return "", err
+				}
+			}
+			return s, nil
+		}
+	}
+
+	if step.kind == wizardPassword {
+		prompts.echo(false)
+		defer func() {
+			prompts.echo(true)
+			fmt.Fprintln(prompts.out)
+		}()
+	}
+
+	var answer string
+	_, err := prompts.ask(sess, q, func(s string) error {
+		if s == WizardBack {
+			answer = WizardBack
+			return nil
+		}
+		a, err := accept(s)
+		if err != nil {
+			return err
+		}
+		answer = a
+		return nil
+	})
+	return answer, err
+}
+
+func (w *Wizard) summary(answers []string) string {
+	var b strings.Builder
+	b.WriteString("summary:\n")
+	for i, step := range w.steps {
+		answer := answers[i]
+		if step.kind == wizardPassword {
+			answer = strings.Repeat("*", len(answer))
+		}
+		fmt.Fprintf(&b, "  %s: %s\n", step.key, answer)
+	}
+	return b.String()
+}
+
+func (w *Wizard) result(answers []string) (*vars.Map, error) {
+	res := new(vars.Map)
+	for i, step := range w.steps {
+		var value any = answers[i]
+		if step.kind == wizardConfirm {
+			value = answers[i] == "true"
+		}
+		if err := res.Store(step.key, value); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newTestWizard() *Wizard {
+	return NewWizard("setup").
+		Input("name", "project name", "app", nil).
+		Select("env", "environment", []string{"dev", "prod"}, 0).
+		Confirm("tls", "enable tls", true)
+}
+
+func TestWizard(t *testing.T) {
+	sess := new(happy.Session)
+	withPromptInput(t, "\n<\nmyapp\n2\ny\n<\nn\ny\n")
+
+	res, err := newTestWizard().Run(sess)
+	testutils.NoError(t, err)
+	testutils.Equal(t, "myapp", res.Get("name").String())
+	testutils.Equal(t, "prod", res.Get("env").String())
+	testutils.False(t, res.Get("tls").Bool(), "expected tls to be changed after going back")
+
+	withPromptInput(t, "\n\n\nn\n")
+	_, err = newTestWizard().Run(sess)
+	testutils.ErrorIs(t, err, ErrWizardCanceled)
+
+	_, err = NewWizard("invalid").Input("name", "a", "", nil).Input("name", "b", "", nil).Run(sess)
+	testutils.ErrorIs(t, err, ErrPrompt)
+}
@@ -0,0 +1,179 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package httpserver provides service which serves net/http handler
+// as part of happy application.
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/mkungla/happy"
+	"golang.org/x/exp/slog"
+)
+
+const (
+	defaultName              = "http"
+	defaultAddr              = ":8080"
+	defaultShutdownTimeout   = 10 * time.Second
+	defaultReadHeaderTimeout = 5 * time.Second
+)
+
+// listen opens listener of the server, tests replace it to fail serving.
+var listen = net.Listen
+
+// Config configures HTTP server service.
+type Config struct {
+	// Name of the service, defaults to "http".
+	Name string
+	// Addr is address server listens on, defaults to ":8080".
+	Addr string
+	// AddrOption is session option which value overrides Addr when set,
+	// defaults to "<name>.addr" e.g. "http.addr", declare it with
+	// Application.Setting. Service is restarted when option changes
+	// on config reload.
+	AddrOption string
+	// ShutdownTimeout is how long in-flight requests are waited for
+	// when service stops, defaults to 10s.
+	ShutdownTimeout time.Duration
+	// ReadHeaderTimeout of the server, defaults to 5s.
+	ReadHeaderTimeout time.Duration
+	// DisableAccessLog disables logging of served requests.
+	DisableAccessLog bool
+}
+
+// Service returns service serving handler. Listener is opened when
+// service starts so the address is in use only while service runs. When
+// service stops e.g. application drains services on shutdown, readiness
+// check starts failing and server is gracefully shut down waiting for
+// in-flight requests up to ShutdownTimeout.
+func Service(handler http.Handler, config Config) *happy.Service {
+	if config.Name == "" {
+		config.Name = defaultName
+	}
+	if config.Addr == "" {
+		config.Addr = defaultAddr
+	}
+	if config.AddrOption == "" {
+		config.AddrOption = config.Name + ".addr"
+	}
+	if config.ShutdownTimeout <= 0 {
+		config.ShutdownTimeout = defaultShutdownTimeout
+	}
+	if config.ReadHeaderTimeout <= 0 {
+		config.ReadHeaderTimeout = defaultReadHeaderTimeout
+	}
+
+	svc := happy.NewService(config.Name)
+	svc.RestartOnReload(config.AddrOption)
+
+	var (
+		server   *http.Server
+		serveErr atomic.Pointer[error]
+		draining atomic.Bool
+	)
+
+	svc.OnStart(func(sess *happy.Session) error {
+		addr := config.Addr
+		if v := sess.Get(config.AddrOption).String(); v != "" {
+			addr = v
+		}
+		ln, err := listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		h := handler
+		if !config.DisableAccessLog {
+			h = accessLog(sess, h)
+		}
+		// error of previous run must not fail health of restarted server
+		serveErr.Store(nil)
+		draining.Store(false)
+		server = &http.Server{
+			Handler:           h,
+			ReadHeaderTimeout: config.ReadHeaderTimeout,
+		}
+		go func(server *http.Server) {
+			if err := server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				serveErr.Store(&err)
+				sess.Log().Error(fmt.Sprintf("%s server failed", config.Name), err)
+			}
+		}(server)
+		sess.Log().Info(fmt.Sprintf("%s server listening", config.Name), slog.String("addr", ln.Addr().String()))
+		return nil
+	})
+
+	svc.OnStop(func(sess *happy.Session) error {
+		draining.Store(true)
+		if server == nil {
+			return nil
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), config.ShutdownTimeout)
+		defer cancel()
+		err := server.Shutdown(ctx)
+		server = nil
+		return err
+	})
+
+	svc.OnHealthCheck(func(sess *happy.Session) error {
+		if err := serveErr.Load(); err != nil {
+			return *err
+		}
+		return nil
+	})
+
+	svc.OnReadyCheck(func(sess *happy.Session) error {
+		if draining.Load() {
+			return fmt.Errorf("%s server is shutting down", config.Name)
+		}
+		return nil
+	})
+	return svc
+}
+
+// accessLog logs method, path, status, size and duration of each request.
+func accessLog(sess *happy.Session, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		sess.Log().Info("http request",
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.Int("status", rec.status),
+			slog.Int("bytes", rec.bytes),
+			slog.Duration("duration", time.Since(start)),
+			slog.String("remote", r.RemoteAddr),
+		)
+	})
+}
+
+// responseRecorder records status and size of the response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap returns underlying response writer for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package httpserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestService(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	testutils.NoError(t, err)
+	addr := ln.Addr().String()
+	testutils.NoError(t, ln.Close())
+
+	app := happy.New(happy.Option("log.console", false))
+	app.Setting("api.addr", addr, "address of api server", nil)
+	app.RegisterService(Service(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}), Config{Name: "api", Addr: "127.0.0.1:1"}))
+
+	var body string
+	app.Do(func(sess *happy.Session, args happy.Args) error {
+		loader := happy.NewServiceLoader(sess, "api")
+		<-loader.Load()
+		if err := loader.Err(); err != nil {
+			return err
+		}
+		res, err := http.Get("http://" + addr + "/")
+		if err != nil {
+			return err
+		}
+		defer res.Body.Close()
+		b, err := io.ReadAll(res.Body)
+		body = string(b)
+		return err
+	})
+	code, err := app.Run(context.Background(), []string{})
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+	testutils.Equal(t, "hello", body, "address should be read from api.addr option")
+
+	_, err = net.Dial("tcp", addr)
+	testutils.Error(t, err, "listener should be closed when service stops")
+}
+
+// failingListener fails to accept connections so that Serve fails.
+type failingListener struct {
+	net.Listener
+}
+
+func (l failingListener) Accept() (net.Conn, error) {
+	return nil, errors.New("accept failed")
+}
+
+func TestServiceRestartAfterFailure(t *testing.T) {
+	var fail atomic.Bool
+	fail.Store(true)
+	listen = func(network, addr string) (net.Listener, error) {
+		ln, err := net.Listen(network, addr)
+		if err != nil || !fail.Swap(false) {
+			return ln, err
+		}
+		return failingListener{ln}, nil
+	}
+	defer func() { listen = net.Listen }()
+
+	app := happy.New(happy.Option("log.console", false))
+	app.RegisterService(Service(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}), Config{Name: "api", Addr: "127.0.0.1:0", DisableAccessLog: true}))
+
+	waitFor := func(msg string, cond func() bool) error {
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				return errors.New(msg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil
+	}
+
+	var failed, recovered happy.HealthStatus
+	app.Do(func(sess *happy.Session, args happy.Args) error {
+		hostaddr, err := address.Parse(sess.Get("app.host.addr").String())
+		if err != nil {
+			return err
+		}
+		svcaddr, err := hostaddr.ResolveService("api")
+		if err != nil {
+			return err
+		}
+		loader := happy.NewServiceLoader(sess, "api")
+		<-loader.Load()
+		if err := loader.Err(); err != nil {
+			return err
+		}
+		if err := waitFor("serve failure was not reported", func() bool {
+			return sess.Health().Status != happy.HealthOK
+		}); err != nil {
+			return err
+		}
+		failed = sess.Health().Status
+
+		sess.Dispatch(happy.StopServicesEvent(svcaddr.String()))
+		if err := waitFor("service did not stop", func() bool {
+			info, err := sess.ServiceInfo(svcaddr.String())
+			return err == nil && !info.Running()
+		}); err != nil {
+			return err
+		}
+		loader = happy.NewServiceLoader(sess, "api")
+		<-loader.Load()
+		if err := loader.Err(); err != nil {
+			return fmt.Errorf("restart: %w", err)
+		}
+		recovered = sess.Health().Status
+		return nil
+	})
+	code, err := app.Run(context.Background(), []string{})
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+	testutils.Equal(t, happy.HealthFailing, failed, "serve failure should fail health")
+	testutils.Equal(t, happy.HealthOK, recovered, "restarted server should be healthy")
+}
+
+func TestResponseRecorder(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+	rec.WriteHeader(http.StatusTeapot)
+	_, err := rec.Write([]byte("tea"))
+	testutils.NoError(t, err)
+	testutils.Equal(t, http.StatusTeapot, rec.status)
+	testutils.Equal(t, 3, rec.bytes)
+	testutils.True(t, http.NewResponseController(rec).Flush() == nil, "flush should reach underlying writer")
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package oteltrace provides happy.Tracer backed by OpenTelemetry
+// tracer, spans are propagated between events as W3C traceparent.
+package oteltrace
+
+import (
+	"context"
+	"time"
+
+	"github.com/mkungla/happy"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/exp/slog"
+)
+
+const traceparentHeader = "traceparent"
+
+// Tracer is happy.Tracer creating spans with OpenTelemetry tracer.
+type Tracer struct {
+	tracer     trace.Tracer
+	propagator propagation.TextMapPropagator
+}
+
+var _ happy.Tracer = (*Tracer)(nil)
+
+// New returns Tracer creating spans with tracer e.g.
+// otel.Tracer("myapp"), see happy.Application.WithTracer.
+func New(tracer trace.Tracer) *Tracer {
+	return &Tracer{
+		tracer:     tracer,
+		propagator: propagation.TraceContext{},
+	}
+}
+
+// Start starts new span as child of span in ctx.
+func (t *Tracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, happy.Span) {
+	ctx, span := t.tracer.Start(ctx, name, trace.WithAttributes(attributes(nil, "", attrs)...))
+	return ctx, otelSpan{span}
+}
+
+// Inject returns W3C traceparent of span in ctx,
+// empty string when ctx has no valid span.
+func (t *Tracer) Inject(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	t.propagator.Inject(ctx, carrier)
+	return carrier.Get(traceparentHeader)
+}
+
+// Extract returns ctx carrying remote span described by traceparent.
+func (t *Tracer) Extract(ctx context.Context, traceparent string) context.Context {
+	return t.propagator.Extract(ctx, propagation.MapCarrier{traceparentHeader: traceparent})
+}
+
+type otelSpan struct {
+	span trace.Span
+}
+
+// End ends the span, non nil err is recorded and marks span as failed.
+func (s otelSpan) End(err error) {
+	if err != nil {
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+// attributes converts slog attributes into span attributes,
+// attributes of groups are prefixed with group key.
+func attributes(kvs []attribute.KeyValue, prefix string, attrs []slog.Attr) []attribute.KeyValue {
+	for _, a := range attrs {
+		key := a.Key
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		v := a.Value.Resolve()
+		switch v.Kind() {
+		case slog.GroupKind:
+			kvs = attributes(kvs, key, v.Group())
+		case slog.BoolKind:
+			kvs = append(kvs, attribute.Bool(key, v.Bool()))
+		case slog.Int64Kind:
+			kvs = append(kvs, attribute.Int64(key, v.Int64()))
+		case slog.Uint64Kind:
+			kvs = append(kvs, attribute.Int64(key, int64(v.Uint64())))
+		case slog.Float64Kind:
+			kvs = append(kvs, attribute.Float64(key, v.Float64()))
+		case slog.DurationKind:
+			kvs = append(kvs, attribute.String(key, v.Duration().String()))
+		case slog.TimeKind:
+			kvs = append(kvs, attribute.String(key, v.Time().Format(time.RFC3339Nano)))
+		default:
+			kvs = append(kvs, attribute.String(key, v.String()))
+		}
+	}
+	return kvs
+}
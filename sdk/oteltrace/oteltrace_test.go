@@ -0,0 +1,73 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package oteltrace
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"golang.org/x/exp/slog"
+)
+
+func newTestTracer() (*Tracer, *tracetest.SpanRecorder) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	return New(tp.Tracer("test")), rec
+}
+
+func TestTracerPropagation(t *testing.T) {
+	tracer, rec := newTestTracer()
+
+	ctx, span := tracer.Start(context.Background(), "dispatch")
+	traceparent := tracer.Inject(ctx)
+	testutils.Equal(t, 55, len(traceparent), "unexpected traceparent %q", traceparent)
+	span.End(nil)
+
+	ctx = tracer.Extract(context.Background(), traceparent)
+	_, child := tracer.Start(ctx, "handle")
+	child.End(errors.New("failed"))
+
+	spans := rec.Ended()
+	testutils.Equal(t, 2, len(spans))
+	testutils.Equal(t, spans[0].SpanContext().TraceID(), spans[1].SpanContext().TraceID())
+	testutils.Equal(t, spans[0].SpanContext().SpanID(), spans[1].Parent().SpanID())
+	testutils.True(t, spans[1].Parent().IsRemote(), "extracted parent should be remote")
+	testutils.Equal(t, codes.Unset, spans[0].Status().Code)
+	testutils.Equal(t, codes.Error, spans[1].Status().Code)
+	testutils.Equal(t, 1, len(spans[1].Events()), "error should be recorded")
+
+	testutils.Equal(t, "", tracer.Inject(context.Background()))
+}
+
+func TestTracerAttributes(t *testing.T) {
+	tracer, rec := newTestTracer()
+	_, span := tracer.Start(context.Background(), "tick",
+		slog.String("service", "svc"),
+		slog.Int("count", 2),
+		slog.Bool("ok", true),
+		slog.Duration("delta", time.Second),
+		slog.Group("event", slog.String("scope", "app")),
+	)
+	span.End(nil)
+
+	spans := rec.Ended()
+	testutils.Equal(t, 1, len(spans))
+	attrs := make(map[attribute.Key]attribute.Value)
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	testutils.Equal(t, "svc", attrs["service"].AsString())
+	testutils.Equal(t, int64(2), attrs["count"].AsInt64())
+	testutils.True(t, attrs["ok"].AsBool())
+	testutils.Equal(t, "1s", attrs["delta"].AsString())
+	testutils.Equal(t, "app", attrs["event.scope"].AsString())
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package output provides renderers for structured command output
+// selected with standard --output flag, e.g.
+//
+//	flag, err := output.Flag()
+//	if err != nil {
+//		return err
+//	}
+//	cmd.AddFlag(flag)
+//	cmd.Do(func(sess *happy.Session, args happy.Args) error {
+//		return output.Print(args, services)
+//	})
+package output
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/pkg/varflag"
+)
+
+var ErrOutput = errors.New("output error")
+
+const (
+	FormatTable    = "table"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+	FormatTemplate = "template"
+)
+
+// FlagName is name of the standard output flag.
+const FlagName = "output"
+
+// Renderer writes data in specific format.
+type Renderer interface {
+	Render(w io.Writer, data any) error
+}
+
+// RendererFunc is adapter to use ordinary func as Renderer.
+type RendererFunc func(w io.Writer, data any) error
+
+func (f RendererFunc) Render(w io.Writer, data any) error {
+	return f(w, data)
+}
+
+// Table is explicit tabular data, other values are rendered as table
+// with columns from struct fields, json tags or map keys.
+type Table struct {
+	Headers []string
+	Rows    [][]string
+}
+
+// Flag returns standard --output (-o) flag accepting
+// table, json, yaml or template=<go template>.
+func Flag() (varflag.Flag, error) {
+	return varflag.New(FlagName, FormatTable, "output format: table, json, yaml or template=<go template>", "o")
+}
+
+// New returns renderer for given format spec.
+func New(spec string) (Renderer, error) {
+	format, arg, _ := strings.Cut(spec, "=")
+	switch format {
+	case "", FormatTable:
+		return RendererFunc(renderTable), nil
+	case FormatJSON:
+		return RendererFunc(renderJSON), nil
+	case FormatYAML:
+		return RendererFunc(renderYAML), nil
+	case FormatTemplate:
+		if arg == "" {
+			return nil, fmt.Errorf("%w: template format requires template e.g. template={{.Name}}", ErrOutput)
+		}
+		tmpl, err := template.New("output").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrOutput, err.Error())
+		}
+		return RendererFunc(func(w io.Writer, data any) error {
+			return renderTemplate(w, tmpl, data)
+		}), nil
+	}
+	return nil, fmt.Errorf("%w: unknown output format %q", ErrOutput, spec)
+}
+
+// FromArgs returns renderer selected with --output flag.
+func FromArgs(args happy.Args) (Renderer, error) {
+	flag := args.Flag(FlagName)
+	if flag.Name() != FlagName {
+		return New(FormatTable)
+	}
+	return New(flag.String())
+}
+
+// Print renders data to stdout in format selected with --output flag.
+func Print(args happy.Args, data any) error {
+	r, err := FromArgs(args)
+	if err != nil {
+		return err
+	}
+	return r.Render(os.Stdout, data)
+}
+
+func renderJSON(w io.Writer, data any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(data)
+}
+
+// renderTemplate executes template for each element of slice data
+// or once for any other data.
+func renderTemplate(w io.Writer, tmpl *template.Template, data any) error {
+	v := reflect.ValueOf(data)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		if err := tmpl.Execute(w, data); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+	for i := 0; i < v.Len(); i++ {
+		if err := tmpl.Execute(w, v.Index(i).Interface()); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderTable(w io.Writer, data any) error {
+	table, err := toTable(data)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if len(table.Headers) > 0 {
+		fmt.Fprintln(tw, strings.Join(table.Headers, "\t"))
+	}
+	for _, row := range table.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func toTable(data any) (Table, error) {
+	switch t := data.(type) {
+	case Table:
+		return t, nil
+	case *Table:
+		return *t, nil
+	}
+
+	v := reflect.Indirect(reflect.ValueOf(data))
+	if !v.IsValid() {
+		return Table{}, nil
+	}
+	var elems []reflect.Value
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		for i := 0; i < v.Len(); i++ {
+			elems = append(elems, reflect.Indirect(v.Index(i)))
+		}
+	} else {
+		elems = append(elems, v)
+	}
+	if len(elems) == 0 {
+		return Table{}, nil
+	}
+
+	var table Table
+	switch elems[0].Kind() {
+	case reflect.Struct:
+		fields := structFields(elems[0].Type())
+		for _, f := range fields {
+			table.Headers = append(table.Headers, strings.ToUpper(f.name))
+		}
+		for _, elem := range elems {
+			row := make([]string, len(fields))
+			for i, f := range fields {
+				row[i] = cell(elem.FieldByIndex(f.index))
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	case reflect.Map:
+		seen := make(map[string]bool)
+		var keys []string
+		for _, elem := range elems {
+			for _, k := range elem.MapKeys() {
+				key := fmt.Sprint(k.Interface())
+				if !seen[key] {
+					seen[key] = true
+					keys = append(keys, key)
+				}
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			table.Headers = append(table.Headers, strings.ToUpper(key))
+		}
+		for _, elem := range elems {
+			row := make([]string, len(keys))
+			for _, k := range elem.MapKeys() {
+				row[sort.SearchStrings(keys, fmt.Sprint(k.Interface()))] = cell(elem.MapIndex(k))
+			}
+			table.Rows = append(table.Rows, row)
+		}
+	default:
+		table.Headers = []string{"VALUE"}
+		for _, elem := range elems {
+			table.Rows = append(table.Rows, []string{cell(elem)})
+		}
+	}
+	return table, nil
+}
+
+type structField struct {
+	name  string
+	index []int
+}
+
+// structFields returns exported fields named by json tags.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Name
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			tagname, _, _ := strings.Cut(tag, ",")
+			if tagname == "-" {
+				continue
+			}
+			if tagname != "" {
+				name = tagname
+			}
+		}
+		fields = append(fields, structField{name: name, index: f.Index})
+	}
+	return fields
+}
+
+func cell(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if s, ok := v.Interface().(fmt.Stringer); ok {
+		return s.String()
+	}
+	v = reflect.Indirect(v)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct:
+		b, err := json.Marshal(v.Interface())
+		if err != nil {
+			return fmt.Sprint(v.Interface())
+		}
+		return string(b)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// renderYAML writes data as YAML. Data is normalized through its
+// JSON representation so json tags and marshalers are honored.
+func renderYAML(w io.Writer, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrOutput, err.Error())
+	}
+	dec := json.NewDecoder(strings.NewReader(string(b)))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %s", ErrOutput, err.Error())
+	}
+	var sb strings.Builder
+	writeYAML(&sb, v, 0, false)
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+func writeYAML(b *strings.Builder, v any, indent int, inList bool) {
+	pad := strings.Repeat("  ", indent)
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			b.WriteString("{}\n")
+			return
+		}
+		keys := make([]string, 0, len(t))
+		for k := range t {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for i, k := range keys {
+			if i > 0 || !inList {
+				b.WriteString(pad)
+			}
+			b.WriteString(yamlScalar(k) + ":")
+			writeYAMLValue(b, t[k], indent+1)
+		}
+	case []any:
+		if len(t) == 0 {
+			b.WriteString("[]\n")
+			return
+		}
+		for i, elem := range t {
+			if i > 0 || !inList {
+				b.WriteString(pad)
+			}
+			b.WriteString("- ")
+			switch elem.(type) {
+			case map[string]any, []any:
+				writeYAML(b, elem, indent+1, true)
+			default:
+				b.WriteString(yamlScalar(elem) + "\n")
+			}
+		}
+	default:
+		b.WriteString(yamlScalar(v) + "\n")
+	}
+}
+
+func writeYAMLValue(b *strings.Builder, v any, indent int) {
+	switch t := v.(type) {
+	case map[string]any:
+		if len(t) == 0 {
+			b.WriteString(" {}\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent, false)
+	case []any:
+		if len(t) == 0 {
+			b.WriteString(" []\n")
+			return
+		}
+		b.WriteString("\n")
+		writeYAML(b, v, indent, false)
+	default:
+		b.WriteString(" " + yamlScalar(v) + "\n")
+	}
+}
+
+func yamlScalar(v any) string {
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return strconv.FormatBool(t)
+	case json.Number:
+		return t.String()
+	case string:
+		if yamlNeedsQuotes(t) {
+			return strconv.Quote(t)
+		}
+		return t
+	}
+	return fmt.Sprint(v)
+}
+
+func yamlNeedsQuotes(s string) bool {
+	if s == "" || strings.TrimSpace(s) != s {
+		return true
+	}
+	switch strings.ToLower(s) {
+	case "null", "~", "true", "false", "yes", "no", "on", "off":
+		return true
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return true
+	}
+	if strings.ContainsAny(s[:1], "-?:,[]{}#&*!|>'\"%@`") {
+		return true
+	}
+	return strings.ContainsAny(s, ":#\n\t")
+}
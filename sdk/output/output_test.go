@@ -0,0 +1,75 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+type testService struct {
+	Name    string   `json:"name"`
+	Running bool     `json:"running"`
+	Tags    []string `json:"tags,omitempty"`
+	secret  string
+}
+
+var testServices = []testService{
+	{Name: "web", Running: true, Tags: []string{"http", "public"}},
+	{Name: "worker", Running: false, secret: "x"},
+}
+
+func render(t *testing.T, spec string, data any) string {
+	t.Helper()
+	r, err := New(spec)
+	testutils.NoError(t, err)
+	var b strings.Builder
+	testutils.NoError(t, r.Render(&b, data))
+	return b.String()
+}
+
+func TestTable(t *testing.T) {
+	out := render(t, FormatTable, testServices)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	testutils.Equal(t, 3, len(lines))
+	testutils.Equal(t, "NAME    RUNNING  TAGS", strings.TrimSpace(lines[0]))
+	testutils.True(t, strings.HasPrefix(lines[1], "web     true     "), "unexpected row %q", lines[1])
+
+	out = render(t, FormatTable, Table{Headers: []string{"A", "B"}, Rows: [][]string{{"1", "2"}}})
+	testutils.Equal(t, "A  B\n1  2\n", out)
+
+	out = render(t, FormatTable, []map[string]any{{"b": 2, "a": 1}, {"a": 3}})
+	testutils.Equal(t, "A  B\n1  2\n3  \n", out)
+}
+
+func TestJSONAndYAML(t *testing.T) {
+	out := render(t, FormatJSON, testServices[1])
+	testutils.Equal(t, "{\n  \"name\": \"worker\",\n  \"running\": false\n}\n", out)
+
+	out = render(t, FormatYAML, testServices)
+	testutils.Equal(t, `- name: web
+  running: true
+  tags:
+    - http
+    - public
+- name: worker
+  running: false
+`, out)
+
+	out = render(t, FormatYAML, map[string]any{"version": "1.0", "empty": []string{}})
+	testutils.Equal(t, "empty: []\nversion: \"1.0\"\n", out)
+}
+
+func TestTemplate(t *testing.T) {
+	out := render(t, "template={{.Name}}={{.Running}}", testServices)
+	testutils.Equal(t, "web=true\nworker=false\n", out)
+
+	_, err := New("template=")
+	testutils.ErrorIs(t, err, ErrOutput)
+	_, err = New("xml")
+	testutils.ErrorIs(t, err, ErrOutput)
+}
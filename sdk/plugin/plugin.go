@@ -0,0 +1,228 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package plugin provides out-of-process addons, so that third-party
+// addons can be distributed as executables instead of being compiled
+// into the application. Plugin executable is started as child process
+// and events are exchanged with it over event bridge using pipes passed
+// to the plugin as file descriptors 3 and 4, stdout and stderr of the
+// plugin are passed through to the application.
+//
+// Host application loads plugin with
+//
+//	app.WithAddons(plugin.Addon("thumbnails", plugin.Config{
+//		Path:   "/usr/lib/myapp/thumbnails",
+//		Scopes: []string{"images"},
+//	}))
+//
+// and plugin, which itself is happy application, connects to the host with
+//
+//	app.RegisterService(bridge.Service(plugin.Host(), bridge.Config{
+//		Scopes: []string{"thumbnails"},
+//	}))
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/mkungla/happy"
+	"github.com/mkungla/happy/sdk/bridge"
+)
+
+// EnvKey is environment variable set for plugin processes.
+const EnvKey = "HAPPY_PLUGIN"
+
+// file descriptors of pipes in plugin process.
+const (
+	fdIn  = 3
+	fdOut = 4
+)
+
+var ErrPlugin = fmt.Errorf("%w: plugin", happy.ErrAddon)
+
+// Config configures out-of-process addon.
+type Config struct {
+	// Path of the plugin executable.
+	Path string
+	// Args passed to the plugin.
+	Args []string
+	// Env is additional environment of the plugin process.
+	Env []string
+	// Scopes of local events forwarded to the plugin.
+	Scopes []string
+}
+
+// Addon returns addon which runs plugin executable as child process
+// while addon service is running. Events dispatched by the plugin are
+// dispatched to the local bus and local events of configured scopes
+// are forwarded to the plugin.
+func Addon(name string, config Config, opts ...happy.OptionArg) *happy.Addon {
+	addon := happy.NewAddon(name, opts...)
+	addon.ProvidesService(bridge.Service(NewProcess(config), bridge.Config{
+		Name:    name,
+		NodeID:  name,
+		Subject: "happy.plugin." + name,
+		Scopes:  config.Scopes,
+	}))
+	return addon
+}
+
+// IsPlugin reports whether current process was started as plugin.
+func IsPlugin() bool {
+	return os.Getenv(EnvKey) != ""
+}
+
+// Host returns broker connected to the application which started
+// current process as plugin.
+func Host() bridge.Broker {
+	return &pipeBroker{
+		r: os.NewFile(fdIn, "happy-plugin-in"),
+		w: os.NewFile(fdOut, "happy-plugin-out"),
+	}
+}
+
+// Process is broker which exchanges messages with plugin process,
+// process is started on Subscribe and killed when its context is done.
+type Process struct {
+	config Config
+
+	mu     sync.Mutex
+	broker *pipeBroker
+	cmd    *exec.Cmd
+}
+
+// NewProcess returns broker for plugin process described by config.
+func NewProcess(config Config) *Process {
+	return &Process{config: config}
+}
+
+// Publish sends data to the plugin.
+func (p *Process) Publish(ctx context.Context, subject string, data []byte) error {
+	p.mu.Lock()
+	broker := p.broker
+	p.mu.Unlock()
+	if broker == nil {
+		return fmt.Errorf("%w: %s is not running", ErrPlugin, p.config.Path)
+	}
+	return broker.Publish(ctx, subject, data)
+}
+
+// Subscribe starts plugin process and calls handler for messages
+// sent by the plugin until ctx is done or plugin exits.
+func (p *Process) Subscribe(ctx context.Context, subject string, handler func(data []byte)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.broker != nil {
+		return fmt.Errorf("%w: %s already running", ErrPlugin, p.config.Path)
+	}
+	// pipes from host to plugin and from plugin to host
+	inr, inw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	outr, outw, err := os.Pipe()
+	if err != nil {
+		inr.Close()
+		inw.Close()
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, p.config.Path, p.config.Args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(append(os.Environ(), EnvKey+"=1"), p.config.Env...)
+	cmd.ExtraFiles = []*os.File{inr, outw}
+	err = cmd.Start()
+	// child has its own copies of plugin ends of the pipes
+	inr.Close()
+	outw.Close()
+	if err != nil {
+		inw.Close()
+		outr.Close()
+		return fmt.Errorf("%w: %s", ErrPlugin, err.Error())
+	}
+
+	p.cmd = cmd
+	p.broker = &pipeBroker{r: outr, w: inw}
+	broker := p.broker
+	go func() {
+		_ = broker.read(ctx, handler)
+		_ = cmd.Wait()
+		broker.Close()
+		p.mu.Lock()
+		p.broker = nil
+		p.cmd = nil
+		p.mu.Unlock()
+	}()
+	return nil
+}
+
+// Running reports whether plugin process is running.
+func (p *Process) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.cmd != nil
+}
+
+// pipeBroker exchanges newline delimited messages over pipes,
+// subject is ignored since pipes are dedicated to single bridge.
+type pipeBroker struct {
+	r  io.ReadCloser
+	wm sync.Mutex
+	w  io.WriteCloser
+}
+
+func (b *pipeBroker) Publish(ctx context.Context, subject string, data []byte) error {
+	b.wm.Lock()
+	defer b.wm.Unlock()
+	msg := make([]byte, len(data)+1)
+	copy(msg, data)
+	msg[len(data)] = '\n'
+	if _, err := b.w.Write(msg); err != nil {
+		return fmt.Errorf("%w: %s", ErrPlugin, err.Error())
+	}
+	return nil
+}
+
+// Subscribe calls handler for received messages in background
+// until reader is closed or ctx is done.
+func (b *pipeBroker) Subscribe(ctx context.Context, subject string, handler func(data []byte)) error {
+	go func() {
+		_ = b.read(ctx, handler)
+	}()
+	return nil
+}
+
+// read reads messages until reader is closed or ctx is done.
+func (b *pipeBroker) read(ctx context.Context, handler func(data []byte)) error {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			b.r.Close()
+		case <-done:
+		}
+	}()
+	scanner := bufio.NewScanner(b.r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		handler(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, os.ErrClosed) {
+		return err
+	}
+	return nil
+}
+
+func (b *pipeBroker) Close() error {
+	return errors.Join(b.r.Close(), b.w.Close())
+}
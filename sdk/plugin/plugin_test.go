@@ -0,0 +1,59 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+// TestHelperPlugin is not real test, it echoes messages back
+// to the host when test binary is started as plugin.
+func TestHelperPlugin(t *testing.T) {
+	if !IsPlugin() {
+		return
+	}
+	host := Host().(*pipeBroker)
+	_ = host.read(context.Background(), func(data []byte) {
+		_ = host.Publish(context.Background(), "", append([]byte("echo:"), data...))
+	})
+	os.Exit(0)
+}
+
+func TestProcess(t *testing.T) {
+	proc := NewProcess(Config{
+		Path: os.Args[0],
+		Args: []string{"-test.run=TestHelperPlugin"},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	received := make(chan string, 1)
+	testutils.NoError(t, proc.Subscribe(ctx, "", func(data []byte) {
+		received <- string(data)
+	}))
+	testutils.True(t, proc.Running(), "plugin should be running")
+	testutils.ErrorIs(t, proc.Subscribe(ctx, "", func([]byte) {}), ErrPlugin)
+	testutils.NoError(t, proc.Publish(ctx, "", []byte(`{"key":"ping"}`)))
+
+	select {
+	case msg := <-received:
+		testutils.Equal(t, `echo:{"key":"ping"}`, msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("plugin did not respond")
+	}
+
+	cancel()
+	deadline := time.Now().Add(5 * time.Second)
+	for proc.Running() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	testutils.False(t, proc.Running(), "plugin should be stopped")
+	testutils.ErrorIs(t, proc.Publish(ctx, "", []byte("late")), ErrPlugin)
+}
@@ -0,0 +1,227 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+// Package progress provides progress bars and spinners for command line
+// applications. Progress output is coordinated with the logger so that log
+// lines are written above progress output instead of corrupting it.
+// When output is not a terminal plain start and completion lines are written.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkungla/happy/pkg/hlog"
+)
+
+const (
+	barWidth        = 30
+	refreshInterval = 100 * time.Millisecond
+)
+
+var spinnerFrames = []string{"|", "/", "-", "\\"}
+
+// Progress renders one or more tasks. Create it with New or NewWriter
+// and call Stop when all tasks are done.
+type Progress struct {
+	mu      sync.Mutex
+	w       io.Writer
+	tty     bool
+	tasks   []*Task
+	lines   int
+	frame   int
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// New returns Progress writing to stdout, live rendering is used
+// only when stdout is terminal.
+func New() *Progress {
+	tty := false
+	if fi, err := os.Stdout.Stat(); err == nil {
+		tty = fi.Mode()&os.ModeCharDevice != 0
+	}
+	return NewWriter(os.Stdout, tty)
+}
+
+// NewWriter returns Progress writing to w. When tty is false
+// progress is written as plain lines.
+func NewWriter(w io.Writer, tty bool) *Progress {
+	p := &Progress{
+		w:       w,
+		tty:     tty,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if tty {
+		hlog.SetInterruptor(p)
+		go p.run()
+	} else {
+		close(p.stopped)
+	}
+	return p
+}
+
+// Bar adds progress bar task with given total.
+func (p *Progress) Bar(title string, total int64) *Task {
+	return p.add(&Task{p: p, title: title, total: total, started: time.Now()})
+}
+
+// Spinner adds task with unknown total.
+func (p *Progress) Spinner(title string) *Task {
+	return p.add(&Task{p: p, title: title, total: -1, started: time.Now()})
+}
+
+func (p *Progress) add(t *Task) *Task {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.tasks = append(p.tasks, t)
+	if !p.tty {
+		fmt.Fprintf(p.w, "%s: started\n", t.title)
+	}
+	return t
+}
+
+// Stop renders final state of tasks and stops rendering.
+func (p *Progress) Stop() {
+	p.mu.Lock()
+	select {
+	case <-p.stop:
+		p.mu.Unlock()
+		return
+	default:
+		close(p.stop)
+	}
+	p.mu.Unlock()
+	<-p.stopped
+}
+
+func (p *Progress) run() {
+	defer close(p.stopped)
+	ticker := time.NewTicker(refreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			hlog.SetInterruptor(nil)
+			p.mu.Lock()
+			p.render()
+			p.mu.Unlock()
+			return
+		case <-ticker.C:
+			p.mu.Lock()
+			p.frame++
+			p.render()
+			p.mu.Unlock()
+		}
+	}
+}
+
+// Interrupt clears progress output before log line is written,
+// it holds the lock until Resume.
+func (p *Progress) Interrupt() {
+	p.mu.Lock()
+	p.clear()
+}
+
+// Resume redraws progress output after log line was written.
+func (p *Progress) Resume() {
+	p.render()
+	p.mu.Unlock()
+}
+
+func (p *Progress) clear() {
+	if p.lines > 0 {
+		fmt.Fprintf(p.w, "\033[%dA\r\033[J", p.lines)
+		p.lines = 0
+	}
+}
+
+func (p *Progress) render() {
+	var b strings.Builder
+	if p.lines > 0 {
+		fmt.Fprintf(&b, "\033[%dA", p.lines)
+	}
+	for _, t := range p.tasks {
+		b.WriteString("\r\033[2K")
+		b.WriteString(t.line(p.frame))
+		b.WriteString("\n")
+	}
+	p.lines = len(p.tasks)
+	_, _ = io.WriteString(p.w, b.String())
+}
+
+// Task is single progress bar or spinner.
+type Task struct {
+	p        *Progress
+	title    string
+	total    int64
+	current  int64
+	done     bool
+	err      error
+	started  time.Time
+	finished time.Time
+}
+
+// Add increments task progress by n.
+func (t *Task) Add(n int64) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	t.current += n
+}
+
+// Set sets task progress to n.
+func (t *Task) Set(n int64) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	t.current = n
+}
+
+// Done marks task completed, non nil err marks task failed.
+func (t *Task) Done(err error) {
+	t.p.mu.Lock()
+	defer t.p.mu.Unlock()
+	if t.done {
+		return
+	}
+	t.done = true
+	t.err = err
+	t.finished = time.Now()
+	if t.total > 0 && err == nil {
+		t.current = t.total
+	}
+	if !t.p.tty {
+		fmt.Fprintln(t.p.w, t.line(0))
+	}
+}
+
+func (t *Task) line(frame int) string {
+	if t.done {
+		elapsed := t.finished.Sub(t.started).Round(time.Millisecond)
+		if t.err != nil {
+			return fmt.Sprintf("%s: failed after %s: %s", t.title, elapsed, t.err)
+		}
+		return fmt.Sprintf("%s: done in %s", t.title, elapsed)
+	}
+	if t.total < 0 {
+		return fmt.Sprintf("%s %s", spinnerFrames[frame%len(spinnerFrames)], t.title)
+	}
+	ratio := 0.0
+	if t.total > 0 {
+		ratio = float64(t.current) / float64(t.total)
+	}
+	if ratio > 1 {
+		ratio = 1
+	}
+	filled := int(ratio * barWidth)
+	bar := strings.Repeat("=", filled)
+	if filled < barWidth {
+		bar += ">" + strings.Repeat(" ", barWidth-filled-1)
+	}
+	return fmt.Sprintf("%s [%s] %3d%% %d/%d", t.title, bar, int(ratio*100), t.current, t.total)
+}
@@ -0,0 +1,76 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package progress
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/sdk/testutils"
+	"golang.org/x/exp/slog"
+)
+
+type syncBuffer struct {
+	mu sync.Mutex
+	b  bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.b.String()
+}
+
+func TestPlainProgress(t *testing.T) {
+	var out syncBuffer
+	p := NewWriter(&out, false)
+	bar := p.Bar("download", 10)
+	spin := p.Spinner("index")
+	bar.Add(5)
+	bar.Done(nil)
+	spin.Done(errors.New("timeout"))
+	p.Stop()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	testutils.Equal(t, 4, len(lines))
+	testutils.Equal(t, "download: started", lines[0])
+	testutils.True(t, strings.HasPrefix(lines[2], "download: done in"), "unexpected line %q", lines[2])
+	testutils.True(t, strings.HasSuffix(lines[3], ": timeout"), "unexpected line %q", lines[3])
+	testutils.False(t, strings.Contains(out.String(), "\033["), "plain output contains escape sequences")
+}
+
+func TestTTYProgressWithLogger(t *testing.T) {
+	var out syncBuffer
+	logger := hlog.New(hlog.Config{}.NewHandler(&out))
+
+	p := NewWriter(&out, true)
+	bar := p.Bar("build", 4)
+	bar.Set(2)
+	p.mu.Lock()
+	p.render()
+	p.mu.Unlock()
+	testutils.True(t, strings.Contains(out.String(), "build ["), "bar not rendered")
+
+	logger.Info("log line", slog.Int("n", 1))
+	s := out.String()
+	logAt := strings.Index(s, "log line")
+	testutils.True(t, logAt > 0, "log line missing")
+	testutils.True(t, strings.Contains(s[:logAt], "\033[1A\r\033[J"), "progress not cleared before log line")
+	testutils.True(t, strings.Contains(s[logAt:], "build ["), "progress not redrawn after log line")
+
+	bar.Done(nil)
+	p.Stop()
+	testutils.True(t, strings.Contains(out.String(), "build: done in"), "final state not rendered")
+}
@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/mkungla/happy/pkg/address"
@@ -28,9 +29,23 @@ type Service struct {
 	stopAction       Action
 	tickAction       ActionTick
 	tockAction       ActionTock
-	listeners        map[string][]ActionWithEvent
+	listeners        []eventListener
+	schemas          []EventSchema
+	bus              string
+	deps             []string
+	stopPriority     int
+	// addon is name of addon providing the service, actions of
+	// the service can only set options in namespace of the addon
+	addon string
+	// reloadOn is set when service restarts on config reload,
+	// empty slice means service restarts on any change
+	reloadOn []string
 
 	cronsetup func(schedule CronScheduler)
+
+	// checks reporting health and readiness of running service
+	healthCheck Action
+	readyCheck  Action
 }
 
 // NewService cretes new draft service which you can compose
@@ -75,22 +90,106 @@ func (s *Service) OnTock(action ActionTock) {
 	s.tockAction = action
 }
 
-// OnEvent is called when a specific event is received.
-func (s *Service) OnEvent(scope, key string, cb ActionWithEvent) {
-	if s.listeners == nil {
-		s.listeners = make(map[string][]ActionWithEvent)
+// DependsOn declares services by name this service depends on.
+// On shutdown service is stopped before services it depends on.
+func (s *Service) DependsOn(svcs ...string) {
+	s.deps = append(s.deps, svcs...)
+}
+
+// StopPriority sets order in which services without dependencies
+// between them are stopped, services with lower priority stop first.
+func (s *Service) StopPriority(priority int) {
+	s.stopPriority = priority
+}
+
+// RestartOnReload restarts running service when config is reloaded
+// on SIGHUP and any of options matching keys changed. Keys can be
+// option keys or glob patterns e.g. "log.*", without keys service
+// is restarted on any change.
+func (s *Service) RestartOnReload(keys ...string) {
+	if s.reloadOn == nil {
+		s.reloadOn = []string{}
+	}
+	s.reloadOn = append(s.reloadOn, keys...)
+}
+
+func (s *Service) reloadsOn(changed []string) bool {
+	if s.reloadOn == nil {
+		return false
 	}
+	if len(s.reloadOn) == 0 {
+		return len(changed) > 0
+	}
+	for _, key := range changed {
+		for _, pattern := range s.reloadOn {
+			if reloadKeyMatch(pattern, key) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ListenOnBus sets event bus which events service receives.
+// By default services listen on MainEventBus.
+func (s *Service) ListenOnBus(name string) {
+	s.bus = name
+}
+
+func (s *Service) busName() string {
+	if s.bus == "" {
+		return MainEventBus
+	}
+	return s.bus
+}
 
-	lid := scope + "." + key
-	s.listeners[lid] = append(s.listeners[lid], cb)
+// OnEvent is called when a specific event is received.
+// Scope and key can be glob patterns e.g. OnEvent("services", "*")
+// or OnEvent("*", "config.changed") to listen group of events.
+func (s *Service) OnEvent(scope, key string, cb ActionWithEvent) {
+	s.OnEventWithPriority(0, scope, key, cb)
+}
+
+// OnEventWithPriority is like OnEvent, but listeners with higher priority
+// are called before listeners with lower priority, e.g. audit or validation
+// handlers can be run before business handlers. Listeners with same priority
+// are called in order of service address and registration.
+// Listeners registered with OnEvent and OnAnyEvent have priority 0.
+func (s *Service) OnEventWithPriority(priority int, scope, key string, cb ActionWithEvent) {
+	s.listeners = append(s.listeners, eventListener{
+		lid:      scope + "." + key,
+		priority: priority,
+		seq:      len(s.listeners),
+		cb:       cb,
+	})
 }
 
 // OnAnyEvent called when any event is received.
 func (s *Service) OnAnyEvent(cb ActionWithEvent) {
-	if s.listeners == nil {
-		s.listeners = make(map[string][]ActionWithEvent)
-	}
-	s.listeners["any"] = append(s.listeners["any"], cb)
+	s.listeners = append(s.listeners, eventListener{
+		lid: "any",
+		seq: len(s.listeners),
+		cb:  cb,
+	})
+}
+
+// EmitsSchema registers event which service emits, in development
+// mode payloads of dispatched events are validated against the schema.
+func (s *Service) EmitsSchema(schema EventSchema) {
+	s.schemas = append(s.schemas, schema)
+}
+
+// OnHealthCheck sets action reporting health of running service e.g.
+// state of connections it depends on, see Session.Health. Action is
+// called when health is queried and should return quickly.
+func (s *Service) OnHealthCheck(action Action) {
+	s.healthCheck = action
+}
+
+// OnReadyCheck sets action reporting whether running service is ready
+// to serve e.g. caches are warmed up, see Session.Readiness.
+func (s *Service) OnReadyCheck(action Action) {
+	s.readyCheck = action
 }
 
 // Cron scheduled cron jobs to run when the service is running.
@@ -103,6 +202,9 @@ func (s *Service) container(sess *Session, addr *address.Address) *serviceContai
 	c.svc = s
 	c.info.addr = addr
 	c.info.name = s.name
+	c.info.addon = s.addon
+	c.info.healthCheck = s.healthCheck
+	c.info.readyCheck = s.readyCheck
 	return c
 }
 
@@ -304,6 +406,11 @@ type ServiceInfo struct {
 	errs      map[time.Time]error
 	startedAt time.Time
 	stoppedAt time.Time
+
+	// addon providing the service and checks of the service
+	addon       string
+	healthCheck Action
+	readyCheck  Action
 }
 
 func (s *ServiceInfo) Running() bool {
@@ -385,14 +492,14 @@ type serviceContainer struct {
 
 func (s *serviceContainer) initialize(sess *Session) error {
 	if s.svc.initializeAction != nil {
-		if err := s.svc.initializeAction(sess); err != nil {
+		if err := s.svc.initializeAction(sess.sandboxed(s.svc.addon)); err != nil {
 			s.info.addErr(err)
 			return err
 		}
 	}
 
 	if s.svc.cronsetup != nil {
-		s.cron = newCron(sess)
+		s.cron = newCron(sess.sandboxed(s.svc.addon))
 		s.svc.cronsetup(s.cron)
 	}
 	sess.Log().Debug("service initialied", slog.String("service", s.info.Addr().String()))
@@ -401,7 +508,7 @@ func (s *serviceContainer) initialize(sess *Session) error {
 
 func (s *serviceContainer) start(ectx context.Context, sess *Session) (err error) {
 	if s.svc.startAction != nil {
-		err = s.svc.startAction(sess)
+		err = s.svc.startAction(sess.sandboxed(s.svc.addon))
 	}
 	if s.cron != nil {
 		sess.Log().SystemDebug("starting cron jobs", slog.String("service", s.info.Addr().String()))
@@ -443,7 +550,7 @@ func (s *serviceContainer) stop(sess *Session, e error) (err error) {
 
 	s.cancel(e)
 	if s.svc.stopAction != nil {
-		err = s.svc.stopAction(sess)
+		err = s.svc.stopAction(sess.sandboxed(s.svc.addon))
 	}
 
 	if e != nil {
@@ -477,31 +584,23 @@ func (s *serviceContainer) tick(sess *Session, ts time.Time, delta time.Duration
 	if s.svc.tickAction == nil {
 		return nil
 	}
-	return s.svc.tickAction(sess, ts, delta)
+	return s.svc.tickAction(sess.sandboxed(s.svc.addon), ts, delta)
 }
 
 func (s *serviceContainer) tock(sess *Session, delta time.Duration, tps int) error {
 	if s.svc.tockAction == nil {
 		return nil
 	}
-	return s.svc.tockAction(sess, delta, tps)
+	return s.svc.tockAction(sess.sandboxed(s.svc.addon), delta, tps)
 }
 
-func (s *serviceContainer) handleEvent(sess *Session, ev Event) {
-	if s.svc.listeners == nil {
-		return
-	}
-	lid := ev.Scope() + "." + ev.Key()
-	for sk, listeners := range s.svc.listeners {
-		for _, listener := range listeners {
-			if sk == "any" || sk == lid {
-				if err := listener(sess, ev); err != nil {
-					s.info.addErr(err)
-					sess.Log().Error("event handler error", err, slog.String("service", s.info.Addr().String()))
-				}
-			}
-		}
+func (s *serviceContainer) handleEvent(sess *Session, ev Event, listener eventListener) error {
+	if err := listener.cb(sess.sandboxed(s.svc.addon), ev); err != nil {
+		s.info.addErr(err)
+		sess.EventLog(ev).Error("event handler error", err, slog.String("service", s.info.Addr().String()))
+		return err
 	}
+	return nil
 }
 
 type CronScheduler interface {
@@ -512,6 +611,10 @@ type Cron struct {
 	sess   *Session
 	lib    *cron.Cron
 	jobIDs []cron.EntryID
+
+	// runs and failures of the jobs
+	runs     atomic.Uint64
+	failures atomic.Uint64
 }
 
 func newCron(sess *Session) *Cron {
@@ -525,7 +628,9 @@ func newCron(sess *Session) *Cron {
 
 func (cs *Cron) Job(expr string, cb Action) {
 	id, err := cs.lib.AddFunc(expr, func() {
+		cs.runs.Add(1)
 		if err := cb(cs.sess); err != nil {
+			cs.failures.Add(1)
 			cs.sess.Log().Error("job failed", err)
 		}
 	})
@@ -7,8 +7,8 @@ package happy
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/signal"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,28 +17,92 @@ import (
 	"golang.org/x/exp/slog"
 )
 
+// Session is passed to actions of the application, services and
+// commands. Sessions of addon services and commands share state
+// with application session, but can only set options in namespace
+// of the addon. Zero Session, e.g. in tests, has no state and its
+// accessors such as Interactive and Done return defaults.
 type Session struct {
+	*sessionState
+	// sandbox is name of the addon which services and
+	// commands use the session, empty for application.
+	sandbox string
+	// ctx limits session passed to event listener,
+	// it is done when listener times out.
+	ctx context.Context
+}
+
+type sessionState struct {
 	mu sync.RWMutex
 
 	logger *hlog.Logger
-	opts   *Options
+	// redactor masks sensitive option values, see log.redact
+	redactor *hlog.Redactor
+	opts     *Options
+	engine   *Engine
+	// logFlush writes buffered log records when async logging is enabled
+	logFlush func()
+	// logMetrics counts logged records when log.metrics is set
+	logMetrics *hlog.Metrics
 
-	ready      context.Context
-	readyFunc  context.CancelFunc
-	sig        context.Context
-	sigRelease context.CancelFunc
-	err        error
+	ready     context.Context
+	readyFunc context.CancelFunc
+	err       error
 
 	done chan struct{}
 	evch chan Event
 	svss map[string]*ServiceInfo
 	apis map[string]API
+	// registered addons in registration order
+	addons []*addonHealthInfo
+	// capabilities published by addons
+	capabilities map[string]capability
+	// application assets and assets mounted by addons
+	assets *Assets
+
+	journal     *eventJournal
+	deadLetters []DeadLetter
+
+	overflow overflowPolicies
+	evstats  eventStats
+	// coalesced events waiting for event queue to drain
+	cmu       sync.Mutex
+	coalesced map[string]Event
+	ckeys     []string
 
 	disposed bool
 
 	// is flag x set to indicate that
 	// external commands should be printed.
 	x bool
+	// is flag no-interactive set to indicate that
+	// user must not be prompted for input.
+	noninteractive bool
+
+	// colored output and theme used for it
+	color bool
+	theme Theme
+
+	// deadline of command Do action when it has time limit
+	deadline time.Time
+
+	// log level overrides changed with log.scopes option
+	logScopes *hlog.ScopeLevels
+
+	// sources of option values which were not defaults
+	optSources map[string]string
+
+	// active profile selected with --profile flag
+	profile string
+
+	// message catalogs and negotiated language
+	i18n *translations
+
+	// called when user.* setting was changed
+	settingsChanged func()
+
+	// statistics of the application
+	monitor *Monitor
 }
 
 // Ready returns channel which blocks until session considers application to be ready.
@@ -57,20 +121,61 @@ func (s *Session) Ready() <-chan struct{} {
 // or DeadlineExceeded if the context's deadline passed.
 // After Err returns a non-nil error, successive calls to Err return the same error.
 func (s *Session) Err() error {
+	if s.sessionState == nil {
+		return nil
+	}
 	s.mu.RLock()
-	defer s.mu.RUnlock()
 	err := s.err
+	s.mu.RUnlock()
+	if err == nil && s.ctx != nil {
+		return s.ctx.Err()
+	}
 	return err
 }
 
 // X Returns true when session expects that commands executed would be printed.
 // To set this true run application with -x flag
 func (s *Session) X() bool {
+	if s.sessionState == nil {
+		return false
+	}
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	return s.x
 }
 
+// Interactive returns false when application was started with
+// --no-interactive flag and commands must not prompt for user input.
+func (s *Session) Interactive() bool {
+	if s.sessionState == nil {
+		return true
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return !s.noninteractive
+}
+
+// Color returns true when colored output is enabled, see --color flag.
+func (s *Session) Color() bool {
+	if s.sessionState == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.color
+}
+
+// Theme returns application theme, styles should be rendered
+// only when Color is true e.g. theme.Error.Render(msg, sess.Color()).
+func (s *Session) Theme() Theme {
+	if s.sessionState == nil {
+		return Theme{}
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.theme
+}
+
 func (s *Session) ServiceInfo(svcurl string) (*ServiceInfo, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -102,11 +207,6 @@ func (s *Session) Destroy(err error) {
 
 	s.mu.Unlock()
 
-	if s.sigRelease != nil {
-		s.sigRelease()
-		s.sigRelease = nil
-	}
-
 	s.mu.Lock()
 	if s.evch != nil {
 		close(s.evch)
@@ -115,25 +215,99 @@ func (s *Session) Destroy(err error) {
 	if s.done != nil {
 		close(s.done)
 	}
-
+	flush := s.logFlush
 	s.mu.Unlock()
+
+	if flush != nil {
+		flush()
+	}
+}
+
+// T returns message key translated to language of the session with
+// placeholders {name} replaced by args given as key value pairs or
+// slog.Attr e.g. sess.T("greeting", "name", user). Key is returned
+// as is when it is not found in any catalog.
+func (s *Session) T(key string, args ...any) string {
+	if s.sessionState == nil || s.i18n == nil {
+		return (&translations{}).translate(key, args...)
+	}
+	return s.i18n.translate(key, args...)
+}
+
+// Language returns language of the session negotiated from app.lang
+// option or environment and available message catalogs.
+func (s *Session) Language() string {
+	if s.sessionState == nil || s.i18n == nil {
+		return i18nFallback
+	}
+	return s.i18n.language()
+}
+
+// Profile returns name of active profile selected with --profile flag,
+// services and commands can use it to branch e.g. on prod or dev profile.
+func (s *Session) Profile() string {
+	if s.sessionState == nil {
+		return ""
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.profile
 }
 
 // Deadline returns the time when work done on behalf of this context
 // should be canceled. Deadline returns ok==false when no deadline is
 // set. Successive calls to Deadline return the same results.
 func (s *Session) Deadline() (deadline time.Time, ok bool) {
-	return
+	if s.ctx != nil {
+		return s.ctx.Deadline()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.deadline, !s.deadline.IsZero()
+}
+
+// Engine returns application engine.
+func (s *Session) Engine() *Engine {
+	return s.engine
 }
 
 func (s *Session) Log() *hlog.Logger {
 	return s.logger
 }
 
+// LogMetrics returns number of logged records per level and
+// labels configured with log.metrics e.g. errors per service.
+func (s *Session) LogMetrics() []hlog.MetricsSample {
+	if s.logMetrics == nil {
+		return nil
+	}
+	return s.logMetrics.Snapshot()
+}
+
+// EventLog returns logger with log attributes carried by the event
+// e.g. correlation id, see WithLogAttrs and Session.DispatchContext.
+func (s *Session) EventLog(ev Event) *hlog.Logger {
+	attrs := EventLogAttrs(ev)
+	if len(attrs) == 0 {
+		return s.logger
+	}
+	args := make([]any, len(attrs))
+	for i, attr := range attrs {
+		args[i] = attr
+	}
+	return s.logger.With(args...)
+}
+
 // Done enables you to hook into chan to know when application exits
 // however DO NOT use that for graceful shutdown actions.
 // Use Application.AddExitFunc instead.
 func (s *Session) Done() <-chan struct{} {
+	if s.sessionState == nil {
+		return nil
+	}
+	if s.ctx != nil {
+		return s.ctx.Done()
+	}
 	s.mu.Lock()
 	if s.done == nil {
 		s.done = make(chan struct{})
@@ -150,11 +324,6 @@ func (s *Session) Value(key any) any {
 		if v, ok := s.opts.Load(k); ok {
 			return v
 		}
-	case *int:
-		if s.sig != nil && s.sig.Err() != nil {
-			s.Destroy(s.sig.Err())
-		}
-		return nil
 	}
 	return nil
 }
@@ -168,7 +337,41 @@ func (s *Session) Get(key string) vars.Variable {
 }
 
 func (s *Session) Set(key string, val any) error {
-	return s.opts.Set(key, val)
+	if s.sandbox != "" && !strings.HasPrefix(key, s.sandbox+".") {
+		err := fmt.Errorf("%w: addon %s can only set options in %s.* namespace, rejected %s",
+			ErrOptionSandbox, s.sandbox, s.sandbox, key)
+		if s.logger != nil {
+			s.logger.Warn("option write rejected", slog.String("addon", s.sandbox), slog.String("key", key))
+		}
+		return err
+	}
+	if isUserSetting(key) {
+		// user settings are always writable
+		if err := s.opts.set(key, val, true); err != nil {
+			return err
+		}
+		if s.settingsChanged != nil {
+			s.settingsChanged()
+		}
+		return nil
+	}
+	if err := s.opts.Set(key, val); err != nil {
+		return err
+	}
+	if key == "app.throttle.ticks" && s.engine != nil {
+		return s.engine.SetTickRate(time.Duration(s.Get(key).Int64()))
+	}
+	if key == "log.level" && s.logger != nil {
+		lvl, err := hlog.ParseLevel(s.Get(key).String())
+		if err != nil {
+			return err
+		}
+		return s.logger.SetLevel(lvl)
+	}
+	if key == "log.scopes" && s.logScopes != nil {
+		return s.logScopes.Set(s.Get(key).String())
+	}
+	return nil
 }
 
 func (s *Session) Has(key string) bool {
@@ -180,9 +383,33 @@ func (s *Session) Dispatch(ev Event) {
 		s.Log().Warn("received <nil> event")
 		return
 	}
+	// deterministic engine delivers events synchronously
+	if s.engine != nil && s.engine.synchronous() {
+		s.mu.RLock()
+		disposed := s.disposed
+		s.mu.RUnlock()
+		if !disposed {
+			s.evstats.dispatched.Add(1)
+			s.engine.handleEvent(s, ev)
+			return
+		}
+	}
+	// events routed to event bus bypass main event loop
+	if s.engine != nil {
+		if bus := s.engine.ingressBus(ev.Scope()); bus != nil {
+			s.mu.RLock()
+			disposed := s.disposed
+			s.mu.RUnlock()
+			if !disposed {
+				s.evstats.dispatched.Add(1)
+				s.engine.enqueueOnBus(s, bus, eventJob{ev: ev})
+				return
+			}
+		}
+	}
 	s.mu.Lock()
 	if !s.disposed {
-		s.evch <- ev
+		s.enqueueEvent(ev)
 	} else {
 		s.Log().SystemDebug(
 			"session is disposed - skipping event dispatch",
@@ -193,6 +420,164 @@ func (s *Session) Dispatch(ev Event) {
 	s.mu.Unlock()
 }
 
+// dispatchNoWait dispatches event without blocking when event queue
+// is full, event is dropped unless its scope has coalesce overflow
+// policy. It is used by engine workers which must not wait for
+// the queue they are draining.
+func (s *Session) dispatchNoWait(ev Event) {
+	if s.engine != nil && s.engine.synchronous() {
+		s.Dispatch(ev)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disposed {
+		return
+	}
+	s.evstats.dispatched.Add(1)
+	policy := s.overflow.policy(ev.Scope())
+	if policy == OverflowBlock {
+		policy = OverflowDrop
+	}
+	s.queueEventPolicy(ev, policy)
+}
+
+// DispatchBatch dispatches events as single unit. Engine handles
+// the events in order without other events interleaving. Events which
+// scope has coalesce overflow policy, see app.events.overflow.scopes,
+// are coalesced within the batch so that only the latest event with same
+// scope and key is delivered, which is useful for high frequency events
+// such as progress updates.
+func (s *Session) DispatchBatch(events []Event) {
+	batch := newEventBatch(events, s.overflow)
+	if len(batch.events) == 0 {
+		return
+	}
+	s.evstats.dispatched.Add(uint64(len(batch.events) - 1))
+	s.evstats.coalesced.Add(uint64(countNonNil(events) - len(batch.events)))
+	s.Dispatch(batch)
+}
+
+func countNonNil(events []Event) (n int) {
+	for _, ev := range events {
+		if ev != nil {
+			n++
+		}
+	}
+	return n
+}
+
+// DispatchAt schedules the event to be dispatched at t. Scheduled events
+// are managed by the engine and can be cancelled with returned
+// ScheduledEvent. Event which becomes due while engine is paused
+// is delivered after engine is resumed.
+func (s *Session) DispatchAt(t time.Time, ev Event) *ScheduledEvent {
+	if ev == nil {
+		s.Log().Warn("received <nil> event")
+		return nil
+	}
+	if s.engine == nil {
+		s.Log().Warn("no engine to schedule event",
+			slog.String("scope", ev.Scope()),
+			slog.String("key", ev.Key()),
+		)
+		return nil
+	}
+	return s.engine.scheduler.schedule(t, ev)
+}
+
+// DispatchAfter schedules the event to be dispatched after d,
+// see DispatchAt.
+func (s *Session) DispatchAfter(d time.Duration, ev Event) *ScheduledEvent {
+	now := time.Now()
+	if s.engine != nil {
+		now = s.engine.Now()
+	}
+	return s.DispatchAt(now.Add(d), ev)
+}
+
+// DispatchContext dispatches the event as part of the trace in ctx
+// when tracer is set, see Application.WithTracer. Log attributes added
+// to ctx with hlog.ContextWith travel with the event, see Session.EventLog.
+func (s *Session) DispatchContext(ctx context.Context, ev Event) {
+	ev = WithLogAttrs(ev, hlog.ContextAttrs(ctx)...)
+	if s.engine != nil && s.engine.tracer != nil && ev != nil {
+		ev = WithTraceParent(ev, s.engine.tracer.Inject(ctx))
+	}
+	s.Dispatch(ev)
+}
+
+// DispatchRequest dispatches the event and waits until one of the listeners
+// replies to it, see RequestEvent and DispatchRequestContext.
+func (s *Session) DispatchRequest(ev Event) (Event, error) {
+	return s.DispatchRequestContext(context.Background(), ev)
+}
+
+// DispatchRequestContext dispatches the event and waits until one of the
+// listeners replies to it, see RequestEvent. Error is returned right away
+// when the event is not registered or no service listens for it. Otherwise
+// error is returned when listener replied with error, session was destroyed
+// or ctx is done. When ctx has no deadline app.events.request.timeout applies.
+func (s *Session) DispatchRequestContext(ctx context.Context, ev Event) (Event, error) {
+	if ev == nil {
+		return nil, fmt.Errorf("%w: received <nil> request", ErrEngine)
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	if s.engine != nil {
+		if err := s.engine.checkRequest(ev); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(s.Get("app.events.request.timeout").Int64()))
+		defer cancel()
+	}
+
+	req := newRequestEvent(ev)
+	s.DispatchContext(ctx, req)
+
+	select {
+	case resp := <-req.reply:
+		if everr, ok := resp.(interface{ Err() error }); ok && everr.Err() != nil {
+			return resp, everr.Err()
+		}
+		return resp, nil
+	case <-s.Done():
+		if err := s.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrSessionDestroyed
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: request %s.%s: %w", ErrEngine, ev.Scope(), ev.Key(), ctx.Err())
+	}
+}
+
+// Replay returns events recorded in event journal at or after since
+// which match given pattern e.g. "services.*", see Service.OnEvent.
+// Empty pattern matches all events. Event journal is enabled
+// by setting app.events.journal option.
+func (s *Session) Replay(since time.Time, pattern string) ([]Event, error) {
+	s.mu.RLock()
+	journal := s.journal
+	s.mu.RUnlock()
+	if journal == nil {
+		return nil, fmt.Errorf("%w: event journal is not enabled", ErrEngine)
+	}
+	return journal.replay(since, pattern)
+}
+
+// DeadLetters returns most recent events which listeners failed to handle.
+func (s *Session) DeadLetters() []DeadLetter {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	dls := make([]DeadLetter, len(s.deadLetters))
+	copy(dls, s.deadLetters)
+	return dls
+}
+
 func (s *Session) API(addonName string) (API, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -251,10 +636,159 @@ func (s *Session) RuntimeOpts() *vars.Map {
 	return opts
 }
 
+// SessionDescription is snapshot of session state, see Session.Describe.
+type SessionDescription struct {
+	Profile  string               `json:"profile"`
+	Ready    bool                 `json:"ready"`
+	Err      string               `json:"err,omitempty"`
+	Uptime   time.Duration        `json:"uptime"`
+	Paused   bool                 `json:"paused"`
+	TickRate time.Duration        `json:"tick_rate"`
+	Events   EventStats           `json:"events"`
+	Services []ServiceDescription `json:"services"`
+	Addons   []AddonHealth        `json:"addons"`
+	Config   map[string]string    `json:"config"`
+	Settings map[string]string    `json:"settings"`
+	// Sources of options which values were not defaults, see OptionSource.
+	Sources map[string]string `json:"sources,omitempty"`
+	// InstanceID and RunID identify application instance and its run.
+	InstanceID string `json:"instance_id,omitempty"`
+	RunID      string `json:"run_id,omitempty"`
+	// Instance is name of isolated instance selected with --instance.
+	Instance string `json:"instance,omitempty"`
+}
+
+// ServiceDescription describes state of the service.
+type ServiceDescription struct {
+	Addr      string    `json:"addr"`
+	Running   bool      `json:"running"`
+	StartedAt time.Time `json:"started_at"`
+	StoppedAt time.Time `json:"stopped_at"`
+	Errs      []string  `json:"errs,omitempty"`
+}
+
+// optionRedactor returns redactor masking values of sensitive options
+// e.g. tokens and passwords, see log.redact and log.secrets options.
+func (s *Session) optionRedactor() *hlog.Redactor {
+	if s.redactor != nil {
+		return s.redactor
+	}
+	r, _ := hlog.NewRedactor()
+	return r
+}
+
+// Describe returns snapshot of session, engine and services state.
+func (s *Session) Describe() SessionDescription {
+	desc := SessionDescription{
+		Profile:  s.Profile(),
+		Events:   s.EventStats(),
+		Config:   make(map[string]string),
+		Settings: make(map[string]string),
+	}
+	desc.InstanceID = s.Get("app.instance.id").String()
+	desc.RunID = s.Get("app.run.id").String()
+	desc.Instance = s.Get("app.instance.name").String()
+	select {
+	case <-s.Ready():
+		desc.Ready = true
+	default:
+	}
+	if err := s.Err(); err != nil {
+		desc.Err = err.Error()
+	}
+	if s.engine != nil {
+		desc.Uptime = s.engine.uptime()
+		desc.Paused = s.engine.Paused()
+		desc.TickRate = s.engine.TickRate()
+	}
+
+	s.mu.RLock()
+	for addr, info := range s.svss {
+		sd := ServiceDescription{
+			Addr:      addr,
+			Running:   info.Running(),
+			StartedAt: info.StartedAt(),
+			StoppedAt: info.StoppedAt(),
+		}
+		for _, err := range info.Errs() {
+			sd.Errs = append(sd.Errs, err.Error())
+		}
+		sort.Strings(sd.Errs)
+		desc.Services = append(desc.Services, sd)
+	}
+	s.mu.RUnlock()
+	sort.Slice(desc.Services, func(i, j int) bool {
+		return desc.Services[i].Addr < desc.Services[j].Addr
+	})
+
+	desc.Addons = s.AddonHealth()
+
+	redactor := s.optionRedactor()
+	s.Config().Range(func(v vars.Variable) bool {
+		desc.Config[v.Name()] = redactor.RedactString(v.Name(), v.String())
+		return true
+	})
+	s.Settings().Range(func(v vars.Variable) bool {
+		desc.Settings[v.Name()] = redactor.RedactString(v.Name(), v.String())
+		return true
+	})
+	s.mu.RLock()
+	if len(s.optSources) > 0 {
+		desc.Sources = make(map[string]string, len(s.optSources))
+		for key, src := range s.optSources {
+			desc.Sources[key] = src
+		}
+	}
+	s.mu.RUnlock()
+	return desc
+}
+
+// OptionSource returns where value of the option came from, options are
+// applied in order of precedence from lowest to highest:
+//
+//	default        default value of the option
+//	option         set with happy.Option when creating application
+//	profile:<name> set with Application.Profile for active profile
+//	state          persisted settings
+//	user           persisted user.* settings
+//	file:<path>    config file
+//	env:<NAME>     environment variable e.g. MYAPP_LOG_LEVEL
+//	flag           command line flag e.g. --instance
+//
+// Empty string is returned for unknown options.
+func (s *Session) OptionSource(key string) string {
+	s.mu.RLock()
+	src, ok := s.optSources[key]
+	s.mu.RUnlock()
+	if ok {
+		return src
+	}
+	if s.Has(key) {
+		return "default"
+	}
+	return ""
+}
+
+func (s *Session) setOptionSource(key, src string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.optSources == nil {
+		s.optSources = make(map[string]string)
+	}
+	s.optSources[key] = src
+}
+
 func (s *Session) start() error {
 	s.ready, s.readyFunc = context.WithCancel(context.Background())
-	s.sig, s.sigRelease = signal.NotifyContext(s, os.Interrupt, os.Kill)
 	s.evch = make(chan Event, 100)
+	overflow, err := parseOverflowPolicies(
+		s.Get("app.events.overflow").String(),
+		s.Get("app.events.overflow.scopes").String(),
+	)
+	if err != nil {
+		return err
+	}
+	s.overflow = overflow
 	s.Log().SystemDebug("session started")
 	return nil
 }
@@ -279,6 +813,133 @@ func (s *Session) registerAPI(addonName string, api API) error {
 	return nil
 }
 
+// sandboxed returns session sharing state with s which
+// can only set options in namespace of given addon.
+func (s *Session) sandboxed(addon string) *Session {
+	if addon == "" || s.sandbox == addon {
+		return s
+	}
+	return &Session{sessionState: s.sessionState, sandbox: addon, ctx: s.ctx}
+}
+
+// withContext returns session sharing state with s which
+// is done when ctx is done, ctx must be derived from s.
+func (s *Session) withContext(ctx context.Context) *Session {
+	return &Session{sessionState: s.sessionState, sandbox: s.sandbox, ctx: ctx}
+}
+
+// Assets returns file system of application assets with
+// assets provided by addons mounted under addons/<addon>.
+func (s *Session) Assets() *Assets {
+	return s.assets
+}
+
+// Capabilities returns capabilities published by addons sorted by name.
+func (s *Session) Capabilities() []CapabilityInfo {
+	s.mu.RLock()
+	infos := make([]CapabilityInfo, 0, len(s.capabilities))
+	for _, c := range s.capabilities {
+		infos = append(infos, CapabilityInfo{
+			Name:     c.name,
+			Provider: c.provider,
+			Type:     fmt.Sprintf("%T", c.impl),
+		})
+	}
+	s.mu.RUnlock()
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+func (s *Session) capability(name string) (capability, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	c, ok := s.capabilities[name]
+	return c, ok
+}
+
+func (s *Session) registerCapability(c capability) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.capabilities == nil {
+		s.capabilities = make(map[string]capability)
+	}
+	if existing, ok := s.capabilities[c.name]; ok {
+		return fmt.Errorf("%w: %s provides capability %s already provided by %s",
+			ErrAddon, c.provider, c.name, existing.provider)
+	}
+	s.capabilities[c.name] = c
+	return nil
+}
+
+// AddonEnabled reports whether addon with given name is
+// registered and not disabled with app.addons.disabled option.
+func (s *Session) AddonEnabled(name string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, info := range s.addons {
+		if info.name == name {
+			return !info.disabled
+		}
+	}
+	return false
+}
+
+// AddonHealth returns health of registered addons in registration order,
+// health checks set with Addon.OnHealthCheck are called on each call.
+func (s *Session) AddonHealth() []AddonHealth {
+	s.mu.RLock()
+	addons := s.addons
+	s.mu.RUnlock()
+	health := make([]AddonHealth, 0, len(addons))
+	for _, info := range addons {
+		health = append(health, info.health(s))
+	}
+	return health
+}
+
+func (s *Session) registerAddonHealth(addon *Addon) {
+	info := &addonHealthInfo{
+		name:     addon.info.Name,
+		version:  addon.info.Version.String(),
+		check:    addon.healthAction,
+		disabled: addon.disabled,
+	}
+	for _, svc := range addon.svcs {
+		info.svcs = append(info.svcs, svc.name)
+	}
+	s.mu.Lock()
+	s.addons = append(s.addons, info)
+	s.mu.Unlock()
+}
+
+// serviceInfos returns snapshot of registered services.
+func (s *Session) serviceInfos() []*ServiceInfo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	infos := make([]*ServiceInfo, 0, len(s.svss))
+	for _, info := range s.svss {
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+func (s *Session) addDeadLetter(dl DeadLetter) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.deadLetters) >= maxDeadLetters {
+		s.deadLetters = s.deadLetters[1:]
+	}
+	s.deadLetters = append(s.deadLetters, dl)
+}
+
+func (s *Session) setJournal(journal *eventJournal) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.journal = journal
+}
+
 func (s *Session) setServiceInfo(info *ServiceInfo) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -289,3 +950,111 @@ func (s *Session) setServiceInfo(info *ServiceInfo) {
 
 	s.svss[info.addr.String()] = info
 }
+
+// EventStats returns snapshot of event queue metrics.
+func (s *Session) EventStats() EventStats {
+	stats := EventStats{
+		QueueDepth:    len(s.evch),
+		QueueCapacity: cap(s.evch),
+		Dispatched:    s.evstats.dispatched.Load(),
+		Dropped:       s.evstats.dropped.Load(),
+		Coalesced:     s.evstats.coalesced.Load(),
+		MaxLatency:    time.Duration(s.evstats.maxLatency.Load()),
+	}
+	if delivered := s.evstats.delivered.Load(); delivered > 0 {
+		stats.AvgLatency = time.Duration(s.evstats.latency.Load() / int64(delivered))
+	}
+	s.cmu.Lock()
+	stats.Pending = len(s.ckeys)
+	s.cmu.Unlock()
+	return stats
+}
+
+// enqueueEvent adds event to event queue applying overflow policy
+// of the event scope when queue is full. Caller must hold s.mu.
+func (s *Session) enqueueEvent(ev Event) {
+	s.evstats.dispatched.Add(1)
+	s.queueEvent(ev)
+}
+
+// queueEvent is enqueueEvent without counting event as dispatched.
+func (s *Session) queueEvent(ev Event) {
+	s.queueEventPolicy(ev, s.overflow.policy(ev.Scope()))
+}
+
+// queueEventPolicy queues event applying given overflow policy
+// when queue is full. Caller must hold s.mu.
+func (s *Session) queueEventPolicy(ev Event, policy OverflowPolicy) {
+	if policy == OverflowBlock {
+		s.evch <- ev
+		return
+	}
+	select {
+	case s.evch <- ev:
+		return
+	default:
+	}
+	if policy == OverflowDrop {
+		s.evstats.dropped.Add(1)
+		s.Log().SystemDebug(
+			"event queue full - dropping event",
+			slog.String("scope", ev.Scope()),
+			slog.String("key", ev.Key()),
+		)
+		return
+	}
+
+	skey := ev.Scope() + "." + ev.Key()
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	if s.coalesced == nil {
+		s.coalesced = make(map[string]Event)
+	}
+	if _, ok := s.coalesced[skey]; ok {
+		s.evstats.coalesced.Add(1)
+	} else {
+		s.ckeys = append(s.ckeys, skey)
+	}
+	s.coalesced[skey] = ev
+}
+
+// requeueEvents puts events held by paused engine back to event queue,
+// events are not counted as dispatched again. Events are queued as
+// single batch when queue has room, otherwise each event is queued
+// applying overflow policy of its scope.
+func (s *Session) requeueEvents(events []Event) {
+	if len(events) == 0 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.disposed {
+		s.Log().SystemDebug("session is disposed - dropping held events", slog.Int("events", len(events)))
+		return
+	}
+	select {
+	case s.evch <- &eventBatch{ts: time.Now(), events: events}:
+		return
+	default:
+	}
+	for _, ev := range events {
+		s.queueEvent(ev)
+	}
+}
+
+// takeCoalesced returns coalesced events in order they were
+// first dispatched.
+func (s *Session) takeCoalesced() []Event {
+	s.cmu.Lock()
+	defer s.cmu.Unlock()
+	if len(s.ckeys) == 0 {
+		return nil
+	}
+	events := make([]Event, 0, len(s.ckeys))
+	for _, skey := range s.ckeys {
+		events = append(events, s.coalesced[skey])
+		delete(s.coalesced, skey)
+	}
+	s.ckeys = nil
+	return events
+}
@@ -0,0 +1,225 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// userSettingsPrefix is namespace of options which are persisted
+// automatically when app.fs.enabled is set e.g. user.theme.
+const userSettingsPrefix = "user."
+
+// userSettingsFile is file in app.path.config where user.* options
+// are stored, previous version is kept with .bak suffix.
+const userSettingsFile = "user.happy"
+
+type persistentUserSettings struct {
+	Date     time.Time         `json:"date"`
+	Settings []persistentValue `json:"settings"`
+}
+
+// userSettings saves user.* options debounced so that burst of changes
+// is written once.
+type userSettings struct {
+	mu       sync.Mutex
+	file     string
+	debounce time.Duration
+	timer    *time.Timer
+	pending  bool
+	save     func() error
+	logger   *hlog.Logger
+}
+
+// isUserSetting reports whether key is in user.* namespace.
+func isUserSetting(key string) bool {
+	return strings.HasPrefix(key, userSettingsPrefix) && len(key) > len(userSettingsPrefix)
+}
+
+// changed schedules save after debounce period, previously
+// scheduled save is postponed.
+func (us *userSettings) changed() {
+	us.mu.Lock()
+	us.pending = true
+	if us.debounce > 0 {
+		if us.timer != nil {
+			us.timer.Stop()
+		}
+		us.timer = time.AfterFunc(us.debounce, us.flush)
+		us.mu.Unlock()
+		return
+	}
+	us.mu.Unlock()
+	us.flush()
+}
+
+// flush saves pending changes.
+func (us *userSettings) flush() {
+	us.mu.Lock()
+	defer us.mu.Unlock()
+	if us.timer != nil {
+		us.timer.Stop()
+		us.timer = nil
+	}
+	if !us.pending {
+		return
+	}
+	us.pending = false
+	if err := us.save(); err != nil {
+		us.logger.Error("failed to save user settings", err, slog.String("file", us.file))
+	}
+}
+
+// loadUserSettings restores user.* options saved on previous runs and
+// saves them on change. Corrupted settings file is moved aside with
+// .corrupt suffix and settings are restored from backup when it is valid.
+func (a *Application) loadUserSettings() error {
+	if !a.session.Get("app.fs.enabled").Bool() {
+		return nil
+	}
+	cpath := a.session.Get("app.path.config").String()
+	if cpath == "" {
+		return fmt.Errorf("%w: config path empty", ErrApplication)
+	}
+	file := filepath.Join(cpath, userSettingsFile)
+
+	settings, err := readUserSettings(file)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		a.logger.Warn("user settings are corrupted, restoring from backup",
+			slog.String("file", file),
+			slog.String("err", err.Error()),
+		)
+		if err := os.Rename(file, file+".corrupt"); err != nil {
+			return fmt.Errorf("%w: failed to move corrupted user settings: %s", ErrApplication, err.Error())
+		}
+		if settings, err = readUserSettings(file + ".bak"); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				a.logger.Warn("user settings backup is corrupted, using defaults",
+					slog.String("file", file+".bak"),
+					slog.String("err", err.Error()),
+				)
+			}
+			settings = nil
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		// crash between rotating backup and writing new file
+		settings, _ = readUserSettings(file + ".bak")
+	}
+
+	for _, setting := range settings {
+		val, _ := vars.NewValueAs(setting.Value, vars.Kind(setting.Kind))
+		if err := a.session.opts.set(setting.Key, val.Any(), true); err != nil {
+			return err
+		}
+		a.session.setOptionSource(setting.Key, "user")
+	}
+
+	a.userSettings = &userSettings{
+		file:     file,
+		debounce: time.Duration(a.session.Get("app.settings.debounce").Int64()),
+		save: func() error {
+			return writeUserSettings(file, a.session.UserSettings())
+		},
+		logger: a.logger,
+	}
+	a.session.settingsChanged = a.userSettings.changed
+	a.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
+		a.userSettings.flush()
+		return nil
+	})
+	if len(settings) > 0 {
+		a.logger.SystemDebug("loaded user settings",
+			slog.String("file", file),
+			slog.Int("settings", len(settings)),
+		)
+	}
+	return nil
+}
+
+// readUserSettings reads and validates settings file.
+func readUserSettings(file string) ([]persistentValue, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var us persistentUserSettings
+	if err := json.Unmarshal(data, &us); err != nil {
+		return nil, err
+	}
+	for _, setting := range us.Settings {
+		if !isUserSetting(setting.Key) {
+			return nil, fmt.Errorf("%w: invalid user setting %q", ErrApplication, setting.Key)
+		}
+		if _, err := vars.NewValueAs(setting.Value, vars.Kind(setting.Kind)); err != nil {
+			return nil, err
+		}
+	}
+	return us.Settings, nil
+}
+
+// writeUserSettings writes settings to temporary file first and replaces
+// the settings file with it, previous file is kept as backup.
+func writeUserSettings(file string, settings *vars.Map) error {
+	us := persistentUserSettings{Date: time.Now().UTC()}
+	settings.Range(func(v vars.Variable) bool {
+		us.Settings = append(us.Settings, persistentValue{
+			Key:   v.Name(),
+			Kind:  uint8(v.Kind()),
+			Value: v.Any(),
+		})
+		return true
+	})
+	data, err := json.MarshalIndent(us, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if _, err := readUserSettings(file); err == nil {
+		if err := os.Rename(file, file+".bak"); err != nil {
+			return err
+		}
+	}
+	return os.Rename(tmp, file)
+}
+
+// UserSettings returns options in user.* namespace which are persisted
+// across runs when app.fs.enabled is set.
+func (s *Session) UserSettings() *vars.Map {
+	settings := &vars.Map{}
+	s.opts.db.Range(func(v vars.Variable) bool {
+		if isUserSetting(v.Name()) {
+			_ = settings.Store(v.Name(), v.Value())
+		}
+		return true
+	})
+	return settings
+}
@@ -0,0 +1,65 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestAppUserSettings(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, userSettingsFile)
+	run := func(debounce time.Duration) *Application {
+		app := New(
+			Option("log.console", false),
+			Option("app.fs.enabled", true),
+			Option("app.settings.debounce", debounce),
+		)
+		testutils.NoError(t, app.session.opts.db.Store("app.path.config", dir))
+		testutils.NoError(t, app.loadUserSettings())
+		return app
+	}
+
+	first := run(time.Hour)
+	testutils.NoError(t, first.session.Set("user.theme", "dark"))
+	testutils.NoError(t, first.session.Set("user.theme", "light"))
+	testutils.NoError(t, first.session.Set("user.volume", 7))
+	_, err := os.Stat(file)
+	testutils.ErrorIs(t, err, os.ErrNotExist, "save should be debounced")
+	first.userSettings.flush()
+
+	second := run(0)
+	testutils.Equal(t, "light", second.session.Get("user.theme").String())
+	testutils.Equal(t, 7, second.session.Get("user.volume").Int())
+	testutils.Equal(t, "user", second.session.OptionSource("user.theme"))
+	testutils.NoError(t, second.session.Set("user.theme", "solarized"))
+
+	// backup is used when settings file is corrupted
+	testutils.NoError(t, os.WriteFile(file, []byte(`{"settings": [`), 0600))
+	third := run(0)
+	testutils.Equal(t, "light", third.session.Get("user.theme").String())
+	_, err = os.Stat(file + ".corrupt")
+	testutils.NoError(t, err)
+
+	// defaults are used when backup is corrupted as well
+	testutils.NoError(t, os.WriteFile(file, []byte(`garbage`), 0600))
+	testutils.NoError(t, os.WriteFile(file+".bak", []byte(`{"settings": [{"key": "app.name"}]}`), 0600))
+	fourth := run(0)
+	testutils.False(t, fourth.session.Has("user.theme"))
+}
+
+func TestSessionUserSettingsWithoutFS(t *testing.T) {
+	app := New(Option("log.console", false))
+	testutils.NoError(t, app.loadUserSettings())
+	testutils.NoError(t, app.session.Set("user.theme", "dark"))
+	testutils.NoError(t, app.session.Set("user.theme", "light"))
+	testutils.Equal(t, "light", app.session.Get("user.theme").String())
+	testutils.Equal(t, 1, app.session.UserSettings().Len())
+}
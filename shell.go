@@ -0,0 +1,284 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slog"
+)
+
+const (
+	shellCommandName = "shell"
+	shellHistoryFile = "shell_history"
+	shellHistoryMax  = 500
+)
+
+// shellCommand returns command running interactive shell. Typed lines are
+// parsed and executed as sub commands of root while session and services
+// keep running. Input is read line by line without line editor so tab
+// completion and arrow key history are not available, line ending with ?
+// lists completions for last word instead.
+func shellCommand(root *Command) *Command {
+	cmd := NewCommand(
+		shellCommandName,
+		Option("usage", "start interactive shell"),
+		Option("description", "Interactive shell executes commands within running session, type help for builtins. Shell reads plain lines without line editor so tab completion and arrow keys are not supported, end line with ? to list completions for last word and use history and !<n> to repeat commands."),
+		Option("category", "GENERAL"),
+	)
+	cmd.walksTree = true
+	cmd.Do(func(sess *Session, args Args) error {
+		return newShell(sess, root).run(os.Stdin, os.Stdout)
+	})
+	return cmd
+}
+
+type shell struct {
+	sess    *Session
+	root    *Command
+	history []string
+	hfile   string
+}
+
+func newShell(sess *Session, root *Command) *shell {
+	sh := &shell{sess: sess, root: root}
+	if sess.Get("app.fs.enabled").Bool() {
+		if cache := sess.Get("app.path.cache").String(); cache != "" {
+			sh.hfile = filepath.Join(cache, shellHistoryFile)
+			if data, err := os.ReadFile(sh.hfile); err == nil {
+				sh.history = strings.Split(strings.TrimSpace(string(data)), "\n")
+			}
+		}
+	}
+	return sh
+}
+
+func (sh *shell) run(in io.Reader, out io.Writer) error {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(in)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-sh.sess.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		fmt.Fprintf(out, "%s> ", sh.root.name)
+		var (
+			line string
+			ok   bool
+		)
+		select {
+		case <-sh.sess.Done():
+			fmt.Fprintln(out)
+			return nil
+		case line, ok = <-lines:
+		}
+		if !ok {
+			fmt.Fprintln(out)
+			return nil
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "!") {
+			n, err := strconv.Atoi(line[1:])
+			if err != nil || n < 1 || n > len(sh.history) {
+				fmt.Fprintf(out, "no such history entry: %s\n", line)
+				continue
+			}
+			line = sh.history[n-1]
+			fmt.Fprintln(out, line)
+		}
+
+		switch line {
+		case "exit", "quit":
+			return nil
+		case "history":
+			for i, h := range sh.history {
+				fmt.Fprintf(out, "%4d  %s\n", i+1, h)
+			}
+			continue
+		case "help":
+			fmt.Fprintln(out, "builtins: help, history, !<n>, exit")
+			fmt.Fprintln(out, "commands: "+strings.Join(sh.complete(nil, ""), " "))
+			fmt.Fprintln(out, "end line with ? to list completions, tab completion is not supported")
+			continue
+		}
+
+		if strings.HasSuffix(line, "?") {
+			words := shellSplit(strings.TrimSuffix(line, "?"))
+			prefix := ""
+			if len(words) > 0 && !strings.HasSuffix(strings.TrimSuffix(line, "?"), " ") {
+				prefix = words[len(words)-1]
+				words = words[:len(words)-1]
+			}
+			fmt.Fprintln(out, strings.Join(sh.complete(words, prefix), " "))
+			continue
+		}
+
+		sh.addHistory(line)
+		if err := sh.exec(shellSplit(line)); err != nil {
+			fmt.Fprintln(out, sh.sess.Theme().Error.Render(err.Error(), sh.sess.Color()))
+		}
+	}
+}
+
+func (sh *shell) addHistory(line string) {
+	if n := len(sh.history); n > 0 && sh.history[n-1] == line {
+		return
+	}
+	sh.history = append(sh.history, line)
+	if len(sh.history) > shellHistoryMax {
+		sh.history = sh.history[len(sh.history)-shellHistoryMax:]
+	}
+	if sh.hfile == "" {
+		return
+	}
+	if err := os.WriteFile(sh.hfile, []byte(strings.Join(sh.history, "\n")+"\n"), 0600); err != nil {
+		sh.sess.Log().Warn("failed to save shell history", slog.String("err", err.Error()))
+	}
+}
+
+// complete returns sub command names and flags of command
+// resolved from words which start with prefix.
+func (sh *shell) complete(words []string, prefix string) []string {
+	cmd := sh.root
+	for _, w := range words {
+		if sub, ok := cmd.getSubCommand(w); ok {
+			cmd = sub
+		}
+	}
+	var candidates []string
+	for name, sub := range cmd.subCommands {
+		if !sub.hidden && name != shellCommandName {
+			candidates = append(candidates, name)
+		}
+	}
+	if cmd != sh.root {
+		for _, flag := range cmd.flags.Flags() {
+			if !flag.Hidden() {
+				candidates = append(candidates, flag.Flag())
+			}
+		}
+	}
+	sort.Strings(candidates)
+	var res []string
+	for _, c := range candidates {
+		if strings.HasPrefix(c, prefix) {
+			res = append(res, c)
+		}
+	}
+	return res
+}
+
+// exec parses words with command flags and executes resolved command
+// Before chain (excluding root), Do and After actions.
+func (sh *shell) exec(words []string) (err error) {
+	if len(words) == 0 {
+		return nil
+	}
+	if r, ok := sh.root.flags.(interface{ Reset() }); ok {
+		r.Reset()
+	}
+	first := words[0]
+	words, raw := splitRawArgs(words)
+	if err := sh.root.flags.Parse(append([]string{os.Args[0]}, words...)); err != nil {
+		return fmt.Errorf("%w: %s", ErrCommand, err.Error())
+	}
+
+	cmd := sh.root
+	for _, set := range sh.root.flags.GetActiveSets()[1:] {
+		sub, ok := cmd.getSubCommand(set.Name())
+		if !ok {
+			break
+		}
+		cmd = sub
+	}
+	if cmd == sh.root || cmd.name == shellCommandName {
+		return fmt.Errorf("%w: unknown command: %s", ErrCommand, first)
+	}
+	if cmd.doAction == nil {
+		return fmt.Errorf("%w: %s requires subcommand", ErrCommand, cmd.name)
+	}
+	cmd.setRawArgs(raw)
+	cmd.warnDeprecated(sh.sess)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%w: %s: panic: %v", ErrCommandAction, cmd.name, r)
+		}
+	}()
+
+	var chain []*Command
+	for c := cmd; c != nil && c != sh.root; c = c.parent {
+		chain = append([]*Command{c}, chain...)
+	}
+	for _, c := range chain {
+		if err = c.callBeforeAction(sh.sess, c == cmd); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = cmd.callDoAction(sh.sess)
+	}
+	if err != nil {
+		err = errors.Join(err, cmd.callAfterFailureAction(sh.sess, err))
+	} else {
+		err = cmd.callAfterSuccessAction(sh.sess)
+	}
+	return errors.Join(err, cmd.callAfterAlwaysAction(sh.sess))
+}
+
+// shellSplit splits line into words honoring single and double quotes.
+func shellSplit(line string) []string {
+	var (
+		words []string
+		word  strings.Builder
+		quote rune
+		inw   bool
+	)
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inw = true
+		case r == ' ' || r == '\t':
+			if inw {
+				words = append(words, word.String())
+				word.Reset()
+				inw = false
+			}
+		default:
+			word.WriteRune(r)
+			inw = true
+		}
+	}
+	if inw {
+		words = append(words, word.String())
+	}
+	return words
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/varflag"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestShell(t *testing.T) {
+	root := NewCommand(filepath.Base(os.Args[0]))
+	var (
+		greeted []string
+		raw     []string
+	)
+	greet := NewCommand("greet")
+	name, err := varflag.New("name", "world", "name to greet")
+	testutils.NoError(t, err)
+	greet.AddFlag(name)
+	greet.Do(func(sess *Session, args Args) error {
+		greeted = append(greeted, args.Flag("name").String())
+		raw = args.Raw()
+		return nil
+	})
+	root.AddSubCommand(greet)
+	root.AddSubCommand(shellCommand(root))
+	testutils.NoError(t, root.verify())
+
+	sess := newTestSession(t)
+	var out strings.Builder
+	in := strings.NewReader("greet --name happy\ngreet\ngr?\ngreet --n?\nhistory\n!1\nunknown\n-- x\ngreet -- npm --name x\nexit\ngreet\n")
+	testutils.NoError(t, newShell(sess, root).run(in, &out))
+
+	testutils.EqualAny(t, []string{"happy", "world", "happy", "world"}, greeted)
+	testutils.EqualAny(t, []string{"npm", "--name", "x"}, raw)
+	output := out.String()
+	testutils.True(t, strings.Contains(output, "> greet\n"), "missing command completion")
+	testutils.True(t, strings.Contains(output, "> --name\n"), "missing flag completion")
+	testutils.True(t, strings.Contains(output, "   2  greet\n"), "missing history")
+	testutils.True(t, strings.Contains(output, "unknown command: unknown"), "missing unknown command error")
+}
+
+func TestShellSplit(t *testing.T) {
+	testutils.EqualAny(t, []string{"run", "--msg", "hello world", "x"}, shellSplit(`run --msg "hello world" x`))
+	testutils.EqualAny(t, []string{"a", ""}, shellSplit(`a ''`))
+}
+
+func TestSplitRawArgs(t *testing.T) {
+	parse, raw := splitRawArgs([]string{"app", "run", "--x", "--", "npm", "start", "--", "y"})
+	testutils.EqualAny(t, []string{"app", "run", "--x"}, parse)
+	testutils.EqualAny(t, []string{"npm", "start", "--", "y"}, raw)
+
+	parse, raw = splitRawArgs([]string{"app", "run"})
+	testutils.EqualAny(t, []string{"app", "run"}, parse)
+	testutils.Equal(t, 0, len(raw))
+}
@@ -0,0 +1,243 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// shutdownPhases groups running services into phases in which they are
+// stopped. Service is stopped only after all services depending on it
+// have been stopped, services in the same dependency level are ordered
+// by stop priority, lower priority stops first.
+func shutdownPhases(registry map[string]*serviceContainer) [][]string {
+	names := make(map[string]string)
+	for addr, svcc := range registry {
+		if svcc.info.Running() {
+			names[svcc.svc.name] = addr
+		}
+	}
+
+	// dependents of each service
+	dependents := make(map[string][]string)
+	for _, addr := range names {
+		for _, dep := range registry[addr].svc.deps {
+			if depaddr, ok := names[dep]; ok {
+				dependents[depaddr] = append(dependents[depaddr], addr)
+			}
+		}
+	}
+
+	levels := make(map[string]int)
+	visiting := make(map[string]bool)
+	var level func(addr string) int
+	level = func(addr string) int {
+		if l, ok := levels[addr]; ok {
+			return l
+		}
+		if visiting[addr] {
+			// dependency cycle, stop these services together
+			return 0
+		}
+		visiting[addr] = true
+		l := 0
+		for _, dependent := range dependents[addr] {
+			if dl := level(dependent) + 1; dl > l {
+				l = dl
+			}
+		}
+		visiting[addr] = false
+		levels[addr] = l
+		return l
+	}
+
+	type phaseKey struct {
+		level    int
+		priority int
+	}
+	phases := make(map[phaseKey][]string)
+	for _, addr := range names {
+		key := phaseKey{level(addr), registry[addr].svc.stopPriority}
+		phases[key] = append(phases[key], addr)
+	}
+	keys := make([]phaseKey, 0, len(phases))
+	for key := range phases {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].level != keys[j].level {
+			return keys[i].level < keys[j].level
+		}
+		return keys[i].priority < keys[j].priority
+	})
+
+	ordered := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		phase := phases[key]
+		sort.Strings(phase)
+		ordered = append(ordered, phase)
+	}
+	return ordered
+}
+
+// stopServices stops running services phase by phase, waiting each
+// phase at most timeout. Returned error reports services which failed
+// to stop cleanly.
+func (e *Engine) stopServices(sess *Session, timeout time.Duration) error {
+	e.mu.RLock()
+	registry := e.registry
+	phases := shutdownPhases(registry)
+	e.mu.RUnlock()
+
+	var (
+		mu     sync.Mutex
+		failed []string
+		errs   []error
+	)
+	for i, phase := range phases {
+		sess.Log().SystemDebug("stopping services",
+			slog.Int("phase", i+1),
+			slog.String("services", strings.Join(phase, ",")),
+		)
+		// pending is filled before stop goroutines start since
+		// they remove stopped services from it.
+		pending := make(map[string]bool, len(phase))
+		for _, addr := range phase {
+			pending[addr] = true
+		}
+		var wg sync.WaitGroup
+		for _, addr := range phase {
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				// service context is cancelled with engine context,
+				// wait until its tick loop has exited.
+				<-registry[addr].Done()
+				err := e.stopService(sess, addr, nil)
+				mu.Lock()
+				defer mu.Unlock()
+				delete(pending, addr)
+				if err != nil {
+					failed = append(failed, addr)
+					errs = append(errs, err)
+				}
+			}(addr)
+		}
+
+		done := make(chan struct{})
+		go func() {
+			wg.Wait()
+			close(done)
+		}()
+		if timeout <= 0 {
+			<-done
+			continue
+		}
+		timer := time.NewTimer(timeout)
+		select {
+		case <-done:
+		case <-timer.C:
+			mu.Lock()
+			for addr := range pending {
+				failed = append(failed, addr)
+				errs = append(errs, fmt.Errorf("%w: service %s did not stop within %s", ErrEngine, addr, timeout))
+			}
+			mu.Unlock()
+		}
+		timer.Stop()
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(failed) == 0 {
+		return nil
+	}
+	sort.Strings(failed)
+	sess.Log().Warn("services failed to stop cleanly", slog.String("services", strings.Join(failed, ",")))
+	return errors.Join(errs...)
+}
+
+// exitPriorityCleanup is priority of internal exit funcs releasing
+// resources of the application, they are called after exit funcs
+// added with AddExitFunc.
+const exitPriorityCleanup = math.MaxInt32
+
+// ExitFunc is called with exit code when application exits.
+type ExitFunc func(sess *Session, code int) error
+
+type exitFunc struct {
+	priority int
+	fn       ExitFunc
+}
+
+// AddExitFunc adds fn called when application exits before engine and
+// services are stopped. Exit funcs are called one by one in order of
+// priority, lower first, and in order they were added within same
+// priority. All exit funcs must complete within app.exit.timeout,
+// exit funcs not called by then are skipped.
+func (a *Application) AddExitFunc(priority int, fn ExitFunc) {
+	a.addExitFunc(priority, fn)
+}
+
+func (a *Application) addExitFunc(priority int, fn ExitFunc) {
+	a.exitFunc = append(a.exitFunc, exitFunc{priority: priority, fn: fn})
+}
+
+// callExitFuncs calls exit funcs in order of priority until
+// app.exit.timeout is exceeded.
+func (a *Application) callExitFuncs(code int) {
+	if len(a.exitFunc) == 0 {
+		return
+	}
+	funcs := append([]exitFunc{}, a.exitFunc...)
+	sort.SliceStable(funcs, func(i, j int) bool {
+		return funcs[i].priority < funcs[j].priority
+	})
+
+	var timeout <-chan time.Time
+	if d := time.Duration(a.session.Get("app.exit.timeout").Int64()); d > 0 {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for i, ef := range funcs {
+		started := time.Now()
+		done := make(chan error, 1)
+		go func(ef exitFunc) {
+			// panicking exit func must not block exit
+			defer func() {
+				if r := recover(); r != nil {
+					done <- fmt.Errorf("%w: exit func panicked: %v", ErrApplication, r)
+				}
+			}()
+			done <- ef.fn(a.session, code)
+		}(ef)
+		select {
+		case err := <-done:
+			if err != nil {
+				a.logger.Error("exit func", err, slog.Int("priority", ef.priority))
+				continue
+			}
+			a.logger.SystemDebug("exit func",
+				slog.Int("priority", ef.priority),
+				slog.Duration("took", time.Since(started)),
+			)
+		case <-timeout:
+			a.logger.Warn("exit funcs timed out",
+				slog.Int("priority", ef.priority),
+				slog.Int("skipped", len(funcs)-i-1),
+			)
+			return
+		}
+	}
+}
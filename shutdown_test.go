@@ -0,0 +1,138 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestShutdownPhases(t *testing.T) {
+	sess := newTestSession(t)
+	registry := make(map[string]*serviceContainer)
+	add := func(name string, priority int, deps ...string) *Service {
+		svc := NewService(name)
+		svc.DependsOn(deps...)
+		svc.StopPriority(priority)
+		addr, err := address.Parse("happy://host/app/service/" + name)
+		testutils.NoError(t, err)
+		svcc := svc.container(sess, addr)
+		svcc.info.started()
+		registry[addr.String()] = svcc
+		return svc
+	}
+	add("db", 0)
+	add("cache", 0)
+	add("api", 0, "db", "cache")
+	add("metrics", 1)
+	add("web", 0, "api")
+
+	var got [][]string
+	for _, phase := range shutdownPhases(registry) {
+		var names []string
+		for _, addr := range phase {
+			names = append(names, registry[addr].svc.name)
+		}
+		got = append(got, names)
+	}
+	testutils.EqualAny(t, [][]string{
+		{"web"},
+		{"metrics"},
+		{"api"},
+		{"cache", "db"},
+	}, got)
+}
+
+func TestEngineStopServicesTimeout(t *testing.T) {
+	sess := newTestSession(t)
+	e := newEngine()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	release := make(chan struct{})
+	defer close(release)
+	for _, name := range []string{"slow", "fast"} {
+		name := name
+		svc := NewService(name)
+		svc.OnStop(func(sess *Session) error {
+			if name == "slow" {
+				<-release
+			}
+			return nil
+		})
+		addr, err := address.Parse("happy://host/app/service/" + name)
+		testutils.NoError(t, err)
+		svcc := svc.container(sess, addr)
+		testutils.NoError(t, svcc.start(ctx, sess))
+		e.registry[addr.String()] = svcc
+	}
+
+	err := e.stopServices(sess, 10*time.Millisecond)
+	testutils.ErrorIs(t, err, ErrEngine)
+	testutils.True(t, strings.Contains(err.Error(), "happy://host/app/service/slow"), "slow service should be reported")
+	testutils.False(t, strings.Contains(err.Error(), "happy://host/app/service/fast"), "fast service stopped cleanly")
+}
+
+func TestAppExitFuncs(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.exit.timeout", 100*time.Millisecond))
+	var calls []string
+	add := func(priority int, name string, err error) {
+		app.AddExitFunc(priority, func(sess *Session, code int) error {
+			testutils.Equal(t, 3, code)
+			calls = append(calls, name)
+			return err
+		})
+	}
+	app.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
+		calls = append(calls, "cleanup")
+		return nil
+	})
+	add(10, "third", nil)
+	add(-1, "first", errors.New("failed"))
+	add(10, "fourth", nil)
+	add(0, "second", nil)
+	app.callExitFuncs(3)
+	testutils.EqualAny(t, []string{"first", "second", "third", "fourth", "cleanup"}, calls)
+
+	// exit funcs after timeout are skipped
+	calls = nil
+	release := make(chan struct{})
+	defer close(release)
+	app.AddExitFunc(5, func(sess *Session, code int) error {
+		<-release
+		return nil
+	})
+	app.callExitFuncs(3)
+	testutils.EqualAny(t, []string{"first", "second"}, calls)
+}
+
+func TestAppExitFuncPanic(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.exit.timeout", time.Duration(0)))
+	var called bool
+	app.AddExitFunc(0, func(sess *Session, code int) error {
+		panic("boom")
+	})
+	app.AddExitFunc(1, func(sess *Session, code int) error {
+		called = true
+		return nil
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.callExitFuncs(1)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exit blocked on panicking exit func")
+	}
+	testutils.True(t, called, "exit funcs after panicking exit func should be called")
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/exp/slog"
+)
+
+// SignalHandler is called when application receives signal it was
+// registered for with Application.OnSignal.
+type SignalHandler func(sess *Session, sig os.Signal) error
+
+// defaultShutdownSignals gracefully shut down application
+// unless changed with Application.ShutdownSignals.
+var defaultShutdownSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
+
+// signalError is error session is destroyed with on shutdown signal.
+type signalError struct {
+	sig os.Signal
+}
+
+func (e *signalError) Error() string {
+	return fmt.Sprintf("%s: %s", ErrSignal.Error(), e.sig)
+}
+
+func (e *signalError) Is(target error) bool {
+	return target == ErrSignal
+}
+
+// exitCode returns 128+n exit code of signal n used by shells.
+func (e *signalError) exitCode() int {
+	if sig, ok := e.sig.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return 1
+}
+
+// OnSignal registers handler called when application receives sig e.g.
+// SIGUSR1 to dump stats. Handlers registered for shutdown signals replace
+// default shutdown so that handler can e.g. wait for jobs to complete
+// and destroy session itself, handlers of SIGUSR1, SIGUSR2 and SIGHUP
+// replace log level and config reload handling.
+func (a *Application) OnSignal(sig os.Signal, handler SignalHandler) {
+	if a.signalHandlers == nil {
+		a.signalHandlers = make(map[os.Signal][]SignalHandler)
+	}
+	a.signalHandlers[sig] = append(a.signalHandlers[sig], handler)
+}
+
+// ShutdownSignals sets signals which gracefully shut down application,
+// os.Interrupt and SIGTERM by default.
+func (a *Application) ShutdownSignals(sigs ...os.Signal) {
+	a.shutdownSignals = sigs
+}
+
+// hasSignalHandler reports whether handlers are registered for sig.
+func (a *Application) hasSignalHandler(sig os.Signal) bool {
+	return len(a.signalHandlers[sig]) > 0
+}
+
+// handleSignals calls registered signal handlers and destroys session
+// on shutdown signals until returned stop func is called.
+func (a *Application) handleSignals() (stop func()) {
+	shutdown := a.shutdownSignals
+	if shutdown == nil {
+		shutdown = defaultShutdownSignals
+	}
+	sigs := append([]os.Signal{}, shutdown...)
+	for sig := range a.signalHandlers {
+		sigs = append(sigs, sig)
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, sigs...)
+	go func() {
+		for {
+			select {
+			case sig := <-ch:
+				a.handleSignal(sig, shutdown)
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(ch)
+		close(done)
+	}
+}
+
+func (a *Application) handleSignal(sig os.Signal, shutdown []os.Signal) {
+	defer a.recoverPanic()
+	if handlers := a.signalHandlers[sig]; len(handlers) > 0 {
+		a.logger.SystemDebug("received signal", slog.String("signal", sig.String()))
+		for _, handler := range handlers {
+			if err := handler(a.session, sig); err != nil {
+				a.logger.Error("signal handler", err, slog.String("signal", sig.String()))
+			}
+		}
+		return
+	}
+	for _, s := range shutdown {
+		if s == sig {
+			a.logger.Notice("received signal, shutting down", slog.String("signal", sig.String()))
+			a.session.Destroy(&signalError{sig: sig})
+			return
+		}
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"context"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestAppSignalShutdown(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.Do(func(sess *Session, args Args) error {
+		if err := syscall.Kill(os.Getpid(), syscall.SIGTERM); err != nil {
+			return err
+		}
+		<-sess.Done()
+		return sess.Err()
+	})
+	code, err := app.Run(context.Background(), nil)
+	testutils.ErrorIs(t, err, ErrSignal)
+	testutils.Equal(t, 128+int(syscall.SIGTERM), code)
+}
+
+func TestAppOnSignal(t *testing.T) {
+	app := New(Option("log.console", false))
+	received := make(chan os.Signal, 2)
+	handler := func(sess *Session, sig os.Signal) error {
+		received <- sig
+		return nil
+	}
+	app.OnSignal(syscall.SIGUSR1, handler)
+	// handler of shutdown signal replaces default shutdown
+	app.OnSignal(syscall.SIGTERM, handler)
+	app.Do(func(sess *Session, args Args) error {
+		for _, sig := range []syscall.Signal{syscall.SIGUSR1, syscall.SIGTERM} {
+			if err := syscall.Kill(os.Getpid(), sig); err != nil {
+				return err
+			}
+			testutils.Equal[os.Signal](t, sig, <-received)
+		}
+		return sess.Err()
+	})
+	code, err := app.Run(context.Background(), nil)
+	testutils.NoError(t, err)
+	testutils.Equal(t, 0, code)
+}
+
+func TestAppShutdownSignals(t *testing.T) {
+	app := New(Option("log.console", false))
+	app.ShutdownSignals(syscall.SIGUSR2)
+	testutils.NoError(t, app.session.start())
+	defer app.session.Destroy(nil)
+
+	app.handleSignal(syscall.SIGINT, app.shutdownSignals)
+	testutils.NoError(t, app.session.Err())
+	app.handleSignal(syscall.SIGUSR2, app.shutdownSignals)
+	testutils.ErrorIs(t, app.session.Err(), ErrSignal)
+	testutils.Equal(t, 128+int(syscall.SIGUSR2), exitCode(app.session.Err()))
+}
@@ -0,0 +1,271 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mkungla/happy/pkg/varflag"
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+// RuntimeStats is sample of process runtime state collected every
+// app.stats.interval, see Session.RuntimeStats.
+type RuntimeStats struct {
+	Time       time.Time `json:"time"`
+	Goroutines int       `json:"goroutines"`
+	// HeapAlloc is bytes of allocated heap objects.
+	HeapAlloc   uint64 `json:"heap_alloc"`
+	HeapObjects uint64 `json:"heap_objects"`
+	// Sys is bytes of memory obtained from the OS.
+	Sys   uint64 `json:"sys"`
+	NumGC uint32 `json:"num_gc"`
+	// GCPause is total time of GC pauses since previous sample.
+	GCPause      time.Duration `json:"gc_pause"`
+	GCPauseTotal time.Duration `json:"gc_pause_total"`
+	// OpenFiles is -1 on platforms where it can not be determined.
+	OpenFiles int `json:"open_files"`
+	// EventQueueDepth is number of events waiting in session event
+	// queue and EventsDispatched events dispatched since previous sample.
+	EventQueueDepth  int    `json:"event_queue_depth"`
+	EventsDispatched uint64 `json:"events_dispatched"`
+}
+
+// statsSampler samples runtime stats and keeps bounded history of them.
+// Methods of nil sampler are no-op so sampling can be disabled.
+type statsSampler struct {
+	mu       sync.RWMutex
+	interval time.Duration
+	history  *ring[RuntimeStats]
+	// events dispatched until last sample
+	dispatched uint64
+}
+
+func newStatsSampler(interval time.Duration, size int) *statsSampler {
+	if interval <= 0 || size <= 0 {
+		return nil
+	}
+	return &statsSampler{
+		interval: interval,
+		history:  newRing[RuntimeStats](size),
+	}
+}
+
+// ring is bounded history keeping last values added to it.
+type ring[T any] struct {
+	values []T
+	next   int
+	full   bool
+}
+
+func newRing[T any](size int) *ring[T] {
+	return &ring[T]{values: make([]T, size)}
+}
+
+func (r *ring[T]) add(v T) {
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// last returns latest value.
+func (r *ring[T]) last() (v T, ok bool) {
+	if !r.full && r.next == 0 {
+		return v, false
+	}
+	return r.values[(r.next+len(r.values)-1)%len(r.values)], true
+}
+
+// all returns values oldest first.
+func (r *ring[T]) all() []T {
+	if !r.full {
+		return append([]T(nil), r.values[:r.next]...)
+	}
+	values := make([]T, 0, len(r.values))
+	values = append(values, r.values[r.next:]...)
+	return append(values, r.values[:r.next]...)
+}
+
+// sample reads runtime stats and adds them to history.
+func (s *statsSampler) sample(now time.Time, events EventStats) RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := RuntimeStats{
+		Time:         now.UTC(),
+		Goroutines:   runtime.NumGoroutine(),
+		HeapAlloc:    mem.HeapAlloc,
+		HeapObjects:  mem.HeapObjects,
+		Sys:          mem.Sys,
+		NumGC:        mem.NumGC,
+		GCPauseTotal: time.Duration(mem.PauseTotalNs),
+		OpenFiles:    openFiles(),
+
+		EventQueueDepth:  events.QueueDepth,
+		EventsDispatched: events.Dispatched,
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if prev, ok := s.history.last(); ok {
+		stats.GCPause = stats.GCPauseTotal - prev.GCPauseTotal
+		s.dispatched, stats.EventsDispatched = stats.EventsDispatched, stats.EventsDispatched-s.dispatched
+	} else {
+		stats.GCPause = stats.GCPauseTotal
+		s.dispatched = stats.EventsDispatched
+	}
+	s.history.add(stats)
+	return stats
+}
+
+// samples returns history oldest first.
+func (s *statsSampler) samples() []RuntimeStats {
+	if s == nil {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.history.all()
+}
+
+// run samples runtime stats and dispatches runtime.stats event
+// with each sample until ctx is done.
+func (s *statsSampler) run(ctx context.Context, sess *Session) {
+	if s == nil {
+		return
+	}
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sess.Dispatch(runtimeStatsEvent(s.sample(now, sess.EventStats())))
+		}
+	}
+}
+
+func runtimeStatsEvent(stats RuntimeStats) Event {
+	payload := new(vars.Map)
+	payload.Store("goroutines", stats.Goroutines)
+	payload.Store("heap.alloc", stats.HeapAlloc)
+	payload.Store("heap.objects", stats.HeapObjects)
+	payload.Store("sys", stats.Sys)
+	payload.Store("gc.num", stats.NumGC)
+	payload.Store("gc.pause", stats.GCPause)
+	payload.Store("open.files", stats.OpenFiles)
+	payload.Store("events.queue", stats.EventQueueDepth)
+	payload.Store("events.dispatched", stats.EventsDispatched)
+	return NewEvent("runtime", "stats", payload, nil)
+}
+
+// RuntimeStats returns runtime stats sampled every app.stats.interval
+// oldest first, at most app.stats.history samples are kept. It returns
+// nil when sampling is disabled. Each sample is also dispatched as
+// runtime.stats event.
+func (s *Session) RuntimeStats() []RuntimeStats {
+	if s.engine == nil {
+		return nil
+	}
+	return s.engine.stats.samples()
+}
+
+// statsInfo is output of "app stats" command.
+type statsInfo struct {
+	Runtime []RuntimeStats    `json:"runtime"`
+	Events  []EventScopeStats `json:"events"`
+	Metrics []Metric          `json:"metrics,omitempty"`
+}
+
+// writeStatsTable writes latest runtime stats and event throughput
+// per scope as tables.
+func writeStatsTable(w io.Writer, info statsInfo) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if n := len(info.Runtime); n > 0 {
+		rs := info.Runtime[n-1]
+		fmt.Fprintln(tw, "RUNTIME")
+		fmt.Fprintf(tw, "  goroutines\t%d\n", rs.Goroutines)
+		fmt.Fprintf(tw, "  heap alloc\t%d\n", rs.HeapAlloc)
+		fmt.Fprintf(tw, "  heap objects\t%d\n", rs.HeapObjects)
+		fmt.Fprintf(tw, "  sys\t%d\n", rs.Sys)
+		fmt.Fprintf(tw, "  gc\t%d (%s)\n", rs.NumGC, rs.GCPauseTotal)
+		if rs.OpenFiles >= 0 {
+			fmt.Fprintf(tw, "  open files\t%d\n", rs.OpenFiles)
+		}
+		fmt.Fprintf(tw, "  event queue\t%d\n", rs.EventQueueDepth)
+	}
+	fmt.Fprintln(tw, "\nEVENTS\n  SCOPE\tEVENTS\tRATE/S\tHANDLED\tFAILED\tAVG\tMAX")
+	for _, s := range info.Events {
+		fmt.Fprintf(tw, "  %s\t%d\t%.2f\t%d\t%d\t%s\t%s\n",
+			s.Scope, s.Events, s.Rate, s.Handled, s.Failed, s.AvgLatency, s.MaxLatency)
+	}
+	if len(info.Metrics) > 0 {
+		fmt.Fprintln(tw, "\nMETRICS")
+		for _, m := range info.Metrics {
+			if m.Kind == MetricHistogram {
+				fmt.Fprintf(tw, "  %s\t%s\tcount=%d sum=%g\n", m.Name, m.Kind, m.Count, m.Sum)
+				continue
+			}
+			fmt.Fprintf(tw, "  %s\t%s\t%g\n", m.Name, m.Kind, m.Value)
+		}
+	}
+	return tw.Flush()
+}
+
+// stats returns runtime stats, event throughput per scope
+// and metrics of the session.
+func (s *Session) stats() statsInfo {
+	info := statsInfo{
+		Runtime: s.RuntimeStats(),
+		Events:  s.Monitor().EventScopes(),
+		Metrics: s.Monitor().Metrics(),
+	}
+	if len(info.Runtime) == 0 {
+		sampler := newStatsSampler(time.Second, 1)
+		info.Runtime = []RuntimeStats{sampler.sample(time.Now(), s.EventStats())}
+	}
+	return info
+}
+
+// statsCommand is command printing runtime stats and event throughput
+// of running application read from /debug/stats endpoint of its
+// diagnostics service. Command is hidden since it is useful only
+// when app.diagnostics.addr is set.
+func statsCommand() *Command {
+	cmd := NewCommand(
+		"stats",
+		Option("usage", "print runtime stats and event throughput per scope"),
+		Option("description", "Print latest runtime stats sampled every app.stats.interval and event counts, rates and handler latencies per event scope of running application to identify chatty or slow subsystems. Stats are read from diagnostics service enabled with app.diagnostics.addr."),
+		Option("hidden", true),
+	)
+	jsonFlag, _ := varflag.Bool("json", false, "print stats as JSON, runtime stats include sampled history")
+	cmd.AddFlag(jsonFlag)
+	cmd.Do(func(sess *Session, args Args) error {
+		addr, err := diagnosticsAddr(sess)
+		if err != nil {
+			return err
+		}
+		var info statsInfo
+		if err := fetchDiagnostics(sess, addr, "/debug/stats", &info); err != nil {
+			return err
+		}
+		if args.Flag("json").Present() {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(info)
+		}
+		return writeStatsTable(os.Stdout, info)
+	})
+	return cmd
+}
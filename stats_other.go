@@ -0,0 +1,13 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !unix
+
+package happy
+
+// openFiles returns -1 on platforms where open file descriptors
+// can not be counted.
+func openFiles() int {
+	return -1
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestStatsSampler(t *testing.T) {
+	var disabled *statsSampler
+	testutils.True(t, newStatsSampler(0, 10) == nil, "zero interval should disable sampling")
+	testutils.Equal(t, 0, len(disabled.samples()))
+
+	s := newStatsSampler(time.Second, 3)
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		stats := s.sample(start.Add(time.Duration(i)*time.Second), EventStats{QueueDepth: i, Dispatched: uint64(i * 10)})
+		testutils.True(t, stats.Goroutines > 0)
+		testutils.True(t, stats.HeapAlloc > 0)
+	}
+	samples := s.samples()
+	testutils.Equal(t, 3, len(samples))
+	testutils.Equal(t, start.Add(2*time.Second).UTC(), samples[0].Time)
+	testutils.Equal(t, start.Add(4*time.Second).UTC(), samples[2].Time)
+	testutils.Equal(t, samples[2].GCPauseTotal-samples[1].GCPauseTotal, samples[2].GCPause)
+	testutils.Equal(t, 4, samples[2].EventQueueDepth)
+	testutils.Equal(t, uint64(10), samples[2].EventsDispatched)
+}
+
+func TestStatsSamplerRun(t *testing.T) {
+	sess := newTestSession(t)
+	sess.engine = newEngine()
+	sess.engine.stats = newStatsSampler(time.Millisecond, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	go sess.engine.stats.run(ctx, sess)
+
+	ev := <-sess.evch
+	cancel()
+	testutils.Equal(t, "runtime", ev.Scope())
+	testutils.Equal(t, "stats", ev.Key())
+	testutils.True(t, ev.Payload().Get("goroutines").Int() > 0)
+	testutils.True(t, len(sess.RuntimeStats()) > 0)
+}
+
+func TestAppRuntimeStatsEventDelivered(t *testing.T) {
+	app := New(Option("log.console", false))
+	testutils.NoError(t, app.registerInternalEvents())
+
+	received := make(chan Event, 1)
+	svc := NewService("stats-listener")
+	svc.OnEvent("runtime", "stats", func(sess *Session, ev Event) error {
+		received <- ev
+		return nil
+	})
+	addr, err := address.Parse("happy://host/app/service/stats-listener")
+	testutils.NoError(t, err)
+	app.engine.registry[addr.String()] = svc.container(app.session, addr)
+
+	stats := newStatsSampler(time.Second, 1).sample(time.Now(), EventStats{})
+	app.engine.handleEvent(app.session, runtimeStatsEvent(stats))
+	select {
+	case ev := <-received:
+		testutils.True(t, ev.Payload().Get("goroutines").Int() > 0)
+	case <-time.After(time.Second):
+		t.Fatal("runtime.stats event was not delivered to listener")
+	}
+}
+
+func TestFetchStats(t *testing.T) {
+	sess := newTestSession(t)
+	srv := httptest.NewServer(diagnosticsHandler(sess))
+	defer srv.Close()
+
+	var info statsInfo
+	testutils.NoError(t, fetchDiagnostics(context.Background(), strings.TrimPrefix(srv.URL, "http://"), "/debug/stats", &info))
+	testutils.Equal(t, 1, len(info.Runtime))
+	testutils.True(t, info.Runtime[0].Goroutines > 0)
+
+	_, err := diagnosticsAddr(sess)
+	testutils.ErrorIs(t, err, ErrCommand, "stats command should require app.diagnostics.addr")
+	testutils.True(t, statsCommand().Hidden(), "stats command should be hidden")
+}
@@ -0,0 +1,20 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import "os"
+
+// openFiles returns number of open file descriptors of the process
+// or -1 when it can not be determined.
+func openFiles() int {
+	entries, err := os.ReadDir("/dev/fd")
+	if err != nil {
+		return -1
+	}
+	// descriptor used to read the directory is listed as well
+	return len(entries) - 1
+}
@@ -0,0 +1,256 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mkungla/happy/pkg/varflag"
+)
+
+// statusClearScreen moves cursor home and clears the terminal
+// before each frame of "app status --watch".
+const statusClearScreen = "\033[H\033[2J"
+
+// statusInfo is snapshot of running application rendered by "app status"
+// dashboard and served on /debug/status by diagnostics service.
+type statusInfo struct {
+	Time      time.Time            `json:"time"`
+	Name      string               `json:"name"`
+	Profile   string               `json:"profile"`
+	Ready     bool                 `json:"ready"`
+	Uptime    time.Duration        `json:"uptime"`
+	Health    HealthReport         `json:"health"`
+	Readiness HealthReport         `json:"readiness"`
+	Services  []ServiceDescription `json:"services"`
+	Events    []EventScopeStats    `json:"events"`
+	Runtime   RuntimeStats         `json:"runtime"`
+}
+
+// status returns snapshot of services, health, event throughput
+// and latest runtime stats of the session.
+func (s *Session) status() statusInfo {
+	desc := s.Describe()
+	info := statusInfo{
+		Time:      time.Now().UTC(),
+		Name:      s.Get("app.name").String(),
+		Profile:   desc.Profile,
+		Ready:     desc.Ready,
+		Uptime:    desc.Uptime,
+		Health:    s.Health(),
+		Readiness: s.Readiness(),
+		Services:  desc.Services,
+		Events:    s.Monitor().EventScopes(),
+	}
+	if samples := s.RuntimeStats(); len(samples) > 0 {
+		info.Runtime = samples[len(samples)-1]
+	} else {
+		info.Runtime = newStatsSampler(time.Second, 1).sample(time.Now(), desc.Events)
+	}
+	return info
+}
+
+// fetchStatus reads status of the application from /debug/status
+// endpoint of diagnostics service listening on addr.
+func fetchStatus(ctx context.Context, addr string) (statusInfo, error) {
+	var info statusInfo
+	err := fetchDiagnostics(ctx, addr, "/debug/status", &info)
+	return info, err
+}
+
+// writeStatusDashboard renders status of the application. Event rates
+// are computed since prev snapshot when it is provided, otherwise they
+// are averages since application started.
+func writeStatusDashboard(w io.Writer, info statusInfo, prev *statusInfo, theme Theme, color bool) error {
+	healthStyle := func(status HealthStatus) Style {
+		switch status {
+		case HealthOK:
+			return theme.Success
+		case HealthDegraded:
+			return theme.Warning
+		}
+		return theme.Error
+	}
+	ready := "not ready"
+	if info.Ready {
+		ready = "ready"
+	}
+	fmt.Fprintf(w, "%s  profile %s  uptime %s  %s  health %s  readiness %s\n",
+		theme.Title.Render(info.Name, color), info.Profile,
+		info.Uptime.Round(time.Second), ready,
+		healthStyle(info.Health.Status).Render(string(info.Health.Status), color),
+		healthStyle(info.Readiness.Status).Render(string(info.Readiness.Status), color),
+	)
+	fmt.Fprintln(w, info.Time.Local().Format(time.RFC1123))
+
+	// colored column is last so escape codes do not break alignment
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	health := make(map[string]ServiceHealth, len(info.Health.Services))
+	for _, sh := range info.Health.Services {
+		health[sh.Service] = sh
+	}
+	fmt.Fprintln(tw, "\nSERVICES\n  SERVICE\tUPTIME\tERRORS\tSTATUS")
+	for _, svc := range info.Services {
+		uptime := "-"
+		state, style := "stopped", theme.Warning
+		if svc.Running {
+			uptime = info.Time.Sub(svc.StartedAt).Round(time.Second).String()
+			state, style = "running", theme.Success
+		}
+		if sh, ok := health[svc.Addr]; ok && sh.Status != HealthOK {
+			state, style = state+" "+string(sh.Status), healthStyle(sh.Status)
+			if sh.Err != "" {
+				state += ": " + sh.Err
+			}
+		} else if len(svc.Errs) > 0 {
+			style = theme.Error
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%d\t%s\n", svc.Addr, uptime, len(svc.Errs), style.Render(state, color))
+	}
+
+	prevEvents := make(map[string]uint64)
+	var elapsed float64
+	if prev != nil {
+		elapsed = info.Time.Sub(prev.Time).Seconds()
+		for _, s := range prev.Events {
+			prevEvents[s.Scope] = s.Events
+		}
+	}
+	fmt.Fprintln(tw, "\nEVENTS\n  SCOPE\tEVENTS\tRATE/S\tAVG\tMAX\tFAILED")
+	for _, s := range info.Events {
+		rate := s.Rate
+		if elapsed > 0 {
+			rate = float64(s.Events-prevEvents[s.Scope]) / elapsed
+		}
+		failed := fmt.Sprint(s.Failed)
+		if s.Failed > 0 {
+			failed = theme.Error.Render(failed, color)
+		}
+		fmt.Fprintf(tw, "  %s\t%d\t%.2f\t%s\t%s\t%s\n",
+			s.Scope, s.Events, rate, s.AvgLatency, s.MaxLatency, failed)
+	}
+
+	rs := info.Runtime
+	fmt.Fprintln(tw, "\nRESOURCES")
+	fmt.Fprintf(tw, "  goroutines\t%d\n", rs.Goroutines)
+	fmt.Fprintf(tw, "  heap alloc\t%s\n", formatBytes(rs.HeapAlloc))
+	fmt.Fprintf(tw, "  sys\t%s\n", formatBytes(rs.Sys))
+	fmt.Fprintf(tw, "  gc\t%d (%s)\n", rs.NumGC, rs.GCPauseTotal)
+	if rs.OpenFiles >= 0 {
+		fmt.Fprintf(tw, "  open files\t%d\n", rs.OpenFiles)
+	}
+	fmt.Fprintf(tw, "  event queue\t%d\n", rs.EventQueueDepth)
+	return tw.Flush()
+}
+
+// formatBytes formats n as human readable size e.g. 1.5MiB.
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// addStatusFlags adds flags of status dashboard to cmd.
+func addStatusFlags(cmd *Command) {
+	watchFlag, _ := varflag.Bool("watch", false, "refresh status until interrupted", "w")
+	cmd.AddFlag(watchFlag)
+	intervalFlag, _ := varflag.New("interval", "2s", "refresh interval of --watch e.g. 1s or 500ms")
+	cmd.AddFlag(intervalFlag)
+}
+
+// runStatusDashboard renders status read from source once or, with --watch
+// flag, redraws it every --interval until session is done.
+func runStatusDashboard(sess *Session, args Args, source func(ctx context.Context) (statusInfo, error)) error {
+	info, err := source(sess)
+	if err != nil {
+		return err
+	}
+	if !args.Flag("watch").Present() {
+		return writeStatusDashboard(os.Stdout, info, nil, sess.Theme(), sess.Color())
+	}
+	interval, err := time.ParseDuration(args.Flag("interval").String())
+	if err != nil {
+		return fmt.Errorf("invalid --interval: %w", err)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid --interval: must be greater than 0")
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var (
+		frame bytes.Buffer
+		prev  *statusInfo
+	)
+	for {
+		frame.Reset()
+		frame.WriteString(statusClearScreen)
+		if err != nil {
+			fmt.Fprintf(&frame, "%s\n", sess.Theme().Error.Render(err.Error(), sess.Color()))
+		} else {
+			if err := writeStatusDashboard(&frame, info, prev, sess.Theme(), sess.Color()); err != nil {
+				return err
+			}
+			prev = &info
+		}
+		fmt.Fprintf(&frame, "\nrefreshing every %s, press Ctrl+C to exit\n", interval)
+		if _, err := os.Stdout.Write(frame.Bytes()); err != nil {
+			return err
+		}
+
+		select {
+		case <-sess.Done():
+			return nil
+		case <-ticker.C:
+		}
+		var next statusInfo
+		if next, err = source(sess); err == nil {
+			info = next
+		}
+	}
+}
+
+// statusCommand is command rendering status dashboard of running
+// application read from /debug/status endpoint of its diagnostics service.
+// Command is hidden since it is useful only when app.diagnostics.addr
+// is set, daemon applications provide "app status" of the daemon instead.
+func statusCommand() *Command {
+	cmd := NewCommand(
+		"status",
+		Option("usage", "print status of services, health, event rates and resource usage"),
+		Option("description", "Print status dashboard of running application with service states, health, event rates per scope and resource usage read from diagnostics service enabled with app.diagnostics.addr, use --watch to refresh it until interrupted."),
+		Option("hidden", true),
+	)
+	addStatusFlags(cmd)
+	cmd.Do(func(sess *Session, args Args) error {
+		return diagnosticsStatus(sess, args)
+	})
+	return cmd
+}
+
+// diagnosticsStatus renders status dashboard of running application
+// read from its diagnostics service.
+func diagnosticsStatus(sess *Session, args Args) error {
+	addr, err := diagnosticsAddr(sess)
+	if err != nil {
+		return err
+	}
+	return runStatusDashboard(sess, args, func(ctx context.Context) (statusInfo, error) {
+		return fetchStatus(ctx, addr)
+	})
+}
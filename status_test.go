@@ -0,0 +1,69 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestStatusDashboard(t *testing.T) {
+	now := time.Now()
+	prev := statusInfo{
+		Time:   now.Add(-2 * time.Second),
+		Events: []EventScopeStats{{Scope: "app", Events: 10}},
+	}
+	info := statusInfo{
+		Time:      now,
+		Name:      "happy",
+		Ready:     true,
+		Uptime:    time.Minute,
+		Health:    HealthReport{Status: HealthDegraded, Services: []ServiceHealth{{Service: "happy://host/app/service/cache", Status: HealthDegraded, Err: "connection refused"}}},
+		Readiness: HealthReport{Status: HealthOK},
+		Services: []ServiceDescription{
+			{Addr: "happy://host/app/service/cache", Running: true, StartedAt: now.Add(-time.Minute)},
+			{Addr: "happy://host/app/service/idle"},
+		},
+		Events:  []EventScopeStats{{Scope: "app", Events: 20, Rate: 0.5}},
+		Runtime: RuntimeStats{Goroutines: 7, HeapAlloc: 3 << 20, OpenFiles: -1},
+	}
+
+	var b strings.Builder
+	testutils.NoError(t, writeStatusDashboard(&b, info, &prev, DefaultTheme(), false))
+	out := strings.Join(strings.Fields(b.String()), " ")
+	testutils.True(t, strings.Contains(out, "health degraded readiness ok"), out)
+	testutils.True(t, strings.Contains(out, "cache 1m0s 0 running degraded: connection refused"), out)
+	testutils.True(t, strings.Contains(out, "idle - 0 stopped"), out)
+	testutils.True(t, strings.Contains(out, "app 20 5.00"), "rate should be computed since previous snapshot: "+out)
+	testutils.True(t, strings.Contains(out, "heap alloc 3.0MiB"), out)
+	testutils.False(t, strings.Contains(out, "open files"), out)
+
+	b.Reset()
+	testutils.NoError(t, writeStatusDashboard(&b, info, nil, DefaultTheme(), true))
+	testutils.True(t, strings.Contains(b.String(), "\033[33mdegraded\033[0m"), b.String())
+}
+
+func TestFetchStatus(t *testing.T) {
+	sess := newTestSession(t)
+	sess.ready, sess.readyFunc = context.WithCancel(context.Background())
+	sess.readyFunc()
+	srv := httptest.NewServer(diagnosticsHandler(sess))
+	defer srv.Close()
+
+	info, err := fetchStatus(context.Background(), strings.TrimPrefix(srv.URL, "http://"))
+	testutils.NoError(t, err)
+	testutils.True(t, info.Ready)
+	testutils.Equal(t, HealthOK, info.Health.Status)
+	testutils.True(t, info.Runtime.Goroutines > 0)
+
+	_, err = fetchStatus(context.Background(), "localhost")
+	testutils.Error(t, err)
+	testutils.True(t, statusCommand().Hidden(), "status command should be hidden")
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// sdNotifier sends service state notifications to systemd when
+// application runs as Type=notify unit. Methods of nil notifier
+// are no-op so notifications are disabled outside of systemd.
+type sdNotifier struct {
+	addr *net.UnixAddr
+	// watchdog is WatchdogSec of the unit, 0 when watchdog is disabled.
+	watchdog time.Duration
+}
+
+// newSDNotifier returns notifier when NOTIFY_SOCKET is set.
+func newSDNotifier() *sdNotifier {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return nil
+	}
+	// abstract socket
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+	n := &sdNotifier{addr: &net.UnixAddr{Name: socket, Net: "unixgram"}}
+
+	// watchdog applies to this process only when WATCHDOG_PID is
+	// not set or matches pid of the process.
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return n
+	}
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 {
+		n.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return n
+}
+
+// notify sends state e.g. READY=1 to systemd.
+func (n *sdNotifier) notify(sess *Session, state string) {
+	if n == nil {
+		return
+	}
+	conn, err := net.DialUnix(n.addr.Net, nil, n.addr)
+	if err == nil {
+		_, err = conn.Write([]byte(state))
+		conn.Close()
+	}
+	if err != nil {
+		sess.Log().Warn("systemd notify failed",
+			slog.String("state", state),
+			slog.String("err", err.Error()),
+		)
+	}
+}
+
+// runWatchdog sends WATCHDOG=1 keepalives at half of the watchdog
+// interval until ctx is done. Keepalives are sent only while engine
+// loop keeps ticking, so systemd restarts application when engine
+// tick or tock hangs.
+func (e *Engine) runWatchdog(ctx context.Context, sess *Session) {
+	if e.notifier == nil || e.notifier.watchdog <= 0 {
+		return
+	}
+	ticker := time.NewTicker(e.notifier.watchdog / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if e.loopStalled(now, e.notifier.watchdog) {
+				sess.Log().Warn("engine loop stalled, skipping systemd watchdog keepalive")
+				continue
+			}
+			e.notifier.notify(sess, "WATCHDOG=1")
+		}
+	}
+}
+
+// loopStalled reports whether engine loop has not ticked within limit,
+// engine without tick action or with ticker disabled never stalls.
+func (e *Engine) loopStalled(now time.Time, limit time.Duration) bool {
+	if e.tickAction == nil {
+		return false
+	}
+	interval, _ := e.tickRate()
+	if interval <= 0 {
+		return false
+	}
+	last := time.Unix(0, e.lastTick.Load())
+	return now.Sub(last) > limit+interval
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build unix
+
+package happy
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func newTestNotifySocket(t *testing.T) *net.UnixConn {
+	t.Helper()
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	testutils.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	t.Setenv("NOTIFY_SOCKET", socket)
+	return conn
+}
+
+func readNotifyState(t *testing.T, conn *net.UnixConn) string {
+	t.Helper()
+	testutils.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	testutils.NoError(t, err)
+	return string(buf[:n])
+}
+
+func TestSDNotifier(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	testutils.True(t, newSDNotifier() == nil, "notifier should be disabled")
+	var disabled *sdNotifier
+	disabled.notify(newTestSession(t), "READY=1")
+
+	conn := newTestNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "2000000")
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	n := newSDNotifier()
+	testutils.NotNil(t, n)
+	testutils.Equal(t, 2*time.Second, n.watchdog)
+
+	n.notify(newTestSession(t), "READY=1")
+	testutils.Equal(t, "READY=1", readNotifyState(t, conn))
+
+	// watchdog of other process
+	t.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	testutils.Equal(t, time.Duration(0), newSDNotifier().watchdog)
+}
+
+func TestEngineSystemdWatchdog(t *testing.T) {
+	conn := newTestNotifySocket(t)
+	t.Setenv("WATCHDOG_USEC", "20000")
+
+	sess := newTestSession(t)
+	e := newEngine()
+	e.notifier = newSDNotifier()
+	e.tickAction = func(sess *Session, ts time.Time, delta time.Duration) error { return nil }
+	e.tickInterval = 5 * time.Millisecond
+	e.lastTick.Store(time.Now().UnixNano())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.runWatchdog(ctx, sess)
+	testutils.Equal(t, "WATCHDOG=1", readNotifyState(t, conn))
+	cancel()
+
+	now := time.Now()
+	testutils.False(t, e.loopStalled(now, 20*time.Millisecond))
+	testutils.True(t, e.loopStalled(now.Add(time.Second), 20*time.Millisecond))
+	e.mu.Lock()
+	e.tickInterval = 0
+	e.mu.Unlock()
+	testutils.False(t, e.loopStalled(now.Add(time.Second), 20*time.Millisecond))
+}
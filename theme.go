@@ -0,0 +1,82 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"strings"
+
+	"github.com/mkungla/happy/pkg/hlog"
+)
+
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Style is ANSI SGR parameter string e.g. "1" for bold or "1;31" for
+// bold red text. Empty style renders text as is.
+type Style string
+
+// Render returns s styled when colored is true.
+func (st Style) Render(s string, colored bool) string {
+	if !colored || st == "" || s == "" {
+		return s
+	}
+	return "\033[" + string(st) + "m" + s + "\033[0m"
+}
+
+// Theme is set of styles used for help and error output.
+// Log is used by console log format.
+type Theme struct {
+	Banner   Style
+	Title    Style
+	Category Style
+	Command  Style
+	Flag     Style
+	Error    Style
+	Warning  Style
+	Success  Style
+	Log      hlog.Theme
+}
+
+// DefaultTheme returns theme used when application does not set own theme.
+func DefaultTheme() Theme {
+	return Theme{
+		Banner:   "33",
+		Title:    "1",
+		Category: "1;36",
+		Command:  "1",
+		Flag:     "",
+		Error:    "1;31",
+		Warning:  "33",
+		Success:  "32",
+		Log:      hlog.DefaultTheme(),
+	}
+}
+
+// colorEnabled resolves whether colored output should be used for
+// given --color mode. In auto mode colors are enabled when NO_COLOR
+// is not set and f is terminal which is not dumb.
+func colorEnabled(mode string, f *os.File) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+	if _, ok := os.LookupEnv("NO_COLOR"); ok {
+		return false
+	}
+	if strings.EqualFold(os.Getenv("TERM"), "dumb") {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
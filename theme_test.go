@@ -0,0 +1,40 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestThemeStyle(t *testing.T) {
+	style := Style("1;31")
+	testutils.Equal(t, "\033[1;31merror\033[0m", style.Render("error", true))
+	testutils.Equal(t, "error", style.Render("error", false))
+	testutils.Equal(t, "error", Style("").Render("error", true))
+
+	p := &cliTmplParser{}
+	p.setTheme(DefaultTheme(), true)
+	testutils.Equal(t, "\033[1;36mADMIN\033[0m", p.cmdCategory("admin"))
+	p.setTheme(DefaultTheme(), false)
+	testutils.Equal(t, "ADMIN", p.cmdCategory("admin"))
+}
+
+func TestColorEnabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out"))
+	testutils.NoError(t, err)
+	defer f.Close()
+
+	testutils.True(t, colorEnabled(ColorAlways, f), "always should enable colors")
+	testutils.False(t, colorEnabled(ColorNever, f), "never should disable colors")
+	testutils.False(t, colorEnabled(ColorAuto, f), "auto should disable colors for files")
+
+	t.Setenv("NO_COLOR", "1")
+	testutils.False(t, colorEnabled(ColorAuto, os.Stdout), "NO_COLOR should disable colors")
+	testutils.True(t, colorEnabled(ColorAlways, os.Stdout), "always should override NO_COLOR")
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+
+	"golang.org/x/exp/slog"
+)
+
+// Tracer creates spans for engine lifecycle, event handling and ticks.
+// It is intended to be implemented by thin adapter on top of
+// OpenTelemetry or other tracing library, see Application.WithTracer.
+// Package sdk/oteltrace provides OpenTelemetry backed Tracer.
+type Tracer interface {
+	// Start starts new span as child of span in ctx.
+	Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span)
+	// Inject returns W3C traceparent of span in ctx.
+	Inject(ctx context.Context) string
+	// Extract returns ctx carrying remote span described by traceparent.
+	Extract(ctx context.Context, traceparent string) context.Context
+}
+
+// Span is single traced operation.
+type Span interface {
+	// End ends the span, non nil err marks span as failed.
+	End(err error)
+}
+
+type noopSpan struct{}
+
+func (noopSpan) End(err error) {}
+
+// startSpan starts span when tracer is set.
+func (e *Engine) startSpan(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	if e.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	return e.tracer.Start(ctx, name, attrs...)
+}
+
+// startEventSpan starts span as child of span which dispatched the event.
+func (e *Engine) startEventSpan(name string, ev Event, attrs ...slog.Attr) (context.Context, Span) {
+	ctx := context.Background()
+	if e.tracer == nil {
+		return ctx, noopSpan{}
+	}
+	if tp := EventTraceParent(ev); tp != "" {
+		ctx = e.tracer.Extract(ctx, tp)
+	}
+	attrs = append(attrs, slog.String("event.scope", ev.Scope()), slog.String("event.key", ev.Key()))
+	return e.tracer.Start(ctx, name, attrs...)
+}
+
+// WithTraceParent returns event carrying W3C traceparent in its metadata.
+func WithTraceParent(ev Event, traceparent string) Event {
+	if ev == nil || traceparent == "" {
+		return ev
+	}
+	// keep request events replyable
+	if req, ok := ev.(*requestEvent); ok {
+		return &requestEvent{
+			Event:   WithTraceParent(req.Event, traceparent),
+			replied: req.replied,
+			reply:   req.reply,
+		}
+	}
+	if traced, ok := ev.(*tracedEvent); ok {
+		ev = traced.Event
+	}
+	return &tracedEvent{
+		Event:       ev,
+		traceparent: traceparent,
+	}
+}
+
+// EventTraceParent returns W3C traceparent carried by event metadata
+// or empty string when event is not traced.
+func EventTraceParent(ev Event) string {
+	if traced, ok := ev.(interface{ TraceParent() string }); ok {
+		return traced.TraceParent()
+	}
+	return ""
+}
+
+type tracedEvent struct {
+	Event
+	traceparent string
+}
+
+func (ev *tracedEvent) TraceParent() string {
+	return ev.traceparent
+}
+
+func (ev *tracedEvent) LogAttrs() []slog.Attr {
+	return EventLogAttrs(ev.Event)
+}
+
+func (ev *tracedEvent) Err() error {
+	if everr, ok := ev.Event.(interface{ Err() error }); ok {
+		return everr.Err()
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/mkungla/happy/pkg/address"
+	"github.com/mkungla/happy/pkg/hlog"
+	"github.com/mkungla/happy/pkg/hlog/hlogtest"
+	"github.com/mkungla/happy/sdk/testutils"
+	"golang.org/x/exp/slog"
+)
+
+type testTraceKey struct{}
+
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (t *testTracer) Start(ctx context.Context, name string, attrs ...slog.Attr) (context.Context, Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	parent, _ := ctx.Value(testTraceKey{}).(string)
+	id := fmt.Sprintf("%d", len(t.spans))
+	t.spans = append(t.spans, parent+">"+name)
+	return context.WithValue(ctx, testTraceKey{}, id), noopSpan{}
+}
+
+func (t *testTracer) Inject(ctx context.Context) string {
+	id, _ := ctx.Value(testTraceKey{}).(string)
+	return id
+}
+
+func (t *testTracer) Extract(ctx context.Context, traceparent string) context.Context {
+	return context.WithValue(ctx, testTraceKey{}, traceparent)
+}
+
+func TestEngineTracing(t *testing.T) {
+	sess := newTestSession(t)
+	tracer := &testTracer{}
+	e := newEngine()
+	e.tracer = tracer
+
+	var traceparent string
+	svc := NewService("traced")
+	svc.OnEvent("app", "test", func(sess *Session, ev Event) error {
+		traceparent = EventTraceParent(ev)
+		return nil
+	})
+	addr, err := address.Parse("happy://host/app/service/traced")
+	testutils.NoError(t, err)
+	d := eventDelivery{
+		svcc:     svc.container(sess, addr),
+		addr:     addr.String(),
+		listener: svc.listeners[0],
+	}
+
+	ev := WithTraceParent(NewEvent("app", "test", nil, nil), "remote")
+	testutils.NoError(t, e.callListener(sess, ev, d, 0))
+	testutils.EqualAny(t, []string{"remote>happy.event.handle"}, tracer.spans)
+	testutils.Equal(t, "0", traceparent)
+}
+
+func TestWithTraceParentRequestEvent(t *testing.T) {
+	req := newRequestEvent(NewEvent("app", "question", nil, nil))
+	ev := WithTraceParent(req, "parent")
+	testutils.Equal(t, "parent", EventTraceParent(ev))
+
+	reply, ok := ev.(RequestEvent)
+	testutils.True(t, ok, "traced request event must be replyable")
+	testutils.NoError(t, reply.Reply(nil, nil))
+	testutils.Equal(t, "question", (<-req.reply).Key())
+	testutils.Error(t, req.Reply(nil, nil))
+}
+
+func TestWithLogAttrs(t *testing.T) {
+	req := newRequestEvent(NewEvent("app", "question", nil, nil))
+	ctx := hlog.ContextWith(context.Background(), "request_id", "r-1")
+	ev := WithTraceParent(WithLogAttrs(req, hlog.ContextAttrs(ctx)...), "parent")
+	ev = WithLogAttrs(ev, slog.String("user", "john"))
+
+	testutils.Equal(t, "parent", EventTraceParent(ev))
+	attrs := EventLogAttrs(ev)
+	testutils.Equal(t, 2, len(attrs))
+	testutils.Equal(t, "request_id", attrs[0].Key)
+	testutils.Equal(t, "user", attrs[1].Key)
+	_, ok := ev.(RequestEvent)
+	testutils.True(t, ok, "request event must stay replyable")
+
+	sess := newTestSession(t)
+	rec := hlogtest.NewRecorder(t)
+	sess.logger = rec.Logger()
+	sess.EventLog(ev).Info("handled")
+	rec.AssertLogged(hlog.LevelInfo, "handled", "request_id", "r-1", "user", "john")
+}
@@ -0,0 +1,165 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mkungla/happy/pkg/vars"
+)
+
+// NewTypedEvent creates event which payload fields are taken from
+// struct or map with string keys. Scalar fields are stored as payload
+// variables of the same kind, other fields are stored as JSON strings.
+// Event carries error when payload can not be encoded.
+func NewTypedEvent[T any](scope, key string, payload T) Event {
+	m, err := encodeTypedPayload(payload)
+	return NewEvent(scope, key, m, err)
+}
+
+// OnTypedEvent adds listener to service which receives event payload
+// decoded into T, see NewTypedEvent.
+func OnTypedEvent[T any](svc *Service, scope, key string, cb func(sess *Session, ev Event, payload T) error) {
+	svc.OnEvent(scope, key, func(sess *Session, ev Event) error {
+		payload, err := EventPayload[T](ev)
+		if err != nil {
+			return err
+		}
+		return cb(sess, ev, payload)
+	})
+}
+
+// EventPayload decodes payload of the event into T.
+func EventPayload[T any](ev Event) (payload T, err error) {
+	fields := make(map[string]any)
+	if m := ev.Payload(); m != nil {
+		rt := reflect.TypeOf(&payload).Elem()
+		m.Range(func(v vars.Variable) bool {
+			if kind, ok := typedFieldKind(rt, v.Name()); ok && !isScalarKind(kind) && v.Kind() == vars.KindString {
+				fields[v.Name()] = json.RawMessage(v.String())
+			} else {
+				fields[v.Name()] = v.Any()
+			}
+			return true
+		})
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return payload, err
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return payload, fmt.Errorf("%w: failed to decode %s.%s payload: %w", ErrEngine, ev.Scope(), ev.Key(), err)
+	}
+	return payload, nil
+}
+
+func encodeTypedPayload(payload any) (*vars.Map, error) {
+	m := new(vars.Map)
+	rv := reflect.ValueOf(payload)
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return m, nil
+		}
+		rv = rv.Elem()
+	}
+	store := func(name string, fv reflect.Value) error {
+		for fv.Kind() == reflect.Pointer || fv.Kind() == reflect.Interface {
+			if fv.IsNil() {
+				return nil
+			}
+			fv = fv.Elem()
+		}
+		if isScalarKind(fv.Kind()) {
+			return m.Store(name, fv.Interface())
+		}
+		data, err := json.Marshal(fv.Interface())
+		if err != nil {
+			return err
+		}
+		return m.Store(name, string(data))
+	}
+
+	switch rv.Kind() {
+	case reflect.Struct:
+		rt := rv.Type()
+		for i := 0; i < rt.NumField(); i++ {
+			name, ok := typedFieldName(rt.Field(i))
+			if !ok {
+				continue
+			}
+			if err := store(name, rv.Field(i)); err != nil {
+				return m, fmt.Errorf("%w: payload field %s: %w", ErrEngine, name, err)
+			}
+		}
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return m, fmt.Errorf("%w: typed payload map must have string keys", ErrEngine)
+		}
+		iter := rv.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			if err := store(name, iter.Value()); err != nil {
+				return m, fmt.Errorf("%w: payload field %s: %w", ErrEngine, name, err)
+			}
+		}
+	default:
+		return m, fmt.Errorf("%w: typed payload must be struct or map got %s", ErrEngine, rv.Kind())
+	}
+	return m, nil
+}
+
+// typedFieldName returns payload key of struct field honoring json tags.
+func typedFieldName(field reflect.StructField) (string, bool) {
+	if !field.IsExported() {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name, _, _ := strings.Cut(tag, ","); name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
+
+// typedFieldKind returns kind of payload field name of struct or map type.
+func typedFieldKind(rt reflect.Type, name string) (reflect.Kind, bool) {
+	deref := func(t reflect.Type) reflect.Type {
+		for t != nil && t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		return t
+	}
+	rt = deref(rt)
+	if rt == nil {
+		return reflect.Invalid, false
+	}
+	switch rt.Kind() {
+	case reflect.Struct:
+		for i := 0; i < rt.NumField(); i++ {
+			if fname, ok := typedFieldName(rt.Field(i)); ok && fname == name {
+				return deref(rt.Field(i).Type).Kind(), true
+			}
+		}
+	case reflect.Map:
+		return deref(rt.Elem()).Kind(), true
+	}
+	return reflect.Invalid, false
+}
+
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64, reflect.String:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"testing"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+type testProgress struct {
+	Task    string   `json:"task"`
+	Done    int      `json:"done"`
+	Ratio   float64  `json:"ratio"`
+	Tags    []string `json:"tags"`
+	Meta    *testMeta
+	private bool
+}
+
+type testMeta struct {
+	Owner string `json:"owner"`
+}
+
+func TestTypedEvent(t *testing.T) {
+	in := testProgress{
+		Task:  "build",
+		Done:  3,
+		Ratio: 0.5,
+		Tags:  []string{"a", "b"},
+		Meta:  &testMeta{Owner: "ci"},
+	}
+	ev := NewTypedEvent("jobs", "progress", in)
+	testutils.NoError(t, ev.(interface{ Err() error }).Err())
+	testutils.Equal(t, vars.KindInt, ev.Payload().Get("done").Kind())
+	testutils.Equal(t, "build", ev.Payload().Get("task").String())
+	testutils.False(t, ev.Payload().Has("private"), "unexported fields must be skipped")
+
+	out, err := EventPayload[testProgress](ev)
+	testutils.NoError(t, err)
+	testutils.EqualAny(t, in, out)
+
+	counts, err := EventPayload[map[string]int](NewTypedEvent("jobs", "counts", map[string]int{"ok": 2}))
+	testutils.NoError(t, err)
+	testutils.Equal(t, 2, counts["ok"])
+
+	ev = NewTypedEvent("jobs", "invalid", 42)
+	testutils.ErrorIs(t, ev.(interface{ Err() error }).Err(), ErrEngine)
+}
+
+func TestOnTypedEvent(t *testing.T) {
+	var got testProgress
+	svc := NewService("typed")
+	OnTypedEvent(svc, "jobs", "progress", func(sess *Session, ev Event, payload testProgress) error {
+		got = payload
+		return nil
+	})
+	testutils.NoError(t, svc.listeners[0].cb(nil, NewTypedEvent("jobs", "progress", testProgress{Task: "test", Done: 1})))
+	testutils.Equal(t, "test", got.Task)
+	testutils.Equal(t, 1, got.Done)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"runtime/pprof"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mkungla/happy/pkg/vars"
+	"golang.org/x/exp/slog"
+)
+
+// watchdog detects tick, tock and event handlers which run longer than
+// limit. Methods of nil watchdog are no-op so watchdog can be disabled.
+type watchdog struct {
+	mu     sync.Mutex
+	limit  time.Duration
+	active map[uint64]*watchedOp
+}
+
+// watchdogSeq is sequence of watched operation ids, ids are unique
+// within process so that goroutine labels of watchdogs do not collide.
+var watchdogSeq atomic.Uint64
+
+type watchedOp struct {
+	id       uint64
+	name     string
+	service  string
+	started  time.Time
+	reported bool
+}
+
+// watchdogLabel is profiler label marking goroutine running watched
+// operation, stack of the goroutine is looked up by the label only
+// when operation is reported.
+const watchdogLabel = "happy.watchdog.op"
+
+func newWatchdog(limit time.Duration) *watchdog {
+	if limit <= 0 {
+		return nil
+	}
+	return &watchdog{
+		limit:  limit,
+		active: make(map[uint64]*watchedOp),
+	}
+}
+
+// begin starts watching operation running in current goroutine.
+// Goroutine is labeled with operation id so that its stack can be
+// found when operation stalls, operation started within other watched
+// operation on same goroutine replaces the label until it ends.
+func (w *watchdog) begin(name, service string) uint64 {
+	if w == nil {
+		return 0
+	}
+	id := watchdogSeq.Add(1)
+	w.mu.Lock()
+	w.active[id] = &watchedOp{
+		id:      id,
+		name:    name,
+		service: service,
+		started: time.Now(),
+	}
+	w.mu.Unlock()
+	pprof.SetGoroutineLabels(pprof.WithLabels(context.Background(), pprof.Labels(watchdogLabel, strconv.FormatUint(id, 10))))
+	return id
+}
+
+func (w *watchdog) end(id uint64) {
+	if w == nil {
+		return
+	}
+	pprof.SetGoroutineLabels(context.Background())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.active, id)
+}
+
+// stalled returns operations exceeding the limit which were not
+// reported yet.
+func (w *watchdog) stalled(now time.Time) []watchedOp {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	var ops []watchedOp
+	for _, op := range w.active {
+		if op.reported || now.Sub(op.started) < w.limit {
+			continue
+		}
+		op.reported = true
+		ops = append(ops, *op)
+	}
+	return ops
+}
+
+func (w *watchdog) run(ctx context.Context, sess *Session) {
+	if w == nil {
+		return
+	}
+	interval := w.limit / 2
+	if interval < time.Millisecond {
+		interval = time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, op := range w.stalled(now) {
+				w.report(sess, op, now.Sub(op.started))
+			}
+		}
+	}
+}
+
+func (w *watchdog) report(sess *Session, op watchedOp, elapsed time.Duration) {
+	sess.Log().Warn(
+		"handler stalled",
+		slog.String("operation", op.name),
+		slog.String("service", op.service),
+		slog.Duration("elapsed", elapsed),
+		slog.Duration("limit", w.limit),
+		slog.String("goroutine", string(watchedStack(op.id))),
+	)
+	payload := new(vars.Map)
+	payload.Store("operation", op.name)
+	payload.Store("service", op.service)
+	payload.Store("elapsed", elapsed)
+	sess.Dispatch(NewEvent("engine", "stalled", payload, nil))
+}
+
+// watchedStack returns stack of goroutine running watched operation
+// with given id, it returns nil when operation has ended.
+func watchedStack(id uint64) []byte {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 1); err != nil {
+		return nil
+	}
+	label := []byte(strconv.Quote(watchdogLabel) + ":" + strconv.Quote(strconv.FormatUint(id, 10)))
+	for _, stack := range bytes.Split(buf.Bytes(), []byte("\n\n")) {
+		if bytes.Contains(stack, label) {
+			return stack
+		}
+	}
+	return nil
+}
@@ -0,0 +1,52 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestWatchdog(t *testing.T) {
+	testutils.True(t, newWatchdog(0) == nil, "watchdog should be disabled")
+	var disabled *watchdog
+	disabled.end(disabled.begin("noop", ""))
+
+	sess := newTestSession(t)
+	w := newWatchdog(5 * time.Millisecond)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.run(ctx, sess)
+
+	release := make(chan struct{})
+	go func() {
+		id := w.begin("event app.test", "happy://host/app/service/slow")
+		<-release
+		w.end(id)
+	}()
+
+	ev := <-sess.evch
+	close(release)
+	testutils.Equal(t, "engine", ev.Scope())
+	testutils.Equal(t, "stalled", ev.Key())
+	testutils.Equal(t, "event app.test", ev.Payload().Get("operation").String())
+	testutils.Equal(t, "happy://host/app/service/slow", ev.Payload().Get("service").String())
+
+	// stalled operation is reported once
+	testutils.Equal(t, 0, len(w.stalled(time.Now().Add(time.Hour))))
+}
+
+func TestWatchedStack(t *testing.T) {
+	w := newWatchdog(time.Second)
+	id := w.begin("event app.test", "")
+	stack := watchedStack(id)
+	testutils.True(t, bytes.Contains(stack, []byte("TestWatchedStack")), "stack should contain test function")
+	w.end(id)
+	testutils.True(t, watchedStack(id) == nil, "ended operation should not have stack")
+}
@@ -0,0 +1,13 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+//go:build !windows
+
+package happy
+
+// windowsServiceCommands returns no commands on platforms
+// other than Windows, see app.windows.service option.
+func windowsServiceCommands(a *Application) []*Command {
+	return nil
+}
@@ -0,0 +1,375 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/exp/slog"
+)
+
+var (
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	procStartServiceCtrlDispatcherW   = advapi32.NewProc("StartServiceCtrlDispatcherW")
+	procRegisterServiceCtrlHandlerExW = advapi32.NewProc("RegisterServiceCtrlHandlerExW")
+	procSetServiceStatus              = advapi32.NewProc("SetServiceStatus")
+	procOpenSCManagerW                = advapi32.NewProc("OpenSCManagerW")
+	procCreateServiceW                = advapi32.NewProc("CreateServiceW")
+	procOpenServiceW                  = advapi32.NewProc("OpenServiceW")
+	procDeleteService                 = advapi32.NewProc("DeleteService")
+	procChangeServiceConfig2W         = advapi32.NewProc("ChangeServiceConfig2W")
+	procCloseServiceHandle            = advapi32.NewProc("CloseServiceHandle")
+)
+
+const (
+	scManagerAllAccess      = 0xF003F
+	scServiceAllAccess      = 0xF01FF
+	scServiceDelete         = 0x10000
+	scServiceWin32Own       = 0x10
+	scServiceAutoStart      = 2
+	scServiceErrorNormal    = 1
+	scServiceConfigDesc     = 1
+	scErrorServiceSpecific  = 1066
+	scErrorNotFromSCM       = 1063
+	scAcceptStop            = 0x1
+	scAcceptPauseContinue   = 0x2
+	scAcceptShutdown        = 0x4
+	scControlStop           = 1
+	scControlPause          = 2
+	scControlContinue       = 3
+	scControlInterrogate    = 4
+	scControlShutdown       = 5
+	scStateStopped          = 1
+	scStateStartPending     = 2
+	scStateStopPending      = 3
+	scStateRunning          = 4
+	scStateContinuePending  = 5
+	scStatePausePending     = 6
+	scStatePaused           = 7
+	scStartPendingWaitHint  = 30000
+	scStopPendingWaitHint   = 30000
+	scServiceControlHandled = 0
+)
+
+type scServiceStatus struct {
+	serviceType             uint32
+	currentState            uint32
+	controlsAccepted        uint32
+	win32ExitCode           uint32
+	serviceSpecificExitCode uint32
+	checkPoint              uint32
+	waitHint                uint32
+}
+
+type scServiceTableEntry struct {
+	name *uint16
+	proc uintptr
+}
+
+type scServiceDescription struct {
+	description *uint16
+}
+
+// windowsService reports state of the application to service control
+// manager and maps stop, pause and continue controls to the session
+// and engine. Only one service can run in process.
+type windowsService struct {
+	app  *Application
+	name string
+
+	mu     sync.Mutex
+	handle uintptr
+	status scServiceStatus
+
+	started chan error
+	stopped chan struct{}
+}
+
+var (
+	winsvc        *windowsService
+	winsvcMain    = syscall.NewCallback(winsvcMainCallback)
+	winsvcHandler = syscall.NewCallback(winsvcHandlerCallback)
+)
+
+func winsvcMainCallback(argc, argv uintptr) uintptr {
+	winsvc.main()
+	return 0
+}
+
+func winsvcHandlerCallback(ctrl, evtype, evdata, context uintptr) uintptr {
+	winsvc.control(uint32(ctrl))
+	return scServiceControlHandled
+}
+
+// windowsServiceCommands returns service command with install,
+// uninstall and run subcommands.
+func windowsServiceCommands(a *Application) []*Command {
+	cmd := NewCommand(
+		"service",
+		Option("usage", "manage windows service"),
+		Option("description", "Install, uninstall and run application as Windows service."),
+		Option("category", "SERVICE"),
+	)
+
+	install := NewCommand(
+		"install",
+		Option("usage", "install windows service"),
+		Option("description", "Install application as automatically started Windows service, arguments after -- are passed to the service."),
+	)
+	install.Do(func(sess *Session, args Args) error {
+		return installWindowsService(sess, args.Raw())
+	})
+	cmd.AddSubCommand(install)
+
+	uninstall := NewCommand(
+		"uninstall",
+		Option("usage", "uninstall windows service"),
+	)
+	uninstall.Do(func(sess *Session, args Args) error {
+		return uninstallWindowsService(sess)
+	})
+	cmd.AddSubCommand(uninstall)
+
+	run := NewCommand(
+		"run",
+		Option("usage", "run as windows service"),
+		Option("description", "Run application as Windows service, command is executed by service control manager."),
+	)
+	run.Do(func(sess *Session, args Args) error {
+		return runWindowsService(a, sess)
+	})
+	cmd.AddSubCommand(run)
+
+	return []*Command{cmd}
+}
+
+func installWindowsService(sess *Session, extra []string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrApplication, err.Error())
+	}
+	cmdline := []string{syscall.EscapeArg(exe), "service", "run"}
+	if name := sess.Get("app.instance.name").String(); name != "" {
+		cmdline = append(cmdline, "--instance", syscall.EscapeArg(name))
+	}
+	if len(extra) > 0 {
+		cmdline = append(cmdline, "--")
+		for _, arg := range extra {
+			cmdline = append(cmdline, syscall.EscapeArg(arg))
+		}
+	}
+
+	m, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer closeServiceHandle(m)
+
+	name := instanceSlug(sess)
+	h, _, err := procCreateServiceW.Call(
+		m,
+		uintptr(unsafe.Pointer(utf16Ptr(name))),
+		uintptr(unsafe.Pointer(utf16Ptr(sess.Get("app.name").String()))),
+		scServiceAllAccess,
+		scServiceWin32Own,
+		scServiceAutoStart,
+		scServiceErrorNormal,
+		uintptr(unsafe.Pointer(utf16Ptr(strings.Join(cmdline, " ")))),
+		0, 0, 0, 0, 0,
+	)
+	if h == 0 {
+		return fmt.Errorf("%w: failed to install service %s: %s", ErrApplication, name, err.Error())
+	}
+	defer closeServiceHandle(h)
+
+	if desc := sess.Get("app.description").String(); desc != "" {
+		sd := scServiceDescription{description: utf16Ptr(desc)}
+		_, _, _ = procChangeServiceConfig2W.Call(h, scServiceConfigDesc, uintptr(unsafe.Pointer(&sd)))
+	}
+	sess.Log().Ok("service installed", slog.String("service", name))
+	return nil
+}
+
+func uninstallWindowsService(sess *Session) error {
+	m, err := openSCManager()
+	if err != nil {
+		return err
+	}
+	defer closeServiceHandle(m)
+
+	name := instanceSlug(sess)
+	h, _, err := procOpenServiceW.Call(m, uintptr(unsafe.Pointer(utf16Ptr(name))), scServiceDelete)
+	if h == 0 {
+		return fmt.Errorf("%w: service %s: %s", ErrApplication, name, err.Error())
+	}
+	defer closeServiceHandle(h)
+	if r, _, err := procDeleteService.Call(h); r == 0 {
+		return fmt.Errorf("%w: failed to uninstall service %s: %s", ErrApplication, name, err.Error())
+	}
+	sess.Log().Ok("service uninstalled", slog.String("service", name))
+	return nil
+}
+
+// runWindowsService connects to service control manager and reports
+// service running until session is destroyed, stopped state is
+// reported when application exits.
+func runWindowsService(a *Application, sess *Session) error {
+	if winsvc != nil {
+		return fmt.Errorf("%w: windows service already running", ErrApplication)
+	}
+	winsvc = &windowsService{
+		app:     a,
+		name:    instanceSlug(sess),
+		started: make(chan error, 1),
+		stopped: make(chan struct{}),
+	}
+	go winsvc.dispatch()
+	if err := <-winsvc.started; err != nil {
+		return err
+	}
+	a.addExitFunc(exitPriorityCleanup, func(sess *Session, code int) error {
+		winsvc.stop(code)
+		return nil
+	})
+
+	// session is ready when Do action is called
+	winsvc.setState(scStateRunning)
+	<-sess.Done()
+	return nil
+}
+
+// dispatch connects main thread of the service to service control
+// manager, it returns when service is stopped.
+func (s *windowsService) dispatch() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	table := []scServiceTableEntry{
+		{name: utf16Ptr(s.name), proc: winsvcMain},
+		{},
+	}
+	r, _, err := procStartServiceCtrlDispatcherW.Call(uintptr(unsafe.Pointer(&table[0])))
+	if r == 0 {
+		var errno syscall.Errno
+		if errors.As(err, &errno) && errno == scErrorNotFromSCM {
+			err = errors.New("not started by service control manager")
+		}
+		s.started <- fmt.Errorf("%w: %s", ErrApplication, err.Error())
+	}
+}
+
+// main is ServiceMain of the service, it blocks until service is stopped.
+func (s *windowsService) main() {
+	h, _, err := procRegisterServiceCtrlHandlerExW.Call(uintptr(unsafe.Pointer(utf16Ptr(s.name))), winsvcHandler, 0)
+	if h == 0 {
+		s.started <- fmt.Errorf("%w: failed to register service control handler: %s", ErrApplication, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.handle = h
+	s.mu.Unlock()
+	s.setState(scStateStartPending)
+	s.started <- nil
+	<-s.stopped
+}
+
+// control handles control request from service control manager.
+func (s *windowsService) control(ctrl uint32) {
+	sess := s.app.session
+	switch ctrl {
+	case scControlStop, scControlShutdown:
+		s.setState(scStateStopPending)
+		sess.Log().Info("service stop requested")
+		sess.Destroy(fmt.Errorf("%w: stopped by service control manager", ErrSessionDestroyed))
+	case scControlPause:
+		s.setState(scStatePausePending)
+		if err := s.app.engine.Pause(); err != nil {
+			sess.Log().Error("failed to pause service", err)
+			s.setState(scStateRunning)
+			return
+		}
+		s.setState(scStatePaused)
+	case scControlContinue:
+		s.setState(scStateContinuePending)
+		if err := s.app.engine.Resume(); err != nil {
+			sess.Log().Error("failed to continue service", err)
+			s.setState(scStatePaused)
+			return
+		}
+		s.setState(scStateRunning)
+	case scControlInterrogate:
+		s.mu.Lock()
+		state := s.status.currentState
+		s.mu.Unlock()
+		s.setState(state)
+	}
+}
+
+// stop reports service stopped with exit code of the application.
+func (s *windowsService) stop(code int) {
+	s.mu.Lock()
+	s.status.win32ExitCode = 0
+	if code != 0 {
+		s.status.win32ExitCode = scErrorServiceSpecific
+		s.status.serviceSpecificExitCode = uint32(code)
+	}
+	s.mu.Unlock()
+	s.setState(scStateStopped)
+	close(s.stopped)
+}
+
+func (s *windowsService) setState(state uint32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.handle == 0 {
+		return
+	}
+	s.status.serviceType = scServiceWin32Own
+	s.status.currentState = state
+	s.status.checkPoint = 0
+	s.status.waitHint = 0
+	switch state {
+	case scStateStartPending:
+		s.status.controlsAccepted = 0
+		s.status.waitHint = scStartPendingWaitHint
+	case scStateStopPending:
+		s.status.controlsAccepted = 0
+		s.status.waitHint = scStopPendingWaitHint
+	case scStateStopped:
+		s.status.controlsAccepted = 0
+	default:
+		s.status.controlsAccepted = scAcceptStop | scAcceptShutdown | scAcceptPauseContinue
+	}
+	_, _, _ = procSetServiceStatus.Call(s.handle, uintptr(unsafe.Pointer(&s.status)))
+}
+
+func openSCManager() (uintptr, error) {
+	m, _, err := procOpenSCManagerW.Call(0, 0, scManagerAllAccess)
+	if m == 0 {
+		return 0, fmt.Errorf("%w: failed to connect to service control manager: %s", ErrApplication, err.Error())
+	}
+	return m, nil
+}
+
+func closeServiceHandle(h uintptr) {
+	_, _, _ = procCloseServiceHandle.Call(h)
+}
+
+// utf16Ptr returns pointer to UTF-16 encoded s, strings
+// containing NUL are encoded as empty strings.
+func utf16Ptr(s string) *uint16 {
+	p, err := syscall.UTF16PtrFromString(s)
+	if err != nil {
+		p, _ = syscall.UTF16PtrFromString("")
+	}
+	return p
+}
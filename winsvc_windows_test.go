@@ -0,0 +1,28 @@
+// Copyright 2022 Marko Kungla
+// Licensed under the Apache License, Version 2.0.
+// See the LICENSE file.
+
+package happy
+
+import (
+	"testing"
+
+	"github.com/mkungla/happy/sdk/testutils"
+)
+
+func TestWindowsServiceCommands(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.windows.service", true))
+	cmds := windowsServiceCommands(app)
+	testutils.Equal(t, 1, len(cmds))
+	for _, name := range []string{"install", "uninstall", "run"} {
+		_, exists := cmds[0].getSubCommand(name)
+		testutils.True(t, exists, "missing service subcommand %s", name)
+	}
+}
+
+func TestWindowsServiceNotFromSCM(t *testing.T) {
+	app := New(Option("log.console", false), Option("app.windows.service", true))
+	err := runWindowsService(app, app.session)
+	testutils.ErrorIs(t, err, ErrApplication)
+	winsvc = nil
+}